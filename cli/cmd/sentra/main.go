@@ -10,6 +10,6 @@ import (
 func main() {
 	if err := cli.Execute(os.Args[1:]); err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		os.Exit(cli.ExitCode(err))
 	}
 }
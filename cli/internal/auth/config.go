@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/mgeovany/sentra/cli/internal/profile"
 )
 
 type Config struct {
@@ -24,11 +25,11 @@ type Config struct {
 }
 
 func configPath() (string, error) {
-	home, err := os.UserHomeDir()
+	dir, err := profile.Dir()
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(home, ".sentra", "config.json"), nil
+	return filepath.Join(dir, "config.json"), nil
 }
 
 func EnsureConfig() (Config, error) {
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mgeovany/sentra/shared/api"
+)
+
+// Poll results that mean "keep waiting" or "give up" (RFC 8628 §3.5).
+var (
+	ErrAuthorizationPending = errors.New("authorization pending")
+	ErrSlowDown             = errors.New("slow down")
+	ErrDeviceCodeExpired    = errors.New("device code expired")
+	ErrAccessDenied         = errors.New("access denied")
+)
+
+// DeviceFlow talks to the Sentra server's device login endpoints
+// (sentra login --device).
+type DeviceFlow struct {
+	ServerURL  string
+	HTTPClient *http.Client
+}
+
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+func (d DeviceFlow) Start(ctx context.Context) (DeviceAuthorization, error) {
+	resp, body, err := d.post(ctx, api.PathDeviceCode, nil)
+	if err != nil {
+		return DeviceAuthorization{}, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg := strings.TrimSpace(string(body))
+		if msg == "" {
+			msg = http.StatusText(resp.StatusCode)
+		}
+		return DeviceAuthorization{}, fmt.Errorf("device login unavailable: %s", msg)
+	}
+
+	var out DeviceAuthorization
+	if err := json.Unmarshal(body, &out); err != nil {
+		return DeviceAuthorization{}, err
+	}
+	if out.DeviceCode == "" || out.UserCode == "" || out.VerificationURI == "" {
+		return DeviceAuthorization{}, fmt.Errorf("device login unavailable: incomplete response")
+	}
+	return out, nil
+}
+
+// Poll returns the session once the user has approved the code, or one of the
+// Err* values above while waiting.
+func (d DeviceFlow) Poll(ctx context.Context, deviceCode string) (TokenResponse, error) {
+	resp, body, err := d.post(ctx, api.PathDeviceToken, map[string]string{"device_code": deviceCode})
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return TokenResponse{}, ErrSlowDown
+	}
+	if resp.StatusCode == http.StatusBadRequest {
+		var e struct {
+			Error string `json:"error"`
+		}
+		_ = json.Unmarshal(body, &e)
+		switch e.Error {
+		case "authorization_pending":
+			return TokenResponse{}, ErrAuthorizationPending
+		case "slow_down":
+			return TokenResponse{}, ErrSlowDown
+		case "expired_token":
+			return TokenResponse{}, ErrDeviceCodeExpired
+		case "access_denied":
+			return TokenResponse{}, ErrAccessDenied
+		default:
+			return TokenResponse{}, fmt.Errorf("device login failed: %s", strings.TrimSpace(e.Error))
+		}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return TokenResponse{}, fmt.Errorf("device login failed: status=%d", resp.StatusCode)
+	}
+
+	var out TokenResponse
+	if err := json.Unmarshal(body, &out); err != nil {
+		return TokenResponse{}, err
+	}
+	if out.AccessToken == "" {
+		return TokenResponse{}, fmt.Errorf("device login failed: empty access_token")
+	}
+	return out, nil
+}
+
+func (d DeviceFlow) post(ctx context.Context, path string, payload any) (*http.Response, []byte, error) {
+	base := strings.TrimRight(strings.TrimSpace(d.ServerURL), "/")
+	if base == "" {
+		return nil, nil, fmt.Errorf("server URL is required")
+	}
+	if d.HTTPClient == nil {
+		d.HTTPClient = &http.Client{Timeout: 15 * time.Second}
+	}
+
+	var rdr io.Reader = http.NoBody
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return nil, nil, err
+		}
+		rdr = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+path, rdr)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := d.HTTPClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(resp.Body)
+	return resp, body, nil
+}
@@ -36,7 +36,7 @@ func canonicalDeviceMessage(machineID, timestamp, nonce, method, path string, bo
 }
 
 func GetOrCreateDevicePrivateKey() (ed25519.PrivateKey, error) {
-	v, err := keyring.Get(keyringService, deviceKeyringUser)
+	v, err := keyring.Get(keyringService(), deviceKeyringUser)
 	if err == nil && strings.TrimSpace(v) != "" {
 		raw, decErr := base64.RawURLEncoding.DecodeString(strings.TrimSpace(v))
 		if decErr != nil {
@@ -56,7 +56,7 @@ func GetOrCreateDevicePrivateKey() (ed25519.PrivateKey, error) {
 		return nil, genErr
 	}
 	enc := base64.RawURLEncoding.EncodeToString([]byte(priv))
-	if setErr := keyring.Set(keyringService, deviceKeyringUser, enc); setErr != nil {
+	if setErr := keyring.Set(keyringService(), deviceKeyringUser, enc); setErr != nil {
 		return nil, setErr
 	}
 	return priv, nil
@@ -17,9 +17,37 @@ const (
 	// Portable encryption using a per-user vault key.
 	// The vault key is wrapped with a user passphrase and stored remotely.
 	envEncCipherVault = "sentra-v1"
+
+	// Vault-key encryption with the file's project, path and plaintext hash
+	// authenticated as AAD, so a blob only decrypts as the file it was pushed for.
+	envEncCipherBound = "sentra-v2"
 )
 
-func encryptAESGCM(key []byte, plain []byte) (b64Ciphertext string, size int, err error) {
+// EnvBlobBinding identifies the file a sentra-v2 or sentra-v3 ciphertext
+// belongs to.
+type EnvBlobBinding struct {
+	Root   string
+	Path   string
+	SHA256 string
+}
+
+func (b EnvBlobBinding) aadFor(cipherName string) []byte {
+	return []byte(cipherName + "\x00" +
+		strings.TrimSpace(b.Root) + "\x00" +
+		strings.TrimSpace(b.Path) + "\x00" +
+		strings.ToLower(strings.TrimSpace(b.SHA256)))
+}
+
+// IsVaultCipher reports whether cipherName is decrypted with the vault key.
+func IsVaultCipher(cipherName string) bool {
+	switch strings.TrimSpace(cipherName) {
+	case envEncCipherVault, envEncCipherBound, EnvStreamCipher:
+		return true
+	}
+	return false
+}
+
+func encryptAESGCM(key []byte, plain []byte, aad []byte) (b64Ciphertext string, size int, err error) {
 	if len(key) != 32 {
 		return "", 0, fmt.Errorf("invalid encryption key length")
 	}
@@ -37,12 +65,12 @@ func encryptAESGCM(key []byte, plain []byte) (b64Ciphertext string, size int, er
 		return "", 0, err
 	}
 
-	ct := gcm.Seal(nil, nonce, plain, nil)
+	ct := gcm.Seal(nil, nonce, plain, aad)
 	out := append(nonce, ct...)
 	return base64.RawURLEncoding.EncodeToString(out), len(plain), nil
 }
 
-func decryptAESGCM(key []byte, b64Ciphertext string) ([]byte, error) {
+func decryptAESGCM(key []byte, b64Ciphertext string, aad []byte) ([]byte, error) {
 	if len(key) != 32 {
 		return nil, fmt.Errorf("invalid encryption key length")
 	}
@@ -64,7 +92,7 @@ func decryptAESGCM(key []byte, b64Ciphertext string) ([]byte, error) {
 	}
 	nonce := raw[:gcm.NonceSize()]
 	ct := raw[gcm.NonceSize():]
-	pt, err := gcm.Open(nil, nonce, ct, nil)
+	pt, err := gcm.Open(nil, nonce, ct, aad)
 	if err != nil {
 		return nil, err
 	}
@@ -74,20 +102,33 @@ func decryptAESGCM(key []byte, b64Ciphertext string) ([]byte, error) {
 // EncryptEnvBlobWithKey encrypts plaintext bytes with a caller-provided 32-byte key.
 // This is the portable path used for cross-device sync.
 func EncryptEnvBlobWithKey(key []byte, plain []byte) (cipherName string, b64Ciphertext string, size int, err error) {
-	b64, sz, err := encryptAESGCM(key, plain)
+	b64, sz, err := encryptAESGCM(key, plain, nil)
 	if err != nil {
 		return "", "", 0, err
 	}
 	return envEncCipherVault, b64, sz, nil
 }
 
-// DecryptEnvBlobWithKey decrypts ciphertext using a caller-provided 32-byte key.
-func DecryptEnvBlobWithKey(cipherName string, key []byte, b64Ciphertext string) ([]byte, error) {
-	cipherName = strings.TrimSpace(cipherName)
-	if cipherName != envEncCipherVault {
-		return nil, fmt.Errorf("unsupported cipher: %s", cipherName)
+// EncryptEnvBlobBound encrypts plaintext bytes with a caller-provided 32-byte key
+// and binds the ciphertext to b. This is the format push writes.
+func EncryptEnvBlobBound(key []byte, plain []byte, b EnvBlobBinding) (cipherName string, b64Ciphertext string, size int, err error) {
+	b64, sz, err := encryptAESGCM(key, plain, b.aadFor(envEncCipherBound))
+	if err != nil {
+		return "", "", 0, err
 	}
-	return decryptAESGCM(key, b64Ciphertext)
+	return envEncCipherBound, b64, sz, nil
+}
+
+// DecryptEnvBlobWithKey decrypts ciphertext using a caller-provided 32-byte key.
+// b is only checked for sentra-v2; sentra-v1 ciphertexts carry no binding.
+func DecryptEnvBlobWithKey(cipherName string, key []byte, b64Ciphertext string, b EnvBlobBinding) ([]byte, error) {
+	switch strings.TrimSpace(cipherName) {
+	case envEncCipherVault:
+		return decryptAESGCM(key, b64Ciphertext, nil)
+	case envEncCipherBound:
+		return decryptAESGCM(key, b64Ciphertext, b.aadFor(envEncCipherBound))
+	}
+	return nil, fmt.Errorf("unsupported cipher: %s", strings.TrimSpace(cipherName))
 }
 
 // EncryptEnvBlobLegacy encrypts plaintext bytes using a per-installation symmetric key.
@@ -97,7 +138,7 @@ func EncryptEnvBlobLegacy(plain []byte) (cipherName string, b64Ciphertext string
 	if err != nil {
 		return "", "", 0, err
 	}
-	b64, sz, err := encryptAESGCM(key, plain)
+	b64, sz, err := encryptAESGCM(key, plain, nil)
 	if err != nil {
 		return "", "", 0, err
 	}
@@ -117,5 +158,5 @@ func DecryptEnvBlobLegacy(cipherName string, b64Ciphertext string) ([]byte, erro
 	if err != nil {
 		return nil, err
 	}
-	return decryptAESGCM(key, b64Ciphertext)
+	return decryptAESGCM(key, b64Ciphertext, nil)
 }
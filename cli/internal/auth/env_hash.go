@@ -1,11 +1,35 @@
 package auth
 
 import (
+	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"strings"
 )
 
 func SHA256Hex(b []byte) string {
 	s := sha256.Sum256(b)
 	return hex.EncodeToString(s[:])
 }
+
+// BlobID is the content address of an env file in BYOS storage. It is keyed
+// by the vault key, so identical files share one object while the storage
+// provider cannot confirm guesses of the plaintext.
+func BlobID(vaultKey []byte, plain []byte) string {
+	sub := hmac.New(sha256.New, vaultKey)
+	sub.Write([]byte("sentra-blob-id-v1"))
+	m := hmac.New(sha256.New, sub.Sum(nil))
+	m.Write(plain)
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// BoundBlobID is the content address of a blob in a bound cipher (sentra-v2
+// and later). The binding is part of the ciphertext, so objects are shared
+// between versions of the same file but never between files or ciphers.
+func BoundBlobID(vaultKey []byte, cipherName string, b EnvBlobBinding) string {
+	sub := hmac.New(sha256.New, vaultKey)
+	sub.Write([]byte("sentra-blob-id-v2"))
+	m := hmac.New(sha256.New, sub.Sum(nil))
+	m.Write(b.aadFor(strings.TrimSpace(cipherName)))
+	return hex.EncodeToString(m.Sum(nil))
+}
@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// EnvStreamCipher is the chunked format push writes. Files of any size are
+// encrypted and decrypted segment by segment without holding them in memory.
+//
+// Layout: salt(32) || seg_0 || ... || seg_n. Each segment is AES-256-GCM over
+// up to 64 KiB of plaintext under a per-file key HKDF(vaultKey, salt). The
+// nonce is the segment counter plus a final-segment flag (STREAM), so
+// segments cannot be reordered, dropped or truncated, and every segment
+// authenticates the same EnvBlobBinding as sentra-v2.
+const EnvStreamCipher = "sentra-v3"
+
+const (
+	envStreamSaltSize    = 32
+	envStreamSegmentSize = 64 << 10
+	envStreamTagSize     = 16
+)
+
+// ErrEnvBlobAuth means a ciphertext did not authenticate: it was altered,
+// truncated, or belongs to another file.
+var ErrEnvBlobAuth = errors.New("ciphertext failed authentication")
+
+// EnvStreamSize returns the ciphertext size for size bytes of plaintext.
+func EnvStreamSize(size int64) int64 {
+	segments := (size + envStreamSegmentSize - 1) / envStreamSegmentSize
+	if segments == 0 {
+		segments = 1
+	}
+	return envStreamSaltSize + size + segments*envStreamTagSize
+}
+
+func envStreamAEAD(key []byte, salt []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("invalid encryption key length")
+	}
+	fileKey, err := hkdf.Key(sha256.New, key, salt, EnvStreamCipher+" file key", 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func envStreamNonce(counter uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], counter)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+type envStreamWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	aad     []byte
+	buf     []byte
+	counter uint64
+	closed  bool
+}
+
+// NewEnvStreamWriter returns a writer that encrypts to w. Close writes the
+// final segment and must be called; it does not close w.
+func NewEnvStreamWriter(w io.Writer, key []byte, b EnvBlobBinding) (io.WriteCloser, error) {
+	salt := make([]byte, envStreamSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	aead, err := envStreamAEAD(key, salt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(salt); err != nil {
+		return nil, err
+	}
+	return &envStreamWriter{
+		w:    w,
+		aead: aead,
+		aad:  b.aadFor(EnvStreamCipher),
+		buf:  make([]byte, 0, envStreamSegmentSize),
+	}, nil
+}
+
+func (s *envStreamWriter) Write(p []byte) (int, error) {
+	if s.closed {
+		return 0, errors.New("write after close")
+	}
+	n := 0
+	for len(p) > 0 {
+		// A full buffer is only flushed once more data arrives, so the
+		// last segment is always the one written by Close.
+		if len(s.buf) == envStreamSegmentSize {
+			if err := s.flush(false); err != nil {
+				return n, err
+			}
+		}
+		k := copy(s.buf[len(s.buf):envStreamSegmentSize], p)
+		s.buf = s.buf[:len(s.buf)+k]
+		p = p[k:]
+		n += k
+	}
+	return n, nil
+}
+
+func (s *envStreamWriter) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.flush(true)
+}
+
+func (s *envStreamWriter) flush(last bool) error {
+	seg := s.aead.Seal(nil, envStreamNonce(s.counter, last), s.buf, s.aad)
+	s.counter++
+	s.buf = s.buf[:0]
+	_, err := s.w.Write(seg)
+	return err
+}
+
+type envStreamReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	aad     []byte
+	seg     []byte
+	plain   []byte
+	counter uint64
+	done    bool
+	err     error
+}
+
+// NewEnvStreamReader returns a reader over the plaintext of a sentra-v3
+// ciphertext. Reads fail with ErrEnvBlobAuth as soon as a segment does not
+// authenticate, so callers must not trust data before io.EOF.
+func NewEnvStreamReader(r io.Reader, key []byte, b EnvBlobBinding) (io.Reader, error) {
+	salt := make([]byte, envStreamSaltSize)
+	if _, err := io.ReadFull(r, salt); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, ErrEnvBlobAuth
+		}
+		return nil, err
+	}
+	aead, err := envStreamAEAD(key, salt)
+	if err != nil {
+		return nil, err
+	}
+	return &envStreamReader{
+		r:    bufio.NewReaderSize(r, envStreamSegmentSize+envStreamTagSize),
+		aead: aead,
+		aad:  b.aadFor(EnvStreamCipher),
+		seg:  make([]byte, envStreamSegmentSize+envStreamTagSize),
+	}, nil
+}
+
+func (s *envStreamReader) Read(p []byte) (int, error) {
+	for len(s.plain) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		if s.done {
+			return 0, io.EOF
+		}
+		s.err = s.next()
+	}
+	n := copy(p, s.plain)
+	s.plain = s.plain[n:]
+	return n, nil
+}
+
+func (s *envStreamReader) next() error {
+	n, err := io.ReadFull(s.r, s.seg)
+	last := false
+	switch {
+	case errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF):
+		last = true
+	case err != nil:
+		return err
+	default:
+		// A full segment is the last one when nothing follows it.
+		if _, err := s.r.Peek(1); errors.Is(err, io.EOF) {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+	if n < envStreamTagSize {
+		return ErrEnvBlobAuth
+	}
+
+	plain, err := s.aead.Open(s.seg[:0], envStreamNonce(s.counter, last), s.seg[:n], s.aad)
+	if err != nil {
+		return ErrEnvBlobAuth
+	}
+	s.counter++
+	s.plain = plain
+	s.done = last
+	return nil
+}
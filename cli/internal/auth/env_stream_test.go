@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+var testBinding = EnvBlobBinding{Root: "root", Path: "app/.env", SHA256: "abc123"}
+
+func testStreamKey(t *testing.T) []byte {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func sealStream(t *testing.T, key []byte, b EnvBlobBinding, plain []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewEnvStreamWriter(&buf, key, b)
+	if err != nil {
+		t.Fatalf("NewEnvStreamWriter: %v", err)
+	}
+	// Write in odd-sized pieces so segment boundaries fall mid-write.
+	for rest := plain; len(rest) > 0; {
+		n := min(len(rest), 7777)
+		if _, err := w.Write(rest[:n]); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		rest = rest[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func openStream(key []byte, b EnvBlobBinding, sealed []byte) ([]byte, error) {
+	r, err := NewEnvStreamReader(bytes.NewReader(sealed), key, b)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestEnvStreamRoundTrip(t *testing.T) {
+	key := testStreamKey(t)
+	sizes := []struct {
+		name string
+		size int
+	}{
+		{name: "empty", size: 0},
+		{name: "one byte", size: 1},
+		{name: "under a segment", size: envStreamSegmentSize - 1},
+		{name: "one segment", size: envStreamSegmentSize},
+		{name: "one segment and a byte", size: envStreamSegmentSize + 1},
+		{name: "three segments", size: 3 * envStreamSegmentSize},
+		{name: "three and a half segments", size: 3*envStreamSegmentSize + envStreamSegmentSize/2},
+	}
+	for _, tt := range sizes {
+		t.Run(tt.name, func(t *testing.T) {
+			plain := make([]byte, tt.size)
+			_, _ = rand.Read(plain)
+			sealed := sealStream(t, key, testBinding, plain)
+			if got, want := int64(len(sealed)), EnvStreamSize(int64(tt.size)); got != want {
+				t.Fatalf("ciphertext size = %d; want EnvStreamSize = %d", got, want)
+			}
+			got, err := openStream(key, testBinding, sealed)
+			if err != nil {
+				t.Fatalf("decrypt: %v", err)
+			}
+			if !bytes.Equal(got, plain) {
+				t.Fatalf("round trip of %d bytes returned %d different bytes", len(plain), len(got))
+			}
+		})
+	}
+}
+
+func TestEnvStreamRejectsTampering(t *testing.T) {
+	key := testStreamKey(t)
+	// Three full segments, so whole segments can be dropped or swapped.
+	plain := make([]byte, 3*envStreamSegmentSize)
+	_, _ = rand.Read(plain)
+	sealed := sealStream(t, key, testBinding, plain)
+
+	const seg = envStreamSegmentSize + envStreamTagSize
+	segment := func(i int) []byte {
+		start := envStreamSaltSize + i*seg
+		return sealed[start : start+seg]
+	}
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+	salt := sealed[:envStreamSaltSize]
+
+	tests := []struct {
+		name    string
+		sealed  []byte
+		key     []byte
+		binding EnvBlobBinding
+	}{
+		{name: "last segment truncated", sealed: sealed[:len(sealed)-1]},
+		{name: "last segment dropped", sealed: sealed[:len(sealed)-seg]},
+		{name: "only the salt", sealed: salt},
+		{name: "shorter than the salt", sealed: salt[:10]},
+		{name: "segments reordered", sealed: join(salt, segment(1), segment(0), segment(2))},
+		{name: "segment repeated", sealed: join(salt, segment(0), segment(0), segment(2))},
+		{name: "trailing data", sealed: join(sealed, []byte{0})},
+		{name: "salt bit flipped", sealed: flipBit(sealed, 0)},
+		{name: "segment bit flipped", sealed: flipBit(sealed, envStreamSaltSize+seg+100)},
+		{name: "tag bit flipped", sealed: flipBit(sealed, len(sealed)-1)},
+		{name: "wrong key", sealed: sealed, key: testStreamKey(t)},
+		{name: "wrong root", sealed: sealed, binding: EnvBlobBinding{Root: "other", Path: testBinding.Path, SHA256: testBinding.SHA256}},
+		{name: "wrong path", sealed: sealed, binding: EnvBlobBinding{Root: testBinding.Root, Path: "app/.env.prod", SHA256: testBinding.SHA256}},
+		{name: "wrong sha", sealed: sealed, binding: EnvBlobBinding{Root: testBinding.Root, Path: testBinding.Path, SHA256: "def456"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, b := key, testBinding
+			if tt.key != nil {
+				k = tt.key
+			}
+			if tt.binding != (EnvBlobBinding{}) {
+				b = tt.binding
+			}
+			if _, err := openStream(k, b, tt.sealed); !errors.Is(err, ErrEnvBlobAuth) {
+				t.Fatalf("decrypt err = %v; want ErrEnvBlobAuth", err)
+			}
+		})
+	}
+}
+
+// TestEnvStreamEmptyTruncation checks that the single segment of an empty
+// file cannot be dropped to pass off an empty ciphertext.
+func TestEnvStreamEmptyTruncation(t *testing.T) {
+	key := testStreamKey(t)
+	sealed := sealStream(t, key, testBinding, nil)
+	if _, err := openStream(key, testBinding, sealed[:envStreamSaltSize]); !errors.Is(err, ErrEnvBlobAuth) {
+		t.Fatalf("decrypt of a bare salt: err = %v; want ErrEnvBlobAuth", err)
+	}
+}
+
+func flipBit(b []byte, i int) []byte {
+	out := bytes.Clone(b)
+	out[i] ^= 0x01
+	return out
+}
@@ -6,6 +6,8 @@ import (
 	"os"
 	"path/filepath"
 	"time"
+
+	"github.com/mgeovany/sentra/cli/internal/profile"
 )
 
 type Session struct {
@@ -43,11 +45,11 @@ func (s Session) NeedsRefresh(now time.Time) bool {
 }
 
 func sessionPath() (string, error) {
-	home, err := os.UserHomeDir()
+	dir, err := profile.Dir()
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(home, ".sentra", "session.json"), nil
+	return filepath.Join(dir, "session.json"), nil
 }
 
 func SaveSession(s Session) error {
@@ -9,13 +9,16 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/mgeovany/sentra/cli/internal/profile"
 	"github.com/zalando/go-keyring"
 )
 
-const (
-	keyringService = "sentra"
-	keyringUser    = "session-key"
-)
+const keyringUser = "session-key"
+
+// keyringService scopes keychain items to the active profile.
+func keyringService() string {
+	return profile.KeyringService()
+}
 
 func getOrCreateSessionKey() ([]byte, error) {
 	// 1) Prefer OS keyring.
@@ -30,7 +33,7 @@ func getOrCreateSessionKey() ([]byte, error) {
 }
 
 func getOrCreateSessionKeyKeyring() ([]byte, error) {
-	v, err := keyring.Get(keyringService, keyringUser)
+	v, err := keyring.Get(keyringService(), keyringUser)
 	if err == nil && strings.TrimSpace(v) != "" {
 		b, decErr := base64.RawURLEncoding.DecodeString(strings.TrimSpace(v))
 		if decErr != nil {
@@ -52,18 +55,18 @@ func getOrCreateSessionKeyKeyring() ([]byte, error) {
 		return nil, err
 	}
 	enc := base64.RawURLEncoding.EncodeToString(k)
-	if err := keyring.Set(keyringService, keyringUser, enc); err != nil {
+	if err := keyring.Set(keyringService(), keyringUser, enc); err != nil {
 		return nil, err
 	}
 	return k, nil
 }
 
 func sessionKeyPath() (string, error) {
-	home, err := os.UserHomeDir()
+	dir, err := profile.Dir()
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(home, ".sentra", "session.key"), nil
+	return filepath.Join(dir, "session.key"), nil
 }
 
 func getOrCreateSessionKeyFile() ([]byte, error) {
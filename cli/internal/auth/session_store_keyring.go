@@ -29,7 +29,7 @@ func allowInsecureSessionFile() bool {
 }
 
 func loadSessionKeyring() (Session, bool, error) {
-	v, err := keyring.Get(keyringService, keyringSessionUser)
+	v, err := keyring.Get(keyringService(), keyringSessionUser)
 	if err != nil {
 		if errors.Is(err, keyring.ErrNotFound) {
 			return Session{}, false, nil
@@ -61,5 +61,5 @@ func saveSessionKeyring(s Session, preserveSavedAt bool) error {
 	if err != nil {
 		return err
 	}
-	return keyring.Set(keyringService, keyringSessionUser, string(b))
+	return keyring.Set(keyringService(), keyringSessionUser, string(b))
 }
@@ -1,7 +1,6 @@
 package cli
 
 import (
-	"errors"
 	"fmt"
 	"path/filepath"
 	"sort"
@@ -9,11 +8,29 @@ import (
 
 	"github.com/mgeovany/sentra/cli/internal/index"
 	"github.com/mgeovany/sentra/cli/internal/scanner"
+	"github.com/spf13/cobra"
 )
 
-func runAdd(args []string) error {
-	if len(args) == 0 {
-		return errors.New("usage: sentra add . | sentra add <path>")
+func newAddCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "add [path]",
+		Short:   "Stage env files (default: .)",
+		GroupID: groupLocal,
+		Args:    maxArgs(1, "sentra add . | sentra add <path>"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			target := "."
+			if len(args) == 1 {
+				target = args[0]
+			}
+			return runAdd(target)
+		},
+	}
+}
+
+func runAdd(target string) error {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return usageError("sentra add . | sentra add <path>")
 	}
 
 	verbosef("Starting add operation...")
@@ -24,7 +41,7 @@ func runAdd(args []string) error {
 	}
 	verbosef("Scan root: %s", scanRoot)
 
-	projects, err := scanner.Scan(scanRoot)
+	projects, err := scanProjects(scanRoot)
 	if err != nil {
 		return err
 	}
@@ -47,7 +64,7 @@ func runAdd(args []string) error {
 		idx.Staged = map[string]string{}
 	}
 
-	switch args[0] {
+	switch target {
 	case ".":
 		if len(available) == 0 {
 			fmt.Println(c(ansiGreen, "✔ staged ") + c(ansiBoldCyan, "0") + c(ansiGreen, " env files"))
@@ -74,11 +91,8 @@ func runAdd(args []string) error {
 		verbosef("Index saved to: %s", indexPath)
 		return nil
 	default:
-		if len(args) != 1 {
-			return errors.New("usage: sentra add . | sentra add <path>")
-		}
 
-		requested := normalizeRelPath(args[0])
+		requested := normalizeRelPath(target)
 		verbosef("Looking for file: %s", requested)
 		hash, ok := available[requested]
 		if !ok {
@@ -88,7 +102,7 @@ func runAdd(args []string) error {
 			hash, ok = available[requested]
 			if !ok {
 				verbosef("File not found in available files")
-				return fmt.Errorf("env file not found: %s", args[0])
+				return fmt.Errorf("env file not found: %s", target)
 			}
 		}
 		verbosef("Found file: %s (hash: %s)", requested, hash)
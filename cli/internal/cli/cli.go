@@ -1,139 +1,206 @@
 package cli
 
 import (
-	"errors"
 	"fmt"
 	"path/filepath"
 	"sort"
 	"strings"
 
-	"github.com/mgeovany/sentra/cli/internal/scanner"
+	"github.com/mgeovany/sentra/cli/internal/profile"
+	"github.com/spf13/cobra"
+)
+
+// globalOptions holds the flags accepted by every command.
+type globalOptions struct {
+	JSON    bool
+	Verbose bool
+	Server  string
+	Profile string
+	NoColor bool
+}
+
+var globals globalOptions
+
+// annotationJSON marks commands that know how to render --json output.
+const annotationJSON = "sentra.json"
+
+const (
+	groupAuth    = "auth"
+	groupRemote  = "remote"
+	groupLocal   = "local"
+	groupStorage = "storage"
+	groupMaint   = "maint"
 )
 
 func Execute(args []string) error {
-	if len(args) == 0 {
-		return usageError()
+	root := newRootCmd()
+	root.SetArgs(args)
+	endTrace := traceCommand(root, args)
+	err := root.ExecuteContext(commandContext())
+	endTrace(err)
+	if err != nil && globals.JSON {
+		writeJSONError(err)
 	}
+	return err
+}
 
-	// Global flags / commands.
-	switch args[0] {
-	case "version", "--version", "-v", "-V":
-		if len(args) > 1 {
-			return errors.New("sentra version does not accept flags/args")
-		}
-		printVersion()
-		return nil
-	case "help", "--help", "-h":
-		return usageError()
+func newRootCmd() *cobra.Command {
+	globals = globalOptions{}
+
+	root := &cobra.Command{
+		Use:           "sentra",
+		Short:         "Scan, stage and push .env files",
+		Long:          "Sentra is a developer-first CLI for scanning, staging and pushing .env* files.",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				return withExitCode(ExitUsage, fmt.Errorf("unknown command %q for %q", args[0], cmd.CommandPath()))
+			}
+			return nil
+		},
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return validateGlobalFlags(cmd)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
 	}
 
-	switch args[0] {
-	case "login":
-		if len(args) > 1 {
-			return errors.New("sentra login does not accept flags/args yet")
-		}
-		return runLogin()
-	case "storage":
-		return runStorage(args[1:])
-	case "export":
-		return runExport(args[1:])
-	case "files":
-		return runFiles(args[1:])
-	case "commits":
-		return runCommits(args[1:])
-	case "projects":
-		if len(args) > 1 {
-			return errors.New("sentra projects does not accept flags/args yet")
-		}
-		return runProjects()
-	case "history":
-		return runHistory(args[1:])
-	case "who":
-		if len(args) > 1 {
-			return errors.New("sentra who does not accept flags/args yet")
-		}
-		return runWho()
-	case "scan":
+	root.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return withExitCode(ExitUsage, err)
+	})
 
-		if len(args) > 1 {
-			return errors.New("sentra scan does not accept flags/args yet")
-		}
-		return runScan()
-	case "overview":
-		return runOverview(args[1:])
-	case "add":
-		return runAdd(args[1:])
-	case "status":
-		if len(args) > 1 {
-			return errors.New("sentra status does not accept flags/args yet")
+	pf := root.PersistentFlags()
+	pf.BoolVar(&globals.JSON, "json", false, "Print machine-readable JSON output")
+	pf.BoolVar(&globals.Verbose, "verbose", false, "Enable verbose logging")
+	pf.StringVar(&globals.Server, "server", "", "Override the server URL for this invocation")
+	pf.StringVar(&globals.Profile, "profile", "", "Use a named profile (default: $SENTRA_PROFILE, then the profile set by 'sentra profile use')")
+	pf.BoolVar(&globals.NoColor, "no-color", false, "Disable colored output")
+
+	_ = root.RegisterFlagCompletionFunc("profile", completeProfiles)
+
+	// Keep `sentra --version` / `-V` working alongside the subcommand.
+	root.Version = versionString()
+	root.SetVersionTemplate("{{.Version}}\n")
+	root.Flags().BoolP("version", "V", false, "Print the version")
+
+	root.AddGroup(
+		&cobra.Group{ID: groupAuth, Title: "Auth:"},
+		&cobra.Group{ID: groupRemote, Title: "Remote (cloud):"},
+		&cobra.Group{ID: groupLocal, Title: "Local workflow:"},
+		&cobra.Group{ID: groupStorage, Title: "Storage (BYOS):"},
+		&cobra.Group{ID: groupMaint, Title: "Maintenance:"},
+	)
+
+	root.AddCommand(
+		newVersionCmd(),
+
+		newLoginCmd(),
+		newWhoCmd(),
+		newProfileCmd(),
+		newTokensCmd(),
+		newWebhooksCmd(),
+
+		newProjectsCmd(),
+		newHistoryCmd(),
+		newCommitsCmd(),
+		newFilesCmd(),
+		newBlameCmd(),
+		newExportCmd(),
+		newSyncCmd(),
+		newPushCmd(),
+		newRunCmd(),
+
+		newScanCmd(),
+		newAddCmd(),
+		newStatusCmd(),
+		newOverviewCmd(),
+		newCommitCmd(),
+		newLogCmd(),
+		newWatchCmd(),
+
+		newStorageCmd(),
+
+		newDoctorCmd(),
+		newWipeCmd(),
+	)
+
+	root.SetHelpCommandGroupID(groupMaint)
+	root.SetCompletionCommandGroupID(groupMaint)
+
+	return root
+}
+
+func validateGlobalFlags(cmd *cobra.Command) error {
+	if globals.JSON && cmd.Annotations[annotationJSON] != "true" {
+		return withExitCode(ExitUsage, fmt.Errorf("%s does not support --json", cmd.CommandPath()))
+	}
+	applyProfileFlag()
+	name := profile.Active()
+	if !profile.ValidName(name) {
+		return withExitCode(ExitUsage, fmt.Errorf("invalid profile name: %q", name))
+	}
+	// `sentra profile ...` manages profiles, so it may run while the active one is missing.
+	if !isProfileCmd(cmd) {
+		if ok, err := profile.Exists(name); err != nil {
+			return err
+		} else if !ok {
+			return withExitCode(ExitUsage, fmt.Errorf("unknown profile: %s (run: sentra profile add %s)", name, name))
 		}
-		return runStatus()
-	case "commit":
-		return runCommit(args[1:])
-	case "sync":
-		return runSync(args[1:])
-	case "log":
-		return runLog(args[1:])
-	case "push":
-		if len(args) > 1 {
-			return errors.New("sentra push does not accept flags/args yet")
+	}
+	return nil
+}
+
+func applyProfileFlag() {
+	profile.SetOverride(strings.TrimSpace(globals.Profile))
+}
+
+func jsonCapable() map[string]string {
+	return map[string]string{annotationJSON: "true"}
+}
+
+// exactArgs keeps the historical "usage: ..." errors instead of cobra's generic ones.
+func exactArgs(n int, usage string) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if len(args) != n {
+			return usageError(usage)
 		}
-		return runPush()
-	case "wipe":
-		return runWipe(args[1:])
-	case "doctor":
-		if len(args) > 1 {
-			return errors.New("sentra doctor does not accept flags/args yet")
+		return nil
+	}
+}
+
+func maxArgs(n int, usage string) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if len(args) > n {
+			return usageError(usage)
 		}
-		return runDoctor()
-	default:
-		return usageError()
+		return nil
 	}
 }
 
-func usageError() error {
-	return errors.New(strings.TrimSpace(`sentra
-
-Usage:
-  sentra <command> [args]
-
-Global:
-  sentra version | --version
-  sentra help | --help | -h
-
-Auth:
-  sentra login              Login and create a session
-  sentra who                Show current logged-in user
-
-Remote (cloud):
-  sentra projects           List remote projects
-  sentra history            List remote commit history (all projects)
-  sentra commits <project>  List commits for a project
-  sentra files <project> [--at <commit>]
-                           List files for a project (optionally at a commit)
-  sentra export <project> [--at <commit>] [--out <dir>]
-                           Download and decrypt files into a folder
-  sentra sync [--out <dir>] Download latest env files and write them locally
-  sentra push               Push pending local commits to remote
-
-Local workflow:
-  sentra scan               Scan repos under scan root for env files
-  sentra add [path]         Stage env files (default: .)
-  sentra status             Show local staged/changed env files
-  sentra commit -m <msg>    Create a local commit from staged env files
-  sentra log [all|pending|pushed|rm <id>|clear|prune <id|all>|verify]
-                           Manage local commit log
-
-Storage (BYOS):
-  sentra storage setup      Configure S3-compatible storage
-  sentra storage status     Show current storage config
-  sentra storage test       Test storage connectivity
-  sentra storage reset      Remove storage config
-
-Maintenance:
-  sentra doctor             Diagnose connectivity/config issues
-  sentra wipe               Delete ALL local Sentra state
-`))
+type scanJSON struct {
+	Schema   string            `json:"schema"`
+	ScanRoot string            `json:"scan_root"`
+	Projects []scanProjectJSON `json:"projects"`
+}
+
+type scanProjectJSON struct {
+	Root     string   `json:"root"`
+	EnvFiles []string `json:"env_files"`
+}
+
+func newScanCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:         "scan",
+		Short:       "Scan repos under scan root for env files",
+		GroupID:     groupLocal,
+		Args:        exactArgs(0, "sentra scan"),
+		Annotations: jsonCapable(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runScan()
+		},
+	}
 }
 
 func runScan() error {
@@ -146,7 +213,7 @@ func runScan() error {
 
 	sp := startSpinner(fmt.Sprintf("Scanning %s...", scanRoot))
 
-	projects, err := scanner.Scan(scanRoot)
+	projects, err := scanProjects(scanRoot)
 	if err != nil {
 		sp.StopInfo("")
 		return err
@@ -163,6 +230,24 @@ func runScan() error {
 	sp.StopSuccess(fmt.Sprintf("✔ %d projects found", len(projects)))
 	verbosef("Scan completed: %d project(s), %d env file(s) total", len(projects), envCount)
 
+	if jsonOutput() {
+		out := scanJSON{Schema: "sentra.scan/v1", ScanRoot: scanRoot, Projects: make([]scanProjectJSON, 0, len(projects))}
+		for _, project := range projects {
+			relProjectRoot, err := filepath.Rel(scanRoot, project.RootPath)
+			if err != nil {
+				return err
+			}
+			p := scanProjectJSON{Root: filepath.ToSlash(strings.TrimPrefix(relProjectRoot, "./")), EnvFiles: make([]string, 0, len(project.EnvFiles))}
+			for _, envFile := range project.EnvFiles {
+				p.EnvFiles = append(p.EnvFiles, filepath.ToSlash(envFile.Path))
+			}
+			sort.Strings(p.EnvFiles)
+			out.Projects = append(out.Projects, p)
+		}
+		sort.Slice(out.Projects, func(i, j int) bool { return out.Projects[i].Root < out.Projects[j].Root })
+		return writeJSON(out)
+	}
+
 	projectRoots := make([]string, 0, len(projects))
 	for _, project := range projects {
 		relProjectRoot, err := filepath.Rel(scanRoot, project.RootPath)
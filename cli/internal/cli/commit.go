@@ -10,13 +10,32 @@ import (
 
 	"github.com/mgeovany/sentra/cli/internal/commit"
 	"github.com/mgeovany/sentra/cli/internal/index"
+	"github.com/spf13/cobra"
 )
 
-func runCommit(args []string) error {
+func newCommitCmd() *cobra.Command {
+	var message string
+	cmd := &cobra.Command{
+		Use:     "commit -m <msg>",
+		Short:   "Create a local commit from staged env files",
+		GroupID: groupLocal,
+		Args:    exactArgs(0, "sentra commit -m 'message'"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !cmd.Flags().Changed("message") {
+				return usageError("sentra commit -m 'message'")
+			}
+			return runCommit(message)
+		},
+	}
+	cmd.Flags().StringVarP(&message, "message", "m", "", "Commit message")
+	return cmd
+}
+
+func runCommit(message string) error {
 	verbosef("Starting commit operation...")
-	message, err := parseCommitMessage(args)
-	if err != nil {
-		return err
+	message = strings.TrimSpace(message)
+	if message == "" {
+		return errors.New("commit message cannot be empty")
 	}
 	verbosef("Commit message: %s", message)
 
@@ -66,33 +85,6 @@ func runCommit(args []string) error {
 	return nil
 }
 
-func parseCommitMessage(args []string) (string, error) {
-	if len(args) < 2 {
-		return "", errors.New("usage: sentra commit -m 'message'")
-	}
-
-	var message string
-	for i := 0; i < len(args); i++ {
-		switch args[i] {
-		case "-m":
-			if i+1 >= len(args) {
-				return "", errors.New("usage: sentra commit -m 'message'")
-			}
-			message = args[i+1]
-			i++
-		default:
-			return "", errors.New("usage: sentra commit -m 'message'")
-		}
-	}
-
-	message = strings.TrimSpace(message)
-	if message == "" {
-		return "", errors.New("commit message cannot be empty")
-	}
-
-	return message, nil
-}
-
 func runPreCommitChecks() error {
 	// Find the project root (monorepo root)
 	projectRoot, err := findProjectRoot()
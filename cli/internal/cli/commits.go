@@ -1,85 +1,210 @@
 package cli
 
 import (
-	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"net/url"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/mgeovany/sentra/shared/api"
+	"github.com/spf13/cobra"
 )
 
-type remoteCommit struct {
+// commitJSON is the --json shape of a remote commit (commits, history).
+type commitJSON struct {
 	CommitID    string   `json:"commit_id"`
+	ProjectRoot string   `json:"project_root"`
 	CreatedAt   string   `json:"created_at"`
 	Message     string   `json:"message"`
-	MachineName string   `json:"machine_name"`
 	MachineID   string   `json:"machine_id"`
-	FilePaths   []string `json:"files"`
-	ProjectRoot string   `json:"project_root"`
-	ProjectID   string   `json:"project_id"`
-	ProjectName string   `json:"project_name"`
+	MachineName string   `json:"machine_name"`
 	FileCount   int      `json:"file_count"`
+	Files       []string `json:"files"`
+}
+
+type commitsJSON struct {
+	Schema      string       `json:"schema"`
+	ProjectRoot string       `json:"project_root"`
+	Commits     []commitJSON `json:"commits"`
+	NextCursor  string       `json:"next_cursor,omitempty"`
 }
 
-func runCommits(args []string) error {
-	if len(args) != 1 {
-		return errors.New("usage: sentra commits <project>")
+// commitFilterFlags are the paging and filter flags of commits and history.
+type commitFilterFlags struct {
+	limit   int
+	cursor  string
+	since   string
+	until   string
+	machine string
+	path    string
+	grep    string
+}
+
+func (ff *commitFilterFlags) register(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&ff.limit, "limit", 50, "Show at most this many commits (0: all)")
+	cmd.Flags().StringVar(&ff.cursor, "cursor", "", "Continue after a previous page (its next_cursor)")
+	cmd.Flags().StringVar(&ff.since, "since", "", "Only commits since a time: RFC 3339, YYYY-MM-DD or an age like 7d or 12h")
+	cmd.Flags().StringVar(&ff.until, "until", "", "Only commits until a time (same forms as --since)")
+	cmd.Flags().StringVar(&ff.machine, "machine", "", "Only commits pushed from this machine ID")
+	cmd.Flags().StringVar(&ff.path, "path", "", "Only commits that touch this file path (e.g. .env.production)")
+	cmd.Flags().StringVar(&ff.grep, "grep", "", "Only commits whose message contains this text (case-insensitive)")
+}
+
+func (ff commitFilterFlags) query(root string, usage string) (api.CommitQuery, error) {
+	if ff.limit < 0 || ff.limit > 500 {
+		return api.CommitQuery{}, usageError(usage + " [--limit <0..500>]")
+	}
+	q := api.CommitQuery{
+		Root:      root,
+		Limit:     ff.limit,
+		Cursor:    strings.TrimSpace(ff.cursor),
+		MachineID: strings.TrimSpace(ff.machine),
+		Path:      strings.TrimPrefix(strings.TrimSpace(ff.path), "./"),
+		Message:   strings.TrimSpace(ff.grep),
+	}
+	if q.Cursor != "" && q.Limit == 0 {
+		return api.CommitQuery{}, withExitCode(ExitUsage, fmt.Errorf("--cursor requires --limit"))
+	}
+	var err error
+	if q.Since, err = parseCommitTime("--since", ff.since); err != nil {
+		return api.CommitQuery{}, err
 	}
+	if q.Until, err = parseCommitTime("--until", ff.until); err != nil {
+		return api.CommitQuery{}, err
+	}
+	return q, nil
+}
 
-	root := projectRootFromPath(args[0])
-	root = strings.TrimSpace(root)
-	if root == "" {
-		return errors.New("usage: sentra commits <project>")
+// parseCommitTime accepts an RFC 3339 time, a local date (YYYY-MM-DD) or
+// an age before now such as 30m, 12h or 7d.
+func parseCommitTime(flag string, s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
 	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if n, ok := strings.CutSuffix(s, "d"); ok {
+		if days, err := strconv.Atoi(n); err == nil && days >= 0 {
+			return time.Now().AddDate(0, 0, -days), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, withExitCode(ExitUsage, fmt.Errorf("invalid %s %q (use RFC 3339, YYYY-MM-DD or an age like 7d)", flag, s))
+}
 
-	sess, err := ensureRemoteSession()
+// fetchRemoteCommitPage returns one page of commits matching q and the
+// cursor of the next page.
+func fetchRemoteCommitPage(serverURL string, accessToken string, q api.CommitQuery) ([]api.Commit, string, error) {
+	commits, next, err := apiClient(serverURL, accessToken, 25*time.Second).ListCommits(commandContext(), q)
 	if err != nil {
-		return err
+		var apiErr *api.Error
+		if errors.As(err, &apiErr) {
+			if apiErr.StatusCode == http.StatusBadRequest {
+				return nil, "", withExitCode(ExitUsage, fmt.Errorf("failed to fetch commits: %s", oneLine(apiErr.Message())))
+			}
+			return nil, "", fmt.Errorf("failed to fetch commits")
+		}
+		return nil, "", err
 	}
-	if strings.TrimSpace(sess.AccessToken) == "" {
-		return errors.New("not logged in (run: sentra login)")
+	return commits, next, nil
+}
+
+// printMoreCommits tells humans how to fetch the page after this one.
+func printMoreCommits(next string) {
+	if next != "" {
+		infof("More commits: add --cursor %s", next)
 	}
+}
 
-	serverURL, err := serverURLFromEnv()
+func toCommitJSON(root string, c api.Commit) commitJSON {
+	files := make([]string, 0, len(c.Files))
+	for _, p := range c.Files {
+		if p = strings.TrimSpace(p); p != "" {
+			files = append(files, p)
+		}
+	}
+	cnt := c.FileCount
+	if cnt == 0 {
+		cnt = len(files)
+	}
+	if r := strings.TrimSpace(c.ProjectRoot); r != "" {
+		root = r
+	}
+	return commitJSON{
+		CommitID:    strings.TrimSpace(c.CommitID),
+		ProjectRoot: root,
+		CreatedAt:   strings.TrimSpace(c.CreatedAt),
+		Message:     strings.TrimSpace(c.Message),
+		MachineID:   strings.TrimSpace(c.MachineID),
+		MachineName: strings.TrimSpace(c.MachineName),
+		FileCount:   cnt,
+		Files:       files,
+	}
+}
+
+func newCommitsCmd() *cobra.Command {
+	var ff commitFilterFlags
+	cmd := &cobra.Command{
+		Use:               "commits <project>",
+		Short:             "List commits for a project",
+		GroupID:           groupRemote,
+		Args:              exactArgs(1, "sentra commits <project>"),
+		Annotations:       jsonCapable(),
+		ValidArgsFunction: completeRemoteProjects,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCommits(args[0], ff)
+		},
+	}
+	ff.register(cmd)
+	return cmd
+}
+
+func runCommits(project string, ff commitFilterFlags) error {
+	root := projectRootFromPath(project)
+	root = strings.TrimSpace(root)
+	if root == "" {
+		return usageError("sentra commits <project>")
+	}
+	q, err := ff.query(root, "sentra commits <project>")
 	if err != nil {
 		return err
 	}
 
-	u, err := url.Parse(serverURL + "/commits")
+	sess, err := ensureRemoteSession()
 	if err != nil {
 		return err
 	}
-	q := u.Query()
-	q.Set("root", root)
-	u.RawQuery = q.Encode()
+	if strings.TrimSpace(sess.AccessToken) == "" {
+		return errNotLoggedIn
+	}
 
-	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, u.String(), nil)
+	serverURL, err := serverURLFromEnv()
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(sess.AccessToken))
 
-	client := &http.Client{Timeout: 20 * time.Second}
-	resp, err := client.Do(req)
+	commits, next, err := fetchRemoteCommitPage(serverURL, sess.AccessToken, q)
 	if err != nil {
 		return err
 	}
-	defer func() { _ = resp.Body.Close() }()
 
-	body, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("failed to fetch commits")
+	if jsonOutput() {
+		out := commitsJSON{Schema: "sentra.commits/v1", ProjectRoot: root, Commits: make([]commitJSON, 0, len(commits)), NextCursor: next}
+		for _, c := range commits {
+			out.Commits = append(out.Commits, toCommitJSON(root, c))
+		}
+		return writeJSON(out)
 	}
 
-	var commits []remoteCommit
-	if err := json.Unmarshal(body, &commits); err != nil {
-		return err
-	}
 	if len(commits) == 0 {
 		fmt.Println("✔ 0 commits")
 		return nil
@@ -105,7 +230,7 @@ func runCommits(args []string) error {
 		}
 
 		fmt.Printf("%s\t%s\t%s\n", created, machine, msg)
-		for _, p := range c.FilePaths {
+		for _, p := range c.Files {
 			p = strings.TrimSpace(p)
 			if p == "" {
 				continue
@@ -114,6 +239,7 @@ func runCommits(args []string) error {
 		}
 		fmt.Println()
 	}
+	printMoreCommits(next)
 
 	return nil
 }
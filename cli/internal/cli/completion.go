@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mgeovany/sentra/cli/internal/auth"
+	"github.com/mgeovany/sentra/cli/internal/commit"
+	"github.com/mgeovany/sentra/shared/api"
+	"github.com/spf13/cobra"
+)
+
+// Dynamic shell completions run on every <TAB>: they must never prompt for
+// login and should quietly return nothing when the session is missing.
+
+func completionRemote() (serverURL string, accessToken string, ok bool) {
+	// Completions skip PersistentPreRunE, so apply --profile here.
+	applyProfileFlag()
+	if t := serviceTokenFromEnv(); t != "" {
+		serverURL, err := serverURLFromEnv()
+		return serverURL, t, err == nil
+	}
+
+	ctx, cancel := context.WithTimeout(commandContext(), 5*time.Second)
+	defer cancel()
+
+	s, err := auth.EnsureSession(ctx, remoteOAuthFromEnv())
+	if err != nil || strings.TrimSpace(s.AccessToken) == "" {
+		return "", "", false
+	}
+	serverURL, err = serverURLFromEnv()
+	if err != nil {
+		return "", "", false
+	}
+	return serverURL, s.AccessToken, true
+}
+
+// completeRemoteProjects suggests remote project roots for the first positional arg.
+func completeRemoteProjects(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	serverURL, token, ok := completionRemote()
+	if !ok {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	projects, err := fetchRemoteProjects(serverURL, token, fileFilter{})
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	out := make([]cobra.Completion, 0, len(projects))
+	for _, p := range projects {
+		root := strings.TrimSpace(p.RootPath)
+		if root == "" || !strings.HasPrefix(root, toComplete) {
+			continue
+		}
+		out = append(out, cobra.CompletionWithDesc(root, oneLine(p.LastCommitMessage)))
+	}
+	sort.Strings(out)
+	return out, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeRemoteCommitIDs suggests commit IDs for --at, scoped to the project arg.
+func completeRemoteCommitIDs(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	root := strings.TrimSpace(projectRootFromPath(args[0]))
+	if root == "" {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	serverURL, token, ok := completionRemote()
+	if !ok {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	commits, _, err := fetchRemoteCommitPage(serverURL, token, api.CommitQuery{Root: root, Limit: 100})
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	// Keep server order (newest first) so the shell shows recent commits on top.
+	out := make([]cobra.Completion, 0, len(commits))
+	for _, c := range commits {
+		id := strings.TrimSpace(c.CommitID)
+		if id == "" || !strings.HasPrefix(id, toComplete) {
+			continue
+		}
+		out = append(out, cobra.CompletionWithDesc(id, oneLine(c.Message)))
+	}
+	return out, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+}
+
+// completeLocalCommitIDs suggests short IDs of local commits.
+func completeLocalCommitIDs(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	applyProfileFlag()
+	commits, err := commit.List()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var out []cobra.Completion
+	if cmd.Name() == "prune" && strings.HasPrefix("all", toComplete) {
+		out = append(out, cobra.CompletionWithDesc("all", "every pending commit with missing files"))
+	}
+	for _, c := range commits {
+		id := shortCommitID(c)
+		if !strings.HasPrefix(id, toComplete) {
+			continue
+		}
+		out = append(out, cobra.CompletionWithDesc(id, oneLine(c.Message)))
+	}
+	return out, cobra.ShellCompDirectiveNoFileComp
+}
@@ -6,47 +6,125 @@ import (
 	"fmt"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/mgeovany/sentra/cli/internal/auth"
 	"github.com/mgeovany/sentra/cli/internal/commit"
+	"github.com/mgeovany/sentra/cli/internal/profile"
+	"github.com/mgeovany/sentra/shared/api"
+	"github.com/spf13/cobra"
 	"github.com/zalando/go-keyring"
 )
 
 type doctorDiag struct {
-	fails int
-	warns int
+	fails   int
+	warns   int
+	section string
+	checks  []doctorCheckJSON
+}
+
+type doctorJSON struct {
+	Schema   string            `json:"schema"`
+	OK       bool              `json:"ok"`
+	Failures int               `json:"failures"`
+	Warnings int               `json:"warnings"`
+	Checks   []doctorCheckJSON `json:"checks"`
+}
+
+type doctorCheckJSON struct {
+	Section string `json:"section"`
+	Status  string `json:"status"` // ok | warn | fail
+	Message string `json:"message"`
 }
 
 func errorsIsKeyringNotFound(err error) bool {
 	return errors.Is(err, keyring.ErrNotFound)
 }
 
+func (d *doctorDiag) record(status string, mark string, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	d.checks = append(d.checks, doctorCheckJSON{Section: d.section, Status: status, Message: msg})
+	d.printf("%s %s\n", mark, msg)
+}
+
 func (d *doctorDiag) okf(format string, args ...any) {
-	fmt.Printf("✔ "+format+"\n", args...)
+	d.record("ok", "✔", format, args...)
 }
 
 func (d *doctorDiag) warnf(format string, args ...any) {
 	d.warns++
-	fmt.Printf("⚠ "+format+"\n", args...)
+	d.record("warn", "⚠", format, args...)
 }
 
 func (d *doctorDiag) failf(format string, args ...any) {
 	d.fails++
-	fmt.Printf("✖ "+format+"\n", args...)
+	d.record("fail", "✖", format, args...)
+}
+
+// begin starts a new report section.
+func (d *doctorDiag) begin(section string) {
+	d.section = section
+	d.println(section)
+}
+
+// println/printf write the human report; --json collects checks instead.
+func (d *doctorDiag) println(a ...any) {
+	if !jsonOutput() {
+		fmt.Println(a...)
+	}
+}
+
+func (d *doctorDiag) printf(format string, args ...any) {
+	if !jsonOutput() {
+		fmt.Printf(format, args...)
+	}
+}
+
+func (d *doctorDiag) result() error {
+	if jsonOutput() {
+		checks := d.checks
+		if checks == nil {
+			checks = []doctorCheckJSON{}
+		}
+		if err := writeJSON(doctorJSON{
+			Schema:   "sentra.doctor/v1",
+			OK:       d.fails == 0,
+			Failures: d.fails,
+			Warnings: d.warns,
+			Checks:   checks,
+		}); err != nil {
+			return err
+		}
+	}
+	if d.fails == 0 {
+		return nil
+	}
+	return withExitCode(ExitCheckFailed, fmt.Errorf("doctor: %d issue(s) found", d.fails))
+}
+
+func newDoctorCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:         "doctor",
+		Short:       "Diagnose connectivity/config issues",
+		GroupID:     groupMaint,
+		Args:        exactArgs(0, "sentra doctor"),
+		Annotations: jsonCapable(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDoctor()
+		},
+	}
 }
 
 func runDoctor() error {
 	auth.LoadDotEnv()
 
 	var d doctorDiag
-	fmt.Println("sentra doctor")
-	fmt.Println()
+	d.println("sentra doctor")
+	d.println()
 
 	// --- Auth ---
-	fmt.Println("Auth")
+	d.begin("Auth")
 
 	supabaseURL := strings.TrimSpace(os.Getenv("SUPABASE_URL"))
 	anonKey := strings.TrimSpace(os.Getenv("SUPABASE_ANON_KEY"))
@@ -101,7 +179,7 @@ func runDoctor() error {
 				// Try to refresh if possible.
 				if supabaseURL != "" && anonKey != "" && strings.TrimSpace(sess.RefreshToken) != "" {
 					oauth := auth.SupabaseOAuth{SupabaseURL: supabaseURL, AnonKey: anonKey, Provider: "google"}
-					ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+					ctx, cancel := context.WithTimeout(commandContext(), 10*time.Second)
 					defer cancel()
 					if _, err := auth.EnsureSession(ctx, oauth); err != nil {
 						d.failf("session expired and refresh failed: %v", err)
@@ -119,19 +197,19 @@ func runDoctor() error {
 		}
 	}
 
-	fmt.Println()
+	d.println()
 
 	// --- Server ---
-	fmt.Println("Server")
+	d.begin("Server")
 	serverURL, err := serverURLFromEnv()
 	if err != nil {
 		d.failf("invalid server url: %v", err)
-		fmt.Println()
-		return fmt.Errorf("doctor: %d issue(s) found", d.fails)
+		d.println()
+		return d.result()
 	}
 	d.okf("url: %s", serverURL)
 
-	client := &http.Client{Timeout: 5 * time.Second}
+	client := newHTTPClient(5 * time.Second)
 	start := time.Now().UTC()
 	resp, err := client.Get(serverURL + "/health")
 	elapsed := time.Since(start)
@@ -154,35 +232,35 @@ func runDoctor() error {
 	}
 
 	if sessOK {
-		req, _ := http.NewRequest("GET", serverURL+"/users/me", nil)
+		req, _ := http.NewRequest("GET", serverURL+api.PathUsersMe, nil)
 		req.Header.Set("Authorization", "Bearer "+sess.AccessToken)
 		resp, err := client.Do(req)
 		if err != nil {
-			d.warnf("/users/me unreachable: %v", err)
+			d.warnf("%s unreachable: %v", api.PathUsersMe, err)
 		} else {
 			_ = resp.Body.Close()
 			switch resp.StatusCode {
 			case http.StatusOK:
-				d.okf("/users/me auth ok")
+				d.okf("%s auth ok", api.PathUsersMe)
 			case http.StatusUnauthorized, http.StatusForbidden:
-				d.failf("/users/me auth failed (%s)", resp.Status)
+				d.failf("%s auth failed (%s)", api.PathUsersMe, resp.Status)
 			default:
-				d.warnf("/users/me returned %s", resp.Status)
+				d.warnf("%s returned %s", api.PathUsersMe, resp.Status)
 			}
 		}
 	} else {
 		d.warnf("skipping /users/me (not logged in)")
 	}
 
-	fmt.Println()
+	d.println()
 
 	// --- Storage ---
-	fmt.Println("Storage")
-	homeDir, err := os.UserHomeDir()
+	d.begin("Storage")
+	sentraDir, err := profile.Dir()
 	if err != nil {
-		d.failf("cannot resolve home dir: %v", err)
+		d.failf("cannot resolve state dir: %v", err)
 	} else {
-		sentraDir := filepath.Join(homeDir, ".sentra")
+		d.okf("profile: %s", profile.Active())
 		if _, err := os.Stat(sentraDir); err != nil {
 			if os.IsNotExist(err) {
 				d.warnf("%s does not exist yet (will be created on first use)", sentraDir)
@@ -207,21 +285,21 @@ func runDoctor() error {
 	}
 
 	// Best-effort keychain diagnostics (no writes).
-	if _, err := keyring.Get("sentra", "session"); err == nil || errorsIsKeyringNotFound(err) {
+	if _, err := keyring.Get(profile.KeyringService(), "session"); err == nil || errorsIsKeyringNotFound(err) {
 		d.okf("keychain: available")
 	} else {
 		d.warnf("keychain unavailable: %v", err)
 		d.warnf("hint: set SENTRA_ALLOW_INSECURE_SESSION_FILE=true to allow encrypted file fallback")
 	}
-	if _, err := keyring.Get("sentra", "session-key"); err != nil && !errorsIsKeyringNotFound(err) {
+	if _, err := keyring.Get(profile.KeyringService(), "session-key"); err != nil && !errorsIsKeyringNotFound(err) {
 		// Don't fail: encryption key can still fallback to ~/.sentra/session.key.
 		d.warnf("keychain session key unavailable: %v", err)
 	}
 
-	fmt.Println()
+	d.println()
 
 	// --- Clock drift ---
-	fmt.Println("Clock drift")
+	d.begin("Clock drift")
 	if serverDate.IsZero() {
 		d.warnf("server time unavailable (missing Date header)")
 	} else {
@@ -243,15 +321,15 @@ func runDoctor() error {
 		}
 	}
 
-	fmt.Println()
+	d.println()
 	if d.fails == 0 {
 		if d.warns > 0 {
-			fmt.Printf("done (%d warning(s))\n", d.warns)
+			d.printf("done (%d warning(s))\n", d.warns)
 		} else {
-			fmt.Println("done")
+			d.println("done")
 		}
-		return nil
+		return d.result()
 	}
-	fmt.Printf("done (%d failure(s), %d warning(s))\n", d.fails, d.warns)
-	return fmt.Errorf("doctor: %d issue(s) found", d.fails)
+	d.printf("done (%d failure(s), %d warning(s))\n", d.fails, d.warns)
+	return d.result()
 }
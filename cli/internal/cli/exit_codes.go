@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"errors"
+
+	"github.com/mgeovany/sentra/cli/internal/auth"
+)
+
+// Process exit codes. Scripts depend on these; keep docs/json-output.md in sync.
+const (
+	ExitOK          = 0
+	ExitError       = 1
+	ExitUsage       = 2
+	ExitAuth        = 3
+	ExitCheckFailed = 4
+)
+
+var errNotLoggedIn = errors.New("not logged in (run: sentra login)")
+
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: code, err: err}
+}
+
+func usageError(usage string) error {
+	return withExitCode(ExitUsage, errors.New("usage: "+usage))
+}
+
+// ExitCode maps an error returned by Execute to a process exit code.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var ee *exitError
+	if errors.As(err, &ee) {
+		return ee.code
+	}
+	if errors.Is(err, errNotLoggedIn) || errors.Is(err, auth.ErrNoSession) {
+		return ExitAuth
+	}
+	return ExitError
+}
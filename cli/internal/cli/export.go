@@ -1,50 +1,69 @@
 package cli
 
 import (
-	"context"
-	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"net/http"
-	"net/url"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
-	"time"
 
 	"github.com/mgeovany/sentra/cli/internal/storage"
+	"github.com/mgeovany/sentra/shared/api"
+	"github.com/spf13/cobra"
 )
 
-type remoteExportFile struct {
-	CommitID        string `json:"commit_id"`
-	Path            string `json:"file_path"`
-	SHA256          string `json:"sha256"`
-	Size            int    `json:"size"`
-	Cipher          string `json:"cipher"`
-	BlobB64         string `json:"blob_b64"`
-	StorageProvider string `json:"storage_provider"`
-	StorageBucket   string `json:"storage_bucket"`
-	StorageKey      string `json:"storage_key"`
-	StorageEndpoint string `json:"storage_endpoint"`
-	StorageRegion   string `json:"storage_region"`
+const exportUsage = "sentra export [<project>] [--project <root>]... [--at <commit>] [--out <dir>] [--path <glob>] [--env <name>] [--dry-run]"
+
+func newExportCmd() *cobra.Command {
+	var at, out string
+	var dryRun bool
+	var ff filterFlags
+	cmd := &cobra.Command{
+		Use:               "export [<project>]",
+		Short:             "Download and decrypt files into a folder",
+		GroupID:           groupRemote,
+		Args:              maxArgs(1, exportUsage),
+		ValidArgsFunction: completeRemoteProjects,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if (cmd.Flags().Changed("at") && strings.TrimSpace(at) == "") ||
+				(cmd.Flags().Changed("out") && strings.TrimSpace(out) == "") {
+				return usageError(exportUsage)
+			}
+			// The positional project is shorthand for --project.
+			ff.projects = append(ff.projects, args...)
+			filter, err := ff.filter()
+			if err != nil {
+				return err
+			}
+			return runExport(filter, at, out, dryRun)
+		},
+	}
+	cmd.Flags().StringVar(&at, "at", "", "Commit ID to export (default: latest; one project only)")
+	cmd.Flags().StringVarP(&out, "out", "o", "", "Directory to write files into")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List the files that would be written without downloading them")
+	ff.register(cmd)
+	_ = cmd.RegisterFlagCompletionFunc("at", completeRemoteCommitIDs)
+	_ = cmd.MarkFlagDirname("out")
+	return cmd
 }
 
-func runExport(args []string) error {
+// runExport writes the selected files of each project in filter under
+// <out>/<root>.
+func runExport(filter fileFilter, at string, out string, dryRun bool) error {
 	verbosef("Starting export operation...")
-	root, at, out, err := parseExportArgs(args)
-	if err != nil {
-		return err
+	roots := filter.roots()
+	at = strings.TrimSpace(at)
+	out = strings.TrimSpace(out)
+	if len(roots) == 0 || (at != "" && len(roots) > 1) {
+		return usageError(exportUsage)
 	}
-	verbosef("Project root: %s", root)
+	verbosef("Project root(s): %s", strings.Join(roots, ", "))
 	if at != "" {
 		verbosef("Exporting at commit: %s", at)
 	} else {
 		verbosef("Exporting latest commit")
 	}
-	if strings.TrimSpace(out) != "" {
+	if out != "" {
 		verbosef("Export directory override: %s", out)
 	}
 
@@ -53,7 +72,7 @@ func runExport(args []string) error {
 		return err
 	}
 	if strings.TrimSpace(sess.AccessToken) == "" {
-		return errors.New("not logged in (run: sentra login)")
+		return errNotLoggedIn
 	}
 	verbosef("Session loaded: user authenticated")
 
@@ -63,200 +82,96 @@ func runExport(args []string) error {
 	}
 	verbosef("Server URL: %s", serverURL)
 
-	u, err := url.Parse(serverURL + "/export")
-	if err != nil {
-		return err
-	}
-	q := u.Query()
-	q.Set("root", root)
-	if at != "" {
-		q.Set("at", at)
-	}
-	u.RawQuery = q.Encode()
-	verbosef("Export URL: %s", u.String())
-
-	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, u.String(), nil)
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(sess.AccessToken))
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	body, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("export failed")
-	}
-
-	var files []remoteExportFile
-	if err := json.Unmarshal(body, &files); err != nil {
-		return err
-	}
-	if len(files) == 0 {
-		fmt.Println("✔ 0 files")
-		verbosef("No files found to export")
-		return nil
-	}
-	verbosef("Received %d file(s) from server", len(files))
-
-	// Resolve vault key once if needed.
+	// Vault key is only needed when decrypting vault-key files; fetch lazily.
 	var vaultKey []byte
-	for _, f := range files {
-		if strings.TrimSpace(f.Cipher) == "sentra-v1" {
-			vaultKey, err = ensureVaultKey(serverURL, sess.AccessToken)
-			if err != nil {
-				return err
-			}
-			break
+	var corrupt []integrityError
+	for _, root := range roots {
+		files, err := fetchRemoteExport(serverURL, sess.AccessToken, root, at, filter)
+		if err != nil {
+			return err
 		}
-	}
-
-	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+		if len(files) == 0 {
+			fmt.Printf("✔ %s: 0 files\n", root)
+			verbosef("No files found to export for %s", root)
+			continue
+		}
+		verbosef("Received %d file(s) from server for %s", len(files), root)
 
-	baseDir := filepath.Join("sentra-export", root)
-	if v := strings.TrimSpace(out); v != "" {
-		v = expandUserHome(v)
-		v = filepath.Clean(v)
-		baseDir = filepath.Join(v, root)
+		baseDir := filepath.Join("sentra-export", root)
+		if out != "" {
+			baseDir = filepath.Join(filepath.Clean(expandUserHome(out)), root)
+		}
+		verbosef("Export directory: %s", baseDir)
+		bad, err := exportProject(serverURL, sess.AccessToken, &vaultKey, root, files, baseDir, dryRun)
+		if err != nil {
+			return err
+		}
+		corrupt = append(corrupt, bad...)
 	}
-	verbosef("Export directory: %s", baseDir)
-	if err := os.MkdirAll(baseDir, 0o755); err != nil {
-		return err
+	return reportIntegrityErrors(corrupt)
+}
+
+func exportProject(serverURL string, accessToken string, vaultKey *[]byte, root string, files []api.ExportFile, baseDir string, dryRun bool) ([]integrityError, error) {
+	if !dryRun {
+		if err := os.MkdirAll(baseDir, 0o755); err != nil {
+			return nil, err
+		}
 	}
 
 	written := 0
+	var corrupt []integrityError
 	for i, f := range files {
-		verbosef("Processing file %d/%d: %s (size: %d bytes, cipher: %s)", i+1, len(files), f.Path, f.Size, f.Cipher)
-		cipherName := strings.TrimSpace(f.Cipher)
-		blobB64 := strings.TrimSpace(f.BlobB64)
-		if blobB64 == "" && strings.TrimSpace(f.StorageKey) != "" {
-			verbosef("File stored in BYOS, downloading from storage: %s", f.StorageKey)
-			s3cfg, _, enabled, err := storage.ResolveS3()
-			if err != nil {
-				return err
-			}
-			if !enabled {
-				return fmt.Errorf("export requires storage setup (run: sentra storage setup)")
-			}
-			// Prefer server-provided location if present.
-			if strings.TrimSpace(f.StorageBucket) != "" {
-				s3cfg.Bucket = strings.TrimSpace(f.StorageBucket)
-				verbosef("Using storage bucket: %s", s3cfg.Bucket)
-			}
-			if strings.TrimSpace(f.StorageEndpoint) != "" {
-				s3cfg.Endpoint = strings.TrimSpace(f.StorageEndpoint)
-				verbosef("Using storage endpoint: %s", s3cfg.Endpoint)
-			}
-			if strings.TrimSpace(f.StorageRegion) != "" {
-				s3cfg.Region = strings.TrimSpace(f.StorageRegion)
-				verbosef("Using storage region: %s", s3cfg.Region)
-			}
-			// Recreate S3 client after applying server-provided overrides
-			// since MinIO clients are bound to endpoint/region at construction.
-			s3c, err := storage.NewS3Client(s3cfg)
-			if err != nil {
-				return fmt.Errorf("failed to connect to storage (%s)", f.Path)
-			}
-			verbosef("Downloading from storage: %s", f.StorageKey)
-			raw, err := storage.GetObject(context.Background(), s3c, s3cfg, strings.TrimSpace(f.StorageKey))
-			if err != nil {
-				return fmt.Errorf("failed to download from storage (%s)", f.Path)
-			}
-			verbosef("Downloaded %d bytes from storage", len(raw))
-			blobB64 = base64.RawURLEncoding.EncodeToString(raw)
-		}
-
-		verbosef("Decrypting file: %s", f.Path)
-		plain, err := decryptEnvFile(cipherName, blobB64, vaultKey)
-		if err != nil {
-			if strings.TrimSpace(cipherName) == "ed25519+aes-256-gcm-v1" {
-				return fmt.Errorf("failed to decrypt legacy file (%s): this file was encrypted with a device-local key; re-push it from the original machine to migrate", f.Path)
-			}
-			return fmt.Errorf("failed to decrypt file (%s): %w", f.Path, err)
+		verbosef("Processing file %d/%d: %s (size: %d bytes, cipher: %s)", i+1, len(files), f.FilePath, f.Size, f.Cipher)
+		if strings.TrimSpace(f.BlobB64) == "" && strings.TrimSpace(f.StorageKey) != "" {
+			verbosef("File stored in %s storage: %s", f.StorageProvider, f.StorageKey)
+			verbosef("Using storage: provider=%s, bucket=%s, endpoint=%s, region=%s", f.StorageProvider, f.StorageBucket, f.StorageEndpoint, f.StorageRegion)
 		}
-		verbosef("Decrypted file: %s (%d bytes)", f.Path, len(plain))
 
-		rel := strings.TrimSpace(f.Path)
+		rel := strings.TrimSpace(f.FilePath)
 		rel = strings.TrimPrefix(rel, root+"/")
 		rel = filepath.ToSlash(rel)
 		rel = strings.TrimPrefix(rel, "/")
 		rel = filepath.Clean(rel)
 		rel = filepath.ToSlash(rel)
 		if rel == "." || rel == "" || strings.HasPrefix(rel, "../") {
-			return fmt.Errorf("invalid file path received from server")
+			return nil, fmt.Errorf("invalid file path received from server")
 		}
 		if strings.HasPrefix(rel, "/") || strings.HasPrefix(rel, "\\") {
-			return fmt.Errorf("invalid file path received from server")
+			return nil, fmt.Errorf("invalid file path received from server")
 		}
 
 		outPath := filepath.Join(baseDir, filepath.FromSlash(rel))
+		if dryRun {
+			fmt.Printf("  would write %s (%d bytes)\n", outPath, f.Size)
+			written++
+			continue
+		}
 		verbosef("Writing file to: %s", outPath)
-		if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
-			return err
+		rc, err := openEnvFile(serverURL, accessToken, vaultKey, f)
+		if err == nil {
+			err = writeEnvFile(outPath, rc)
+			_ = rc.Close()
 		}
-		if err := os.WriteFile(outPath, plain, 0o600); err != nil {
-			return err
+		if err != nil {
+			var ie integrityError
+			if errors.As(err, &ie) {
+				corrupt = append(corrupt, ie)
+				continue
+			}
+			if errors.Is(err, storage.ErrNotConfigured) {
+				return nil, fmt.Errorf("export requires storage setup (run: sentra storage setup)")
+			}
+			return nil, err
 		}
 		written++
 		verbosef("Successfully exported file: %s", outPath)
 	}
 
+	if dryRun {
+		infof("Dry run: %d file(s) would be written to %s", written, baseDir)
+		return corrupt, nil
+	}
 	fmt.Printf("✔ exported %d files to %s\n", written, baseDir)
 	verbosef("Export completed: %d file(s) written to %s", written, baseDir)
-	return nil
-}
-
-func parseExportArgs(args []string) (root string, at string, out string, err error) {
-	if len(args) < 1 {
-		return "", "", "", errors.New("usage: sentra export <project> [--at <commit>] [--out <dir>]")
-	}
-
-	root = projectRootFromPath(args[0])
-	root = strings.TrimSpace(root)
-	if root == "" {
-		return "", "", "", errors.New("usage: sentra export <project> [--at <commit>] [--out <dir>]")
-	}
-
-	// Flags:
-	// --at <commit>
-	// --out <dir> (or -o <dir>)
-	rest := args[1:]
-	for i := 0; i < len(rest); i++ {
-		switch rest[i] {
-		case "--at":
-			if i+1 >= len(rest) {
-				return "", "", "", errors.New("usage: sentra export <project> [--at <commit>] [--out <dir>]")
-			}
-			if at != "" {
-				return "", "", "", errors.New("export: --at provided multiple times")
-			}
-			at = strings.TrimSpace(rest[i+1])
-			if at == "" {
-				return "", "", "", errors.New("usage: sentra export <project> [--at <commit>] [--out <dir>]")
-			}
-			i++
-		case "--out", "-o":
-			if i+1 >= len(rest) {
-				return "", "", "", errors.New("usage: sentra export <project> [--at <commit>] [--out <dir>]")
-			}
-			if strings.TrimSpace(out) != "" {
-				return "", "", "", errors.New("export: --out provided multiple times")
-			}
-			out = strings.TrimSpace(rest[i+1])
-			if out == "" {
-				return "", "", "", errors.New("usage: sentra export <project> [--at <commit>] [--out <dir>]")
-			}
-			i++
-		default:
-			return "", "", "", errors.New("usage: sentra export <project> [--at <commit>] [--out <dir>]")
-		}
-	}
-
-	return root, at, out, nil
+	return corrupt, nil
 }
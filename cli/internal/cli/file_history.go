@@ -0,0 +1,274 @@
+package cli
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/mgeovany/sentra/shared/api"
+	"github.com/spf13/cobra"
+)
+
+type fileLogJSON struct {
+	Schema      string       `json:"schema"`
+	ProjectRoot string       `json:"project_root"`
+	FilePath    string       `json:"file_path"`
+	Commits     []commitJSON `json:"commits"`
+	NextCursor  string       `json:"next_cursor,omitempty"`
+}
+
+type blameJSON struct {
+	Schema      string          `json:"schema"`
+	ProjectRoot string          `json:"project_root"`
+	FilePath    string          `json:"file_path"`
+	At          string          `json:"at"`
+	Keys        []blameLineJSON `json:"keys"`
+}
+
+// blameLineJSON attributes one key to the commit that last set its value.
+// Values are never included.
+type blameLineJSON struct {
+	Key         string `json:"key"`
+	CommitID    string `json:"commit_id"`
+	CreatedAt   string `json:"created_at"`
+	MachineID   string `json:"machine_id"`
+	MachineName string `json:"machine_name"`
+	Message     string `json:"message"`
+}
+
+// splitProjectFile splits "<project>/<path>" into the project root and the
+// file path inside it.
+func splitProjectFile(arg string) (string, string, bool) {
+	arg = strings.TrimPrefix(strings.TrimSpace(arg), "./")
+	root, p, ok := strings.Cut(arg, "/")
+	root = strings.TrimSpace(root)
+	p = path.Clean(strings.TrimSpace(p))
+	if !ok || root == "" || p == "." || p == "" || strings.HasPrefix(p, "../") {
+		return "", "", false
+	}
+	return root, p, true
+}
+
+// runFileLog lists the remote commits that touched one file.
+func runFileLog(arg string, ff commitFilterFlags) error {
+	const usage = "sentra log <project>/<path>"
+	root, filePath, ok := splitProjectFile(arg)
+	if !ok {
+		return usageError(usage)
+	}
+	ff.path = filePath
+	q, err := ff.query(root, usage)
+	if err != nil {
+		return err
+	}
+
+	sess, err := ensureRemoteSession()
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(sess.AccessToken) == "" {
+		return errNotLoggedIn
+	}
+	serverURL, err := serverURLFromEnv()
+	if err != nil {
+		return err
+	}
+
+	commits, next, err := fetchRemoteCommitPage(serverURL, sess.AccessToken, q)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput() {
+		out := fileLogJSON{Schema: "sentra.file_log/v1", ProjectRoot: root, FilePath: filePath, Commits: make([]commitJSON, 0, len(commits)), NextCursor: next}
+		for _, c := range commits {
+			out.Commits = append(out.Commits, toCommitJSON(root, c))
+		}
+		return writeJSON(out)
+	}
+
+	if len(commits) == 0 {
+		fmt.Printf("✔ no commits touch %s/%s\n", root, filePath)
+		return nil
+	}
+	for _, c := range commits {
+		fmt.Printf("%s\t%s\t%s\t%s\n", orDash(c.CreatedAt), shortRemoteCommitID(c.CommitID), commitMachine(c), commitMessage(c))
+	}
+	printMoreCommits(next)
+	return nil
+}
+
+func newBlameCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:               "blame <project>/<path>",
+		Short:             "Show which commit and machine last changed each key of a remote env file",
+		GroupID:           groupRemote,
+		Args:              exactArgs(1, "sentra blame <project>/<path>"),
+		Annotations:       jsonCapable(),
+		ValidArgsFunction: completeRemoteProjects,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runBlame(args[0])
+		},
+	}
+}
+
+// runBlame decrypts every pushed version of one file, oldest first, and
+// attributes each key of the newest version to the commit where its value
+// last changed. Only key names are printed.
+func runBlame(arg string) error {
+	root, filePath, ok := splitProjectFile(arg)
+	if !ok {
+		return usageError("sentra blame <project>/<path>")
+	}
+
+	sess, err := ensureRemoteSession()
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(sess.AccessToken) == "" {
+		return errNotLoggedIn
+	}
+	serverURL, err := serverURLFromEnv()
+	if err != nil {
+		return err
+	}
+
+	sp := startSpinner("Reading history of " + root + "/" + filePath + "...")
+	commits, _, err := fetchRemoteCommitPage(serverURL, sess.AccessToken, api.CommitQuery{Root: root, Path: filePath})
+	if err != nil {
+		sp.StopInfo("")
+		return err
+	}
+	if len(commits) == 0 {
+		sp.StopInfo("")
+		return fmt.Errorf("no commits touch %s/%s", root, filePath)
+	}
+
+	var (
+		vaultKey []byte
+		prevSHA  string
+		prev     map[string]string
+		blame    = map[string]api.Commit{}
+	)
+	// Commits arrive newest first; replay them in push order.
+	for i := len(commits) - 1; i >= 0; i-- {
+		c := commits[i]
+		id := strings.TrimSpace(c.CommitID)
+		files, err := fetchRemoteExport(serverURL, sess.AccessToken, root, id, fileFilter{})
+		if err != nil {
+			sp.StopInfo("")
+			return err
+		}
+		var version *api.ExportFile
+		for j := range files {
+			if strings.TrimSpace(files[j].FilePath) == filePath {
+				version = &files[j]
+				break
+			}
+		}
+		if version == nil {
+			// Removed in this commit: keys that come back later start over.
+			prevSHA, prev = "", nil
+			blame = map[string]api.Commit{}
+			continue
+		}
+		if sha := strings.TrimSpace(version.SHA256); sha != "" && sha == prevSHA {
+			continue
+		}
+
+		plain, err := decryptRemoteExportFile(serverURL, sess.AccessToken, &vaultKey, *version)
+		if err != nil {
+			sp.StopInfo("")
+			return err
+		}
+		vars, err := godotenv.UnmarshalBytes(plain)
+		if err != nil {
+			sp.StopInfo("")
+			return fmt.Errorf("failed to parse %s at %s: %w", filePath, shortRemoteCommitID(id), err)
+		}
+		verbosef("Commit %s: %d key(s)", shortRemoteCommitID(id), len(vars))
+
+		for k, v := range vars {
+			if old, ok := prev[k]; !ok || old != v {
+				blame[k] = c
+			}
+		}
+		for k := range blame {
+			if _, ok := vars[k]; !ok {
+				delete(blame, k)
+			}
+		}
+		prevSHA, prev = strings.TrimSpace(version.SHA256), vars
+	}
+	sp.StopInfo("")
+
+	keys := make([]string, 0, len(blame))
+	for k := range blame {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if jsonOutput() {
+		out := blameJSON{Schema: "sentra.blame/v1", ProjectRoot: root, FilePath: filePath, At: strings.TrimSpace(commits[0].CommitID), Keys: make([]blameLineJSON, 0, len(keys))}
+		for _, k := range keys {
+			c := blame[k]
+			out.Keys = append(out.Keys, blameLineJSON{
+				Key:         k,
+				CommitID:    strings.TrimSpace(c.CommitID),
+				CreatedAt:   strings.TrimSpace(c.CreatedAt),
+				MachineID:   strings.TrimSpace(c.MachineID),
+				MachineName: strings.TrimSpace(c.MachineName),
+				Message:     strings.TrimSpace(c.Message),
+			})
+		}
+		return writeJSON(out)
+	}
+
+	if len(keys) == 0 {
+		fmt.Printf("✔ %s/%s has no keys\n", root, filePath)
+		return nil
+	}
+	keyW := 0
+	for _, k := range keys {
+		keyW = max(keyW, len(k))
+	}
+	for _, k := range keys {
+		c := blame[k]
+		fmt.Printf("%s  %s  %s  %s  %s\n", padRight(k, keyW), shortRemoteCommitID(c.CommitID), orDash(c.CreatedAt), commitMachine(c), commitMessage(c))
+	}
+	return nil
+}
+
+func shortRemoteCommitID(id string) string {
+	id = strings.TrimSpace(id)
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+func commitMachine(c api.Commit) string {
+	if m := strings.TrimSpace(c.MachineName); m != "" {
+		return m
+	}
+	if m := strings.TrimSpace(c.MachineID); m != "" {
+		return m
+	}
+	return "unknown"
+}
+
+func commitMessage(c api.Commit) string {
+	if m := strings.TrimSpace(c.Message); m != "" {
+		return m
+	}
+	return "(no message)"
+}
+
+func orDash(s string) string {
+	if s = strings.TrimSpace(s); s != "" {
+		return s
+	}
+	return "-"
+}
@@ -1,37 +1,56 @@
 package cli
 
 import (
-	"context"
-	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
-	"net/http"
-	"net/url"
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/mgeovany/sentra/shared/api"
+	"github.com/spf13/cobra"
 )
 
-type remoteFile struct {
-	CommitID string `json:"commit_id"`
-	Path     string `json:"file_path"`
-	SHA256   string `json:"sha256"`
-	Size     int    `json:"size"`
+type filesJSON struct {
+	Schema      string     `json:"schema"`
+	ProjectRoot string     `json:"project_root"`
+	At          string     `json:"at,omitempty"`
+	Files       []api.File `json:"files"`
 }
 
-func runFiles(args []string) error {
-	root, at, err := parseFilesArgs(args)
-	if err != nil {
-		return err
+func newFilesCmd() *cobra.Command {
+	var at string
+	cmd := &cobra.Command{
+		Use:               "files <project>",
+		Short:             "List files for a project (optionally at a commit)",
+		GroupID:           groupRemote,
+		Args:              exactArgs(1, "sentra files <project> [--at <commit>]"),
+		Annotations:       jsonCapable(),
+		ValidArgsFunction: completeRemoteProjects,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if cmd.Flags().Changed("at") && strings.TrimSpace(at) == "" {
+				return usageError("sentra files <project> [--at <commit>]")
+			}
+			return runFiles(args[0], at)
+		},
+	}
+	cmd.Flags().StringVar(&at, "at", "", "Commit ID to list files at")
+	_ = cmd.RegisterFlagCompletionFunc("at", completeRemoteCommitIDs)
+	return cmd
+}
+
+func runFiles(project string, at string) error {
+	root := strings.TrimSpace(projectRootFromPath(project))
+	if root == "" {
+		return usageError("sentra files <project> [--at <commit>]")
 	}
+	at = strings.TrimSpace(at)
 
 	sess, err := ensureRemoteSession()
 	if err != nil {
 		return err
 	}
 	if strings.TrimSpace(sess.AccessToken) == "" {
-		return errors.New("not logged in (run: sentra login)")
+		return errNotLoggedIn
 	}
 
 	serverURL, err := serverURLFromEnv()
@@ -39,73 +58,30 @@ func runFiles(args []string) error {
 		return err
 	}
 
-	u, err := url.Parse(serverURL + "/files")
-	if err != nil {
-		return err
-	}
-	q := u.Query()
-	q.Set("root", root)
-	if at != "" {
-		q.Set("at", at)
-	}
-	u.RawQuery = q.Encode()
-
-	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, u.String(), nil)
+	files, err := apiClient(serverURL, sess.AccessToken, 20*time.Second).Files(commandContext(), root, at)
 	if err != nil {
+		if api.StatusCode(err) != 0 {
+			return fmt.Errorf("failed to fetch files")
+		}
 		return err
 	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(sess.AccessToken))
+	sort.Slice(files, func(i, j int) bool { return files[i].FilePath < files[j].FilePath })
 
-	client := &http.Client{Timeout: 20 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
+	if jsonOutput() {
+		if files == nil {
+			files = []api.File{}
+		}
+		return writeJSON(filesJSON{Schema: "sentra.files/v1", ProjectRoot: root, At: at, Files: files})
 	}
-	defer func() { _ = resp.Body.Close() }()
 
-	body, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("failed to fetch files")
-	}
-
-	var files []remoteFile
-	if err := json.Unmarshal(body, &files); err != nil {
-		return err
-	}
 	if len(files) == 0 {
 		fmt.Println("✔ 0 files")
 		return nil
 	}
 
-	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
 	for _, f := range files {
-		fmt.Printf("%s\t%d\t%s\n", strings.TrimSpace(f.Path), f.Size, strings.TrimSpace(f.SHA256))
+		fmt.Printf("%s\t%d\t%s\n", strings.TrimSpace(f.FilePath), f.Size, strings.TrimSpace(f.SHA256))
 	}
 
 	return nil
 }
-
-func parseFilesArgs(args []string) (root string, at string, err error) {
-	if len(args) < 1 {
-		return "", "", errors.New("usage: sentra files <project> [--at <commit>]")
-	}
-
-	root = projectRootFromPath(args[0])
-	root = strings.TrimSpace(root)
-	if root == "" {
-		return "", "", errors.New("usage: sentra files <project> [--at <commit>]")
-	}
-
-	if len(args) == 1 {
-		return root, "", nil
-	}
-	if len(args) != 3 || args[1] != "--at" {
-		return "", "", errors.New("usage: sentra files <project> [--at <commit>]")
-	}
-	at = strings.TrimSpace(args[2])
-	if at == "" {
-		return "", "", errors.New("usage: sentra files <project> [--at <commit>]")
-	}
-	return root, at, nil
-}
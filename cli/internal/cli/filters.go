@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// filterFlags are the --project/--path/--env flags shared by push, sync and
+// export.
+type filterFlags struct {
+	projects []string
+	path     string
+	env      string
+}
+
+func (ff *filterFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&ff.projects, "project", nil, "Only this project root (repeatable)")
+	cmd.Flags().StringVar(&ff.path, "path", "", "Only files matching this glob (a name like '.env.*', or a path inside the project like 'web/.env*')")
+	cmd.Flags().StringVar(&ff.env, "env", "", "Only files for this environment (e.g. production selects .env.production and .env.production.local)")
+	_ = cmd.RegisterFlagCompletionFunc("project", func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		return completeRemoteProjects(cmd, nil, toComplete)
+	})
+}
+
+func (ff filterFlags) filter() (fileFilter, error) {
+	return newFileFilter(ff.projects, ff.path, ff.env)
+}
+
+// fileFilter selects project roots and files. The zero value matches
+// everything.
+type fileFilter struct {
+	projects map[string]bool
+	path     string
+	env      string
+}
+
+func newFileFilter(projects []string, pathGlob string, env string) (fileFilter, error) {
+	var f fileFilter
+	for _, p := range projects {
+		root := projectRootFromPath(p)
+		if root == "" {
+			return fileFilter{}, withExitCode(ExitUsage, fmt.Errorf("invalid --project: %q", p))
+		}
+		if f.projects == nil {
+			f.projects = map[string]bool{}
+		}
+		f.projects[root] = true
+	}
+	f.path = strings.TrimPrefix(strings.TrimSpace(pathGlob), "./")
+	if f.path != "" {
+		if _, err := path.Match(f.path, ""); err != nil {
+			return fileFilter{}, withExitCode(ExitUsage, fmt.Errorf("invalid --path glob %q: %w", pathGlob, err))
+		}
+	}
+	f.env = strings.TrimSpace(env)
+	if strings.ContainsAny(f.env, "/.") {
+		return fileFilter{}, withExitCode(ExitUsage, fmt.Errorf("invalid --env: %q", env))
+	}
+	return f, nil
+}
+
+// roots returns the selected project roots in order, or nil when every
+// project is selected.
+func (f fileFilter) roots() []string {
+	if len(f.projects) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(f.projects))
+	for root := range f.projects {
+		out = append(out, root)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (f fileFilter) selectsFiles() bool {
+	return f.path != "" || f.env != ""
+}
+
+func (f fileFilter) matchProject(root string) bool {
+	return len(f.projects) == 0 || f.projects[strings.TrimSpace(root)]
+}
+
+// matchFile reports whether p ("<root>/<path>") is selected. A glob
+// without a slash matches the file name; one with a slash matches the
+// path inside the project.
+func (f fileFilter) matchFile(p string) bool {
+	p = strings.TrimPrefix(strings.TrimSpace(p), "./")
+	root := projectRootFromPath(p)
+	if !f.matchProject(root) {
+		return false
+	}
+	name := path.Base(p)
+	if f.path != "" {
+		subject := name
+		if strings.Contains(f.path, "/") {
+			subject = strings.TrimPrefix(p, root+"/")
+		}
+		if ok, _ := path.Match(f.path, subject); !ok {
+			return false
+		}
+	}
+	if f.env != "" && envName(name) != f.env {
+		return false
+	}
+	return true
+}
+
+// envName is the environment a file name configures: "production" for
+// .env.production and .env.production.local, "local" for .env.local, and
+// "" for .env.
+func envName(name string) string {
+	suffix, ok := strings.CutPrefix(name, ".env.")
+	if !ok {
+		return ""
+	}
+	if base, ok := strings.CutSuffix(suffix, ".local"); ok && base != "" {
+		return base
+	}
+	return suffix
+}
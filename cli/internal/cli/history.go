@@ -1,23 +1,44 @@
 package cli
 
 import (
-	"context"
-	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
-	"net/http"
-	"net/url"
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/mgeovany/sentra/shared/api"
+	"github.com/spf13/cobra"
 )
 
+type historyJSON struct {
+	Schema     string       `json:"schema"`
+	Commits    []commitJSON `json:"commits"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+func newHistoryCmd() *cobra.Command {
+	var ff commitFilterFlags
+	cmd := &cobra.Command{
+		Use:         "history",
+		Short:       "List remote commit history (all projects)",
+		GroupID:     groupRemote,
+		Args:        exactArgs(0, "sentra history"),
+		Annotations: jsonCapable(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runHistory(ff)
+		},
+	}
+	ff.register(cmd)
+	return cmd
+}
+
 // sentra history
-// Lists remote commit history across all projects.
-func runHistory(args []string) error {
-	if len(args) != 0 {
-		return errors.New("usage: sentra history")
+// Lists remote commit history across all projects, newest first, one page
+// at a time.
+func runHistory(ff commitFilterFlags) error {
+	q, err := ff.query("", "sentra history")
+	if err != nil {
+		return err
 	}
 
 	sess, err := ensureRemoteSession()
@@ -25,7 +46,7 @@ func runHistory(args []string) error {
 		return err
 	}
 	if strings.TrimSpace(sess.AccessToken) == "" {
-		return errors.New("not logged in (run: sentra login)")
+		return errNotLoggedIn
 	}
 
 	serverURL, err := serverURLFromEnv()
@@ -33,35 +54,42 @@ func runHistory(args []string) error {
 		return err
 	}
 
-	projects, err := fetchRemoteProjects(serverURL, sess.AccessToken)
+	commits, next, err := fetchRemoteCommitPage(serverURL, sess.AccessToken, q)
 	if err != nil {
 		return err
 	}
-	if len(projects) == 0 {
-		fmt.Println("✔ 0 projects")
-		return nil
+
+	if jsonOutput() {
+		out := historyJSON{Schema: "sentra.history/v1", Commits: make([]commitJSON, 0, len(commits)), NextCursor: next}
+		for _, c := range commits {
+			out.Commits = append(out.Commits, toCommitJSON("", c))
+		}
+		return writeJSON(out)
 	}
 
-	sort.Slice(projects, func(i, j int) bool {
-		return strings.TrimSpace(projects[i].RootPath) < strings.TrimSpace(projects[j].RootPath)
-	})
+	if len(commits) == 0 {
+		fmt.Println("✔ 0 commits")
+		return nil
+	}
 
-	total := 0
-	for _, p := range projects {
-		root := strings.TrimSpace(p.RootPath)
+	// Group the page by project, keeping each project's commits newest first.
+	byRoot := map[string][]api.Commit{}
+	for _, c := range commits {
+		root := strings.TrimSpace(c.ProjectRoot)
 		if root == "" {
-			continue
-		}
-		commits, err := fetchRemoteCommits(serverURL, sess.AccessToken, root)
-		if err != nil {
-			return err
-		}
-		if len(commits) == 0 {
-			continue
+			root = "(unknown)"
 		}
+		byRoot[root] = append(byRoot[root], c)
+	}
+	roots := make([]string, 0, len(byRoot))
+	for root := range byRoot {
+		roots = append(roots, root)
+	}
+	sort.Strings(roots)
 
+	for _, root := range roots {
 		fmt.Println(root)
-		for _, c := range commits {
+		for _, c := range byRoot[root] {
 			created := strings.TrimSpace(c.CreatedAt)
 			if created == "" {
 				created = "-"
@@ -84,52 +112,24 @@ func runHistory(args []string) error {
 			}
 			cnt := c.FileCount
 			if cnt == 0 {
-				cnt = len(c.FilePaths)
+				cnt = len(c.Files)
 			}
 			fmt.Printf("  %s\t%s\t%d\t%s\t%s\n", created, short, cnt, machine, msg)
-			total++
 		}
 		fmt.Println()
 	}
 
-	if total == 0 {
-		fmt.Println("✔ 0 commits")
-		return nil
-	}
-	fmt.Printf("✔ %d commit(s)\n", total)
+	fmt.Printf("✔ %d commit(s)\n", len(commits))
+	printMoreCommits(next)
 	return nil
 }
 
-func fetchRemoteCommits(serverURL string, accessToken string, root string) ([]remoteCommit, error) {
-	u, err := url.Parse(strings.TrimRight(strings.TrimSpace(serverURL), "/") + "/commits")
-	if err != nil {
-		return nil, err
-	}
-	q := u.Query()
-	q.Set("root", strings.TrimSpace(root))
-	u.RawQuery = q.Encode()
-
-	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, u.String(), nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(accessToken))
-
-	client := &http.Client{Timeout: 25 * time.Second}
-	resp, err := client.Do(req)
+func fetchRemoteCommits(serverURL string, accessToken string, root string) ([]api.Commit, error) {
+	commits, err := apiClient(serverURL, accessToken, 25*time.Second).Commits(commandContext(), root)
 	if err != nil {
-		return nil, err
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	body, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("failed to fetch commits")
-	}
-
-	var commits []remoteCommit
-	if err := json.Unmarshal(body, &commits); err != nil {
+		if api.StatusCode(err) != 0 {
+			return nil, fmt.Errorf("failed to fetch commits")
+		}
 		return nil, err
 	}
 	return commits, nil
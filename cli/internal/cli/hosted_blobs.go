@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mgeovany/sentra/cli/internal/storage"
+	"github.com/mgeovany/sentra/cli/internal/telemetry"
+	"github.com/mgeovany/sentra/shared/api"
+)
+
+// hostedProvider is the storage_provider of blobs kept by the Sentra server
+// (PUT /blobs) rather than in user-managed storage.
+const hostedProvider = "sentra"
+
+// hostedBlobStore uploads and downloads hosted-mode ciphertexts through the
+// server, so push requests only carry metadata. Keys are "<root>/<id>"; the
+// server namespaces them by user.
+type hostedBlobStore struct {
+	serverURL   string
+	accessToken string
+	client      *http.Client
+}
+
+func newHostedBlobStore(serverURL string, accessToken string) *hostedBlobStore {
+	// No overall client timeout: it would also cut off large transfers while
+	// the body is still streaming.
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.ResponseHeaderTimeout = 60 * time.Second
+	return &hostedBlobStore{
+		serverURL:   strings.TrimRight(strings.TrimSpace(serverURL), "/"),
+		accessToken: strings.TrimSpace(accessToken),
+		client:      &http.Client{Transport: telemetry.Transport(paced(t))},
+	}
+}
+
+func hostedBlobKey(root string, blobID string) string {
+	return strings.TrimSpace(root) + "/" + strings.TrimSpace(blobID)
+}
+
+func (s *hostedBlobStore) url(key string) (string, error) {
+	root, id, ok := strings.Cut(key, "/")
+	if !ok || root == "" || id == "" || strings.Contains(id, "/") {
+		return "", fmt.Errorf("invalid hosted blob key: %q", key)
+	}
+	return s.serverURL + api.PathBlobs + url.PathEscape(root) + "/" + url.PathEscape(id), nil
+}
+
+func (s *hostedBlobStore) do(ctx context.Context, method string, key string, body io.Reader, size int64) (*http.Response, error) {
+	target, err := s.url(key)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	req.Header.Set("Authorization", "Bearer "+s.accessToken)
+	return s.client.Do(req)
+}
+
+func hostedBlobError(op string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	msg := oneLine(string(body))
+	if msg == "" {
+		msg = strings.TrimSpace(http.StatusText(resp.StatusCode))
+	}
+	return retryableStatus(resp.StatusCode, resp.Header, fmt.Errorf("hosted blob %s failed: %s", op, msg))
+}
+
+func (s *hostedBlobStore) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	resp, err := s.do(ctx, http.MethodPut, key, r, size)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return hostedBlobError("upload", resp)
+	}
+	return nil
+}
+
+func (s *hostedBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s", os.ErrNotExist, key)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer func() { _ = resp.Body.Close() }()
+		return nil, hostedBlobError("download", resp)
+	}
+	return resp.Body, nil
+}
+
+func (s *hostedBlobStore) Exists(ctx context.Context, key string) (bool, error) {
+	resp, err := s.do(ctx, http.MethodHead, key, nil, 0)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return true, nil
+	}
+	return false, hostedBlobError("check", resp)
+}
+
+// Hosted blobs belong to the server; `sentra storage gc` only covers BYOS.
+func (s *hostedBlobStore) Delete(ctx context.Context, key string) error {
+	return errors.ErrUnsupported
+}
+
+func (s *hostedBlobStore) List(ctx context.Context, prefix string) ([]storage.ObjectInfo, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func (s *hostedBlobStore) Location() storage.Location {
+	return storage.Location{Provider: hostedProvider}
+}
@@ -5,12 +5,9 @@ import (
 	"strings"
 )
 
-func responseTrace(resp *http.Response) string {
-	if resp == nil {
-		return ""
-	}
+func responseTrace(h http.Header) string {
 	for _, k := range []string{"X-Request-Id", "X-Cloud-Trace-Context", "traceparent", "X-Amzn-Trace-Id"} {
-		v := strings.TrimSpace(resp.Header.Get(k))
+		v := strings.TrimSpace(h.Get(k))
 		if v != "" {
 			return k + "=" + oneLine(v)
 		}
@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+)
+
+// With --json every command prints exactly one JSON object to stdout.
+// Objects carry a "schema" field ("sentra.<command>/v<N>") that is bumped on
+// breaking changes; docs/json-output.md documents each of them. Human
+// output (spinners, info lines) moves to stderr so stdout stays parseable.
+
+func jsonOutput() bool {
+	return globals.JSON
+}
+
+// humanOut is where informational lines go.
+func humanOut() io.Writer {
+	if jsonOutput() {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+func writeJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
+
+type errorJSON struct {
+	Schema string `json:"schema"`
+	Error  struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func writeJSONError(err error) {
+	var out errorJSON
+	out.Schema = "sentra.error/v1"
+	out.Error.Code = ExitCode(err)
+	out.Error.Message = err.Error()
+	_ = writeJSON(out)
+}
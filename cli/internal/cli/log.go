@@ -7,58 +7,120 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/mgeovany/sentra/cli/internal/commit"
 	"github.com/mgeovany/sentra/cli/internal/index"
+	"github.com/spf13/cobra"
 )
 
-func runLog(args []string) error {
-	if len(args) == 0 {
-		return runLogList("pending")
-	}
+type logJSON struct {
+	Schema  string            `json:"schema"`
+	Mode    string            `json:"mode"`
+	Commits []localCommitJSON `json:"commits"`
+}
 
-	switch strings.TrimSpace(args[0]) {
-	case "all":
-		if len(args) != 1 {
-			return errors.New("usage: sentra log all")
-		}
-		return runLogList("all")
-	case "pending":
-		if len(args) != 1 {
-			return errors.New("usage: sentra log pending")
-		}
-		return runLogList("pending")
-	case "pushed":
-		if len(args) != 1 {
-			return errors.New("usage: sentra log pushed")
-		}
-		return runLogList("pushed")
-	case "rm", "delete":
-		if len(args) != 2 {
-			return errors.New("usage: sentra log rm <id>")
-		}
-		return runLogDelete(strings.TrimSpace(args[1]))
-	case "clear":
-		if len(args) != 1 {
-			return errors.New("usage: sentra log clear")
-		}
-		return runLogClear()
-	case "prune":
-		if len(args) != 2 {
-			return errors.New("usage: sentra log prune <id|all>")
-		}
-		return runLogPrune(strings.TrimSpace(args[1]))
-	case "verify":
-		if len(args) != 1 {
-			return errors.New("usage: sentra log verify")
-		}
-		return runLogVerify()
-	default:
-		return errors.New("usage: sentra log [all|pending|pushed|rm <id>|clear|prune <id|all>|verify]")
-	}
+type localCommitJSON struct {
+	ID        string   `json:"id"`
+	ShortID   string   `json:"short_id"`
+	CreatedAt string   `json:"created_at"`
+	PushedAt  string   `json:"pushed_at,omitempty"`
+	Message   string   `json:"message"`
+	FileCount int      `json:"file_count"`
+	Files     []string `json:"files"`
+}
+
+type logVerifyJSON struct {
+	Schema string               `json:"schema"`
+	OK     bool                 `json:"ok"`
+	Issues []logVerifyIssueJSON `json:"issues"`
+}
+
+type logVerifyIssueJSON struct {
+	CommitID string   `json:"commit_id"`
+	Missing  []string `json:"missing"`
+}
+
+func newLogCmd() *cobra.Command {
+	const usage = "sentra log [<project>/<path>|all|pending|pushed|rm <id>|clear|prune <id|all>|verify]"
+	var ff commitFilterFlags
+	cmd := &cobra.Command{
+		Use:               "log [<project>/<path>]",
+		Short:             "Manage local commit log (default: pending), or list the remote commits of a file",
+		GroupID:           groupLocal,
+		Args:              maxArgs(1, usage),
+		Annotations:       jsonCapable(),
+		ValidArgsFunction: completeRemoteProjects,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 1 {
+				return runFileLog(args[0], ff)
+			}
+			// The commit filters only apply to a remote file's log.
+			for _, name := range []string{"limit", "cursor", "since", "until", "machine", "path", "grep"} {
+				if cmd.Flags().Changed(name) {
+					return usageError(usage)
+				}
+			}
+			return runLogList("pending")
+		},
+	}
+	ff.register(cmd)
+	_ = cmd.Flags().MarkHidden("path")
+
+	for _, mode := range []string{"all", "pending", "pushed"} {
+		cmd.AddCommand(&cobra.Command{
+			Use:         mode,
+			Short:       "List " + mode + " local commits",
+			Args:        exactArgs(0, "sentra log "+mode),
+			Annotations: jsonCapable(),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runLogList(mode)
+			},
+		})
+	}
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:               "rm <id>",
+			Aliases:           []string{"delete"},
+			Short:             "Delete a local commit",
+			Args:              exactArgs(1, "sentra log rm <id>"),
+			ValidArgsFunction: completeLocalCommitIDs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runLogDelete(strings.TrimSpace(args[0]))
+			},
+		},
+		&cobra.Command{
+			Use:   "clear",
+			Short: "Delete all local commits",
+			Args:  exactArgs(0, "sentra log clear"),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runLogClear()
+			},
+		},
+		&cobra.Command{
+			Use:               "prune <id|all>",
+			Short:             "Drop pending commits whose files are missing",
+			Args:              exactArgs(1, "sentra log prune <id|all>"),
+			ValidArgsFunction: completeLocalCommitIDs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runLogPrune(strings.TrimSpace(args[0]))
+			},
+		},
+		&cobra.Command{
+			Use:         "verify",
+			Short:       "Check pending commits for missing files",
+			Args:        exactArgs(0, "sentra log verify"),
+			Annotations: jsonCapable(),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runLogVerify()
+			},
+		},
+	)
+	return cmd
 }
 
 func runLogList(mode string) error {
@@ -72,7 +134,7 @@ func runLogList(mode string) error {
 		mode = "pending"
 	}
 
-	if len(commits) == 0 {
+	if len(commits) == 0 && !jsonOutput() {
 		if mode == "pending" {
 			fmt.Println("✔ 0 pending commits")
 			return nil
@@ -98,6 +160,15 @@ func runLogList(mode string) error {
 		}
 	}
 
+	if jsonOutput() {
+		out := logJSON{Schema: "sentra.log/v1", Mode: mode, Commits: make([]localCommitJSON, 0, len(filtered))}
+		// newest first, same as the human listing
+		for i := len(filtered) - 1; i >= 0; i-- {
+			out.Commits = append(out.Commits, toLocalCommitJSON(filtered[i]))
+		}
+		return writeJSON(out)
+	}
+
 	if len(filtered) == 0 {
 		switch mode {
 		case "pushed":
@@ -140,10 +211,27 @@ func runLogList(mode string) error {
 	return nil
 }
 
+func toLocalCommitJSON(cm commit.Commit) localCommitJSON {
+	files := make([]string, 0, len(cm.Files))
+	for p := range cm.Files {
+		files = append(files, p)
+	}
+	sort.Strings(files)
+	return localCommitJSON{
+		ID:        cm.ID,
+		ShortID:   shortCommitID(cm),
+		CreatedAt: cm.CreatedAt,
+		PushedAt:  strings.TrimSpace(cm.PushedAt),
+		Message:   strings.TrimSpace(cm.Message),
+		FileCount: len(cm.Files),
+		Files:     files,
+	}
+}
+
 func runLogDelete(selector string) error {
 	selector = strings.TrimSpace(selector)
 	if selector == "" {
-		return errors.New("usage: sentra log rm <id>")
+		return usageError("sentra log rm <id>")
 	}
 
 	commits, err := commit.List()
@@ -185,11 +273,12 @@ func runLogVerify() error {
 	if err != nil {
 		return err
 	}
-	if len(commits) == 0 {
+	if len(commits) == 0 && !jsonOutput() {
 		fmt.Println("no commits")
 		return nil
 	}
 
+	out := logVerifyJSON{Schema: "sentra.log.verify/v1", Issues: []logVerifyIssueJSON{}}
 	issues := 0
 	for _, c := range commits {
 		if strings.TrimSpace(c.PushedAt) != "" {
@@ -200,6 +289,11 @@ func runLogVerify() error {
 			continue
 		}
 		issues++
+		sort.Strings(missing)
+		if jsonOutput() {
+			out.Issues = append(out.Issues, logVerifyIssueJSON{CommitID: c.ID, Missing: missing})
+			continue
+		}
 		fmt.Printf("commit %s missing %d file(s):\n", c.ID, len(missing))
 		for _, p := range missing {
 			fmt.Printf("  %s\n", p)
@@ -207,17 +301,28 @@ func runLogVerify() error {
 		fmt.Printf("  fix: sentra log prune %s\n\n", c.ID)
 	}
 
+	if jsonOutput() {
+		out.OK = issues == 0
+		if err := writeJSON(out); err != nil {
+			return err
+		}
+		if issues == 0 {
+			return nil
+		}
+		return withExitCode(ExitCheckFailed, errors.New("missing files detected in pending commits"))
+	}
+
 	if issues == 0 {
 		fmt.Println("✔ all pending commits are readable")
 		return nil
 	}
-	return errors.New("missing files detected in pending commits")
+	return withExitCode(ExitCheckFailed, errors.New("missing files detected in pending commits"))
 }
 
 func runLogPrune(selector string) error {
 	selector = strings.TrimSpace(selector)
 	if selector == "" {
-		return errors.New("usage: sentra log prune <id|all>")
+		return usageError("sentra log prune <id|all>")
 	}
 
 	scanRoot, err := resolveScanRootFromIndex()
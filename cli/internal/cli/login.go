@@ -18,9 +18,43 @@ import (
 
 	"github.com/mgeovany/sentra/cli/internal/auth"
 	"github.com/mgeovany/sentra/cli/internal/storage"
+	"github.com/spf13/cobra"
 )
 
-func runLogin() error {
+func newLoginCmd() *cobra.Command {
+	var device bool
+	cmd := &cobra.Command{
+		Use:     "login",
+		Short:   "Login and create a session",
+		GroupID: groupAuth,
+		Args:    exactArgs(0, "sentra login [--device]"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runLogin(device)
+		},
+	}
+	cmd.Flags().BoolVar(&device, "device", false, "Approve the login from a browser on any machine (SSH, containers)")
+	return cmd
+}
+
+func runLogin(device bool) error {
+	var (
+		tr  auth.TokenResponse
+		err error
+	)
+	if device {
+		tr, err = loginWithDeviceCode()
+	} else {
+		tr, err = loginWithBrowser()
+	}
+	if err != nil {
+		return err
+	}
+	return finishLogin(tr)
+}
+
+// loginWithBrowser runs the PKCE flow with a callback listener on 127.0.0.1,
+// so the browser must run on this machine.
+func loginWithBrowser() (auth.TokenResponse, error) {
 	auth.LoadDotEnv()
 
 	supabaseURL := strings.TrimSpace(os.Getenv("SUPABASE_URL"))
@@ -34,7 +68,7 @@ func runLogin() error {
 
 	verifier, err := auth.NewCodeVerifier()
 	if err != nil {
-		return err
+		return auth.TokenResponse{}, err
 	}
 	challenge := auth.CodeChallengeS256(verifier)
 
@@ -43,7 +77,7 @@ func runLogin() error {
 	if v := strings.TrimSpace(os.Getenv("SENTRA_AUTH_PORT")); v != "" {
 		p, err := strconv.Atoi(v)
 		if err != nil {
-			return fmt.Errorf("invalid auth port: %w", err)
+			return auth.TokenResponse{}, fmt.Errorf("invalid auth port: %w", err)
 		}
 		port = p
 	}
@@ -51,19 +85,19 @@ func runLogin() error {
 	addr := fmt.Sprintf("127.0.0.1:%d", port)
 	ln, err := net.Listen("tcp", addr)
 	if err != nil {
-		return fmt.Errorf("cannot listen on %s: %w", addr, err)
+		return auth.TokenResponse{}, fmt.Errorf("cannot listen on %s: %w", addr, err)
 	}
 	defer func() { _ = ln.Close() }()
 
 	// Resolve the actual port (important when using 0).
 	tcpAddr, ok := ln.Addr().(*net.TCPAddr)
 	if !ok || tcpAddr == nil || tcpAddr.Port == 0 {
-		return fmt.Errorf("cannot resolve listener port")
+		return auth.TokenResponse{}, fmt.Errorf("cannot resolve listener port")
 	}
 
 	nonce, err := auth.NewState()
 	if err != nil {
-		return err
+		return auth.TokenResponse{}, err
 	}
 
 	redirectToURL := &url.URL{
@@ -79,7 +113,7 @@ func runLogin() error {
 	oauth := auth.SupabaseOAuth{SupabaseURL: supabaseURL, AnonKey: anonKey, Provider: "google"}
 	authURL, err := oauth.AuthorizeURL(redirectTo, challenge)
 	if err != nil {
-		return err
+		return auth.TokenResponse{}, err
 	}
 
 	fmt.Println()
@@ -93,6 +127,8 @@ func runLogin() error {
 		fmt.Println(c(ansiDim, "Please open this link in your browser:"))
 		fmt.Println()
 		fmt.Println(c(ansiCyan, authURL))
+		fmt.Println()
+		fmt.Println(c(ansiDim, "No browser on this machine? Run: sentra login --device"))
 	}
 	fmt.Println()
 
@@ -191,11 +227,11 @@ func runLogin() error {
 	case err := <-errCh:
 		sp.StopInfo("")
 		_ = srv.Shutdown(context.Background())
-		return err
+		return auth.TokenResponse{}, err
 	case <-ctx.Done():
 		sp.StopInfo("")
 		_ = srv.Shutdown(context.Background())
-		return errors.New("login timed out")
+		return auth.TokenResponse{}, errors.New("login timed out")
 	}
 
 	_ = srv.Shutdown(context.Background())
@@ -207,11 +243,11 @@ func runLogin() error {
 
 	exchangeCtx, cancelExchange := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancelExchange()
-	tr, err := oauth.ExchangePKCE(exchangeCtx, authCode, verifier)
-	if err != nil {
-		return err
-	}
+	return oauth.ExchangePKCE(exchangeCtx, authCode, verifier)
+}
 
+// finishLogin persists the session and runs the one-time setup shared by every login method.
+func finishLogin(tr auth.TokenResponse) error {
 	if err := auth.SaveSession(auth.Session{
 		AccessToken:  tr.AccessToken,
 		RefreshToken: tr.RefreshToken,
@@ -263,7 +299,7 @@ func runLogin() error {
 		fmt.Println(c(ansiBoldCyan, "Choose storage mode for encrypted .env blobs"))
 		choice, err := promptSelect(r, []string{
 			"Use Sentra storage (recommended)",
-			"Use my storage (AWS S3 / Cloudflare R2 / MinIO / custom S3)",
+			"Use my storage (S3-compatible, local/NAS directory, WebDAV, SFTP)",
 		})
 		if err != nil {
 			return err
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/mgeovany/sentra/cli/internal/auth"
+)
+
+// loginWithDeviceCode lets the user approve the login from a browser on any
+// machine: the CLI shows a short code and polls the server until it is approved.
+func loginWithDeviceCode() (auth.TokenResponse, error) {
+	serverURL, err := serverURLFromEnv()
+	if err != nil {
+		return auth.TokenResponse{}, err
+	}
+	flow := auth.DeviceFlow{ServerURL: serverURL}
+
+	startCtx, cancelStart := context.WithTimeout(commandContext(), 15*time.Second)
+	defer cancelStart()
+	da, err := flow.Start(startCtx)
+	if err != nil {
+		return auth.TokenResponse{}, err
+	}
+
+	fmt.Println()
+	fmt.Println(c(ansiBoldCyan, "Login"))
+	fmt.Println()
+	fmt.Println(c(ansiDim, "On any device, open:"))
+	fmt.Println()
+	fmt.Println(c(ansiCyan, da.VerificationURI))
+	fmt.Println()
+	fmt.Println(c(ansiDim, "and enter the code:"))
+	fmt.Println()
+	fmt.Println(c(ansiBoldCyan, da.UserCode))
+	fmt.Println()
+	verbosef("Direct link: %s", da.VerificationURIComplete)
+
+	interval := time.Duration(da.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	expiresIn := time.Duration(da.ExpiresIn) * time.Second
+	if expiresIn <= 0 {
+		expiresIn = 10 * time.Minute
+	}
+	ctx, cancel := context.WithTimeout(commandContext(), expiresIn)
+	defer cancel()
+
+	sp := startSpinner("Waiting for approval...")
+	for {
+		select {
+		case <-ctx.Done():
+			sp.StopInfo("")
+			return auth.TokenResponse{}, errors.New("login timed out (code expired)")
+		case <-time.After(interval):
+		}
+
+		tr, err := flow.Poll(ctx, da.DeviceCode)
+		switch {
+		case err == nil:
+			sp.StopSuccess("✔ Authentication successful")
+			return tr, nil
+		case errors.Is(err, auth.ErrAuthorizationPending):
+		case errors.Is(err, auth.ErrSlowDown):
+			interval += 5 * time.Second
+			verbosef("Server asked to slow down; polling every %s", interval)
+		case errors.Is(err, auth.ErrDeviceCodeExpired):
+			sp.StopInfo("")
+			return auth.TokenResponse{}, errors.New("login timed out (code expired)")
+		case errors.Is(err, auth.ErrAccessDenied):
+			sp.StopInfo("")
+			return auth.TokenResponse{}, errors.New("login was denied in the browser")
+		default:
+			var netErr *url.Error
+			if !errors.As(err, &netErr) {
+				sp.StopInfo("")
+				return auth.TokenResponse{}, err
+			}
+			// Transient network errors: keep polling until the code expires.
+			verbosef("Poll failed: %v", err)
+		}
+	}
+}
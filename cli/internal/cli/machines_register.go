@@ -13,6 +13,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/mgeovany/sentra/cli/internal/auth"
+	"github.com/mgeovany/sentra/shared/api"
 )
 
 type registerMachineRequest struct {
@@ -42,7 +43,7 @@ func registerMachine(ctx context.Context, accessToken string) error {
 		return err
 	}
 
-	endpoint := serverURL + "/machines/register"
+	endpoint := serverURL + api.PathMachinesRegister
 
 	pub, err := auth.GetOrCreateDevicePublicKey()
 	if err != nil {
@@ -71,7 +72,7 @@ func registerMachine(ctx context.Context, accessToken string) error {
 	// Device binding: sign this request with the device key.
 	ts := fmt.Sprintf("%d", time.Now().UTC().Unix())
 	nonce := uuid.NewString()
-	sig, err := auth.SignDeviceRequest(cfg.MachineID, ts, nonce, http.MethodPost, "/machines/register", b)
+	sig, err := auth.SignDeviceRequest(cfg.MachineID, ts, nonce, http.MethodPost, api.PathMachinesRegister, b)
 	if err != nil {
 		return err
 	}
@@ -80,7 +81,7 @@ func registerMachine(ctx context.Context, accessToken string) error {
 	req.Header.Set("X-Sentra-Nonce", nonce)
 	req.Header.Set("X-Sentra-Signature", sig)
 
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := newHTTPClient(10 * time.Second)
 	resp, err := client.Do(req)
 	if err != nil {
 		return err
@@ -93,7 +94,7 @@ func registerMachine(ctx context.Context, accessToken string) error {
 		if msg == "" {
 			msg = strings.TrimSpace(http.StatusText(resp.StatusCode))
 		}
-		if tr := responseTrace(resp); tr != "" {
+		if tr := responseTrace(resp.Header); tr != "" {
 			msg = msg + "; " + tr
 		}
 		if isVerbose() {
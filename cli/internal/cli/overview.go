@@ -11,6 +11,7 @@ import (
 	"github.com/mgeovany/sentra/cli/internal/index"
 	"github.com/mgeovany/sentra/cli/internal/scanner"
 	"github.com/mgeovany/sentra/cli/internal/state"
+	"github.com/spf13/cobra"
 )
 
 type projectOverview struct {
@@ -24,18 +25,44 @@ type projectOverview struct {
 	TotalBytes   int64
 }
 
-func runOverview(args []string) error {
-	if len(args) != 0 {
-		return fmt.Errorf("usage: sentra overview")
+type overviewJSON struct {
+	Schema   string                `json:"schema"`
+	ScanRoot string                `json:"scan_root"`
+	Projects []overviewProjectJSON `json:"projects"`
+}
+
+type overviewProjectJSON struct {
+	Root         string `json:"root"`
+	EnvCount     int    `json:"env_count"`
+	TrackedCount int    `json:"tracked_count"`
+	StagedCount  int    `json:"staged_count"`
+	ChangedCount int    `json:"changed_count"`
+	LatestFile   string `json:"latest_file,omitempty"`
+	LatestAt     string `json:"latest_at,omitempty"`
+	TotalBytes   int64  `json:"total_bytes"`
+}
+
+func newOverviewCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:         "overview",
+		Short:       "Summarize env files per project under scan root",
+		GroupID:     groupLocal,
+		Args:        exactArgs(0, "sentra overview"),
+		Annotations: jsonCapable(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runOverview()
+		},
 	}
+}
 
+func runOverview() error {
 	scanRoot, err := resolveScanRoot()
 	if err != nil {
 		return err
 	}
 
 	sp := startSpinner("Building project overview...")
-	projects, err := scanner.Scan(scanRoot)
+	projects, err := scanProjects(scanRoot)
 	if err != nil {
 		sp.StopInfo("")
 		return err
@@ -54,6 +81,26 @@ func runOverview(args []string) error {
 	}
 	sp.StopSuccess(fmt.Sprintf("✔ %d project(s)", len(items)))
 
+	if jsonOutput() {
+		out := overviewJSON{Schema: "sentra.overview/v1", ScanRoot: scanRoot, Projects: make([]overviewProjectJSON, 0, len(items))}
+		for _, it := range items {
+			p := overviewProjectJSON{
+				Root:         it.Root,
+				EnvCount:     it.EnvCount,
+				TrackedCount: it.TrackedCount,
+				StagedCount:  it.StagedCount,
+				ChangedCount: it.ChangedCount,
+				LatestFile:   it.LatestFile,
+				TotalBytes:   it.TotalBytes,
+			}
+			if !it.LatestAt.IsZero() {
+				p.LatestAt = it.LatestAt.UTC().Format(time.RFC3339)
+			}
+			out.Projects = append(out.Projects, p)
+		}
+		return writeJSON(out)
+	}
+
 	if len(items) == 0 {
 		infof("No git repos found under %s", scanRoot)
 		return nil
@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mgeovany/sentra/shared/api"
+)
+
+// pacer spaces out requests to a server route whose last response reported
+// an empty rate limit bucket (X-RateLimit-Remaining: 0). Parallel push
+// workers then take turns at the refill rate instead of collecting 429s.
+type pacer struct {
+	mu     sync.Mutex
+	routes map[string]*paceState
+}
+
+type paceState struct {
+	next     time.Time
+	interval time.Duration
+}
+
+var serverPacer = &pacer{routes: make(map[string]*paceState)}
+
+// paced wraps base (http.DefaultTransport when nil) with serverPacer.
+func paced(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &pacedTransport{base: base, p: serverPacer}
+}
+
+type pacedTransport struct {
+	base http.RoundTripper
+	p    *pacer
+}
+
+func (t *pacedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := paceKey(req.URL)
+	if wait := t.p.reserve(key, time.Now()); wait > 0 {
+		verbosef("Rate limit reached on %s, waiting %v", key, wait.Round(time.Millisecond))
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err == nil {
+		t.p.observe(key, resp.Header, time.Now())
+	}
+	return resp, err
+}
+
+// paceKey groups requests the way the server buckets them: per host and
+// top-level route ("/v1/blobs/<root>/<id>" counts as "/blobs").
+func paceKey(u *url.URL) string {
+	seg, _, _ := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(u.Path, api.Prefix), "/"), "/")
+	return u.Host + "/" + seg
+}
+
+// reserve returns how long a request to key must wait, and books the next
+// slot one token interval later.
+func (p *pacer) reserve(key string, now time.Time) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s, ok := p.routes[key]
+	if !ok {
+		return 0
+	}
+	start := s.next
+	if start.Before(now) {
+		start = now
+	}
+	s.next = start.Add(s.interval)
+	return start.Sub(now)
+}
+
+// observe reads the X-RateLimit-* headers of a response. Reset is the time
+// until the bucket holds Limit tokens again, so with none left one token
+// takes Reset/Limit.
+func (p *pacer) observe(key string, h http.Header, now time.Time) {
+	remaining, err := strconv.Atoi(strings.TrimSpace(h.Get("X-RateLimit-Remaining")))
+	if err != nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if remaining > 0 {
+		delete(p.routes, key)
+		return
+	}
+	limit, _ := strconv.Atoi(strings.TrimSpace(h.Get("X-RateLimit-Limit")))
+	reset, _ := strconv.Atoi(strings.TrimSpace(h.Get("X-RateLimit-Reset")))
+	if limit <= 0 || reset <= 0 {
+		return
+	}
+	interval := time.Duration(reset) * time.Second / time.Duration(limit)
+	if s, ok := p.routes[key]; ok {
+		s.interval = interval
+		return
+	}
+	p.routes[key] = &paceState{next: now.Add(interval), interval: interval}
+}
@@ -0,0 +1,259 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/mgeovany/sentra/cli/internal/auth"
+	"github.com/mgeovany/sentra/cli/internal/profile"
+	"github.com/spf13/cobra"
+	"github.com/zalando/go-keyring"
+)
+
+type profilesJSON struct {
+	Schema   string        `json:"schema"`
+	Active   string        `json:"active"`
+	Profiles []profileJSON `json:"profiles"`
+}
+
+type profileJSON struct {
+	Name      string `json:"name"`
+	Active    bool   `json:"active"`
+	Dir       string `json:"dir"`
+	ServerURL string `json:"server_url,omitempty"`
+	UserID    string `json:"user_id,omitempty"`
+}
+
+func newProfileCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:         "profile",
+		Short:       "Manage named profiles (accounts/servers)",
+		GroupID:     groupAuth,
+		Args:        exactArgs(0, "sentra profile [ls|use|add|rm]"),
+		Annotations: jsonCapable(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runProfileList()
+		},
+	}
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:         "ls",
+			Aliases:     []string{"list"},
+			Short:       "List profiles",
+			Args:        exactArgs(0, "sentra profile ls"),
+			Annotations: jsonCapable(),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runProfileList()
+			},
+		},
+		&cobra.Command{
+			Use:               "use <name>",
+			Short:             "Make a profile the default for future commands",
+			Args:              exactArgs(1, "sentra profile use <name>"),
+			ValidArgsFunction: completeProfiles,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runProfileUse(args[0])
+			},
+		},
+		&cobra.Command{
+			Use:   "add <name>",
+			Short: "Create a profile (use --server to pin its server URL)",
+			Args:  exactArgs(1, "sentra profile add <name> [--server <url>]"),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runProfileAdd(args[0], globals.Server)
+			},
+		},
+		&cobra.Command{
+			Use:               "rm <name>",
+			Aliases:           []string{"remove"},
+			Short:             "Delete a profile and its local state",
+			Args:              exactArgs(1, "sentra profile rm <name>"),
+			ValidArgsFunction: completeProfiles,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runProfileRemove(args[0])
+			},
+		},
+	)
+	return cmd
+}
+
+func isProfileCmd(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		if c.Name() == "profile" && c.Parent() != nil && !c.Parent().HasParent() {
+			return true
+		}
+	}
+	return false
+}
+
+func runProfileList() error {
+	names, err := profile.List()
+	if err != nil {
+		return err
+	}
+	active := profile.Active()
+
+	out := profilesJSON{Schema: "sentra.profiles/v1", Active: active, Profiles: make([]profileJSON, 0, len(names))}
+	for _, name := range names {
+		dir, err := profile.DirFor(name)
+		if err != nil {
+			return err
+		}
+		p := profileJSON{Name: name, Active: name == active, Dir: dir}
+		if cfg, ok, err := loadProfileConfig(name); err == nil && ok {
+			p.ServerURL = strings.TrimSpace(cfg.ServerURL)
+			p.UserID = strings.TrimSpace(cfg.UserID)
+		}
+		out.Profiles = append(out.Profiles, p)
+	}
+
+	if jsonOutput() {
+		return writeJSON(out)
+	}
+
+	for _, p := range out.Profiles {
+		marker := "  "
+		name := p.Name
+		if p.Active {
+			marker = c(ansiGreen, "* ")
+			name = c(ansiBoldCyan, name)
+		}
+		server := p.ServerURL
+		if server == "" {
+			server = "(default server)"
+		}
+		fmt.Printf("%s%s\t%s\n", marker, name, c(ansiDim, server))
+	}
+	return nil
+}
+
+func runProfileUse(name string) error {
+	name = strings.TrimSpace(name)
+	if err := profile.Use(name); err != nil {
+		return err
+	}
+	successf("✔ now using profile %s", name)
+	if env := strings.TrimSpace(globals.Profile); env == "" {
+		if v := profile.Active(); v != name {
+			warnf("SENTRA_PROFILE=%s still overrides this in the current shell", v)
+		}
+	}
+	return nil
+}
+
+func runProfileAdd(name string, serverURL string) error {
+	name = strings.TrimSpace(name)
+	serverURL = strings.TrimSpace(serverURL)
+	if serverURL != "" {
+		v, err := validateServerURL(serverURL)
+		if err != nil {
+			return err
+		}
+		// Saved loopback URLs are ignored by serverURLFromEnv; don't pretend to pin one.
+		if u, err := url.Parse(v); err == nil && isLoopbackHost(u.Hostname()) {
+			return errors.New("loopback server URLs are not saved; use --server or SENTRA_SERVER_URL per command")
+		}
+		serverURL = v
+	}
+
+	if err := profile.Add(name); err != nil {
+		return err
+	}
+
+	if serverURL != "" {
+		err := withProfile(name, func() error {
+			cfg, err := auth.EnsureConfig()
+			if err != nil {
+				return err
+			}
+			cfg.ServerURL = serverURL
+			return auth.SaveConfig(cfg)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	successf("✔ added profile %s", name)
+	fmt.Printf("Next: sentra --profile %s login   (or: sentra profile use %s)\n", name, name)
+	return nil
+}
+
+func runProfileRemove(name string) error {
+	name = strings.TrimSpace(name)
+	if name == profile.Default {
+		return errors.New("cannot remove the default profile (use: sentra wipe)")
+	}
+	ok, err := profile.Exists(name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("unknown profile: %s", name)
+	}
+	dir, err := profile.DirFor(name)
+	if err != nil {
+		return err
+	}
+
+	if err := wipeProfile(name, dir); err != nil {
+		return err
+	}
+	if profile.Current() == name {
+		if err := profile.Use(profile.Default); err != nil {
+			return err
+		}
+		infof("Switched back to the default profile")
+	}
+	successf("✔ removed profile %s", name)
+	return nil
+}
+
+// withProfile runs fn with name as the active profile, then restores --profile.
+func withProfile(name string, fn func() error) error {
+	profile.SetOverride(name)
+	defer applyProfileFlag()
+	return fn()
+}
+
+func loadProfileConfig(name string) (auth.Config, bool, error) {
+	var (
+		cfg auth.Config
+		ok  bool
+	)
+	err := withProfile(name, func() error {
+		var err error
+		cfg, ok, err = auth.LoadConfig()
+		return err
+	})
+	return cfg, ok, err
+}
+
+func completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	names, err := profile.List()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var out []cobra.Completion
+	for _, n := range names {
+		if strings.HasPrefix(n, toComplete) {
+			out = append(out, n)
+		}
+	}
+	return out, cobra.ShellCompDirectiveNoFileComp
+}
+
+// deleteVaultKeyCache drops the cached vault key of the profile's user, if any.
+func deleteVaultKeyCache(name string) {
+	cfg, ok, err := loadProfileConfig(name)
+	if err != nil || !ok || strings.TrimSpace(cfg.UserID) == "" {
+		return
+	}
+	_ = keyring.Delete(profile.KeyringServiceFor(name), vaultKeychainUser(cfg.UserID))
+}
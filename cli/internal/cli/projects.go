@@ -1,33 +1,46 @@
 package cli
 
 import (
-	"context"
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/mgeovany/sentra/shared/api"
+	"github.com/spf13/cobra"
 	"golang.org/x/term"
 )
 
-type remoteProject struct {
-	RootPath          string `json:"root_path"`
-	LastCommitID      string `json:"last_commit_id"`
-	LastCommitMessage string `json:"last_commit_message"`
-	FileCount         int    `json:"file_count"`
+type projectsJSON struct {
+	Schema   string        `json:"schema"`
+	Projects []api.Project `json:"projects"`
 }
 
-func runProjects() error {
+func newProjectsCmd() *cobra.Command {
+	var all bool
+	cmd := &cobra.Command{
+		Use:         "projects",
+		Short:       "List and manage remote projects",
+		GroupID:     groupRemote,
+		Args:        exactArgs(0, "sentra projects [--all] | rename|rm|archive|unarchive <project>"),
+		Annotations: jsonCapable(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runProjects(all)
+		},
+	}
+	cmd.Flags().BoolVar(&all, "all", false, "Include archived projects")
+	cmd.AddCommand(newProjectsManageCmds()...)
+	return cmd
+}
+
+func runProjects(all bool) error {
 	verbosef("Fetching projects from remote...")
 	sess, err := ensureRemoteSession()
 	if err != nil {
 		return err
 	}
 	if strings.TrimSpace(sess.AccessToken) == "" {
-		return fmt.Errorf("not logged in (run: sentra login)")
+		return errNotLoggedIn
 	}
 	verbosef("Session loaded: user authenticated")
 
@@ -40,43 +53,41 @@ func runProjects() error {
 	}
 	verbosef("Server URL: %s", serverURL)
 
-	endpoint := serverURL + "/projects"
-	verbosef("Projects endpoint: %s", endpoint)
-	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, endpoint, nil)
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(sess.AccessToken))
-
-	client := &http.Client{Timeout: 15 * time.Second}
+	verbosef("Projects endpoint: %s", serverURL+api.PathProjects)
 	startTime := time.Now()
-	resp, err := client.Do(req)
+	projects, err := apiClient(serverURL, sess.AccessToken, 15*time.Second).Projects(commandContext())
 	elapsed := time.Since(startTime)
 	if err != nil {
 		sp.StopInfo("")
 		verbosef("Request failed after %v: %v", elapsed, err)
+		if api.StatusCode(err) != 0 {
+			return fmt.Errorf("failed to fetch projects")
+		}
 		return err
 	}
-	defer func() { _ = resp.Body.Close() }()
-	verbosef("Response received: status=%d, elapsed=%v", resp.StatusCode, elapsed)
-
-	body, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		sp.StopInfo("")
-		verbosef("Response body: %s", string(body))
-		return fmt.Errorf("failed to fetch projects")
-	}
-	verbosef("Response body size: %d bytes", len(body))
-
-	var projects []remoteProject
-	if err := json.Unmarshal(body, &projects); err != nil {
-		sp.StopInfo("")
-		return err
+	verbosef("Response received: elapsed=%v", elapsed)
+	if !all {
+		listed := projects[:0]
+		for _, p := range projects {
+			if p.ArchivedAt == nil {
+				listed = append(listed, p)
+			}
+		}
+		if hidden := len(projects) - len(listed); hidden > 0 {
+			verbosef("Hiding %d archived project(s) (use --all)", hidden)
+		}
+		projects = listed
 	}
 	sp.StopSuccess(fmt.Sprintf("✔ %d project(s)", len(projects)))
 	verbosef("Parsed %d project(s) from response", len(projects))
 
+	if jsonOutput() {
+		if projects == nil {
+			projects = []api.Project{}
+		}
+		return writeJSON(projectsJSON{Schema: "sentra.projects/v1", Projects: projects})
+	}
+
 	if len(projects) == 0 {
 		// spinner already printed count
 		return nil
@@ -115,7 +126,7 @@ func runProjects() error {
 	return nil
 }
 
-func printProjectsTable(projects []remoteProject) {
+func printProjectsTable(projects []api.Project) {
 	// Header
 	fmt.Println(c(ansiBoldCyan, "sentra projects"))
 
@@ -130,10 +141,7 @@ func printProjectsTable(projects []remoteProject) {
 
 	projW := 10
 	for _, p := range projects {
-		root := strings.TrimSpace(p.RootPath)
-		if root == "" {
-			root = "(unknown)"
-		}
+		root := projectLabel(p)
 		if len(root) > projW {
 			projW = len(root)
 		}
@@ -168,10 +176,7 @@ func printProjectsTable(projects []remoteProject) {
 	fmt.Println(c(ansiDim, strings.Repeat("-", minInt(width, len(header)))))
 
 	for _, p := range projects {
-		root := strings.TrimSpace(p.RootPath)
-		if root == "" {
-			root = "(unknown)"
-		}
+		root := projectLabel(p)
 		last := strings.TrimSpace(p.LastCommitID)
 		if last == "" {
 			last = "-"
@@ -208,6 +213,22 @@ func printProjectsTable(projects []remoteProject) {
 	}
 }
 
+// projectLabel is the root as shown to humans: with its display name, and
+// marked when archived.
+func projectLabel(p api.Project) string {
+	label := strings.TrimSpace(p.RootPath)
+	if label == "" {
+		label = "(unknown)"
+	}
+	if name := strings.TrimSpace(p.DisplayName); name != "" {
+		label = name + " (" + label + ")"
+	}
+	if p.ArchivedAt != nil {
+		label += " [archived]"
+	}
+	return label
+}
+
 func padRight(s string, n int) string {
 	if len(s) >= n {
 		return s
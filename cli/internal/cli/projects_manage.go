@@ -0,0 +1,258 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mgeovany/sentra/cli/internal/storage"
+	"github.com/mgeovany/sentra/shared/api"
+	"github.com/spf13/cobra"
+)
+
+type projectJSON struct {
+	Schema string `json:"schema"`
+	api.Project
+}
+
+type projectDeletionJSON struct {
+	Schema string `json:"schema"`
+	api.ProjectDeletion
+	// StorageDeleted counts the BYOS objects this CLI deleted from the
+	// configured storage.
+	StorageDeleted int `json:"storage_deleted"`
+}
+
+const renameUsage = "sentra projects rename <project> [<new-root>] [--display-name <name>]"
+
+func newProjectsManageCmds() []*cobra.Command {
+	var displayName string
+	rename := &cobra.Command{
+		Use:               "rename <project> [<new-root>]",
+		Short:             "Rename a remote project (e.g. after moving its directory)",
+		Args:              maxArgs(2, renameUsage),
+		Annotations:       jsonCapable(),
+		ValidArgsFunction: completeRemoteProjects,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var patch api.ProjectPatch
+			if len(args) == 2 {
+				root := strings.TrimSpace(projectRootFromPath(args[1]))
+				if root == "" {
+					return usageError(renameUsage)
+				}
+				patch.Root = &root
+			}
+			if cmd.Flags().Changed("display-name") {
+				name := strings.TrimSpace(displayName)
+				patch.DisplayName = &name
+			}
+			if len(args) == 0 || (patch.Root == nil && patch.DisplayName == nil) {
+				return usageError(renameUsage)
+			}
+			return runProjectUpdate(args[0], patch)
+		},
+	}
+	rename.Flags().StringVar(&displayName, "display-name", "", "Label shown instead of the root (empty clears it)")
+
+	var yes bool
+	rm := &cobra.Command{
+		Use:               "rm <project>",
+		Aliases:           []string{"delete"},
+		Short:             "Delete a remote project with all its commits and files",
+		Args:              exactArgs(1, "sentra projects rm <project> [--yes]"),
+		Annotations:       jsonCapable(),
+		ValidArgsFunction: completeRemoteProjects,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runProjectDelete(args[0], yes)
+		},
+	}
+	rm.Flags().BoolVarP(&yes, "yes", "y", false, "Do not ask for confirmation")
+
+	archived := func(use string, short string, archive bool) *cobra.Command {
+		return &cobra.Command{
+			Use:               use + " <project>",
+			Short:             short,
+			Args:              exactArgs(1, "sentra projects "+use+" <project>"),
+			Annotations:       jsonCapable(),
+			ValidArgsFunction: completeRemoteProjects,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runProjectUpdate(args[0], api.ProjectPatch{Archived: &archive})
+			},
+		}
+	}
+
+	return []*cobra.Command{
+		rename,
+		rm,
+		archived("archive", "Hide a remote project from `sentra projects`, keeping its history", true),
+		archived("unarchive", "List an archived project again", false),
+	}
+}
+
+// resolveRemoteProject finds the remote project pushed as root.
+func resolveRemoteProject(client *api.Client, root string) (api.Project, error) {
+	projects, err := client.Projects(commandContext())
+	if err != nil {
+		if api.StatusCode(err) != 0 {
+			return api.Project{}, fmt.Errorf("failed to fetch projects")
+		}
+		return api.Project{}, err
+	}
+	for _, p := range projects {
+		if strings.TrimSpace(p.RootPath) != root {
+			continue
+		}
+		if strings.TrimSpace(p.ID) == "" {
+			return api.Project{}, errors.New("server does not report project ids (update the server)")
+		}
+		return p, nil
+	}
+	return api.Project{}, fmt.Errorf("no remote project %q (see: sentra projects --all)", root)
+}
+
+func runProjectUpdate(project string, patch api.ProjectPatch) error {
+	root := strings.TrimSpace(projectRootFromPath(project))
+	if root == "" {
+		return usageError("sentra projects rename|archive|unarchive <project>")
+	}
+	serverURL, accessToken, _, err := storageUserSession("managing projects")
+	if err != nil {
+		return err
+	}
+	client := apiClient(serverURL, accessToken, 20*time.Second)
+
+	p, err := resolveRemoteProject(client, root)
+	if err != nil {
+		return err
+	}
+	updated, err := client.UpdateProject(commandContext(), p.ID, patch)
+	if err != nil {
+		switch api.StatusCode(err) {
+		case 0:
+			return err
+		case 409:
+			if patch.Root != nil {
+				return fmt.Errorf("a remote project named %q already exists", *patch.Root)
+			}
+		}
+		var apiErr *api.Error
+		if errors.As(err, &apiErr) {
+			return fmt.Errorf("project update failed: %s", oneLine(apiErr.Message()))
+		}
+		return err
+	}
+
+	if jsonOutput() {
+		return writeJSON(projectJSON{Schema: "sentra.project/v1", Project: updated})
+	}
+	switch {
+	case patch.Root != nil:
+		successf("✔ renamed %s to %s", root, updated.RootPath)
+		infof("Push from the renamed directory to continue its history.")
+	case patch.Archived != nil && *patch.Archived:
+		successf("✔ archived %s", root)
+	case patch.Archived != nil:
+		successf("✔ unarchived %s", root)
+	}
+	if patch.DisplayName != nil {
+		if name := strings.TrimSpace(updated.DisplayName); name != "" {
+			successf("✔ %s is now shown as %q", updated.RootPath, name)
+		} else {
+			successf("✔ cleared the display name of %s", updated.RootPath)
+		}
+	}
+	return nil
+}
+
+func runProjectDelete(project string, yes bool) error {
+	root := strings.TrimSpace(projectRootFromPath(project))
+	if root == "" {
+		return usageError("sentra projects rm <project> [--yes]")
+	}
+	serverURL, accessToken, _, err := storageUserSession("managing projects")
+	if err != nil {
+		return err
+	}
+	client := apiClient(serverURL, accessToken, 60*time.Second)
+
+	p, err := resolveRemoteProject(client, root)
+	if err != nil {
+		return err
+	}
+
+	if !yes {
+		if !isTTY(os.Stdin) {
+			return errors.New("refusing to delete without a TTY (use --yes)")
+		}
+		fmt.Printf("This deletes remote project %s: every commit and %d file(s) of its latest commit. Local files are kept.\n", root, p.FileCount)
+		ok, err := promptYesNo(bufio.NewReader(os.Stdin), fmt.Sprintf("Delete %s?", root), false)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("delete cancelled")
+		}
+	}
+
+	deleted, err := client.DeleteProject(commandContext(), p.ID)
+	if err != nil {
+		if api.StatusCode(err) != 0 {
+			return fmt.Errorf("failed to delete project")
+		}
+		return err
+	}
+
+	removed, left := deleteStorageObjects(deleted.Storage)
+	if jsonOutput() {
+		if deleted.Storage == nil {
+			deleted.Storage = []api.StorageObject{}
+		}
+		return writeJSON(projectDeletionJSON{Schema: "sentra.project_deletion/v1", ProjectDeletion: deleted, StorageDeleted: removed})
+	}
+
+	successf("✔ deleted %s (%d commit(s), %d file(s))", root, deleted.Commits, deleted.Files)
+	if removed > 0 {
+		successf("✔ deleted %d object(s) from storage", removed)
+	}
+	if len(left) > 0 {
+		warnf("%d stored object(s) are no longer referenced; delete them from your storage:", len(left))
+		for _, o := range left {
+			fmt.Printf("  %s %s/%s\n", o.Provider, o.Bucket, o.Key)
+		}
+		infof("If this is your configured storage, `sentra storage gc` deletes them too.")
+	}
+	return nil
+}
+
+// deleteStorageObjects deletes the objects that live in the configured BYOS
+// storage and returns how many it deleted and the ones it could not reach.
+func deleteStorageObjects(objects []api.StorageObject) (int, []api.StorageObject) {
+	if len(objects) == 0 {
+		return 0, nil
+	}
+	blobs, enabled, err := storage.Open()
+	if err != nil || !enabled {
+		return 0, objects
+	}
+	loc := blobs.Location()
+
+	ctx := commandContext()
+	var removed int
+	var left []api.StorageObject
+	for _, o := range objects {
+		if o.Provider != loc.Provider || o.Bucket != loc.Bucket {
+			left = append(left, o)
+			continue
+		}
+		if err := blobs.Delete(ctx, o.Key); err != nil {
+			verbosef("delete failed %s: %v", o.Key, err)
+			left = append(left, o)
+			continue
+		}
+		removed++
+	}
+	return removed, left
+}
@@ -1,23 +1,27 @@
 package cli
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
+	"slices"
 	"sort"
-	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/mgeovany/sentra/cli/internal/auth"
 	"github.com/mgeovany/sentra/cli/internal/commit"
 	"github.com/mgeovany/sentra/cli/internal/storage"
-	"github.com/minio/minio-go/v7"
+	"github.com/mgeovany/sentra/shared/api"
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func oneLine(s string) string {
@@ -39,7 +43,81 @@ func oneLine(s string) string {
 	return s
 }
 
-func runPush() error {
+const (
+	pushUsage       = "sentra push [--project <root>]... [--path <glob>] [--env <name>] [--jobs N] [--dry-run]"
+	defaultPushJobs = 4
+)
+
+func newPushCmd() *cobra.Command {
+	var jobs int
+	var dryRun bool
+	var ff filterFlags
+	cmd := &cobra.Command{
+		Use:     "push",
+		Short:   "Push pending local commits to remote",
+		GroupID: groupRemote,
+		Args:    exactArgs(0, pushUsage),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filter, err := ff.filter()
+			if err != nil {
+				return err
+			}
+			return runPush(jobs, filter, dryRun)
+		},
+	}
+	cmd.Flags().IntVarP(&jobs, "jobs", "j", defaultPushJobs, "Projects and uploads to push in parallel")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List the commits and files that would be pushed without uploading anything")
+	ff.register(cmd)
+	return cmd
+}
+
+// pushStep is one commit's files under one project root.
+type pushStep struct {
+	commit commit.Commit
+	paths  []string
+}
+
+// pushProject is the work for one project root. Its steps run in commit
+// order; projects are independent of each other.
+type pushProject struct {
+	root  string
+	steps []pushStep
+}
+
+type pushProjectResult struct {
+	root    string
+	pushed  int
+	skipped int
+	err     error
+	// head is the server's id for the last commit pushed.
+	head string
+}
+
+// pusher holds what every project worker shares during one push.
+type pusher struct {
+	client      *api.Client
+	machineID   string
+	machineName string
+	userID      string
+	scanRoot    string
+	vaultKey    []byte
+	blobs       storage.BlobStore
+	journal     *pushJournal
+	uploads     chan struct{}
+
+	sp    *spinner
+	total int
+	done  atomic.Int64
+}
+
+// runPush pushes the pending commits selected by filter. --path and --env
+// select the commits that touch a matching file; a selected commit is
+// pushed whole for that project, since the server cannot take half of it.
+func runPush(jobs int, filter fileFilter, dryRun bool) error {
+	if jobs < 1 {
+		return usageError(pushUsage)
+	}
+
 	verbosef("Starting push operation...")
 	sess, err := ensureRemoteSession()
 	if err != nil {
@@ -47,19 +125,6 @@ func runPush() error {
 	}
 	verbosef("Session loaded: user authenticated")
 
-	// Ensure this machine is registered before pushing.
-	{
-		sp := startSpinner("Registering machine...")
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		if err := registerMachine(ctx, sess.AccessToken); err != nil {
-			sp.StopInfo("")
-			return err
-		}
-		sp.StopSuccess("✔ machine registered")
-		verbosef("Machine registration completed")
-	}
-
 	commits, err := commit.List()
 	if err != nil {
 		return err
@@ -99,178 +164,358 @@ func runPush() error {
 	}
 	verbosef("Machine name: %s", name)
 
+	userID := strings.TrimSpace(cfg.UserID)
+	if userID == "" {
+		if claims, err := auth.ParseAccessTokenClaims(sess.AccessToken); err == nil {
+			userID = strings.TrimSpace(claims.Sub)
+		}
+	}
+	if userID == "" {
+		return fmt.Errorf("not logged in; please run: sentra login")
+	}
+	verbosef("User ID: %s", userID)
+
 	serverURL, err := serverURLFromEnv()
 	if err != nil {
 		return err
 	}
+	verbosef("Server URL: %s", serverURL)
+	verbosef("Push endpoint: %s", serverURL+api.PathPush)
+
+	journal, err := loadPushJournal(serverURL, userID)
+	if err != nil {
+		return err
+	}
+
+	// Regroup the pending commits by project, skipping what the journal says
+	// an earlier, interrupted push already delivered.
+	byRoot := map[string]*pushProject{}
+	commitRoots := make(map[string][]string, len(pending))
+	for _, c := range pending {
+		pathsByRoot := pushCommitRoots(c)
+		if len(pathsByRoot) == 0 {
+			return fmt.Errorf("cannot determine project root for commit %s", c.ID)
+		}
+		for root, paths := range pathsByRoot {
+			commitRoots[c.ID] = append(commitRoots[c.ID], root)
+			if !filter.matchProject(root) || (filter.selectsFiles() && !slices.ContainsFunc(paths, filter.matchFile)) {
+				continue
+			}
+			if journal.done(c.ID, root) {
+				verbosef("Commit %s already pushed to %s (resuming)", c.ID, root)
+				continue
+			}
+			if byRoot[root] == nil {
+				byRoot[root] = &pushProject{root: root}
+			}
+			byRoot[root].steps = append(byRoot[root].steps, pushStep{commit: c, paths: paths})
+		}
+	}
+	projects := make([]*pushProject, 0, len(byRoot))
+	total := 0
+	for _, p := range byRoot {
+		projects = append(projects, p)
+		total += len(p.steps)
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].root < projects[j].root })
+	if dryRun {
+		printPushPlan(projects)
+		return nil
+	}
+	if len(projects) == 0 {
+		// Finish commits an interrupted push delivered but never marked.
+		if _, err := markPushedCommits(pending, commitRoots, journal); err != nil {
+			return err
+		}
+		successf("✔ nothing to push")
+		return nil
+	}
+
+	// Ensure this machine is registered before pushing.
+	{
+		sp := startSpinner("Registering machine...")
+		ctx, cancel := context.WithTimeout(commandContext(), 10*time.Second)
+		defer cancel()
+		if err := registerMachine(ctx, sess.AccessToken); err != nil {
+			sp.StopInfo("")
+			return err
+		}
+		sp.StopSuccess("✔ machine registered")
+		verbosef("Machine registration completed")
+	}
 
 	vaultKey, err := ensureVaultKey(serverURL, sess.AccessToken)
 	if err != nil {
 		return err
 	}
-	endpoint := serverURL + "/push"
-	verbosef("Server URL: %s", serverURL)
-	verbosef("Push endpoint: %s", endpoint)
 
 	scanRoot, err := resolveScanRoot()
 	if err != nil {
 		return err
 	}
 
-	client := &http.Client{Timeout: 20 * time.Second}
+	storageMode := strings.TrimSpace(cfg.StorageMode)
+	if storageMode == "" {
+		storageMode = "hosted"
+	}
+	verbosef("Storage mode: %s", storageMode)
 
-	now := time.Now().UTC().Format(time.RFC3339)
-	for i, c := range pending {
-		shortID := c.ID
-		if len(shortID) > 8 {
-			shortID = shortID[:8]
+	var blobs storage.BlobStore = newHostedBlobStore(serverURL, sess.AccessToken)
+	if storageMode == "byos" {
+		var enabled bool
+		blobs, enabled, err = storage.Open()
+		if err != nil {
+			return err
+		}
+		if !enabled {
+			return fmt.Errorf("storage not configured (run: sentra storage setup)")
+		}
+		loc := blobs.Location()
+		verbosef("BYOS storage: provider=%s, bucket=%s, endpoint=%s, region=%s", loc.Provider, loc.Bucket, loc.Endpoint, loc.Region)
+	}
+
+	// The remote heads before pushing tell whether this machine was up to
+	// date; only then does its push become the project's synced commit.
+	headsBefore, headsErr := fetchRemoteHeads(serverURL, sess.AccessToken)
+	if headsErr != nil {
+		verbosef("Not recording synced heads: %v", headsErr)
+	}
+
+	verbosef("Pushing %d commit(s) to %d project(s), %d at a time", len(pending), len(projects), jobs)
+
+	results := make([]pushProjectResult, len(projects))
+	p := &pusher{
+		client:      apiClient(serverURL, sess.AccessToken, 20*time.Second),
+		machineID:   machineID,
+		machineName: name,
+		userID:      userID,
+		scanRoot:    scanRoot,
+		vaultKey:    vaultKey,
+		blobs:       blobs,
+		journal:     journal,
+		uploads:     make(chan struct{}, jobs),
+		total:       total,
+	}
+	p.sp = startSpinner(fmt.Sprintf("Pushing to %d project(s)...", len(projects)))
+
+	slots := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	for i, proj := range projects {
+		wg.Go(func() {
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			results[i] = p.pushProject(commandContext(), proj)
+		})
+	}
+	wg.Wait()
+	p.sp.StopInfo("")
+
+	pushedCommits, err := markPushedCommits(pending, commitRoots, journal)
+	if err != nil {
+		return err
+	}
+	if headsErr == nil {
+		if err := recordPushedHeads(serverURL, headsBefore, results); err != nil {
+			verbosef("Failed to record synced heads: %v", err)
+		}
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.err == nil {
+			successf("✔ %s: %d commit(s) pushed", r.root, r.pushed)
+			continue
+		}
+		failed++
+		warnf("✖ %s: %v", r.root, r.err)
+		if r.pushed > 0 {
+			infof("  %d commit(s) pushed before the failure", r.pushed)
 		}
-		sp := startSpinner(fmt.Sprintf("Pushing commit %s (%d/%d)...", shortID, i+1, len(pending)))
-		verbosef("Processing commit %s: %d file(s), message: %s", c.ID, len(c.Files), oneLine(c.Message))
+		if r.skipped > 0 {
+			infof("  %d later commit(s) not attempted", r.skipped)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("push failed for %d of %d project(s); fix the cause and run: sentra push (pushed projects are not sent again)", failed, len(projects))
+	}
+	successf("✔ pushed %d commit(s)", pushedCommits)
+	return nil
+}
 
-		userID := strings.TrimSpace(cfg.UserID)
-		if userID == "" {
-			if claims, err := auth.ParseAccessTokenClaims(sess.AccessToken); err == nil {
-				userID = strings.TrimSpace(claims.Sub)
+// markPushedCommits marks each pending commit whose projects are all in the
+// journal as pushed, and drops it from the journal.
+func markPushedCommits(pending []commit.Commit, commitRoots map[string][]string, journal *pushJournal) (int, error) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	pushed := 0
+	for _, c := range pending {
+		complete := true
+		for _, root := range commitRoots[c.ID] {
+			if !journal.done(c.ID, root) {
+				complete = false
+				break
 			}
 		}
-		if userID == "" {
-			sp.StopInfo("")
-			return fmt.Errorf("not logged in; please run: sentra login")
+		if !complete {
+			continue
+		}
+		c.PushedAt = now
+		if err := commit.Update(c); err != nil {
+			return pushed, err
+		}
+		if err := journal.forget(c.ID); err != nil {
+			return pushed, err
 		}
-		verbosef("User ID: %s", userID)
+		pushed++
+		verbosef("Commit %s marked as pushed at %s", c.ID, now)
+	}
+	return pushed, nil
+}
 
-		storageMode := strings.TrimSpace(cfg.StorageMode)
-		if storageMode == "" {
-			storageMode = "hosted"
+// recordPushedHeads records each project's last pushed commit as synced,
+// unless the remote had moved past the commit this machine last synced: then
+// the checkout still lacks those changes and stays behind.
+func recordPushedHeads(serverURL string, before []api.ProjectHead, results []pushProjectResult) error {
+	_, synced, err := loadSyncedHeads(serverURL)
+	if err != nil {
+		return err
+	}
+	prior := map[string]string{}
+	for _, h := range before {
+		prior[strings.TrimSpace(h.RootPath)] = strings.TrimSpace(h.CommitID)
+	}
+	next := map[string]string{}
+	for _, r := range results {
+		if r.head == "" {
+			continue
 		}
-		verbosef("Storage mode: %s", storageMode)
-
-		var (
-			s3cfg storage.S3Config
-			s3c   *minio.Client
-			byos  bool
-		)
-		if storageMode == "byos" {
-			var enabled bool
-			s3cfg, s3c, enabled, err = storage.ResolveS3()
-			if err != nil {
-				return err
+		if id, ok := prior[r.root]; ok && id != synced.Projects[r.root].CommitID {
+			verbosef("Not recording %s as synced: remote changed since the last sync", r.root)
+			continue
+		}
+		next[r.root] = r.head
+	}
+	return recordSyncedHeads(serverURL, next)
+}
+
+// printPushPlan lists what a push would send, per project and commit.
+// Objects already in storage are skipped by a real push, so this is an
+// upper bound on what gets uploaded.
+func printPushPlan(projects []*pushProject) {
+	fmt.Println(c(ansiBoldCyan, "Push") + c(ansiDim, " (dry run)"))
+	for _, proj := range projects {
+		fmt.Println(proj.root)
+		for _, step := range proj.steps {
+			shortID := step.commit.ID
+			if len(shortID) > 8 {
+				shortID = shortID[:8]
 			}
-			if !enabled {
-				sp.StopInfo("")
-				return fmt.Errorf("storage not configured (run: sentra storage setup)")
+			fmt.Printf("  %s %s\n", shortID, oneLine(step.commit.Message))
+			for _, p := range step.paths {
+				fmt.Println("    " + p)
 			}
-			byos = true
-			verbosef("BYOS storage: bucket=%s, endpoint=%s, region=%s", s3cfg.Bucket, s3cfg.Endpoint, s3cfg.Region)
 		}
+	}
+	infof("Dry run: nothing uploaded or pushed")
+}
+
+// pushProject pushes a project's commits in order and stops at the first
+// failure, so the server never sees a later commit without an earlier one.
+func (p *pusher) pushProject(ctx context.Context, proj *pushProject) (res pushProjectResult) {
+	ctx, span := tracer.Start(ctx, "push project", trace.WithAttributes(
+		attribute.String("sentra.project", proj.root),
+		attribute.Int("sentra.commits", len(proj.steps)),
+	))
+	defer func() { endSpan(span, res.err) }()
 
-		verbosef("Building push request for commit %s...", c.ID)
-		reqs, err := buildPushRequestV1(context.Background(), scanRoot, machineID, name, vaultKey, c, s3cfg, s3c, byos, userID)
+	res = pushProjectResult{root: proj.root}
+	for i, step := range proj.steps {
+		c := step.commit
+		verbosef("Pushing commit %s to project %s (%d file(s)), message: %s", c.ID, proj.root, len(step.paths), oneLine(c.Message))
+
+		var head string
+		reqBody, err := buildPushRequestV1(ctx, p.scanRoot, p.machineID, p.machineName, p.vaultKey, c, proj.root, step.paths, p.blobs, p.userID, p.uploads)
+		if err == nil {
+			head, err = p.post(ctx, reqBody)
+		}
+		if err == nil {
+			err = p.journal.markDone(c.ID, proj.root, time.Now().UTC().Format(time.RFC3339))
+		}
 		if err != nil {
-			sp.StopInfo("")
-			return err
+			res.err = err
+			res.skipped = len(proj.steps) - i - 1
+			return res
 		}
-		verbosef("Built %d push request(s) for %d project(s)", len(reqs), len(reqs))
 
-		for _, reqBody := range reqs {
-			verbosef("Pushing to project: %s (%d file(s))", reqBody.Project.Root, len(reqBody.Files))
-			b, err := json.Marshal(reqBody)
-			if err != nil {
-				return err
-			}
-			verbosef("Request payload size: %d bytes", len(b))
+		res.pushed++
+		res.head = head
+		n := p.done.Add(1)
+		p.sp.Set(fmt.Sprintf("Pushing... %d/%d commit(s) across projects", n, p.total))
+		verbosef("Successfully pushed commit %s to project %s", c.ID, proj.root)
+	}
+	return res
+}
 
-			// Stable per (user, project.root, commit.client_id) so retries can be cheap.
-			idemKey := uuid.NewSHA1(uuid.NameSpaceOID, []byte("push:"+userID+":"+strings.TrimSpace(reqBody.Project.Root)+":"+strings.TrimSpace(reqBody.Commit.ClientID))).String()
-			verbosef("Idempotency key: %s", idemKey)
-
-			// Retry on 429 using Retry-After.
-			for attempt := 0; attempt < 5; attempt++ {
-				if attempt > 0 {
-					verbosef("Retry attempt %d/5", attempt+1)
-				}
-				startTime := time.Now()
-				hreq, err := http.NewRequestWithContext(context.Background(), http.MethodPost, endpoint, bytes.NewReader(b))
-				if err != nil {
-					sp.StopInfo("")
-					return err
-				}
-				hreq.Header.Set("Content-Type", "application/json")
-				hreq.Header.Set("Accept", "application/json")
-				hreq.Header.Set("Authorization", "Bearer "+strings.TrimSpace(sess.AccessToken))
-				hreq.Header.Set("X-Idempotency-Key", idemKey)
-
-				ts := fmt.Sprintf("%d", time.Now().UTC().Unix())
-				nonce := uuid.NewString()
-				sig, err := auth.SignDeviceRequest(machineID, ts, nonce, http.MethodPost, "/push", b)
-				if err != nil {
-					sp.StopInfo("")
-					return err
-				}
-				hreq.Header.Set("X-Sentra-Machine-ID", machineID)
-				hreq.Header.Set("X-Sentra-Timestamp", ts)
-				hreq.Header.Set("X-Sentra-Nonce", nonce)
-				hreq.Header.Set("X-Sentra-Signature", sig)
-				verbosef("Sending HTTP POST to %s", endpoint)
-
-				resp, err := client.Do(hreq)
-				elapsed := time.Since(startTime)
-				if err != nil {
-					sp.StopInfo("")
-					verbosef("Request failed after %v: %v", elapsed, err)
-					return err
-				}
-				respBody, _ := io.ReadAll(resp.Body)
-				_ = resp.Body.Close()
-				trace := responseTrace(resp)
-				verbosef("Response received: status=%d, size=%d bytes, elapsed=%v", resp.StatusCode, len(respBody), elapsed)
-				if trace != "" {
-					verbosef("Response trace: %s", trace)
-				}
-
-				if resp.StatusCode == http.StatusTooManyRequests {
-					retryAfter := 2 * time.Second
-					if v := strings.TrimSpace(resp.Header.Get("Retry-After")); v != "" {
-						if n, parseErr := strconv.Atoi(v); parseErr == nil && n > 0 {
-							retryAfter = time.Duration(n) * time.Second
-						}
-					}
-					verbosef("Rate limited, retrying after %v", retryAfter)
-					time.Sleep(retryAfter)
-					continue
-				}
-
-				if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-					msg := oneLine(string(respBody))
-					if msg == "" {
-						msg = strings.TrimSpace(http.StatusText(resp.StatusCode))
-					}
-					if trace != "" {
-						msg = msg + "; " + trace
-					}
-					if isVerbose() {
-						sp.StopInfo("")
-						verbosef("Response body: %s", string(respBody))
-						return fmt.Errorf("push failed: status=%d msg=%s", resp.StatusCode, msg)
-					}
-					sp.StopInfo("")
-					return fmt.Errorf("push failed: server returned %d (%s)", resp.StatusCode, msg)
-				}
-
-				verbosef("Successfully pushed project %s", reqBody.Project.Root)
-				break
-			}
+// post sends one push request and returns the server's id for the commit.
+func (p *pusher) post(ctx context.Context, reqBody api.PushRequest) (string, error) {
+	if isVerbose() {
+		if b, err := json.Marshal(reqBody); err == nil {
+			verbosef("Request payload size: %d bytes", len(b))
 		}
+	}
 
-		c.PushedAt = now
-		if err := commit.Update(c); err != nil {
-			sp.StopInfo("")
-			return err
+	// Stable per (user, project.root, commit.client_id) so retries can be cheap.
+	idemKey := uuid.NewSHA1(uuid.NameSpaceOID, []byte("push:"+p.userID+":"+strings.TrimSpace(reqBody.Project.Root)+":"+strings.TrimSpace(reqBody.Commit.ClientID))).String()
+	verbosef("Idempotency key: %s", idemKey)
+
+	// Each attempt needs a fresh nonce; the server rejects replays.
+	sign := func(method string, path string, body []byte) (http.Header, error) {
+		ts := fmt.Sprintf("%d", time.Now().UTC().Unix())
+		nonce := uuid.NewString()
+		sig, err := auth.SignDeviceRequest(p.machineID, ts, nonce, method, path, body)
+		if err != nil {
+			return nil, err
 		}
-		sp.StopSuccess(fmt.Sprintf("✔ pushed commit %s", shortID))
-		verbosef("Commit %s marked as pushed at %s", c.ID, now)
+		h := http.Header{}
+		h.Set("X-Sentra-Machine-ID", p.machineID)
+		h.Set("X-Sentra-Timestamp", ts)
+		h.Set("X-Sentra-Nonce", nonce)
+		h.Set("X-Sentra-Signature", sig)
+		return h, nil
 	}
 
-	return nil
+	var commitID string
+	err := retryWithBackoff(ctx, "push "+reqBody.Project.Root, func() error {
+		startTime := time.Now()
+		verbosef("Sending HTTP POST to %s", api.PathPush)
+		res, err := p.client.Push(ctx, reqBody, idemKey, sign)
+		elapsed := time.Since(startTime)
+
+		var apiErr *api.Error
+		if errors.As(err, &apiErr) {
+			trace := responseTrace(apiErr.Header)
+			verbosef("Response received: status=%d, size=%d bytes, elapsed=%v", apiErr.StatusCode, len(apiErr.Body), elapsed)
+			msg := oneLine(apiErr.Message())
+			if trace != "" {
+				verbosef("Response trace: %s", trace)
+				msg = msg + "; " + trace
+			}
+			if isVerbose() {
+				verbosef("Response body: %s", string(apiErr.Body))
+				return retryableStatus(apiErr.StatusCode, apiErr.Header, fmt.Errorf("push failed: status=%d msg=%s", apiErr.StatusCode, msg))
+			}
+			return retryableStatus(apiErr.StatusCode, apiErr.Header, fmt.Errorf("push failed: server returned %d (%s)", apiErr.StatusCode, msg))
+		}
+		var schemaErr *api.SchemaError
+		if errors.As(err, &schemaErr) {
+			return fmt.Errorf("push payload rejected before sending: %w", err)
+		}
+		if err != nil {
+			verbosef("Request failed after %v: %v", elapsed, err)
+			return err
+		}
+		verbosef("Response received: elapsed=%v, deduped=%t", elapsed, res.Deduped)
+		commitID = strings.TrimSpace(res.CommitID)
+		return nil
+	})
+	return commitID, err
 }
@@ -3,20 +3,23 @@ package cli
 import (
 	"context"
 	"crypto/sha256"
-	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/google/uuid"
 	"github.com/mgeovany/sentra/cli/internal/auth"
 	"github.com/mgeovany/sentra/cli/internal/commit"
 	"github.com/mgeovany/sentra/cli/internal/storage"
-	"github.com/minio/minio-go/v7"
+	"github.com/mgeovany/sentra/shared/api"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type missingCommitFile struct {
@@ -87,7 +90,9 @@ func (e missingCommitFilesError) Error() string {
 	return strings.TrimSpace(out.String())
 }
 
-func buildPushRequestV1(ctx context.Context, scanRoot, machineID, machineName string, vaultKey []byte, c commit.Commit, s3cfg storage.S3Config, s3 *minio.Client, byos bool, userID string) ([]pushRequestV1, error) {
+// pushCommitRoots groups a commit's files by project root. Each root becomes
+// its own push request.
+func pushCommitRoots(c commit.Commit) map[string][]string {
 	pathsByRoot := map[string][]string{}
 	for p := range c.Files {
 		root := projectRootFromPath(p)
@@ -96,119 +101,282 @@ func buildPushRequestV1(ctx context.Context, scanRoot, machineID, machineName st
 		}
 		pathsByRoot[root] = append(pathsByRoot[root], p)
 	}
-	if len(pathsByRoot) == 0 {
-		return nil, fmt.Errorf("cannot determine project root")
-	}
-
-	roots := make([]string, 0, len(pathsByRoot))
-	for root := range pathsByRoot {
-		roots = append(roots, root)
+	for _, paths := range pathsByRoot {
+		sort.Strings(paths)
 	}
-	sort.Strings(roots)
+	return pathsByRoot
+}
 
+func pushClientID(c commit.Commit) string {
 	clientID := strings.TrimSpace(c.ID)
 	if _, err := uuid.Parse(clientID); err != nil {
 		// Fallback: convert legacy timestamp-based IDs to UUIDs.
 		// Commits should be migrated on load, but this provides safety for edge cases.
 		clientID = uuid.NewSHA1(uuid.NameSpaceOID, []byte(clientID)).String()
 	}
+	return clientID
+}
 
-	out := make([]pushRequestV1, 0, len(roots))
-	var missing []missingCommitFile
-	for _, root := range roots {
-		paths := pathsByRoot[root]
-		sort.Strings(paths)
+// buildPushRequestV1 builds the push request for one project of c. Each
+// file's ciphertext is uploaded first; uploads run concurrently, at most
+// cap(slots) at a time across the whole push.
+func buildPushRequestV1(ctx context.Context, scanRoot, machineID, machineName string, vaultKey []byte, c commit.Commit, root string, paths []string, blobs storage.BlobStore, userID string, slots chan struct{}) (api.PushRequest, error) {
+	files := make([]api.PushFile, len(paths))
+	missing := make([]*missingCommitFile, len(paths))
+	errs := make([]error, len(paths))
 
-		files := make([]pushFileV1, 0, len(paths))
-		for _, p := range paths {
-			abs := filepath.Join(scanRoot, filepath.FromSlash(p))
-			plain, err := os.ReadFile(abs)
-			if err != nil {
-				if os.IsNotExist(err) {
-					missing = append(missing, missingCommitFile{Path: p, Abs: abs})
-					continue
-				}
-				return nil, fmt.Errorf("cannot read %s: %w", p, err)
-			}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-			shaPlain := auth.SHA256Hex(plain)
-			cipherName, blobB64, size, err := auth.EncryptEnvBlobWithKey(vaultKey, plain)
-			if err != nil {
-				return nil, err
+	var wg sync.WaitGroup
+	for i, p := range paths {
+		wg.Go(func() {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
 			}
+			defer func() { <-slots }()
 
-			var blob string
-			var st *pushStorageV1
-			if byos {
-				if s3 == nil {
-					return nil, fmt.Errorf("storage client not available")
-				}
-				raw, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(blobB64))
-				if err != nil {
-					return nil, err
-				}
-				key := s3ObjectKey(userID, root, p, shaPlain)
-				if err := storage.PutObject(ctx, s3, s3cfg, key, raw); err != nil {
-					return nil, fmt.Errorf("s3 upload failed (%s): %w", p, err)
-				}
-				st = &pushStorageV1{
-					Provider: "s3",
-					Bucket:   s3cfg.Bucket,
-					Key:      key,
-					Endpoint: s3cfg.Endpoint,
-					Region:   s3cfg.Region,
+			abs := filepath.Join(scanRoot, filepath.FromSlash(p))
+			f, err := pushFile(ctx, vaultKey, blobs, userID, root, p, abs)
+			if err != nil {
+				if os.IsNotExist(err) {
+					missing[i] = &missingCommitFile{Path: p, Abs: abs}
+					return
 				}
-			} else {
-				blob = blobB64
+				errs[i] = err
+				// One failed file fails the project; stop the others.
+				cancel()
+				return
 			}
-
-			files = append(files, pushFileV1{
-				Path:      p,
-				SHA256:    shaPlain,
-				Size:      size,
-				Encrypted: true,
-				Cipher:    cipherName,
-				Blob:      blob,
-				Storage:   st,
-			})
-		}
-
-		out = append(out, pushRequestV1{
-			V:       1,
-			Project: pushProjectV1{Root: strings.TrimSpace(root)},
-			Machine: pushMachineV1{ID: machineID, Name: machineName},
-			Commit: pushCommitV1{
-				ClientID: clientID,
-				Message:  strings.TrimSpace(c.Message),
-			},
-			Files: files,
+			files[i] = f
 		})
 	}
+	wg.Wait()
 
-	if len(missing) > 0 {
-		return nil, missingCommitFilesError{
+	var missed []missingCommitFile
+	for _, m := range missing {
+		if m != nil {
+			missed = append(missed, *m)
+		}
+	}
+	if len(missed) > 0 {
+		return api.PushRequest{}, missingCommitFilesError{
 			CommitID:  strings.TrimSpace(c.ID),
 			Message:   strings.TrimSpace(c.Message),
 			ScanRoot:  scanRoot,
-			Missing:   missing,
+			Missing:   missed,
 			CauseHint: errors.New("a tracked env file was deleted or moved after creating the commit"),
 		}
 	}
+	// Report the failure that cancelled the others, not the cancellations.
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return api.PushRequest{}, err
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return api.PushRequest{}, err
+		}
+	}
 
-	return out, nil
+	return api.PushRequest{
+		V:       api.PushVersion,
+		Project: api.PushProject{Root: strings.TrimSpace(root)},
+		Machine: api.PushMachine{ID: machineID, Name: machineName},
+		Commit: api.PushCommit{
+			ClientID: pushClientID(c),
+			Message:  strings.TrimSpace(c.Message),
+		},
+		Files: files,
+	}, nil
 }
 
-func s3ObjectKey(userID string, root string, path string, shaPlain string) string {
-	userID = strings.TrimSpace(userID)
-	root = strings.TrimSpace(root)
-	path = strings.TrimSpace(path)
-	shaPlain = strings.TrimSpace(shaPlain)
+// pushFile stores the ciphertext of one file unless an identical object is
+// already there, which also makes re-running an interrupted push cheap.
+func pushFile(ctx context.Context, vaultKey []byte, blobs storage.BlobStore, userID string, root string, p string, abs string) (api.PushFile, error) {
+	shaPlain, size, err := hashFile(abs)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return api.PushFile{}, err
+		}
+		return api.PushFile{}, fmt.Errorf("cannot read %s: %w", p, err)
+	}
+	if size > maxPushFileBytes {
+		return api.PushFile{}, fmt.Errorf("%s is too large to push (%d bytes, max %d)", p, size, maxPushFileBytes)
+	}
+
+	binding := auth.EnvBlobBinding{Root: root, Path: p, SHA256: shaPlain}
+	blobID := auth.BoundBlobID(vaultKey, auth.EnvStreamCipher, binding)
+	loc := blobs.Location()
+	key := blobObjectKey(userID, blobID)
+	if loc.Provider == hostedProvider {
+		key = hostedBlobKey(root, blobID)
+	}
+
+	var exists bool
+	err = retryWithBackoff(ctx, "storage check "+p, func() error {
+		exists, err = blobs.Exists(ctx, key)
+		return err
+	})
+	if err != nil {
+		return api.PushFile{}, fmt.Errorf("storage check failed (%s): %w", p, err)
+	}
+	if exists && loc.Provider != hostedProvider {
+		exists, err = refreshObject(ctx, blobs, key, p)
+		if err != nil {
+			return api.PushFile{}, fmt.Errorf("storage refresh failed (%s): %w", p, err)
+		}
+	}
+	if exists {
+		verbosef("Reusing stored object for %s: %s", p, key)
+	} else {
+		if err := putEncryptedFile(ctx, blobs, key, p, abs, vaultKey, binding, size); err != nil {
+			return api.PushFile{}, fmt.Errorf("storage upload failed (%s): %w", p, err)
+		}
+		verbosef("Uploaded %s (%d bytes)", p, size)
+	}
+
+	return api.PushFile{
+		Path:      p,
+		SHA256:    shaPlain,
+		Size:      int(size),
+		Encrypted: true,
+		Cipher:    auth.EnvStreamCipher,
+		Storage: &api.PushStorage{
+			Provider: loc.Provider,
+			Bucket:   loc.Bucket,
+			Key:      key,
+			Endpoint: loc.Endpoint,
+			Region:   loc.Region,
+		},
+	}, nil
+}
+
+// refreshObject touches a BYOS object the push is about to reuse, so
+// `sentra storage gc` does not delete it as old and unreferenced before the
+// commit reaches the server. It reports false when the object must be
+// uploaded again: the store cannot touch, or gc deleted it meanwhile.
+func refreshObject(ctx context.Context, blobs storage.BlobStore, key string, p string) (bool, error) {
+	t, ok := blobs.(storage.Toucher)
+	if !ok {
+		return false, nil
+	}
+	err := retryWithBackoff(ctx, "storage refresh "+p, func() error {
+		return t.Touch(ctx, key)
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// maxPushFileBytes mirrors the server's per-file size limit.
+const maxPushFileBytes = 64 << 20
+
+func hashFile(abs string) (sha string, size int64, err error) {
+	f, err := os.Open(abs)
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	size, err = io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// errFileChanged is not retried: the commit no longer matches the file.
+var errFileChanged = errors.New("file changed while pushing; run the push again")
+
+// putEncryptedFile seals abs into a temp file and uploads that, retrying
+// transient failures. Keys are content-addressed and reused by later pushes,
+// so only ciphertext whose plaintext matched b.SHA256 is ever written.
+func putEncryptedFile(ctx context.Context, blobs storage.BlobStore, key string, p string, abs string, vaultKey []byte, b auth.EnvBlobBinding, size int64) error {
+	spool, err := sealFile(ctx, abs, vaultKey, b, size)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = spool.Close()
+		_ = os.Remove(spool.Name())
+	}()
+
+	return retryWithBackoff(ctx, "upload "+p, func() (err error) {
+		ctx, span := tracer.Start(ctx, "storage put", trace.WithAttributes(
+			attribute.String("sentra.storage.provider", blobs.Location().Provider),
+			attribute.Int64("sentra.size", auth.EnvStreamSize(size)),
+		))
+		defer func() { endSpan(span, err) }()
 
-	h := sha256.Sum256([]byte(path))
-	pathHash := hex.EncodeToString(h[:])
+		if _, err := spool.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		return blobs.Put(ctx, key, spool, auth.EnvStreamSize(size))
+	})
+}
+
+// sealFile encrypts abs with the sentra-v3 stream cipher into a temp file,
+// so large files are never held in memory. The plaintext is hashed again on
+// the way: a file edited since hashFile fails with errFileChanged before
+// anything is uploaded. The caller closes and removes the file.
+func sealFile(ctx context.Context, abs string, vaultKey []byte, b auth.EnvBlobBinding, size int64) (_ *os.File, err error) {
+	_, span := tracer.Start(ctx, "encrypt", trace.WithAttributes(attribute.String("sentra.cipher", auth.EnvStreamCipher)))
+	defer func() { endSpan(span, err) }()
+
+	f, err := os.Open(abs)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	tmp, err := os.CreateTemp("", "sentra-push-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	h := sha256.New()
+	enc, err := auth.NewEnvStreamWriter(tmp, vaultKey, b)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(enc, io.TeeReader(io.LimitReader(f, size+1), h)); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	if hex.EncodeToString(h.Sum(nil)) != b.SHA256 {
+		return nil, errFileChanged
+	}
+	return tmp, nil
+}
 
-	// Stable + safe key; content remains encrypted client-side.
-	return "sentra/v1/" + userID + "/" + root + "/" + shaPlain + "/" + pathHash + ".bin"
+// blobObjectKey addresses BYOS objects by content (see auth.BoundBlobID), so
+// a file that is unchanged across commits is stored once. Ciphertexts use
+// random nonces; an existing object is reused instead of re-encrypted.
+func blobObjectKey(userID string, blobID string) string {
+	userID = strings.TrimSpace(userID)
+	blobID = strings.TrimSpace(blobID)
+	return "sentra/v2/" + userID + "/" + blobID[:2] + "/" + blobID + ".bin"
+}
+
+// blobObjectPrefixes lists where a user's objects live: v1 keys were
+// "sentra/v1/<user>/<root>/<sha>/<path hash>.bin".
+func blobObjectPrefixes(userID string) []string {
+	userID = strings.TrimSpace(userID)
+	return []string{"sentra/v1/" + userID + "/", "sentra/v2/" + userID + "/"}
 }
 
 func projectRootFromPath(p string) string {
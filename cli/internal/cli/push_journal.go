@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/mgeovany/sentra/cli/internal/profile"
+)
+
+// pushJournal records which (commit, project) pairs the server has accepted
+// while a push is unfinished. A push that is interrupted or fails for some
+// projects resumes with the projects that are still missing; a commit's
+// entry is dropped once the commit is marked pushed.
+type pushJournal struct {
+	ServerURL string `json:"serverURL"`
+	UserID    string `json:"userID"`
+	// Commits maps commit id -> project root -> time the server accepted it.
+	Commits map[string]map[string]string `json:"commits"`
+	Version int                          `json:"version"`
+
+	path string
+	mu   sync.Mutex
+}
+
+func pushJournalPath() (string, error) {
+	dir, err := profile.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "push-journal.json"), nil
+}
+
+// loadPushJournal returns the journal for serverURL and userID. Entries left
+// by another account or server say nothing about this one and are ignored.
+func loadPushJournal(serverURL string, userID string) (*pushJournal, error) {
+	p, err := pushJournalPath()
+	if err != nil {
+		return nil, err
+	}
+	j := &pushJournal{path: p}
+
+	b, err := os.ReadFile(p)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(b, j); err != nil {
+			// A torn journal only costs re-sending requests the server
+			// deduplicates by idempotency key.
+			verbosef("Ignoring unreadable push journal: %v", err)
+			j = &pushJournal{path: p}
+		}
+	}
+	if j.ServerURL != serverURL || j.UserID != userID {
+		j.Commits = nil
+	}
+	j.ServerURL = serverURL
+	j.UserID = userID
+	j.Version = 1
+	if j.Commits == nil {
+		j.Commits = map[string]map[string]string{}
+	}
+	return j, nil
+}
+
+func (j *pushJournal) done(commitID string, root string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, ok := j.Commits[commitID][root]
+	return ok
+}
+
+func (j *pushJournal) markDone(commitID string, root string, at string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.Commits[commitID] == nil {
+		j.Commits[commitID] = map[string]string{}
+	}
+	j.Commits[commitID][root] = at
+	return j.save()
+}
+
+func (j *pushJournal) forget(commitID string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, ok := j.Commits[commitID]; !ok {
+		return nil
+	}
+	delete(j.Commits, commitID)
+	return j.save()
+}
+
+// save writes the journal, or removes it once nothing is in flight. The
+// caller holds mu.
+func (j *pushJournal) save() error {
+	if len(j.Commits) == 0 {
+		if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0o755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := j.path + ".tmp"
+	if err := os.WriteFile(tmpPath, b, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, j.path); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	pushRetryAttempts = 5
+	pushRetryBase     = 500 * time.Millisecond
+	pushRetryMax      = 15 * time.Second
+)
+
+// retryableError marks a server response worth another attempt (429 or
+// 5xx). after is the delay the server asked for, if any.
+type retryableError struct {
+	err   error
+	after time.Duration
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+// pushRetryMaxWait is the longest Retry-After worth waiting for. A daily
+// quota answers 429 with a wait until midnight; that fails right away.
+const pushRetryMaxWait = 2 * time.Minute
+
+// retryableStatus wraps err so retryWithBackoff retries it when status is a
+// 429 or 5xx. A full storage quota (507) is not retried.
+func retryableStatus(status int, h http.Header, err error) error {
+	if status != http.StatusTooManyRequests && status < 500 {
+		return err
+	}
+	if status == http.StatusInsufficientStorage {
+		return err
+	}
+	var after time.Duration
+	if v := strings.TrimSpace(h.Get("Retry-After")); v != "" {
+		if n, parseErr := strconv.Atoi(v); parseErr == nil && n > 0 {
+			after = time.Duration(n) * time.Second
+		}
+	}
+	if after > pushRetryMaxWait {
+		return err
+	}
+	return &retryableError{err: err, after: after}
+}
+
+// retryWithBackoff runs fn until it succeeds, fails with an error that is
+// not transient, or pushRetryAttempts is reached. Delays grow exponentially
+// with jitter unless the server sent Retry-After.
+func retryWithBackoff(ctx context.Context, op string, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if attempt == pushRetryAttempts || ctx.Err() != nil || !transientError(err) {
+			return err
+		}
+
+		delay := backoffDelay(attempt)
+		var re *retryableError
+		if errors.As(err, &re) && re.after > 0 {
+			delay = re.after
+		}
+		verbosef("%s failed (attempt %d/%d), retrying in %v: %v", op, attempt, pushRetryAttempts, delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+func backoffDelay(attempt int) time.Duration {
+	d := pushRetryBase << (attempt - 1)
+	if d > pushRetryMax {
+		d = pushRetryMax
+	}
+	// Equal jitter: wait between half and all of d, so parallel workers do not
+	// retry in lockstep but still back off.
+	return d/2 + rand.N(d/2+1)
+}
+
+// transientError reports failures a retry can fix: 429/5xx responses and
+// network errors (timeouts, refused or reset connections).
+func transientError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, errFileChanged) {
+		return false
+	}
+	var re *retryableError
+	if errors.As(err, &re) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
@@ -11,7 +11,7 @@ import (
 	"github.com/mgeovany/sentra/cli/internal/auth"
 )
 
-func ensureRemoteSession() (auth.Session, error) {
+func remoteOAuthFromEnv() auth.SupabaseOAuth {
 	auth.LoadDotEnv()
 
 	supabaseURL := strings.TrimSpace(os.Getenv("SUPABASE_URL"))
@@ -23,8 +23,17 @@ func ensureRemoteSession() (auth.Session, error) {
 		anonKey = defaultHostedSupabaseAnonKey
 	}
 
-	oauth := auth.SupabaseOAuth{SupabaseURL: supabaseURL, AnonKey: anonKey, Provider: "google"}
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	return auth.SupabaseOAuth{SupabaseURL: supabaseURL, AnonKey: anonKey, Provider: "google"}
+}
+
+func ensureRemoteSession() (auth.Session, error) {
+	// CI: a service token replaces the browser session and never touches the keyring.
+	if t := serviceTokenFromEnv(); t != "" {
+		return auth.Session{AccessToken: t, TokenType: "bearer"}, nil
+	}
+
+	oauth := remoteOAuthFromEnv()
+	ctx, cancel := context.WithTimeout(commandContext(), 15*time.Second)
 	defer cancel()
 
 	s, err := auth.EnsureSession(ctx, oauth)
@@ -36,9 +45,17 @@ func ensureRemoteSession() (auth.Session, error) {
 		return s, nil
 	}
 
+	// Scripts asked for JSON; never block them on an interactive login.
+	if jsonOutput() {
+		if errors.Is(err, auth.ErrNoSession) {
+			return auth.Session{}, errNotLoggedIn
+		}
+		return auth.Session{}, fmt.Errorf("%w: %v", errNotLoggedIn, err)
+	}
+
 	if errors.Is(err, auth.ErrNoSession) {
 		fmt.Println("please login to push changes to remote")
-		if loginErr := runLogin(); loginErr != nil {
+		if loginErr := runLogin(false); loginErr != nil {
 			return auth.Session{}, loginErr
 		}
 		// After interactive login, load the session again.
@@ -47,7 +64,7 @@ func ensureRemoteSession() (auth.Session, error) {
 
 	// If refresh failed for any reason, ask user to login again.
 	fmt.Println("session expired; please login again")
-	if loginErr := runLogin(); loginErr != nil {
+	if loginErr := runLogin(false); loginErr != nil {
 		return auth.Session{}, loginErr
 	}
 	return auth.EnsureSession(ctx, oauth)
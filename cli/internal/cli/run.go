@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+)
+
+func newRunCmd() *cobra.Command {
+	var (
+		at    string
+		files []string
+	)
+	cmd := &cobra.Command{
+		Use:     "run <project> -- <command> [args...]",
+		Short:   "Run a command with a project's remote env injected",
+		GroupID: groupRemote,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if cmd.ArgsLenAtDash() != 1 || len(args) < 2 {
+				return usageError("sentra run <project> [--at <commit>] [--file <path>]... -- <command> [args...]")
+			}
+			return nil
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+			if cmd.ArgsLenAtDash() >= 0 {
+				return nil, cobra.ShellCompDirectiveDefault
+			}
+			return completeRemoteProjects(cmd, args, toComplete)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRun(args[0], at, files, args[1:])
+		},
+	}
+	cmd.Flags().StringVar(&at, "at", "", "Use the env files of a specific commit")
+	cmd.Flags().StringArrayVarP(&files, "file", "f", nil, "Only load this env file path (repeatable; default: all)")
+	_ = cmd.RegisterFlagCompletionFunc("at", completeRemoteCommitIDs)
+	return cmd
+}
+
+// runRun loads the remote env files into the child's environment only; nothing is written to disk.
+func runRun(project string, at string, only []string, argv []string) error {
+	root := strings.TrimSpace(projectRootFromPath(project))
+	if root == "" || len(argv) == 0 {
+		return usageError("sentra run <project> [--at <commit>] [--file <path>]... -- <command> [args...]")
+	}
+
+	sess, err := ensureRemoteSession()
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(sess.AccessToken) == "" {
+		return errNotLoggedIn
+	}
+	serverURL, err := serverURLFromEnv()
+	if err != nil {
+		return err
+	}
+
+	files, err := fetchRemoteExport(serverURL, sess.AccessToken, root, at, fileFilter{})
+	if err != nil {
+		return err
+	}
+
+	want := map[string]bool{}
+	for _, f := range only {
+		if f = path.Clean(strings.TrimSpace(f)); f != "" && f != "." {
+			want[f] = false
+		}
+	}
+
+	vars := map[string]string{}
+	var vaultKey []byte
+	for _, f := range files {
+		p := strings.TrimSpace(f.FilePath)
+		if len(want) > 0 {
+			if _, ok := want[p]; !ok {
+				continue
+			}
+			want[p] = true
+		}
+		plain, err := decryptRemoteExportFile(serverURL, sess.AccessToken, &vaultKey, f)
+		if err != nil {
+			return err
+		}
+		parsed, err := godotenv.UnmarshalBytes(plain)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", p, err)
+		}
+		verbosef("Loaded %d variable(s) from %s", len(parsed), p)
+		// Files are sorted by path, so later files win on duplicate keys.
+		for k, v := range parsed {
+			vars[k] = v
+		}
+	}
+	for p, found := range want {
+		if !found {
+			return fmt.Errorf("env file not found in %s: %s", root, p)
+		}
+	}
+
+	env := os.Environ()
+	for k, v := range vars {
+		env = append(env, k+"="+v)
+	}
+
+	child := exec.Command(argv[0], argv[1:]...)
+	child.Env = env
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+	if err := child.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+			return withExitCode(exitErr.ExitCode(), fmt.Errorf("%s exited with status %d", argv[0], exitErr.ExitCode()))
+		}
+		return err
+	}
+	return nil
+}
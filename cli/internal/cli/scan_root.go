@@ -34,6 +34,15 @@ func resolveScanRoot() (string, error) {
 		}
 	}
 
+	// --json keeps stdout to one document, so it never prompts: the
+	// default root is used when it exists.
+	if jsonOutput() {
+		if !isDir(defaultRoot) {
+			return "", withExitCode(ExitUsage, fmt.Errorf("no scan root configured and %s does not exist (run `sentra scan` once without --json to choose one)", defaultRoot))
+		}
+		return defaultRoot, nil
+	}
+
 	chosen, err := promptScanRoot(defaultRoot)
 	if err != nil {
 		return "", err
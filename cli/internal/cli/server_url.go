@@ -16,7 +16,12 @@ func serverURLFromEnv() (string, error) {
 	// Load .env file first to ensure environment variables are available.
 	auth.LoadDotEnv()
 
-	// Highest priority: explicit server URL from environment.
+	// Highest priority: --server for this invocation.
+	if v := strings.TrimSpace(globals.Server); v != "" {
+		return validateServerURL(v)
+	}
+
+	// Next: explicit server URL from environment.
 	v := strings.TrimSpace(os.Getenv("SENTRA_SERVER_URL"))
 	if v != "" {
 		return validateServerURL(v)
@@ -0,0 +1,21 @@
+package cli
+
+import (
+	"os"
+	"strings"
+
+	"github.com/mgeovany/sentra/cli/internal/auth"
+)
+
+// serviceTokenPrefix matches the server's service token format.
+const serviceTokenPrefix = "sst_"
+
+// serviceTokenFromEnv returns SENTRA_TOKEN, used by CI instead of `sentra login`.
+func serviceTokenFromEnv() string {
+	auth.LoadDotEnv()
+	return strings.TrimSpace(os.Getenv("SENTRA_TOKEN"))
+}
+
+func isServiceToken(token string) bool {
+	return strings.HasPrefix(strings.TrimSpace(token), serviceTokenPrefix)
+}
@@ -16,6 +16,9 @@ type spinner struct {
 }
 
 func spinnerEnabled() bool {
+	if jsonOutput() {
+		return false
+	}
 	if !colorEnabled() {
 		return false
 	}
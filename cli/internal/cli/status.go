@@ -3,11 +3,36 @@ package cli
 import (
 	"fmt"
 
-	"github.com/mgeovany/sentra/cli/internal/scanner"
 	"github.com/mgeovany/sentra/cli/internal/state"
+	"github.com/spf13/cobra"
 )
 
-func runStatus() error {
+type statusJSON struct {
+	Schema          string `json:"schema"`
+	ScanRoot        string `json:"scan_root"`
+	ProjectsTracked int    `json:"projects_tracked"`
+	Changed         int    `json:"changed"`
+	// Remote is set with --remote.
+	Remote []remoteStatusJSON `json:"remote,omitempty"`
+}
+
+func newStatusCmd() *cobra.Command {
+	var remote bool
+	cmd := &cobra.Command{
+		Use:         "status",
+		Short:       "Show local staged/changed env files",
+		GroupID:     groupLocal,
+		Args:        exactArgs(0, "sentra status [--remote]"),
+		Annotations: jsonCapable(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runStatus(remote)
+		},
+	}
+	cmd.Flags().BoolVar(&remote, "remote", false, "Also compare each project with the remote (behind, ahead or diverged)")
+	return cmd
+}
+
+func runStatus(remote bool) error {
 	verbosef("Checking status...")
 	scanRoot, err := resolveScanRoot()
 	if err != nil {
@@ -33,7 +58,7 @@ func runStatus() error {
 	}
 
 	verbosef("Scanning current state...")
-	currentProjects, err := scanner.Scan(scanRoot)
+	currentProjects, err := scanProjects(scanRoot)
 	if err != nil {
 		return err
 	}
@@ -47,14 +72,35 @@ func runStatus() error {
 	changed := countChangedEnvFiles(prev, curr)
 	verbosef("Changed files detected: %d", changed)
 
+	var remoteStatuses []remoteStatusJSON
+	if remote {
+		remoteStatuses, err = remoteStatus(scanRoot, currentProjects)
+		if err != nil {
+			return err
+		}
+	}
+
+	if jsonOutput() {
+		return writeJSON(statusJSON{
+			Schema:          "sentra.status/v1",
+			ScanRoot:        scanRoot,
+			ProjectsTracked: len(prev.Projects),
+			Changed:         changed,
+			Remote:          remoteStatuses,
+		})
+	}
+
 	fmt.Println(c(ansiGreen, "✔ ") + c(ansiBoldCyan, fmt.Sprintf("%d", len(prev.Projects))) + c(ansiGreen, " projects tracked"))
 	if changed == 0 {
 		fmt.Println(c(ansiGreen, "✔ ") + c(ansiBoldCyan, "0") + c(ansiGreen, " env changed"))
 		verbosef("All env files are up to date")
-		return nil
+	} else {
+		fmt.Println(c(ansiYellow, "⚠ ") + c(ansiBoldCyan, fmt.Sprintf("%d", changed)) + c(ansiYellow, " env changed"))
+		verbosef("Run 'sentra add .' to stage changed files")
+	}
+	if remote {
+		printRemoteStatus(remoteStatuses)
 	}
-	fmt.Println(c(ansiYellow, "⚠ ") + c(ansiBoldCyan, fmt.Sprintf("%d", changed)) + c(ansiYellow, " env changed"))
-	verbosef("Run 'sentra add .' to stage changed files")
 
 	return nil
 }
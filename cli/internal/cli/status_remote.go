@@ -0,0 +1,218 @@
+package cli
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mgeovany/sentra/cli/internal/commit"
+	"github.com/mgeovany/sentra/cli/internal/heads"
+	"github.com/mgeovany/sentra/cli/internal/scanner"
+	"github.com/mgeovany/sentra/shared/api"
+)
+
+const (
+	remoteUpToDate = "up_to_date"
+	remoteBehind   = "behind"
+	remoteAhead    = "ahead"
+	remoteDiverged = "diverged"
+)
+
+// remoteStatusJSON compares one local project with its remote head.
+// synced_commit is the remote commit this machine last synced or pushed.
+type remoteStatusJSON struct {
+	Root          string `json:"root"`
+	State         string `json:"state"`
+	SyncedCommit  string `json:"synced_commit,omitempty"`
+	RemoteCommit  string `json:"remote_commit,omitempty"`
+	RemoteAt      string `json:"remote_at,omitempty"`
+	RemoteMachine string `json:"remote_machine,omitempty"`
+	Unpushed      int    `json:"unpushed"`
+}
+
+// remoteStatus lists the projects checked out under scanRoot (or with
+// unpushed commits) that are on the remote or have something to push.
+// A project is behind when its remote head is not the commit recorded at
+// the last sync or push, ahead when it has unpushed commits, and diverged
+// when both hold.
+func remoteStatus(scanRoot string, projects []scanner.Project) ([]remoteStatusJSON, error) {
+	sess, err := ensureRemoteSession()
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(sess.AccessToken) == "" {
+		return nil, errNotLoggedIn
+	}
+	serverURL, err := serverURLFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	sp := startSpinner("Fetching project heads from remote...")
+	remote, err := fetchRemoteHeads(serverURL, sess.AccessToken)
+	sp.StopInfo("")
+	if err != nil {
+		return nil, err
+	}
+	verbosef("Fetched %d remote project head(s)", len(remote))
+
+	_, synced, err := loadSyncedHeads(serverURL)
+	if err != nil {
+		return nil, err
+	}
+
+	unpushed := map[string]int{}
+	commits, err := commit.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range commits {
+		if c.PushedAt != "" {
+			continue
+		}
+		for root := range pushCommitRoots(c) {
+			unpushed[root]++
+		}
+	}
+
+	local := map[string]bool{}
+	for _, p := range projects {
+		rel, err := filepath.Rel(scanRoot, p.RootPath)
+		if err != nil {
+			return nil, err
+		}
+		if root := projectRootFromPath(filepath.ToSlash(rel)); root != "" {
+			local[root] = true
+		}
+	}
+	for root := range unpushed {
+		local[root] = true
+	}
+
+	byRoot := map[string]api.ProjectHead{}
+	for _, h := range remote {
+		byRoot[strings.TrimSpace(h.RootPath)] = h
+	}
+
+	out := make([]remoteStatusJSON, 0, len(local))
+	for root := range local {
+		h, onRemote := byRoot[root]
+		if !onRemote && unpushed[root] == 0 {
+			continue
+		}
+		s := remoteStatusJSON{
+			Root:          root,
+			SyncedCommit:  synced.Projects[root].CommitID,
+			RemoteCommit:  strings.TrimSpace(h.CommitID),
+			RemoteAt:      strings.TrimSpace(h.CreatedAt),
+			RemoteMachine: strings.TrimSpace(h.MachineName),
+			Unpushed:      unpushed[root],
+		}
+		behind := onRemote && s.RemoteCommit != s.SyncedCommit
+		switch {
+		case behind && s.Unpushed > 0:
+			s.State = remoteDiverged
+		case behind:
+			s.State = remoteBehind
+		case s.Unpushed > 0:
+			s.State = remoteAhead
+		default:
+			s.State = remoteUpToDate
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Root < out[j].Root })
+	return out, nil
+}
+
+func printRemoteStatus(statuses []remoteStatusJSON) {
+	fmt.Println(c(ansiBoldCyan, "Remote"))
+	if len(statuses) == 0 {
+		fmt.Println(c(ansiDim, "  no local project is on the remote"))
+		return
+	}
+	for _, s := range statuses {
+		switch s.State {
+		case remoteUpToDate:
+			fmt.Println(c(ansiGreen, "  ✔ ") + s.Root + c(ansiDim, " up to date"))
+		case remoteBehind:
+			fmt.Println(c(ansiYellow, "  ⚠ ") + s.Root + c(ansiYellow, " behind: ") + describeRemoteHead(s) +
+				c(ansiDim, " (run: sentra sync --project "+s.Root+")"))
+		case remoteAhead:
+			detail := fmt.Sprintf("%d unpushed commit(s)", s.Unpushed)
+			if s.RemoteCommit == "" {
+				detail += ", not on remote yet"
+			}
+			fmt.Println(c(ansiYellow, "  ⚠ ") + s.Root + c(ansiYellow, " ahead: ") + detail +
+				c(ansiDim, " (run: sentra push --project "+s.Root+")"))
+		case remoteDiverged:
+			fmt.Println(c(ansiRed, "  ✖ ") + s.Root + c(ansiRed, " diverged: ") +
+				fmt.Sprintf("%d unpushed commit(s); remote ", s.Unpushed) + describeRemoteHead(s) +
+				c(ansiDim, " (review with: sentra sync --project "+s.Root+" --out <dir>)"))
+		}
+	}
+}
+
+func describeRemoteHead(s remoteStatusJSON) string {
+	msg := "updated"
+	if s.RemoteAt != "" {
+		msg += " " + s.RemoteAt
+	}
+	if s.RemoteMachine != "" {
+		msg += " from " + s.RemoteMachine
+	}
+	if s.SyncedCommit == "" {
+		msg += ", never synced here"
+	}
+	return msg
+}
+
+func fetchRemoteHeads(serverURL string, accessToken string) ([]api.ProjectHead, error) {
+	heads, err := apiClient(serverURL, accessToken, 15*time.Second).ProjectHeads(commandContext())
+	switch api.StatusCode(err) {
+	case 0:
+		return heads, err
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("server does not report project heads (update the server)")
+	default:
+		return nil, fmt.Errorf("failed to fetch project heads")
+	}
+}
+
+// loadSyncedHeads returns the heads recorded for serverURL. Heads recorded
+// against another server say nothing about this one and are dropped.
+func loadSyncedHeads(serverURL string) (string, heads.Heads, error) {
+	p, err := heads.DefaultPath()
+	if err != nil {
+		return "", heads.Heads{}, err
+	}
+	h, _, err := heads.Load(p)
+	if err != nil {
+		return "", heads.Heads{}, err
+	}
+	if h.ServerURL != serverURL {
+		h = heads.Heads{ServerURL: serverURL, Projects: map[string]heads.Head{}, Version: 1}
+	}
+	return p, h, nil
+}
+
+// recordSyncedHeads records commitIDs (project root -> remote commit) as
+// what this machine now has.
+func recordSyncedHeads(serverURL string, commitIDs map[string]string) error {
+	if len(commitIDs) == 0 {
+		return nil
+	}
+	p, h, err := loadSyncedHeads(serverURL)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	for root, id := range commitIDs {
+		h.Projects[root] = heads.Head{CommitID: id, SyncedAt: now}
+		verbosef("Recorded %s at remote commit %s", root, id)
+	}
+	return heads.Save(p, h)
+}
@@ -6,42 +6,63 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/mgeovany/sentra/cli/internal/storage"
+	"github.com/spf13/cobra"
 )
 
-func runStorage(args []string) error {
-	if len(args) == 0 {
-		return errors.New("usage: sentra storage setup|status|test|reset")
-	}
-
-	switch args[0] {
-	case "setup":
-		if len(args) != 1 {
-			return errors.New("usage: sentra storage setup")
-		}
-		return runStorageSetup()
-	case "status":
-		if len(args) != 1 {
-			return errors.New("usage: sentra storage status")
-		}
-		return runStorageStatus()
-	case "test":
-		if len(args) != 1 {
-			return errors.New("usage: sentra storage test")
-		}
-		return runStorageTest()
-	case "reset":
-		if len(args) != 1 {
-			return errors.New("usage: sentra storage reset")
-		}
-		return runStorageReset()
-	default:
-		return errors.New("usage: sentra storage setup|status|test|reset")
-	}
+func newStorageCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "storage",
+		Short:   "Manage BYOS storage (S3, directory, WebDAV, SFTP)",
+		GroupID: groupStorage,
+		Args:    exactArgs(0, "sentra storage setup|status|test|gc|migrate|reset"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return usageError("sentra storage setup|status|test|gc|migrate|reset")
+		},
+	}
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "setup",
+			Short: "Configure a storage backend",
+			Args:  exactArgs(0, "sentra storage setup"),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runStorageSetup()
+			},
+		},
+		&cobra.Command{
+			Use:   "status",
+			Short: "Show current storage config",
+			Args:  exactArgs(0, "sentra storage status"),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runStorageStatus()
+			},
+		},
+		&cobra.Command{
+			Use:   "test",
+			Short: "Test storage connectivity",
+			Args:  exactArgs(0, "sentra storage test"),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runStorageTest()
+			},
+		},
+		newStorageGCCmd(),
+		newStorageMigrateCmd(),
+		&cobra.Command{
+			Use:   "reset",
+			Short: "Remove storage config",
+			Args:  exactArgs(0, "sentra storage reset"),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runStorageReset()
+			},
+		},
+	)
+	return cmd
 }
 
 func runStorageStatus() error {
@@ -53,29 +74,36 @@ func runStorageStatus() error {
 	}
 	if ok {
 		successf("✔ BYOS: active")
-		fmt.Println(c(ansiDim, "Provider: ") + string(cfg.Provider))
-		fmt.Println(c(ansiDim, "Bucket: ") + cfg.Bucket)
-		fmt.Println(c(ansiDim, "Endpoint: ") + cfg.Endpoint)
-		if strings.TrimSpace(cfg.Region) != "" {
-			fmt.Println(c(ansiDim, "Region: ") + cfg.Region)
-		}
-		fmt.Println(c(ansiDim, "Auth: ") + string(cfg.AuthMethod))
-		switch cfg.AuthMethod {
-		case storage.AuthAWSProfile:
-			p := strings.TrimSpace(cfg.AWSProfile)
-			if p == "" {
-				p = "default"
+		fmt.Println(c(ansiDim, "Backend: ") + cfg.BackendName())
+		switch cfg.BackendName() {
+		case storage.BackendS3:
+			printS3StorageStatus(cfg)
+		case storage.BackendFS:
+			fmt.Println(c(ansiDim, "Path: ") + cfg.Path)
+		case storage.BackendWebDAV:
+			fmt.Println(c(ansiDim, "URL: ") + cfg.URL)
+			if strings.TrimSpace(cfg.Username) != "" {
+				fmt.Println(c(ansiDim, "Username: ") + cfg.Username)
+				fmt.Println(c(ansiDim, "Secret storage: ") + string(cfg.SecretLocation))
+			}
+		case storage.BackendSFTP:
+			port := cfg.Port
+			if port == 0 {
+				port = 22
+			}
+			fmt.Println(c(ansiDim, "Host: ") + fmt.Sprintf("%s@%s:%d", cfg.Username, cfg.Host, port))
+			fmt.Println(c(ansiDim, "Path: ") + cfg.Path)
+			if strings.TrimSpace(cfg.KeyFile) != "" {
+				fmt.Println(c(ansiDim, "Key file: ") + cfg.KeyFile)
+			}
+			if cfg.SecretLocation != storage.SecretNone && cfg.SecretLocation != "" {
+				fmt.Println(c(ansiDim, "Secret storage: ") + string(cfg.SecretLocation))
 			}
-			fmt.Println(c(ansiDim, "AWS profile: ") + p)
-		case storage.AuthStatic:
-			fmt.Println(c(ansiDim, "Secret storage: ") + string(cfg.SecretLocation))
-		case storage.AuthEnvOnly:
-			fmt.Println(c(ansiDim, "Secret storage: ") + "env")
 		}
 		return nil
 	}
 
-	_, _, enabled, err := storage.ResolveS3()
+	_, enabled, err := storage.Open()
 	if err != nil {
 		return err
 	}
@@ -90,6 +118,28 @@ func runStorageStatus() error {
 	return nil
 }
 
+func printS3StorageStatus(cfg storage.Config) {
+	fmt.Println(c(ansiDim, "Provider: ") + string(cfg.Provider))
+	fmt.Println(c(ansiDim, "Bucket: ") + cfg.Bucket)
+	fmt.Println(c(ansiDim, "Endpoint: ") + cfg.Endpoint)
+	if strings.TrimSpace(cfg.Region) != "" {
+		fmt.Println(c(ansiDim, "Region: ") + cfg.Region)
+	}
+	fmt.Println(c(ansiDim, "Auth: ") + string(cfg.AuthMethod))
+	switch cfg.AuthMethod {
+	case storage.AuthAWSProfile:
+		p := strings.TrimSpace(cfg.AWSProfile)
+		if p == "" {
+			p = "default"
+		}
+		fmt.Println(c(ansiDim, "AWS profile: ") + p)
+	case storage.AuthStatic:
+		fmt.Println(c(ansiDim, "Secret storage: ") + string(cfg.SecretLocation))
+	case storage.AuthEnvOnly:
+		fmt.Println(c(ansiDim, "Secret storage: ") + "env")
+	}
+}
+
 func runStorageTest() error {
 	_, ok, err := storage.LoadConfig()
 	if err != nil {
@@ -99,7 +149,7 @@ func runStorageTest() error {
 		return errors.New("no storage config found (run: sentra storage setup)")
 	}
 
-	cfg, client, enabled, err := storage.ResolveS3()
+	blobs, enabled, err := storage.Open()
 	if err != nil {
 		return err
 	}
@@ -108,9 +158,9 @@ func runStorageTest() error {
 	}
 
 	sp := startSpinner("Testing storage connection...")
-	ctx, cancel := context.WithTimeout(context.Background(), 45*time.Second)
+	ctx, cancel := context.WithTimeout(commandContext(), 45*time.Second)
 	defer cancel()
-	if err := storage.TestS3(ctx, client, cfg); err != nil {
+	if err := storage.Test(ctx, blobs); err != nil {
 		sp.StopInfo("")
 		return err
 	}
@@ -130,15 +180,78 @@ func runStorageReset() error {
 	return nil
 }
 
+// storageSetupFlows prompts for backend-specific settings. Every registered
+// storage backend needs an entry here to be offered by `sentra storage setup`.
+var storageSetupFlows = map[string]func(r *bufio.Reader, cfg *storage.Config) error{
+	storage.BackendS3:     setupS3Storage,
+	storage.BackendFS:     setupFSStorage,
+	storage.BackendWebDAV: setupWebDAVStorage,
+	storage.BackendSFTP:   setupSFTPStorage,
+}
+
 func runStorageSetup() error {
 	r := bufio.NewReader(os.Stdin)
 
+	var backends []storage.Backend
+	var titles []string
+	for _, b := range storage.Backends() {
+		if _, ok := storageSetupFlows[b.Name]; !ok {
+			continue
+		}
+		backends = append(backends, b)
+		titles = append(titles, b.Title)
+	}
+
 	fmt.Println()
+	fmt.Println(c(ansiBoldCyan, "Choose storage backend:"))
+	choice, err := promptSelect(r, titles)
+	if err != nil {
+		return err
+	}
+	fmt.Println() // Blank line after selection
+	backend := backends[choice-1]
+
+	cfg := storage.Config{Version: 1, Backend: backend.Name}
+	cfg.ID = uuid.NewString()
+	if err := storageSetupFlows[backend.Name](r, &cfg); err != nil {
+		return err
+	}
+
+	test, err := promptYesNo(r, "Test connection?", true)
+	if err != nil {
+		return err
+	}
+	if test {
+		blobs, err := storage.OpenConfig(cfg)
+		if err != nil {
+			return err
+		}
+		infof("Testing connection...")
+		ctx, cancel := context.WithTimeout(commandContext(), 45*time.Second)
+		defer cancel()
+		if err := storage.Test(ctx, blobs); err != nil {
+			return err
+		}
+		successf("✔ Connected")
+		successf("✔ Upload test OK")
+		successf("✔ Download test OK")
+	}
+
+	if err := storage.SaveConfig(cfg); err != nil {
+		return err
+	}
+	p, _ := storage.DefaultPath()
+	fmt.Printf("Saved to %s\n", p)
+	return nil
+}
+
+func setupS3Storage(r *bufio.Reader, cfg *storage.Config) error {
 	fmt.Println(c(ansiBoldCyan, "Choose storage provider:"))
 	providerChoice, err := promptSelect(r, []string{
 		"AWS S3",
 		"Cloudflare R2",
 		"MinIO (self-hosted)",
+		"Google Cloud Storage (HMAC keys)",
 		"Custom S3 endpoint",
 	})
 	if err != nil {
@@ -146,8 +259,7 @@ func runStorageSetup() error {
 	}
 	fmt.Println() // Blank line after selection
 
-	cfg := storage.Config{Version: 1, UseSSL: true}
-	cfg.ID = uuid.NewString()
+	cfg.UseSSL = true
 	switch providerChoice {
 	case 1:
 		cfg.Provider = storage.ProviderAWSS3
@@ -159,6 +271,10 @@ func runStorageSetup() error {
 		cfg.Provider = storage.ProviderMinIO
 		cfg.UseSSL = false
 	case 4:
+		// GCS speaks the S3 XML API with HMAC keys (interoperability mode).
+		cfg.Provider = storage.ProviderGCS
+		cfg.Endpoint = "storage.googleapis.com"
+	case 5:
 		cfg.Provider = storage.ProviderCustom
 	}
 
@@ -252,35 +368,142 @@ func runStorageSetup() error {
 	default:
 		return errors.New("invalid auth method")
 	}
+	return nil
+}
 
-	test, err := promptYesNo(r, "Test connection?", true)
+func setupFSStorage(r *bufio.Reader, cfg *storage.Config) error {
+	dir, err := promptLine(r, "Directory (local path or mounted NAS share)")
 	if err != nil {
 		return err
 	}
-	if test {
-		s3cfg, client, _, err := storage.ResolveS3FromConfig(cfg)
+	dir, err = filepath.Abs(expandUserHome(dir))
+	if err != nil {
+		return err
+	}
+	cfg.Path = dir
+	cfg.SecretLocation = storage.SecretNone
+	return nil
+}
+
+func setupWebDAVStorage(r *bufio.Reader, cfg *storage.Config) error {
+	u, err := promptLine(r, "WebDAV URL (e.g. https://cloud.example.com/remote.php/dav/files/me/sentra)")
+	if err != nil {
+		return err
+	}
+	cfg.URL = u
+	if strings.HasPrefix(strings.ToLower(u), "http://") {
+		warnf("⚠ WebDAV over http:// sends credentials in clear text")
+	}
+
+	user, err := promptLineOptional(r, "Username (optional)")
+	if err != nil {
+		return err
+	}
+	cfg.Username = user
+	cfg.SecretLocation = storage.SecretNone
+	if user == "" {
+		return nil
+	}
+	pw, err := promptSecret("Password (or app password)")
+	if err != nil {
+		return err
+	}
+	saveStoragePassword(cfg, pw)
+	return nil
+}
+
+func setupSFTPStorage(r *bufio.Reader, cfg *storage.Config) error {
+	host, err := promptLine(r, "SFTP host")
+	if err != nil {
+		return err
+	}
+	cfg.Host = host
+
+	port, err := promptLineOptional(r, "Port (default: 22)")
+	if err != nil {
+		return err
+	}
+	if port != "" {
+		n, err := strconv.Atoi(port)
+		if err != nil || n <= 0 || n > 65535 {
+			return fmt.Errorf("invalid port: %s", port)
+		}
+		cfg.Port = n
+	}
+
+	user, err := promptLine(r, "Username")
+	if err != nil {
+		return err
+	}
+	cfg.Username = user
+
+	dir, err := promptLine(r, "Remote directory (absolute)")
+	if err != nil {
+		return err
+	}
+	cfg.Path = dir
+
+	fmt.Println(c(ansiBoldCyan, "Auth method"))
+	authChoice, err := promptSelect(r, []string{
+		"ssh-agent (recommended)",
+		"Private key file (unencrypted)",
+		"Password",
+	})
+	if err != nil {
+		return err
+	}
+	cfg.SecretLocation = storage.SecretNone
+	switch authChoice {
+	case 1:
+		if strings.TrimSpace(os.Getenv("SSH_AUTH_SOCK")) == "" {
+			warnf("⚠ SSH_AUTH_SOCK is not set; start ssh-agent before pushing")
+		}
+	case 2:
+		kf, err := promptLine(r, "Private key file")
 		if err != nil {
 			return err
 		}
-		infof("Testing connection...")
-		ctx, cancel := context.WithTimeout(context.Background(), 45*time.Second)
-		defer cancel()
-		if err := storage.TestS3(ctx, client, s3cfg); err != nil {
+		kf, err = filepath.Abs(expandUserHome(kf))
+		if err != nil {
 			return err
 		}
-		successf("✔ Connected")
-		successf("✔ Upload test OK")
-		successf("✔ Download test OK")
+		cfg.KeyFile = kf
+	case 3:
+		pw, err := promptSecret("Password")
+		if err != nil {
+			return err
+		}
+		saveStoragePassword(cfg, pw)
+	default:
+		return errors.New("invalid auth method")
 	}
 
-	if err := storage.SaveConfig(cfg); err != nil {
+	kh, err := promptLineOptional(r, "known_hosts file (default: ~/.ssh/known_hosts)")
+	if err != nil {
 		return err
 	}
-	p, _ := storage.DefaultPath()
-	fmt.Printf("Saved to %s\n", p)
+	if kh != "" {
+		kh, err = filepath.Abs(expandUserHome(kh))
+		if err != nil {
+			return err
+		}
+		cfg.KnownHostsFile = kh
+	}
 	return nil
 }
 
+// saveStoragePassword prefers the keychain and falls back to storage.json.
+func saveStoragePassword(cfg *storage.Config, pw string) {
+	cfg.SecretLocation = storage.SecretKeyring
+	cfg.SecretRef = storage.SecretRefForID(cfg.ID)
+	if err := storage.SaveSecret(cfg.SecretRef, pw, ""); err != nil {
+		fmt.Println("Warning: could not save to keychain; falling back to local file (~/.sentra/storage.json)")
+		cfg.SecretLocation = storage.SecretFile
+		cfg.Password = pw
+		cfg.SecretRef = ""
+	}
+}
+
 func promptLine(r *bufio.Reader, label string) (string, error) {
 	for {
 		v, err := promptBox(r, label, "", "")
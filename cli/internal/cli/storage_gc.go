@@ -0,0 +1,251 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mgeovany/sentra/cli/internal/auth"
+	"github.com/mgeovany/sentra/cli/internal/storage"
+	"github.com/mgeovany/sentra/shared/api"
+	"github.com/spf13/cobra"
+)
+
+func newStorageGCCmd() *cobra.Command {
+	var (
+		grace  time.Duration
+		dryRun bool
+		yes    bool
+	)
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Delete stored objects that no remote file references",
+		Long: `Delete stored objects that no remote file references.
+
+Only objects older than --grace are deleted, so a push in progress keeps the
+objects it just uploaded. A push that reuses an existing object refreshes its
+modification time first (on S3, fs and SFTP; WebDAV pushes upload it again),
+and references are checked again right before deleting.
+
+One race remains: a push that reuses an old, unreferenced object (for example
+after "sentra projects rm" and a push of the same content) in the moment
+between that last check and the delete leaves a commit pointing to a deleted
+object. Run gc while no push is running.`,
+		Args: exactArgs(0, "sentra storage gc [--grace <duration>] [--dry-run] [--yes]"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runStorageGC(grace, dryRun, yes)
+		},
+	}
+	// The grace period protects objects uploaded by a push that has not reached the server yet.
+	cmd.Flags().DurationVar(&grace, "grace", 7*24*time.Hour, "Only delete objects older than this")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List what would be deleted without deleting")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Do not ask for confirmation")
+	return cmd
+}
+
+func runStorageGC(grace time.Duration, dryRun bool, yes bool) error {
+	if grace < 0 {
+		return usageError("sentra storage gc [--grace <duration>] [--dry-run] [--yes]")
+	}
+	serverURL, accessToken, userID, err := storageUserSession("storage gc")
+	if err != nil {
+		return err
+	}
+
+	blobs, enabled, err := storage.Open()
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return errors.New("no storage config found (run: sentra storage setup)")
+	}
+
+	// List before fetching references: a commit that lands in between then
+	// shows up as a reference instead of leaving its objects unprotected.
+	sp := startSpinner("Scanning storage...")
+	ctx := commandContext()
+	var objects []storage.ObjectInfo
+	for _, prefix := range blobObjectPrefixes(userID) {
+		objs, err := blobs.List(ctx, prefix)
+		if err != nil {
+			sp.StopInfo("")
+			return fmt.Errorf("failed to list storage (%s): %w", prefix, err)
+		}
+		objects = append(objects, objs...)
+	}
+	referenced, err := fetchStorageRefSet(serverURL, accessToken)
+	if err != nil {
+		sp.StopInfo("")
+		return err
+	}
+	sp.StopInfo("")
+
+	if len(referenced) == 0 && len(objects) > 0 {
+		// An empty answer more likely means a broken server than an empty account.
+		return fmt.Errorf("server reports no referenced objects but storage holds %d; refusing to delete", len(objects))
+	}
+
+	cutoff := time.Now().Add(-grace)
+	var garbage []storage.ObjectInfo
+	var young int
+	for _, o := range objects {
+		if referenced[o.Key] {
+			continue
+		}
+		if o.ModTime.IsZero() || o.ModTime.After(cutoff) {
+			young++
+			continue
+		}
+		garbage = append(garbage, o)
+	}
+	sort.Slice(garbage, func(i, j int) bool { return garbage[i].Key < garbage[j].Key })
+
+	var size int64
+	for _, o := range garbage {
+		size += o.Size
+	}
+	loc := blobs.Location()
+	fmt.Println(c(ansiBoldCyan, "Storage GC") + c(ansiDim, " ("+loc.Provider+": "+loc.Bucket+")"))
+	fmt.Printf("%d object(s), %d referenced, %d unreferenced within grace period\n", len(objects), len(objects)-len(garbage)-young, young)
+	if len(garbage) == 0 {
+		successf("✔ nothing to delete")
+		return nil
+	}
+	fmt.Printf("%d unreferenced object(s) older than %s (%d bytes)\n", len(garbage), grace, size)
+
+	if dryRun {
+		for _, o := range garbage {
+			fmt.Println("  " + o.Key)
+		}
+		infof("Dry run: nothing deleted")
+		return nil
+	}
+
+	if !yes {
+		if !isTTY(os.Stdin) {
+			return errors.New("refusing to delete without a TTY (use --yes)")
+		}
+		ok, err := promptYesNo(bufio.NewReader(os.Stdin), fmt.Sprintf("Delete %d object(s)?", len(garbage)), false)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("gc cancelled")
+		}
+	}
+
+	// Check references again right before deleting: a push may have
+	// committed one of these objects while the prompt was open.
+	referenced, err = fetchStorageRefSet(serverURL, accessToken)
+	if err != nil {
+		return err
+	}
+	sp = startSpinner("Deleting unreferenced objects...")
+	var failed, kept int
+	for _, o := range garbage {
+		if referenced[o.Key] {
+			kept++
+			continue
+		}
+		if err := blobs.Delete(ctx, o.Key); err != nil {
+			failed++
+			verbosef("delete failed %s: %v", o.Key, err)
+		}
+	}
+	deleted := len(garbage) - failed - kept
+	if failed > 0 {
+		sp.StopInfo("")
+		return fmt.Errorf("deleted %d object(s), %d failed", deleted, failed)
+	}
+	sp.StopSuccess(fmt.Sprintf("✔ deleted %d object(s)", deleted))
+	if kept > 0 {
+		infof("Kept %d object(s) a push referenced meanwhile", kept)
+	}
+	return nil
+}
+
+// storageUserSession resolves the interactive session that storage-wide
+// commands need; the server refuses service tokens for them.
+func storageUserSession(command string) (serverURL string, accessToken string, userID string, err error) {
+	if serviceTokenFromEnv() != "" {
+		return "", "", "", fmt.Errorf("%s requires a user session (unset SENTRA_TOKEN and run: sentra login)", command)
+	}
+	sess, err := ensureRemoteSession()
+	if err != nil {
+		return "", "", "", err
+	}
+	if strings.TrimSpace(sess.AccessToken) == "" {
+		return "", "", "", errNotLoggedIn
+	}
+	serverURL, err = serverURLFromEnv()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	cfg, err := auth.EnsureConfig()
+	if err != nil {
+		return "", "", "", err
+	}
+	userID = strings.TrimSpace(cfg.UserID)
+	if userID == "" {
+		if claims, err := auth.ParseAccessTokenClaims(sess.AccessToken); err == nil {
+			userID = strings.TrimSpace(claims.Sub)
+		}
+	}
+	if userID == "" {
+		return "", "", "", errNotLoggedIn
+	}
+	return serverURL, sess.AccessToken, userID, nil
+}
+
+func fetchStorageRefSet(serverURL string, accessToken string) (map[string]bool, error) {
+	refs, err := fetchStorageRefs(serverURL, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[string]bool, len(refs))
+	for _, k := range refs {
+		set[k] = true
+	}
+	return set, nil
+}
+
+func fetchStorageRefs(serverURL string, accessToken string) ([]string, error) {
+	req, err := http.NewRequestWithContext(commandContext(), http.MethodGet, serverURL+api.PathStorageRefs, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(accessToken))
+
+	client := newHTTPClient(60 * time.Second)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg := oneLine(string(body))
+		if msg == "" {
+			msg = strings.TrimSpace(http.StatusText(resp.StatusCode))
+		}
+		return nil, fmt.Errorf("storage refs request failed: %s", msg)
+	}
+
+	var out struct {
+		Keys []string `json:"keys"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, err
+	}
+	return out.Keys, nil
+}
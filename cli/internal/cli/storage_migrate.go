@@ -0,0 +1,428 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mgeovany/sentra/cli/internal/auth"
+	"github.com/mgeovany/sentra/cli/internal/storage"
+	"github.com/mgeovany/sentra/shared/api"
+	"github.com/spf13/cobra"
+)
+
+const storageMigrateUsage = "sentra storage migrate --to byos|hosted [--bucket <name>] [--project <root>]"
+
+// Relocate batches stay under the server's per-request limit.
+const migrateBatchFiles = 200
+
+type fileLocationV1 struct {
+	CommitID        string `json:"commit_id"`
+	FilePath        string `json:"file_path"`
+	SHA256          string `json:"sha256"`
+	BlobB64         string `json:"blob_b64,omitempty"`
+	StorageProvider string `json:"storage_provider,omitempty"`
+	StorageBucket   string `json:"storage_bucket,omitempty"`
+	StorageKey      string `json:"storage_key,omitempty"`
+	StorageEndpoint string `json:"storage_endpoint,omitempty"`
+	StorageRegion   string `json:"storage_region,omitempty"`
+}
+
+type relocateRequestV1 struct {
+	Root  string           `json:"root"`
+	Files []fileLocationV1 `json:"files"`
+}
+
+func newStorageMigrateCmd() *cobra.Command {
+	var to, bucket, project string
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Move existing remote files between hosted storage and BYOS",
+		Args:  exactArgs(0, storageMigrateUsage),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runStorageMigrate(to, bucket, project)
+		},
+	}
+	cmd.Flags().StringVar(&to, "to", "", "Target storage: byos or hosted")
+	cmd.Flags().StringVar(&bucket, "bucket", "", "With --to byos on S3: copy into this bucket instead of the configured one")
+	cmd.Flags().StringVar(&project, "project", "", "Only migrate this project (default: all projects)")
+	_ = cmd.RegisterFlagCompletionFunc("to", cobra.FixedCompletions([]string{"byos", "hosted"}, cobra.ShellCompDirectiveNoFileComp))
+	_ = cmd.RegisterFlagCompletionFunc("project", completeRemoteProjects)
+	return cmd
+}
+
+// runStorageMigrate copies every historical blob to the target and repoints
+// the remote file rows in batches. Rows already at the target are skipped,
+// so an interrupted run is resumed by running it again.
+func runStorageMigrate(to string, bucket string, project string) error {
+	to = strings.TrimSpace(to)
+	bucket = strings.TrimSpace(bucket)
+	if to != "byos" && to != "hosted" {
+		return usageError(storageMigrateUsage)
+	}
+	if bucket != "" && to != "byos" {
+		return usageError(storageMigrateUsage)
+	}
+
+	serverURL, accessToken, userID, err := storageUserSession("storage migrate")
+	if err != nil {
+		return err
+	}
+
+	var target storage.BlobStore = newHostedBlobStore(serverURL, accessToken)
+	if to == "byos" {
+		target, err = openMigrateTarget(bucket)
+		if err != nil {
+			return err
+		}
+		loc := target.Location()
+		verbosef("Target storage: provider=%s, bucket=%s, endpoint=%s", loc.Provider, loc.Bucket, loc.Endpoint)
+	}
+
+	vaultKey, err := ensureVaultKey(serverURL, accessToken)
+	if err != nil {
+		return err
+	}
+
+	var roots []string
+	if project = strings.TrimSpace(project); project != "" {
+		roots = []string{projectRootFromPath(project)}
+	} else {
+		projects, err := fetchRemoteProjects(serverURL, accessToken, fileFilter{})
+		if err != nil {
+			return err
+		}
+		for _, p := range projects {
+			if r := strings.TrimSpace(p.RootPath); r != "" {
+				roots = append(roots, r)
+			}
+		}
+		sort.Strings(roots)
+	}
+
+	m := storageMigration{
+		serverURL:   serverURL,
+		accessToken: accessToken,
+		userID:      userID,
+		vaultKey:    vaultKey,
+		target:      target,
+	}
+	for _, root := range roots {
+		if err := m.migrateProject(root); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println(c(ansiBoldCyan, "Storage migrate") + c(ansiDim, " (to "+to+")"))
+	fmt.Printf("%d file(s) moved, %d already in place, %d skipped\n", m.moved, m.inPlace, len(m.skipped))
+	for _, s := range m.skipped {
+		warnf("⚠ %s", s)
+	}
+	if len(m.skipped) > 0 {
+		return fmt.Errorf("%d file(s) were not migrated; fix the cause and run the command again", len(m.skipped))
+	}
+
+	// Future pushes follow the migrated data.
+	cfg, err := auth.EnsureConfig()
+	if err != nil {
+		return err
+	}
+	if project == "" && cfg.StorageMode != to {
+		cfg.StorageMode = to
+		if err := auth.SaveConfig(cfg); err != nil {
+			return err
+		}
+		infof("Storage mode set to %s for future pushes", to)
+	}
+	successf("✔ migration complete")
+	if to == "hosted" || bucket != "" {
+		infof("Objects in the previous storage were not deleted")
+	}
+	return nil
+}
+
+func openMigrateTarget(bucket string) (storage.BlobStore, error) {
+	if bucket == "" {
+		blobs, enabled, err := storage.Open()
+		if err != nil {
+			return nil, err
+		}
+		if !enabled {
+			return nil, storage.ErrNotConfigured
+		}
+		return blobs, nil
+	}
+
+	cfg, ok, err := storage.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, storage.ErrNotConfigured
+	}
+	if cfg.BackendName() != storage.BackendS3 {
+		return nil, fmt.Errorf("--bucket only applies to S3 storage (configured: %s)", cfg.BackendName())
+	}
+	cfg.Bucket = bucket
+	return storage.OpenConfig(cfg)
+}
+
+type storageMigration struct {
+	serverURL   string
+	accessToken string
+	userID      string
+	vaultKey    []byte
+	target      storage.BlobStore
+
+	moved   int
+	inPlace int
+	skipped []string
+}
+
+func (m *storageMigration) migrateProject(root string) error {
+	sp := startSpinner(fmt.Sprintf("Migrating %s...", root))
+
+	files, err := m.projectFiles(root)
+	if err != nil {
+		sp.StopInfo("")
+		return err
+	}
+	verbosef("%s: %d stored file version(s)", root, len(files))
+
+	var batch []fileLocationV1
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		updated, err := m.relocate(root, batch)
+		if err != nil {
+			return err
+		}
+		if updated < len(batch) {
+			// Rows whose sha256 no longer matches are left alone by the server.
+			m.skipped = append(m.skipped, fmt.Sprintf("%s: server updated %d of %d file(s)", root, updated, len(batch)))
+		}
+		m.moved += updated
+		batch = nil
+		return nil
+	}
+
+	for _, f := range files {
+		if m.inTarget(f) {
+			m.inPlace++
+			continue
+		}
+		loc, err := m.copyFile(f)
+		if err != nil {
+			short := strings.TrimSpace(f.CommitID)
+			if len(short) > 8 {
+				short = short[:8]
+			}
+			m.skipped = append(m.skipped, fmt.Sprintf("%s (commit %s): %v", f.FilePath, short, err))
+			continue
+		}
+		if len(batch) >= migrateBatchFiles {
+			if err := flush(); err != nil {
+				sp.StopInfo("")
+				return err
+			}
+		}
+		batch = append(batch, loc)
+	}
+	if err := flush(); err != nil {
+		sp.StopInfo("")
+		return err
+	}
+	sp.StopSuccess(fmt.Sprintf("✔ %s", root))
+	return nil
+}
+
+// projectFiles returns every stored file version of a project, one per
+// (commit, path), by exporting each remote commit.
+func (m *storageMigration) projectFiles(root string) ([]api.ExportFile, error) {
+	commits, err := fetchRemoteCommits(m.serverURL, m.accessToken, root)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var out []api.ExportFile
+	for _, cm := range commits {
+		files, err := fetchRemoteExport(m.serverURL, m.accessToken, root, strings.TrimSpace(cm.CommitID), fileFilter{})
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			id := strings.TrimSpace(f.CommitID) + "\x00" + strings.TrimSpace(f.FilePath)
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			out = append(out, f)
+		}
+	}
+	return out, nil
+}
+
+func (m *storageMigration) inTarget(f api.ExportFile) bool {
+	loc := m.target.Location()
+	provider := strings.TrimSpace(f.StorageProvider)
+	if strings.TrimSpace(f.BlobB64) != "" || strings.TrimSpace(f.StorageKey) == "" {
+		// Inline rows predate hosted blobs and are already hosted.
+		return loc.Provider == hostedProvider
+	}
+	if provider == "" {
+		provider = storage.BackendS3
+	}
+	return provider == loc.Provider &&
+		strings.TrimSpace(f.StorageBucket) == loc.Bucket &&
+		strings.TrimSpace(f.StorageEndpoint) == loc.Endpoint
+}
+
+// copyFile writes one file version to the target and verifies the copy
+// decrypts to the recorded sha256 before the row is repointed. The
+// ciphertext is spooled to a temp file, so large files are not held in memory.
+func (m *storageMigration) copyFile(f api.ExportFile) (fileLocationV1, error) {
+	src, err := openStoredBlob(m.serverURL, m.accessToken, f)
+	if err != nil {
+		return fileLocationV1{}, err
+	}
+	spool, err := os.CreateTemp("", "sentra-migrate-*")
+	if err != nil {
+		_ = src.Close()
+		return fileLocationV1{}, err
+	}
+	defer func() {
+		_ = spool.Close()
+		_ = os.Remove(spool.Name())
+	}()
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(spool, h), src)
+	_ = src.Close()
+	if err != nil {
+		return fileLocationV1{}, err
+	}
+
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return fileLocationV1{}, err
+	}
+	if err := m.verify(f, spool); err != nil {
+		return fileLocationV1{}, err
+	}
+
+	// Bound ciphers share content-addressed objects with push; anything else
+	// is addressed by its ciphertext so ciphers never mix.
+	id := hex.EncodeToString(h.Sum(nil))
+	cipherName := strings.TrimSpace(f.Cipher)
+	if cipherName == "sentra-v2" || cipherName == auth.EnvStreamCipher {
+		id = auth.BoundBlobID(m.vaultKey, cipherName, envBlobBinding(f))
+	}
+	root := projectRootFromPath(f.FilePath)
+	loc := m.target.Location()
+	key := blobObjectKey(m.userID, id)
+	if loc.Provider == hostedProvider {
+		key = hostedBlobKey(root, id)
+	}
+
+	ctx, cancel := context.WithTimeout(commandContext(), 10*time.Minute)
+	defer cancel()
+	exists, err := m.target.Exists(ctx, key)
+	if err != nil {
+		return fileLocationV1{}, err
+	}
+	if !exists {
+		if _, err := spool.Seek(0, io.SeekStart); err != nil {
+			return fileLocationV1{}, err
+		}
+		if err := m.target.Put(ctx, key, spool, size); err != nil {
+			return fileLocationV1{}, err
+		}
+	}
+	back, err := m.target.Get(ctx, key)
+	if err != nil {
+		return fileLocationV1{}, err
+	}
+	err = m.verify(f, back)
+	_ = back.Close()
+	if err != nil {
+		return fileLocationV1{}, fmt.Errorf("copy verification failed: %w", err)
+	}
+
+	return fileLocationV1{
+		CommitID:        strings.TrimSpace(f.CommitID),
+		FilePath:        strings.TrimSpace(f.FilePath),
+		SHA256:          strings.TrimSpace(f.SHA256),
+		StorageProvider: loc.Provider,
+		StorageBucket:   loc.Bucket,
+		StorageKey:      key,
+		StorageEndpoint: loc.Endpoint,
+		StorageRegion:   loc.Region,
+	}, nil
+}
+
+// verify decrypts a ciphertext of f to the end, checking it against the
+// recorded sha256 and size.
+func (m *storageMigration) verify(f api.ExportFile, ciphertext io.Reader) error {
+	plain, err := decryptEnvStream(f, ciphertext, m.vaultKey)
+	if err == nil {
+		_, err = io.Copy(io.Discard, plain)
+	}
+	if err != nil {
+		var ie integrityError
+		if errors.As(err, &ie) {
+			return errors.New(ie.Reason)
+		}
+		if strings.TrimSpace(f.Cipher) == "ed25519+aes-256-gcm-v1" {
+			return errors.New("legacy device-local encryption; migrate from the original machine")
+		}
+		return fmt.Errorf("decrypt failed: %w", err)
+	}
+	return nil
+}
+
+func (m *storageMigration) relocate(root string, files []fileLocationV1) (int, error) {
+	b, err := json.Marshal(relocateRequestV1{Root: root, Files: files})
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(commandContext(), http.MethodPost, m.serverURL+api.PathStorageMigrate, bytes.NewReader(b))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(m.accessToken))
+
+	client := newHTTPClient(60 * time.Second)
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg := oneLine(string(body))
+		if msg == "" {
+			msg = strings.TrimSpace(http.StatusText(resp.StatusCode))
+		}
+		return 0, fmt.Errorf("storage migrate request failed: %s", msg)
+	}
+
+	var out struct {
+		Updated int `json:"updated"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return 0, err
+	}
+	return out.Updated, nil
+}
@@ -1,39 +1,67 @@
 package cli
 
 import (
-	"context"
+	"bytes"
+	"crypto/sha256"
 	"encoding/base64"
-	"encoding/json"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
-	"net/http"
-	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/mgeovany/sentra/cli/internal/auth"
 	"github.com/mgeovany/sentra/cli/internal/storage"
+	"github.com/mgeovany/sentra/shared/api"
+	"github.com/spf13/cobra"
 )
 
-// sentra sync
-// Downloads latest env files from remote and writes them into local repos under scan root.
-func runSync(args []string) error {
-	outDir, err := parseSyncArgs(args)
-	if err != nil {
-		return err
+const syncUsage = "sentra sync [--out <dir>] [--project <root>]... [--path <glob>] [--env <name>] [--dry-run]"
+
+func newSyncCmd() *cobra.Command {
+	var out string
+	var dryRun bool
+	var ff filterFlags
+	cmd := &cobra.Command{
+		Use:     "sync",
+		Short:   "Download latest env files and write them locally",
+		GroupID: groupRemote,
+		Args:    exactArgs(0, syncUsage),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if cmd.Flags().Changed("out") && strings.TrimSpace(out) == "" {
+				return usageError(syncUsage)
+			}
+			filter, err := ff.filter()
+			if err != nil {
+				return err
+			}
+			return runSync(strings.TrimSpace(out), filter, dryRun)
+		},
 	}
+	cmd.Flags().StringVarP(&out, "out", "o", "", "Write files under this directory instead of the scan root")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List the files that would be written without downloading them")
+	ff.register(cmd)
+	_ = cmd.MarkFlagDirname("out")
+	return cmd
+}
 
+// sentra sync
+// Downloads latest env files from remote and writes them into local repos under scan root.
+// Only the projects and files selected by filter are synced.
+func runSync(outDir string, filter fileFilter, dryRun bool) error {
 	verbosef("Starting sync operation...")
 	sess, err := ensureRemoteSession()
 	if err != nil {
 		return err
 	}
 	if strings.TrimSpace(sess.AccessToken) == "" {
-		return errors.New("not logged in (run: sentra login)")
+		return errNotLoggedIn
 	}
 	verbosef("Session loaded: user authenticated")
 
@@ -54,20 +82,43 @@ func runSync(args []string) error {
 		return err
 	}
 	verbosef("Server URL: %s", serverURL)
-	if strings.TrimSpace(outDir) == "" {
+	if strings.TrimSpace(outDir) == "" && !dryRun {
 		warnf("⚠ sentra sync will overwrite local env files under %s based on remote state", scanRoot)
 		infof("Hint: use `sentra sync --out <dir>` to write into a separate folder")
 	}
 
-	// Vault key is only needed when decrypting sentra-v1 files; fetch lazily.
+	// Vault key is only needed when decrypting vault-key files; fetch lazily.
 	var vaultKey []byte
 
 	sp := startSpinner("Fetching projects from remote...")
-	projects, err := fetchRemoteProjects(serverURL, sess.AccessToken)
+
+	// A sync of whole projects into the scan root brings them to the remote
+	// head, which `sentra status --remote` compares against later. Heads are
+	// read first, so a push landing mid-sync leaves the project behind
+	// rather than wrongly up to date.
+	remoteHeads := map[string]string{}
+	recordHeads := strings.TrimSpace(outDir) == "" && !dryRun && !filter.selectsFiles()
+	if recordHeads {
+		hs, err := fetchRemoteHeads(serverURL, sess.AccessToken)
+		if err != nil {
+			verbosef("Not recording synced heads: %v", err)
+			recordHeads = false
+		}
+		for _, h := range hs {
+			remoteHeads[strings.TrimSpace(h.RootPath)] = strings.TrimSpace(h.CommitID)
+		}
+	}
+
+	projects, err := fetchRemoteProjects(serverURL, sess.AccessToken, filter)
 	if err != nil {
 		sp.StopInfo("")
 		return err
 	}
+	for _, root := range filter.roots() {
+		if !slices.ContainsFunc(projects, func(p api.Project) bool { return strings.TrimSpace(p.RootPath) == root }) {
+			warnf("⚠ project %s not found on remote", root)
+		}
+	}
 	if len(projects) == 0 {
 		sp.StopSuccess("✔ 0 projects")
 		fmt.Println("✔ 0 projects")
@@ -85,6 +136,9 @@ func runSync(args []string) error {
 	written := 0
 	scanned := 0
 	skippedMissing := 0
+	var corrupt []integrityError
+	var planned []string
+	syncedHeads := map[string]string{}
 	sp2 := startSpinner("Syncing projects...")
 	for i, p := range projects {
 		root := strings.TrimSpace(p.RootPath)
@@ -103,7 +157,7 @@ func runSync(args []string) error {
 		}
 
 		verbosef("Fetching files for project: %s", root)
-		files, err := fetchRemoteExport(serverURL, sess.AccessToken, root)
+		files, err := fetchRemoteExport(serverURL, sess.AccessToken, root, "", filter)
 		if err != nil {
 			sp2.StopInfo("")
 			return err
@@ -115,17 +169,12 @@ func runSync(args []string) error {
 		verbosef("Found %d file(s) for project: %s", len(files), root)
 
 		scanned++
+		corruptBefore := len(corrupt)
 		for _, f := range files {
-			verbosef("Processing file: %s (size: %d bytes, cipher: %s)", f.Path, f.Size, f.Cipher)
-			plain, err := decryptRemoteExportFile(serverURL, sess.AccessToken, &vaultKey, f)
-			if err != nil {
-				sp2.StopInfo("")
-				return err
-			}
-			verbosef("Decrypted file: %s (%d bytes)", f.Path, len(plain))
+			verbosef("Processing file: %s (size: %d bytes, cipher: %s)", f.FilePath, f.Size, f.Cipher)
 
 			// Server returns full file path (e.g. "root/.env"); write into scanRoot.
-			rel := filepath.ToSlash(strings.TrimSpace(f.Path))
+			rel := filepath.ToSlash(strings.TrimSpace(f.FilePath))
 			if rel == "" || strings.HasPrefix(rel, "/") || strings.HasPrefix(rel, "\\") {
 				return fmt.Errorf("invalid file path received from server")
 			}
@@ -140,20 +189,48 @@ func runSync(args []string) error {
 			}
 
 			outPath := filepath.Join(destRoot, filepath.FromSlash(rel))
+			if dryRun {
+				planned = append(planned, fmt.Sprintf("  would write %s (%d bytes)", outPath, f.Size))
+				written++
+				continue
+			}
 			verbosef("Writing file to: %s", outPath)
-			if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
-				sp2.StopInfo("")
-				return err
+			rc, err := openEnvFile(serverURL, sess.AccessToken, &vaultKey, f)
+			if err == nil {
+				err = writeEnvFile(outPath, rc)
+				_ = rc.Close()
 			}
-			if err := os.WriteFile(outPath, plain, 0o600); err != nil {
+			if err != nil {
+				var ie integrityError
+				if errors.As(err, &ie) {
+					corrupt = append(corrupt, ie)
+					continue
+				}
 				sp2.StopInfo("")
+				if errors.Is(err, storage.ErrNotConfigured) {
+					return fmt.Errorf("sync requires storage setup (run: sentra storage setup)")
+				}
 				return err
 			}
 			written++
 			verbosef("Successfully wrote file: %s", outPath)
 		}
+		if recordHeads && len(corrupt) == corruptBefore && remoteHeads[root] != "" {
+			syncedHeads[root] = remoteHeads[root]
+		}
+	}
+	if err := recordSyncedHeads(serverURL, syncedHeads); err != nil {
+		verbosef("Failed to record synced heads: %v", err)
+	}
+	if dryRun {
+		sp2.StopInfo("")
+		for _, line := range planned {
+			fmt.Println(line)
+		}
+		infof("Dry run: %d env file(s) across %d project(s) would be written", written, scanned)
+	} else {
+		sp2.StopSuccess(fmt.Sprintf("✔ synced %d env file(s) across %d project(s)", written, scanned))
 	}
-	sp2.StopSuccess(fmt.Sprintf("✔ synced %d env file(s) across %d project(s)", written, scanned))
 	if strings.TrimSpace(outDir) == "" {
 		if skippedMissing > 0 {
 			warnf("⚠ %d project(s) missing locally under %s", skippedMissing, scanRoot)
@@ -161,160 +238,269 @@ func runSync(args []string) error {
 		}
 	}
 	verbosef("Sync completed: %d file(s) written, %d project(s) synced, %d skipped", written, scanned, skippedMissing)
-	return nil
+	return reportIntegrityErrors(corrupt)
 }
 
-func parseSyncArgs(args []string) (out string, err error) {
-	// sentra sync [--out <dir>]
-	if len(args) == 0 {
-		return "", nil
+// reportIntegrityErrors warns about each file that failed verification and
+// turns them into the command's error. Those files were not written.
+func reportIntegrityErrors(corrupt []integrityError) error {
+	if len(corrupt) == 0 {
+		return nil
 	}
-
-	for i := 0; i < len(args); i++ {
-		switch args[i] {
-		case "--out", "-o":
-			if i+1 >= len(args) {
-				return "", errors.New("usage: sentra sync [--out <dir>]")
-			}
-			if strings.TrimSpace(out) != "" {
-				return "", errors.New("sync: --out provided multiple times")
-			}
-			out = strings.TrimSpace(args[i+1])
-			if out == "" {
-				return "", errors.New("usage: sentra sync [--out <dir>]")
-			}
-			i++
-		default:
-			return "", errors.New("usage: sentra sync [--out <dir>]")
-		}
+	for _, e := range corrupt {
+		warnf("⚠ %s", e.Error())
 	}
-	return out, nil
+	return fmt.Errorf("%d file(s) failed integrity verification and were not written", len(corrupt))
 }
 
-func fetchRemoteProjects(serverURL string, accessToken string) ([]remoteProject, error) {
-	endpoint := strings.TrimRight(strings.TrimSpace(serverURL), "/") + "/projects"
-	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, endpoint, nil)
+// fetchRemoteProjects lists the user's remote projects selected by f.
+func fetchRemoteProjects(serverURL string, accessToken string, f fileFilter) ([]api.Project, error) {
+	projects, err := apiClient(serverURL, accessToken, 20*time.Second).Projects(commandContext())
 	if err != nil {
+		if api.StatusCode(err) != 0 {
+			return nil, fmt.Errorf("failed to fetch projects")
+		}
 		return nil, err
 	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(accessToken))
+	out := projects[:0]
+	for _, p := range projects {
+		if f.matchProject(p.RootPath) {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
 
-	client := &http.Client{Timeout: 20 * time.Second}
-	resp, err := client.Do(req)
+// fetchRemoteExport returns the encrypted files of root selected by f, at
+// commit at (latest when empty).
+func fetchRemoteExport(serverURL string, accessToken string, root string, at string, f fileFilter) ([]api.ExportFile, error) {
+	files, err := apiClient(serverURL, accessToken, 45*time.Second).Export(commandContext(), root, at)
 	if err != nil {
+		if api.StatusCode(err) != 0 {
+			return nil, fmt.Errorf("export failed")
+		}
 		return nil, err
 	}
-	defer func() { _ = resp.Body.Close() }()
+	if f.selectsFiles() {
+		selected := files[:0]
+		for _, file := range files {
+			if f.matchFile(file.FilePath) {
+				selected = append(selected, file)
+			}
+		}
+		files = selected
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return strings.TrimSpace(files[i].FilePath) < strings.TrimSpace(files[j].FilePath)
+	})
+	return files, nil
+}
 
-	body, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("failed to fetch projects")
+// openStoredBlob opens the ciphertext of f: inline in the export, hosted by
+// the server, or in the BYOS backend recorded in storage_provider.
+func openStoredBlob(serverURL string, accessToken string, f api.ExportFile) (io.ReadCloser, error) {
+	if b64 := strings.TrimSpace(f.BlobB64); b64 != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(b64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid blob received from server (%s)", strings.TrimSpace(f.FilePath))
+		}
+		return io.NopCloser(bytes.NewReader(raw)), nil
+	}
+	if strings.TrimSpace(f.StorageKey) == "" {
+		return nil, fmt.Errorf("no blob received from server (%s)", strings.TrimSpace(f.FilePath))
 	}
 
-	var projects []remoteProject
-	if err := json.Unmarshal(body, &projects); err != nil {
-		return nil, err
+	var blobs storage.BlobStore
+	if strings.TrimSpace(f.StorageProvider) == hostedProvider {
+		blobs = newHostedBlobStore(serverURL, accessToken)
+	} else {
+		var err error
+		blobs, err = storage.OpenForLocation(storage.Location{
+			Provider: f.StorageProvider,
+			Bucket:   f.StorageBucket,
+			Endpoint: f.StorageEndpoint,
+			Region:   f.StorageRegion,
+		})
+		if err != nil {
+			if errors.Is(err, storage.ErrNotConfigured) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("failed to connect to storage (%s): %w", strings.TrimSpace(f.FilePath), err)
+		}
+	}
+	rc, err := blobs.Get(commandContext(), strings.TrimSpace(f.StorageKey))
+	if err != nil {
+		verbosef("Download failed for %s: %v", f.FilePath, err)
+		return nil, fmt.Errorf("failed to download from storage (%s)", strings.TrimSpace(f.FilePath))
 	}
-	return projects, nil
+	return rc, nil
 }
 
-func fetchRemoteExport(serverURL string, accessToken string, root string) ([]remoteExportFile, error) {
-	u, err := url.Parse(strings.TrimRight(strings.TrimSpace(serverURL), "/") + "/export")
+// openEnvFile downloads and decrypts f. The returned reader yields verified
+// plaintext: reads fail with an integrityError instead of io.EOF when the
+// content does not match what was pushed, so nothing read before io.EOF may
+// be trusted.
+func openEnvFile(serverURL string, accessToken string, vaultKey *[]byte, f api.ExportFile) (io.ReadCloser, error) {
+	if auth.IsVaultCipher(f.Cipher) && len(*vaultKey) == 0 {
+		k, err := ensureVaultKey(serverURL, accessToken)
+		if err != nil {
+			return nil, err
+		}
+		*vaultKey = k
+	}
+
+	rc, err := openStoredBlob(serverURL, accessToken, f)
 	if err != nil {
 		return nil, err
 	}
-	q := u.Query()
-	q.Set("root", strings.TrimSpace(root))
-	u.RawQuery = q.Encode()
-
-	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, u.String(), nil)
+	plain, err := decryptEnvStream(f, rc, *vaultKey)
 	if err != nil {
+		_ = rc.Close()
 		return nil, err
 	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(accessToken))
+	return struct {
+		io.Reader
+		io.Closer
+	}{plain, rc}, nil
+}
 
-	client := &http.Client{Timeout: 45 * time.Second}
-	resp, err := client.Do(req)
+// decryptRemoteExportFile reads all of f into memory, for callers that need
+// the plaintext as a whole.
+func decryptRemoteExportFile(serverURL string, accessToken string, vaultKey *[]byte, f api.ExportFile) ([]byte, error) {
+	rc, err := openEnvFile(serverURL, accessToken, vaultKey, f)
 	if err != nil {
 		return nil, err
 	}
-	defer func() { _ = resp.Body.Close() }()
+	defer func() { _ = rc.Close() }()
+	return io.ReadAll(rc)
+}
 
-	body, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("export failed")
-	}
+// integrityError reports a downloaded file that does not match what was
+// pushed. Callers report it per file instead of aborting the whole download.
+type integrityError struct {
+	Path   string
+	Reason string
+}
 
-	var files []remoteExportFile
-	if err := json.Unmarshal(body, &files); err != nil {
-		return nil, err
-	}
-	sort.Slice(files, func(i, j int) bool { return strings.TrimSpace(files[i].Path) < strings.TrimSpace(files[j].Path) })
-	return files, nil
+func (e integrityError) Error() string {
+	return fmt.Sprintf("integrity check failed (%s): %s", e.Path, e.Reason)
 }
 
-func decryptRemoteExportFile(serverURL string, accessToken string, vaultKey *[]byte, f remoteExportFile) ([]byte, error) {
-	cipherName := strings.TrimSpace(f.Cipher)
-	blobB64 := strings.TrimSpace(f.BlobB64)
-	if blobB64 == "" && strings.TrimSpace(f.StorageKey) != "" {
-		s3cfg, _, enabled, err := storage.ResolveS3()
+// decryptEnvStream decrypts the ciphertext of f read from r and checks the
+// plaintext against the sha256 and size recorded at push time. sentra-v3 is
+// decrypted segment by segment; older ciphers are small and read whole.
+func decryptEnvStream(f api.ExportFile, r io.Reader, vaultKey []byte) (io.Reader, error) {
+	c := strings.TrimSpace(f.Cipher)
+	p := strings.TrimSpace(f.FilePath)
+
+	var plain io.Reader
+	switch {
+	case c == auth.EnvStreamCipher:
+		sr, err := auth.NewEnvStreamReader(r, vaultKey, envBlobBinding(f))
 		if err != nil {
+			if errors.Is(err, auth.ErrEnvBlobAuth) {
+				return nil, integrityError{Path: p, Reason: err.Error()}
+			}
 			return nil, err
 		}
-		if !enabled {
-			return nil, fmt.Errorf("sync requires storage setup (run: sentra storage setup)")
-		}
-		if strings.TrimSpace(f.StorageBucket) != "" {
-			s3cfg.Bucket = strings.TrimSpace(f.StorageBucket)
-		}
-		if strings.TrimSpace(f.StorageEndpoint) != "" {
-			s3cfg.Endpoint = strings.TrimSpace(f.StorageEndpoint)
-		}
-		if strings.TrimSpace(f.StorageRegion) != "" {
-			s3cfg.Region = strings.TrimSpace(f.StorageRegion)
-		}
-		s3c, err := storage.NewS3Client(s3cfg)
+		plain = sr
+
+	case auth.IsVaultCipher(c):
+		raw, err := io.ReadAll(r)
 		if err != nil {
-			return nil, fmt.Errorf("failed to connect to storage (%s)", strings.TrimSpace(f.Path))
+			return nil, err
 		}
-		raw, err := storage.GetObject(context.Background(), s3c, s3cfg, strings.TrimSpace(f.StorageKey))
+		b, err := auth.DecryptEnvBlobWithKey(c, vaultKey, base64.RawURLEncoding.EncodeToString(raw), envBlobBinding(f))
 		if err != nil {
-			return nil, fmt.Errorf("failed to download from storage (%s)", strings.TrimSpace(f.Path))
+			// With the vault key in hand, a GCM failure means the ciphertext
+			// was altered or (sentra-v2) belongs to another file.
+			return nil, integrityError{Path: p, Reason: auth.ErrEnvBlobAuth.Error()}
 		}
-		blobB64 = base64.RawURLEncoding.EncodeToString(raw)
-	}
+		plain = bytes.NewReader(b)
 
-	if strings.TrimSpace(cipherName) == "sentra-v1" {
-		if vaultKey != nil && len(*vaultKey) == 0 {
-			k, err := ensureVaultKey(serverURL, accessToken)
-			if err != nil {
-				return nil, err
-			}
-			*vaultKey = k
+	default:
+		raw, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
 		}
-		plain, err := auth.DecryptEnvBlobWithKey(cipherName, *vaultKey, blobB64)
+		b, err := auth.DecryptEnvBlobLegacy(c, base64.RawURLEncoding.EncodeToString(raw))
 		if err != nil {
-			return nil, fmt.Errorf("failed to decrypt file (%s)", strings.TrimSpace(f.Path))
+			if c == "ed25519+aes-256-gcm-v1" {
+				return nil, fmt.Errorf("failed to decrypt legacy file (%s): this file was encrypted with a device-local key; re-push it from the original machine to migrate", p)
+			}
+			return nil, fmt.Errorf("failed to decrypt file (%s)", p)
 		}
-		return plain, nil
+		plain = bytes.NewReader(b)
+	}
+	return &verifiedReader{r: plain, f: f, h: sha256.New()}, nil
+}
+
+// verifiedReader hashes plaintext as it is read and replaces io.EOF with an
+// integrityError when the sha256 or size differ from the pushed values.
+type verifiedReader struct {
+	r io.Reader
+	f api.ExportFile
+	h hash.Hash
+	n int64
+}
+
+func (v *verifiedReader) Read(b []byte) (int, error) {
+	n, err := v.r.Read(b)
+	v.h.Write(b[:n])
+	v.n += int64(n)
+	p := strings.TrimSpace(v.f.FilePath)
+	if v.n > int64(v.f.Size) {
+		return n, integrityError{Path: p, Reason: fmt.Sprintf("size mismatch (more than %d bytes)", v.f.Size)}
+	}
+	if errors.Is(err, auth.ErrEnvBlobAuth) {
+		return n, integrityError{Path: p, Reason: err.Error()}
+	}
+	if err != io.EOF {
+		return n, err
 	}
+	if v.n != int64(v.f.Size) {
+		return n, integrityError{Path: p, Reason: fmt.Sprintf("size mismatch (%d bytes, expected %d)", v.n, v.f.Size)}
+	}
+	if hex.EncodeToString(v.h.Sum(nil)) != strings.ToLower(strings.TrimSpace(v.f.SHA256)) {
+		return n, integrityError{Path: p, Reason: "sha256 mismatch"}
+	}
+	return n, io.EOF
+}
 
-	plain, err := auth.DecryptEnvBlobLegacy(cipherName, blobB64)
+// writeEnvFile writes verified plaintext to outPath through a temp file, so a
+// file that fails verification never replaces the existing one.
+func writeEnvFile(outPath string, plain io.Reader) error {
+	dir := filepath.Dir(outPath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".sentra-*.tmp")
 	if err != nil {
-		if strings.TrimSpace(cipherName) == "ed25519+aes-256-gcm-v1" {
-			return nil, fmt.Errorf("failed to decrypt legacy file (%s): this file was encrypted with a device-local key; re-push it from the original machine to migrate", strings.TrimSpace(f.Path))
-		}
-		return nil, fmt.Errorf("failed to decrypt file (%s)", strings.TrimSpace(f.Path))
+		return err
 	}
-	return plain, nil
+	name := tmp.Name()
+	if _, err := io.Copy(tmp, plain); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(name)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(name)
+		return err
+	}
+	if err := os.Rename(name, outPath); err != nil {
+		_ = os.Remove(name)
+		return err
+	}
+	return nil
 }
 
-func decryptEnvFile(cipherName string, blobB64 string, vaultKey []byte) ([]byte, error) {
-	c := strings.TrimSpace(cipherName)
-	if c == "sentra-v1" {
-		return auth.DecryptEnvBlobWithKey(c, vaultKey, blobB64)
+// envBlobBinding is the AAD context push used for f; file paths always start
+// with their project root.
+func envBlobBinding(f api.ExportFile) auth.EnvBlobBinding {
+	return auth.EnvBlobBinding{
+		Root:   projectRootFromPath(strings.TrimSpace(f.FilePath)),
+		Path:   strings.TrimSpace(f.FilePath),
+		SHA256: strings.TrimSpace(f.SHA256),
 	}
-	return auth.DecryptEnvBlobLegacy(c, blobB64)
 }
@@ -0,0 +1,267 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mgeovany/sentra/shared/api"
+	"github.com/spf13/cobra"
+)
+
+type serviceToken struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	ProjectRoot string `json:"project_root,omitempty"`
+	ReadOnly    bool   `json:"read_only"`
+	CreatedAt   string `json:"created_at"`
+	ExpiresAt   string `json:"expires_at,omitempty"`
+	RevokedAt   string `json:"revoked_at,omitempty"`
+	Token       string `json:"token,omitempty"`
+}
+
+type tokensJSON struct {
+	Schema string         `json:"schema"`
+	Tokens []serviceToken `json:"tokens"`
+}
+
+type tokenJSON struct {
+	Schema string `json:"schema"`
+	serviceToken
+}
+
+func newTokensCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "tokens",
+		Short:   "Manage service tokens for CI",
+		GroupID: groupAuth,
+		Args:    exactArgs(0, "sentra tokens create|ls|revoke"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return usageError("sentra tokens create|ls|revoke")
+		},
+	}
+
+	var (
+		project  string
+		name     string
+		readOnly bool
+		days     int
+	)
+	create := &cobra.Command{
+		Use:         "create",
+		Short:       "Create a service token (printed once)",
+		Args:        exactArgs(0, "sentra tokens create [--project <root>] [--read-only] [--name <name>] [--expires-days <n>]"),
+		Annotations: jsonCapable(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := ""
+			if strings.TrimSpace(project) != "" {
+				root = strings.TrimSpace(projectRootFromPath(project))
+			}
+			return runTokensCreate(name, root, readOnly, days)
+		},
+	}
+	create.Flags().StringVar(&project, "project", "", "Limit the token to one project root")
+	create.Flags().BoolVar(&readOnly, "read-only", false, "Disallow push and other writes")
+	create.Flags().StringVar(&name, "name", "", "Label shown in `sentra tokens ls`")
+	create.Flags().IntVar(&days, "expires-days", 0, "Expire after N days (0: never, max 365)")
+	_ = create.RegisterFlagCompletionFunc("project", func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		return completeRemoteProjects(cmd, nil, toComplete)
+	})
+
+	cmd.AddCommand(
+		create,
+		&cobra.Command{
+			Use:         "ls",
+			Aliases:     []string{"list"},
+			Short:       "List service tokens",
+			Args:        exactArgs(0, "sentra tokens ls"),
+			Annotations: jsonCapable(),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runTokensList()
+			},
+		},
+		&cobra.Command{
+			Use:   "revoke <id>",
+			Short: "Revoke a service token",
+			Args:  exactArgs(1, "sentra tokens revoke <id>"),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runTokensRevoke(args[0])
+			},
+		},
+	)
+	return cmd
+}
+
+func runTokensCreate(name string, root string, readOnly bool, days int) error {
+	if days < 0 || days > 365 {
+		return usageError("sentra tokens create [--expires-days <0..365>]")
+	}
+	serverURL, accessToken, err := userSessionForTokens()
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(map[string]any{
+		"name":            strings.TrimSpace(name),
+		"project_root":    root,
+		"read_only":       readOnly,
+		"expires_in_days": days,
+	})
+	if err != nil {
+		return err
+	}
+
+	body, err := tokensRequest(http.MethodPost, serverURL+api.PathTokens, accessToken, payload)
+	if err != nil {
+		return err
+	}
+	var t serviceToken
+	if err := json.Unmarshal(body, &t); err != nil {
+		return err
+	}
+
+	if jsonOutput() {
+		return writeJSON(tokenJSON{Schema: "sentra.token/v1", serviceToken: t})
+	}
+
+	successf("✔ created token %s", t.ID)
+	fmt.Println(t.Token)
+	warnf("This token is shown only once. Store it as SENTRA_TOKEN in your CI secrets.")
+	return nil
+}
+
+func runTokensList() error {
+	serverURL, accessToken, err := userSessionForTokens()
+	if err != nil {
+		return err
+	}
+
+	body, err := tokensRequest(http.MethodGet, serverURL+api.PathTokens, accessToken, nil)
+	if err != nil {
+		return err
+	}
+	var tokens []serviceToken
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return err
+	}
+
+	if jsonOutput() {
+		if tokens == nil {
+			tokens = []serviceToken{}
+		}
+		return writeJSON(tokensJSON{Schema: "sentra.tokens/v1", Tokens: tokens})
+	}
+
+	if len(tokens) == 0 {
+		fmt.Println("✔ 0 tokens")
+		return nil
+	}
+	for _, t := range tokens {
+		scope := strings.TrimSpace(t.ProjectRoot)
+		if scope == "" {
+			scope = "*"
+		}
+		access := "read-write"
+		if t.ReadOnly {
+			access = "read-only"
+		}
+		status := "active"
+		if strings.TrimSpace(t.RevokedAt) != "" {
+			status = "revoked"
+		} else if exp, err := time.Parse(time.RFC3339, strings.TrimSpace(t.ExpiresAt)); err == nil && time.Now().After(exp) {
+			status = "expired"
+		}
+		name := strings.TrimSpace(t.Name)
+		if name == "" {
+			name = "-"
+		}
+		fmt.Printf("%s\t%s\t%s\t%s\t%s\n", t.ID, name, scope, access, status)
+	}
+	return nil
+}
+
+func runTokensRevoke(id string) error {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return usageError("sentra tokens revoke <id>")
+	}
+	serverURL, accessToken, err := userSessionForTokens()
+	if err != nil {
+		return err
+	}
+
+	u, err := url.Parse(serverURL + api.PathTokens)
+	if err != nil {
+		return err
+	}
+	q := u.Query()
+	q.Set("id", id)
+	u.RawQuery = q.Encode()
+
+	if _, err := tokensRequest(http.MethodDelete, u.String(), accessToken, nil); err != nil {
+		return err
+	}
+	successf("✔ revoked token %s", id)
+	return nil
+}
+
+// userSessionForTokens: tokens can only be managed from a real login.
+func userSessionForTokens() (serverURL string, accessToken string, err error) {
+	if serviceTokenFromEnv() != "" {
+		return "", "", errors.New("service tokens cannot manage tokens (unset SENTRA_TOKEN and run: sentra login)")
+	}
+	sess, err := ensureRemoteSession()
+	if err != nil {
+		return "", "", err
+	}
+	if strings.TrimSpace(sess.AccessToken) == "" {
+		return "", "", errNotLoggedIn
+	}
+	serverURL, err = serverURLFromEnv()
+	if err != nil {
+		return "", "", err
+	}
+	return serverURL, sess.AccessToken, nil
+}
+
+func tokensRequest(method string, endpoint string, accessToken string, payload []byte) ([]byte, error) {
+	var rdr io.Reader
+	if payload != nil {
+		rdr = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(commandContext(), method, endpoint, rdr)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(accessToken))
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := newHTTPClient(20 * time.Second)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if resp.StatusCode == http.StatusNotFound && method == http.MethodDelete {
+			return nil, errors.New("token not found")
+		}
+		msg := oneLine(string(body))
+		if msg == "" {
+			msg = strings.TrimSpace(http.StatusText(resp.StatusCode))
+		}
+		return nil, fmt.Errorf("tokens request failed: %s", msg)
+	}
+	return body, nil
+}
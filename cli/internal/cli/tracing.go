@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/mgeovany/sentra/cli/internal/scanner"
+	"github.com/mgeovany/sentra/cli/internal/telemetry"
+	"github.com/mgeovany/sentra/shared/api"
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/mgeovany/sentra/cli/internal/cli")
+
+// commandCtx carries the span of the running command. Work that has no
+// better context of its own starts from it, so its spans and the server
+// requests it makes end up in the command's trace.
+var commandCtx = context.Background()
+
+func commandContext() context.Context {
+	return commandCtx
+}
+
+// traceCommand starts tracing (see telemetry.Setup) and a root span named
+// after the command args resolve to. The returned function ends the span
+// with the command's error and flushes the exporter.
+func traceCommand(root *cobra.Command, args []string) func(error) {
+	shutdown, err := telemetry.Setup(context.Background(), "sentra-cli", resolvedVersion())
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "sentra: tracing disabled: %v\n", err)
+	}
+
+	name := root.Name()
+	if cmd, _, err := root.Find(args); err == nil {
+		name = cmd.CommandPath()
+	}
+	ctx, span := tracer.Start(context.Background(), name)
+	commandCtx = ctx
+
+	return func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "command failed")
+			span.SetAttributes(attribute.Int("sentra.exit_code", ExitCode(err)))
+		}
+		span.End()
+		commandCtx = context.Background()
+
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdown(flushCtx); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "sentra: tracing flush failed: %v\n", err)
+		}
+	}
+}
+
+// newHTTPClient is the client for calls to the Sentra server. It paces
+// itself by the server's rate limit headers and, with tracing on, its
+// requests carry the command's trace context.
+func newHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: telemetry.Transport(paced(nil))}
+}
+
+// apiClient returns a shared API client for serverURL over newHTTPClient.
+func apiClient(serverURL string, accessToken string, timeout time.Duration) *api.Client {
+	return api.NewClient(serverURL, accessToken, newHTTPClient(timeout))
+}
+
+// scanProjects runs scanner.Scan inside a "scan" span.
+func scanProjects(scanRoot string) ([]scanner.Project, error) {
+	_, span := tracer.Start(commandContext(), "scan")
+	defer span.End()
+	projects, err := scanner.Scan(scanRoot)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "scan failed")
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("sentra.projects", len(projects)))
+	return projects, nil
+}
+
+// endSpan records err on span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -20,6 +20,9 @@ const (
 )
 
 func colorEnabled() bool {
+	if globals.NoColor {
+		return false
+	}
 	if strings.TrimSpace(os.Getenv("NO_COLOR")) != "" {
 		return false
 	}
@@ -67,20 +70,20 @@ func formatRecommendedLabel(label string, muted bool) string {
 }
 
 func infof(format string, args ...any) {
-	_, _ = fmt.Fprintln(os.Stdout, c(ansiDim, fmt.Sprintf(format, args...)))
+	_, _ = fmt.Fprintln(humanOut(), c(ansiDim, fmt.Sprintf(format, args...)))
 }
 
 func successf(format string, args ...any) {
-	_, _ = fmt.Fprintln(os.Stdout, c(ansiGreen, fmt.Sprintf(format, args...)))
+	_, _ = fmt.Fprintln(humanOut(), c(ansiGreen, fmt.Sprintf(format, args...)))
 }
 
 func warnf(format string, args ...any) {
-	_, _ = fmt.Fprintln(os.Stdout, c(ansiYellow, fmt.Sprintf(format, args...)))
+	_, _ = fmt.Fprintln(humanOut(), c(ansiYellow, fmt.Sprintf(format, args...)))
 }
 
 func verbosef(format string, args ...any) {
 	if !isVerbose() {
 		return
 	}
-	_, _ = fmt.Fprintln(os.Stdout, c(ansiDim, fmt.Sprintf(format, args...)))
+	_, _ = fmt.Fprintln(humanOut(), c(ansiDim, fmt.Sprintf(format, args...)))
 }
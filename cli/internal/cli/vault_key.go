@@ -15,6 +15,8 @@ import (
 	"time"
 
 	"github.com/mgeovany/sentra/cli/internal/auth"
+	"github.com/mgeovany/sentra/cli/internal/profile"
+	"github.com/mgeovany/sentra/shared/api"
 	"github.com/zalando/go-keyring"
 )
 
@@ -25,7 +27,7 @@ func vaultKeychainUser(userID string) string {
 }
 
 func getVaultKeyFromKeychain(userID string) ([]byte, bool) {
-	v, err := keyring.Get("sentra", vaultKeychainUser(userID))
+	v, err := keyring.Get(profile.KeyringService(), vaultKeychainUser(userID))
 	if err != nil {
 		return nil, false
 	}
@@ -40,7 +42,7 @@ func saveVaultKeyToKeychain(userID string, key []byte) {
 	if len(key) != 32 {
 		return
 	}
-	_ = keyring.Set("sentra", vaultKeychainUser(userID), base64.RawURLEncoding.EncodeToString(key))
+	_ = keyring.Set(profile.KeyringService(), vaultKeychainUser(userID), base64.RawURLEncoding.EncodeToString(key))
 }
 
 func promptVaultPassphrase(confirm bool) (string, error) {
@@ -77,7 +79,7 @@ func userIDFromAccessToken(accessToken string) (string, error) {
 }
 
 func fetchVaultEnvelope(ctx context.Context, serverURL string, accessToken string) (auth.VaultKeyEnvelopeV1, bool, error) {
-	endpoint := strings.TrimRight(strings.TrimSpace(serverURL), "/") + "/vault/key"
+	endpoint := strings.TrimRight(strings.TrimSpace(serverURL), "/") + api.PathVaultKey
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
 	if err != nil {
 		return auth.VaultKeyEnvelopeV1{}, false, err
@@ -85,7 +87,7 @@ func fetchVaultEnvelope(ctx context.Context, serverURL string, accessToken strin
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(accessToken))
 
-	client := &http.Client{Timeout: 15 * time.Second}
+	client := newHTTPClient(15 * time.Second)
 	resp, err := client.Do(req)
 	if err != nil {
 		return auth.VaultKeyEnvelopeV1{}, false, err
@@ -112,7 +114,7 @@ func fetchVaultEnvelope(ctx context.Context, serverURL string, accessToken strin
 }
 
 func putVaultEnvelope(ctx context.Context, serverURL string, accessToken string, env auth.VaultKeyEnvelopeV1) error {
-	endpoint := strings.TrimRight(strings.TrimSpace(serverURL), "/") + "/vault/key"
+	endpoint := strings.TrimRight(strings.TrimSpace(serverURL), "/") + api.PathVaultKey
 	b, err := json.Marshal(env)
 	if err != nil {
 		return err
@@ -125,7 +127,7 @@ func putVaultEnvelope(ctx context.Context, serverURL string, accessToken string,
 	req.Header.Set("Accept", "text/plain")
 	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(accessToken))
 
-	client := &http.Client{Timeout: 15 * time.Second}
+	client := newHTTPClient(15 * time.Second)
 	resp, err := client.Do(req)
 	if err != nil {
 		return err
@@ -148,6 +150,10 @@ func putVaultEnvelope(ctx context.Context, serverURL string, accessToken string,
 // ensureVaultKey returns a 32-byte per-user key used to encrypt/decrypt env blobs.
 // It is portable across machines by wrapping it with a user passphrase and storing the wrapper remotely.
 func ensureVaultKey(serverURL string, accessToken string) ([]byte, error) {
+	if isServiceToken(accessToken) {
+		return unlockVaultKeyForServiceToken(serverURL, accessToken)
+	}
+
 	uid, err := userIDFromAccessToken(accessToken)
 	if err != nil {
 		return nil, err
@@ -156,7 +162,7 @@ func ensureVaultKey(serverURL string, accessToken string) ([]byte, error) {
 		return k, nil
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	ctx, cancel := context.WithTimeout(commandContext(), 20*time.Second)
 	defer cancel()
 
 	env, ok, err := fetchVaultEnvelope(ctx, serverURL, accessToken)
@@ -196,3 +202,28 @@ func ensureVaultKey(serverURL string, accessToken string) ([]byte, error) {
 	saveVaultKeyToKeychain(uid, k)
 	return k, nil
 }
+
+// unlockVaultKeyForServiceToken is the CI path: no keychain and no prompts.
+// The vault must already exist (created by a logged-in machine).
+func unlockVaultKeyForServiceToken(serverURL string, accessToken string) ([]byte, error) {
+	pass := strings.TrimSpace(os.Getenv("SENTRA_VAULT_PASSPHRASE"))
+	if pass == "" {
+		return nil, errors.New("SENTRA_VAULT_PASSPHRASE is required when using SENTRA_TOKEN")
+	}
+
+	ctx, cancel := context.WithTimeout(commandContext(), 20*time.Second)
+	defer cancel()
+
+	env, ok, err := fetchVaultEnvelope(ctx, serverURL, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("no vault key on the server yet (push once from a logged-in machine)")
+	}
+	k, err := env.Unwrap(pass)
+	if err != nil {
+		return nil, errors.New("failed to unlock vault key (wrong SENTRA_VAULT_PASSPHRASE?)")
+	}
+	return k, nil
+}
@@ -16,6 +16,9 @@ func isTruthyEnv(key string) bool {
 // - SENTRA_VERBOSE=1|true
 // - SENTRA_LOG=debug|trace|verbose
 func isVerbose() bool {
+	if globals.Verbose {
+		return true
+	}
 	if isTruthyEnv("SENTRA_VERBOSE") || isTruthyEnv("SENTRA_DEBUG") {
 		return true
 	}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"runtime/debug"
 	"strings"
+
+	"github.com/spf13/cobra"
 )
 
 // Version information is typically injected at build time via -ldflags.
@@ -14,7 +16,40 @@ var (
 	Date    = ""
 )
 
+type versionJSON struct {
+	Schema  string `json:"schema"`
+	Version string `json:"version"`
+	Commit  string `json:"commit,omitempty"`
+	Date    string `json:"date,omitempty"`
+}
+
+func newVersionCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:         "version",
+		Short:       "Print the version",
+		GroupID:     groupMaint,
+		Args:        exactArgs(0, "sentra version"),
+		Annotations: jsonCapable(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if jsonOutput() {
+				return writeJSON(versionJSON{
+					Schema:  "sentra.version/v1",
+					Version: resolvedVersion(),
+					Commit:  strings.TrimSpace(Commit),
+					Date:    strings.TrimSpace(Date),
+				})
+			}
+			printVersion()
+			return nil
+		},
+	}
+}
+
 func printVersion() {
+	fmt.Println(versionString())
+}
+
+func resolvedVersion() string {
 	v := strings.TrimSpace(Version)
 	if info, ok := debug.ReadBuildInfo(); ok {
 		mv := strings.TrimSpace(info.Main.Version)
@@ -25,13 +60,16 @@ func printVersion() {
 	if v == "" {
 		v = "dev"
 	}
+	return v
+}
 
-	out := "sentra " + v
+func versionString() string {
+	out := "sentra " + resolvedVersion()
 	if c := strings.TrimSpace(Commit); c != "" {
 		out += " (" + c + ")"
 	}
 	if d := strings.TrimSpace(Date); d != "" {
 		out += " " + d
 	}
-	fmt.Println(out)
+	return out
 }
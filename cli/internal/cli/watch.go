@@ -0,0 +1,386 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/mgeovany/sentra/cli/internal/auth"
+	"github.com/mgeovany/sentra/cli/internal/commit"
+	"github.com/mgeovany/sentra/cli/internal/index"
+	"github.com/mgeovany/sentra/cli/internal/scanner"
+	"github.com/spf13/cobra"
+)
+
+const watchUsage = "sentra watch [--commit] [--push <interval>] [--debounce <duration>] [--log <file>]"
+
+// watchRescanInterval bounds how long a missed file event (a full inotify
+// queue, a directory created between walks) can go unnoticed.
+const watchRescanInterval = 5 * time.Minute
+
+// watchPolicy is what sentra watch does beyond staging. The same flags are
+// written into the user service by `sentra watch install`.
+type watchPolicy struct {
+	commit   bool
+	push     time.Duration
+	debounce time.Duration
+	logPath  string
+}
+
+func (p *watchPolicy) register(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&p.commit, "commit", false, "Commit staged files automatically after each change")
+	cmd.Flags().DurationVar(&p.push, "push", 0, "Push pending commits at this interval (e.g. 15m; default: never)")
+	cmd.Flags().DurationVar(&p.debounce, "debounce", 2*time.Second, "Wait this long after the last edit before staging")
+	cmd.Flags().StringVar(&p.logPath, "log", "", "Also append events to this file")
+}
+
+func (p watchPolicy) validate() error {
+	if p.debounce <= 0 || p.push < 0 {
+		return usageError(watchUsage)
+	}
+	if p.push > 0 && p.push < time.Minute {
+		return withExitCode(ExitUsage, errors.New("--push interval must be at least 1m"))
+	}
+	return nil
+}
+
+// args renders the policy back into watch flags.
+func (p watchPolicy) args() []string {
+	var out []string
+	if p.commit {
+		out = append(out, "--commit")
+	}
+	if p.push > 0 {
+		out = append(out, "--push", p.push.String())
+	}
+	out = append(out, "--debounce", p.debounce.String())
+	if p.logPath != "" {
+		out = append(out, "--log", p.logPath)
+	}
+	return out
+}
+
+func newWatchCmd() *cobra.Command {
+	var policy watchPolicy
+	cmd := &cobra.Command{
+		Use:     "watch",
+		Short:   "Watch env files and stage (optionally commit and push) changes",
+		GroupID: groupLocal,
+		Args:    exactArgs(0, watchUsage),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := policy.validate(); err != nil {
+				return err
+			}
+			return runWatch(policy)
+		},
+	}
+	policy.register(cmd)
+	cmd.AddCommand(newWatchInstallCmd(), newWatchUninstallCmd())
+	return cmd
+}
+
+// watcher stages env files under scanRoot as they change. Events are
+// written as timestamped lines to stdout and, with --log, to a file, so any
+// supervisor (systemd, launchd, a terminal) can surface them.
+type watcher struct {
+	policy   watchPolicy
+	scanRoot string
+	fs       *fsnotify.Watcher
+	watched  map[string]bool
+	out      io.Writer
+}
+
+func runWatch(policy watchPolicy) error {
+	scanRoot, err := resolveScanRoot()
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = humanOut()
+	if policy.logPath != "" {
+		logPath := filepath.Clean(expandUserHome(policy.logPath))
+		if err := os.MkdirAll(filepath.Dir(logPath), 0o755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		out = io.MultiWriter(out, f)
+	}
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = fsw.Close() }()
+
+	w := &watcher{policy: policy, scanRoot: scanRoot, fs: fsw, watched: map[string]bool{}, out: out}
+	if err := w.refreshWatches(); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(commandContext(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	mode := "stage"
+	if policy.commit {
+		mode += ", commit"
+	}
+	if policy.push > 0 {
+		mode += ", push every " + policy.push.String()
+	}
+	w.notify("watching %s (%d dir(s); %s)", scanRoot, len(w.watched), mode)
+
+	// Changes made while nothing was watching.
+	w.sync()
+
+	debounce := time.NewTimer(policy.debounce)
+	debounce.Stop()
+	rescan := time.NewTicker(watchRescanInterval)
+	defer rescan.Stop()
+	var pushC <-chan time.Time
+	if policy.push > 0 {
+		t := time.NewTicker(policy.push)
+		defer t.Stop()
+		pushC = t.C
+	}
+
+	rewatch := false
+	for {
+		select {
+		case <-ctx.Done():
+			w.notify("stopped")
+			return nil
+
+		case ev, ok := <-fsw.Events:
+			if !ok {
+				return nil
+			}
+			if ev.Has(fsnotify.Create) && isDir(ev.Name) {
+				rewatch = true
+				debounce.Reset(policy.debounce)
+				continue
+			}
+			if scanner.IsEnvFileName(filepath.Base(ev.Name)) {
+				verbosef("watch event: %s", ev)
+				debounce.Reset(policy.debounce)
+			}
+
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				return nil
+			}
+			// Usually a full event queue; the next rescan catches up.
+			w.notify("watch error: %v", err)
+
+		case <-debounce.C:
+			if rewatch {
+				rewatch = false
+				w.logErr("refresh watches", w.refreshWatches())
+			}
+			w.sync()
+
+		case <-rescan.C:
+			w.logErr("refresh watches", w.refreshWatches())
+			w.sync()
+
+		case <-pushC:
+			w.push()
+		}
+	}
+}
+
+func (w *watcher) notify(format string, args ...any) {
+	_, _ = fmt.Fprintf(w.out, "%s %s\n", time.Now().UTC().Format(time.RFC3339), fmt.Sprintf(format, args...))
+}
+
+func (w *watcher) logErr(op string, err error) {
+	if err != nil {
+		w.notify("%s failed: %v", op, err)
+	}
+}
+
+// refreshWatches adds every directory the scanner reads. fsnotify is not
+// recursive, so new directories are picked up here.
+func (w *watcher) refreshWatches() error {
+	dirs, err := scanner.Dirs(w.scanRoot)
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		if w.watched[dir] {
+			continue
+		}
+		if err := w.fs.Add(dir); err != nil {
+			// Out of inotify watches, or the directory vanished; the
+			// periodic rescan still covers it.
+			verbosef("cannot watch %s: %v", dir, err)
+			continue
+		}
+		w.watched[dir] = true
+	}
+	return nil
+}
+
+// sync stages env files whose content differs from both the index and the
+// latest local commit, then commits when the policy asks for it.
+func (w *watcher) sync() {
+	staged, err := w.stageChanges()
+	if err != nil {
+		w.notify("stage failed: %v", err)
+		return
+	}
+	if len(staged) > 0 {
+		w.notify("staged %d file(s): %s", len(staged), strings.Join(staged, ", "))
+	}
+	if w.policy.commit {
+		w.logErr("commit", w.commitStaged())
+	}
+}
+
+func (w *watcher) stageChanges() ([]string, error) {
+	projects, err := scanProjects(w.scanRoot)
+	if err != nil {
+		return nil, err
+	}
+	available := flattenScan(w.scanRoot, projects)
+
+	committed, err := latestCommittedHashes()
+	if err != nil {
+		return nil, err
+	}
+
+	indexPath, err := index.DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	idx, _, err := index.Load(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	if idx.Staged == nil {
+		idx.Staged = map[string]string{}
+	}
+
+	var staged []string
+	for p, hash := range available {
+		if idx.Staged[p] == hash || committed[p] == hash {
+			continue
+		}
+		idx.Staged[p] = hash
+		staged = append(staged, p)
+	}
+	if len(staged) == 0 {
+		return nil, nil
+	}
+	sort.Strings(staged)
+	idx.ScanRoot = w.scanRoot
+	if err := index.Save(indexPath, idx); err != nil {
+		return nil, err
+	}
+	return staged, nil
+}
+
+// latestCommittedHashes maps each path to its hash in the newest local
+// commit that contains it.
+func latestCommittedHashes() (map[string]string, error) {
+	commits, err := commit.List()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(commits, func(i, j int) bool { return commits[i].CreatedAt < commits[j].CreatedAt })
+	out := map[string]string{}
+	for _, c := range commits {
+		for p, hash := range c.Files {
+			out[p] = hash
+		}
+	}
+	return out, nil
+}
+
+// commitStaged commits everything in the index with a generated message.
+// Unlike `sentra commit` it skips the repo's pre-commit checks, which are
+// for Sentra's own sources and would block an unattended watcher.
+func (w *watcher) commitStaged() error {
+	indexPath, err := index.DefaultPath()
+	if err != nil {
+		return err
+	}
+	idx, ok, err := index.Load(indexPath)
+	if err != nil || !ok || len(idx.Staged) == 0 {
+		return err
+	}
+
+	paths := make([]string, 0, len(idx.Staged))
+	for p := range idx.Staged {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	cm := commit.New(autoCommitMessage(paths), idx.Staged)
+	if _, err := commit.Save(cm); err != nil {
+		return err
+	}
+	idx.Staged = map[string]string{}
+	if err := index.Save(indexPath, idx); err != nil {
+		return err
+	}
+	w.notify("committed %s: %s", cm.ID[:8], cm.Message)
+	return nil
+}
+
+func autoCommitMessage(paths []string) string {
+	const shown = 3
+	msg := "watch: update " + strings.Join(paths[:min(len(paths), shown)], ", ")
+	if len(paths) > shown {
+		msg += fmt.Sprintf(" (+%d more)", len(paths)-shown)
+	}
+	return msg
+}
+
+// push pushes pending commits when a session is available without
+// prompting; an unattended watcher must never start an interactive login.
+func (w *watcher) push() {
+	commits, err := commit.List()
+	if err != nil {
+		w.notify("push failed: %v", err)
+		return
+	}
+	pending := 0
+	for _, c := range commits {
+		if c.PushedAt == "" {
+			pending++
+		}
+	}
+	if pending == 0 {
+		return
+	}
+
+	if serviceTokenFromEnv() == "" {
+		ctx, cancel := context.WithTimeout(commandContext(), 15*time.Second)
+		_, err := auth.EnsureSession(ctx, remoteOAuthFromEnv())
+		cancel()
+		if err != nil {
+			w.notify("push skipped: not logged in (run: sentra login)")
+			return
+		}
+	}
+
+	w.notify("pushing %d commit(s)", pending)
+	if err := runPush(defaultPushJobs, fileFilter{}, false); err != nil {
+		w.notify("push failed: %s", oneLine(err.Error()))
+		return
+	}
+	w.notify("pushed %d commit(s)", pending)
+}
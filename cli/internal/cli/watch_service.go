@@ -0,0 +1,211 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/mgeovany/sentra/cli/internal/profile"
+	"github.com/spf13/cobra"
+)
+
+// `sentra watch install` writes a per-user service (systemd on Linux,
+// launchd on macOS) that runs `sentra watch` with the given policy. It only
+// writes the unit and prints how to start it; enabling is left to the user.
+
+func newWatchInstallCmd() *cobra.Command {
+	var policy watchPolicy
+	cmd := &cobra.Command{
+		Use:   "install",
+		Short: "Install sentra watch as a user service",
+		Args:  exactArgs(0, "sentra watch install [--commit] [--push <interval>] [--debounce <duration>] [--log <file>]"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := policy.validate(); err != nil {
+				return err
+			}
+			return runWatchInstall(policy)
+		},
+	}
+	policy.register(cmd)
+	return cmd
+}
+
+func newWatchUninstallCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "uninstall",
+		Short: "Remove the sentra watch user service",
+		Args:  exactArgs(0, "sentra watch uninstall"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runWatchUninstall()
+		},
+	}
+}
+
+// watchServiceName is per profile, so each profile can run its own watcher.
+func watchServiceName() string {
+	if p := profile.Active(); p != profile.Default {
+		return "sentra-watch-" + p
+	}
+	return "sentra-watch"
+}
+
+func watchServicePath() (string, error) {
+	switch runtime.GOOS {
+	case "linux":
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, "systemd", "user", watchServiceName()+".service"), nil
+	case "darwin":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, "Library", "LaunchAgents", watchLaunchdLabel()+".plist"), nil
+	}
+	return "", fmt.Errorf("watch install supports systemd (Linux) and launchd (macOS); on %s run `sentra watch` from your own service manager", runtime.GOOS)
+}
+
+func watchLaunchdLabel() string {
+	return "dev.sentra." + strings.TrimPrefix(watchServiceName(), "sentra-")
+}
+
+// watchCommand is the argv the service runs. The active profile and
+// server override are pinned so the service does not depend on the shell
+// environment it was installed from.
+func watchCommand(policy watchPolicy) ([]string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	argv := []string{exe}
+	if p := profile.Active(); p != profile.Default {
+		argv = append(argv, "--profile", p)
+	}
+	if s := strings.TrimSpace(globals.Server); s != "" {
+		argv = append(argv, "--server", s)
+	}
+	argv = append(argv, "watch")
+	if policy.logPath != "" {
+		policy.logPath = filepath.Clean(expandUserHome(policy.logPath))
+	}
+	return append(argv, policy.args()...), nil
+}
+
+func runWatchInstall(policy watchPolicy) error {
+	unitPath, err := watchServicePath()
+	if err != nil {
+		return err
+	}
+	argv, err := watchCommand(policy)
+	if err != nil {
+		return err
+	}
+
+	var unit []byte
+	var start string
+	if runtime.GOOS == "darwin" {
+		logDir, err := profile.Dir()
+		if err != nil {
+			return err
+		}
+		unit = launchdPlist(watchLaunchdLabel(), argv, filepath.Join(logDir, "watch.log"))
+		start = "launchctl load -w " + unitPath
+	} else {
+		unit = systemdUnit(argv)
+		start = "systemctl --user daemon-reload && systemctl --user enable --now " + watchServiceName() + ".service"
+	}
+
+	if err := os.MkdirAll(filepath.Dir(unitPath), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(unitPath, unit, 0o644); err != nil {
+		return err
+	}
+	successf("✔ installed %s", unitPath)
+	infof("Start it with: %s", start)
+	return nil
+}
+
+func runWatchUninstall() error {
+	unitPath, err := watchServicePath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(unitPath); os.IsNotExist(err) {
+		successf("✔ no watch service installed")
+		return nil
+	}
+	if runtime.GOOS == "darwin" {
+		infof("Stop it first if it is running: launchctl unload -w %s", unitPath)
+	} else {
+		infof("Stop it first if it is running: systemctl --user disable --now %s.service", watchServiceName())
+	}
+	if err := os.Remove(unitPath); err != nil {
+		return err
+	}
+	successf("✔ removed %s", unitPath)
+	return nil
+}
+
+func systemdUnit(argv []string) []byte {
+	quoted := make([]string, len(argv))
+	for i, a := range argv {
+		quoted[i] = systemdQuote(a)
+	}
+	var b strings.Builder
+	b.WriteString("[Unit]\n")
+	b.WriteString("Description=Sentra env file watcher\n")
+	b.WriteString("After=network-online.target\n\n")
+	b.WriteString("[Service]\n")
+	b.WriteString("ExecStart=" + strings.Join(quoted, " ") + "\n")
+	b.WriteString("Restart=on-failure\n")
+	b.WriteString("RestartSec=10\n\n")
+	b.WriteString("[Install]\n")
+	b.WriteString("WantedBy=default.target\n")
+	return []byte(b.String())
+}
+
+// systemdQuote quotes an ExecStart argument; systemd also expands % and $.
+func systemdQuote(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	s = strings.ReplaceAll(s, "$", "$$")
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+func launchdPlist(label string, argv []string, logPath string) []byte {
+	esc := func(s string) string {
+		var buf bytes.Buffer
+		_ = xml.EscapeText(&buf, []byte(s))
+		return buf.String()
+	}
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	b.WriteString("<plist version=\"1.0\">\n<dict>\n")
+	b.WriteString("  <key>Label</key>\n  <string>" + esc(label) + "</string>\n")
+	b.WriteString("  <key>ProgramArguments</key>\n  <array>\n")
+	for _, a := range argv {
+		b.WriteString("    <string>" + esc(a) + "</string>\n")
+	}
+	b.WriteString("  </array>\n")
+	b.WriteString("  <key>RunAtLoad</key>\n  <true/>\n")
+	b.WriteString("  <key>KeepAlive</key>\n  <true/>\n")
+	b.WriteString("  <key>StandardOutPath</key>\n  <string>" + esc(logPath) + "</string>\n")
+	b.WriteString("  <key>StandardErrorPath</key>\n  <string>" + esc(logPath) + "</string>\n")
+	b.WriteString("</dict>\n</plist>\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,225 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mgeovany/sentra/shared/api"
+	"github.com/spf13/cobra"
+)
+
+type webhooksJSON struct {
+	Schema   string        `json:"schema"`
+	Webhooks []api.Webhook `json:"webhooks"`
+}
+
+type webhookJSON struct {
+	Schema string `json:"schema"`
+	api.WebhookCreated
+}
+
+type webhookTestJSON struct {
+	Schema string `json:"schema"`
+	ID     string `json:"id"`
+	OK     bool   `json:"ok"`
+	api.WebhookDelivery
+}
+
+func newWebhooksCmd() *cobra.Command {
+	const usage = "sentra webhooks add|ls|rm|test"
+	cmd := &cobra.Command{
+		Use:     "webhooks",
+		Short:   "Notify URLs after every push",
+		GroupID: groupRemote,
+		Args:    exactArgs(0, usage),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return usageError(usage)
+		},
+	}
+
+	var project string
+	add := &cobra.Command{
+		Use:         "add <url>",
+		Short:       "Subscribe an https URL to push events (prints its signing secret once)",
+		Args:        exactArgs(1, "sentra webhooks add <url> [--project <root>]"),
+		Annotations: jsonCapable(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := ""
+			if strings.TrimSpace(project) != "" {
+				root = strings.TrimSpace(projectRootFromPath(project))
+			}
+			return runWebhooksAdd(args[0], root)
+		},
+	}
+	add.Flags().StringVar(&project, "project", "", "Only notify pushes of one project root")
+	_ = add.RegisterFlagCompletionFunc("project", func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		return completeRemoteProjects(cmd, nil, toComplete)
+	})
+
+	cmd.AddCommand(
+		add,
+		&cobra.Command{
+			Use:         "ls",
+			Aliases:     []string{"list"},
+			Short:       "List webhooks",
+			Args:        exactArgs(0, "sentra webhooks ls"),
+			Annotations: jsonCapable(),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runWebhooksList()
+			},
+		},
+		&cobra.Command{
+			Use:     "rm <id>",
+			Aliases: []string{"delete"},
+			Short:   "Delete a webhook",
+			Args:    exactArgs(1, "sentra webhooks rm <id>"),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runWebhooksDelete(args[0])
+			},
+		},
+		&cobra.Command{
+			Use:         "test <id>",
+			Short:       "Send a signed ping event to a webhook",
+			Args:        exactArgs(1, "sentra webhooks test <id>"),
+			Annotations: jsonCapable(),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runWebhooksTest(args[0])
+			},
+		},
+	)
+	return cmd
+}
+
+func webhooksClient() (*api.Client, error) {
+	serverURL, accessToken, _, err := storageUserSession("managing webhooks")
+	if err != nil {
+		return nil, err
+	}
+	return apiClient(serverURL, accessToken, 20*time.Second), nil
+}
+
+// webhookError keeps the server's reason for 4xx answers (a bad URL, too
+// many webhooks) and hides the body of anything else.
+func webhookError(action string, err error) error {
+	var apiErr *api.Error
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	if apiErr.StatusCode == 404 {
+		return errors.New("webhook not found (see: sentra webhooks ls)")
+	}
+	if apiErr.StatusCode >= 400 && apiErr.StatusCode < 500 {
+		return fmt.Errorf("%s failed: %s", action, oneLine(apiErr.Message()))
+	}
+	return fmt.Errorf("%s failed", action)
+}
+
+func runWebhooksAdd(rawURL string, root string) error {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return usageError("sentra webhooks add <url> [--project <root>]")
+	}
+	client, err := webhooksClient()
+	if err != nil {
+		return err
+	}
+
+	hook, err := client.CreateWebhook(commandContext(), api.WebhookRequest{URL: rawURL, ProjectRoot: root})
+	if err != nil {
+		return webhookError("webhook create", err)
+	}
+
+	if jsonOutput() {
+		return writeJSON(webhookJSON{Schema: "sentra.webhook/v1", WebhookCreated: hook})
+	}
+
+	scope := "every project"
+	if r := strings.TrimSpace(hook.ProjectRoot); r != "" {
+		scope = r
+	}
+	successf("✔ created webhook %s for %s", hook.ID, scope)
+	fmt.Println(hook.Secret)
+	warnf("This signing secret is shown only once. Verify X-Sentra-Signature with it.")
+	return nil
+}
+
+func runWebhooksList() error {
+	client, err := webhooksClient()
+	if err != nil {
+		return err
+	}
+	hooks, err := client.Webhooks(commandContext())
+	if err != nil {
+		return webhookError("webhooks list", err)
+	}
+
+	if jsonOutput() {
+		if hooks == nil {
+			hooks = []api.Webhook{}
+		}
+		return writeJSON(webhooksJSON{Schema: "sentra.webhooks/v1", Webhooks: hooks})
+	}
+
+	if len(hooks) == 0 {
+		fmt.Println("✔ 0 webhooks")
+		return nil
+	}
+	for _, h := range hooks {
+		scope := strings.TrimSpace(h.ProjectRoot)
+		if scope == "" {
+			scope = "*"
+		}
+		fmt.Printf("%s\t%s\t%s\n", h.ID, scope, h.URL)
+	}
+	return nil
+}
+
+func runWebhooksDelete(id string) error {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return usageError("sentra webhooks rm <id>")
+	}
+	client, err := webhooksClient()
+	if err != nil {
+		return err
+	}
+	if err := client.DeleteWebhook(commandContext(), id); err != nil {
+		return webhookError("webhook delete", err)
+	}
+	successf("✔ deleted webhook %s", id)
+	return nil
+}
+
+func runWebhooksTest(id string) error {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return usageError("sentra webhooks test <id>")
+	}
+	client, err := webhooksClient()
+	if err != nil {
+		return err
+	}
+	res, err := client.TestWebhook(commandContext(), id)
+	if err != nil {
+		return webhookError("webhook test", err)
+	}
+	ok := res.StatusCode >= 200 && res.StatusCode < 300
+
+	if jsonOutput() {
+		if err := writeJSON(webhookTestJSON{Schema: "sentra.webhook_test/v1", ID: id, OK: ok, WebhookDelivery: res}); err != nil {
+			return err
+		}
+	} else if ok {
+		successf("✔ webhook %s answered %d in %dms", id, res.StatusCode, res.DurationMS)
+	}
+	switch {
+	case ok:
+		return nil
+	case res.StatusCode != 0:
+		return fmt.Errorf("webhook %s answered %d", id, res.StatusCode)
+	default:
+		return fmt.Errorf("webhook %s unreachable: %s", id, oneLine(res.Error))
+	}
+}
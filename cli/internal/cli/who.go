@@ -1,18 +1,55 @@
 package cli
 
 import (
-	"errors"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/mgeovany/sentra/cli/internal/auth"
+	"github.com/mgeovany/sentra/shared/api"
+	"github.com/spf13/cobra"
 )
 
+type whoJSON struct {
+	Schema string `json:"schema"`
+	UserID string `json:"user_id"`
+	Email  string `json:"email,omitempty"`
+
+	// Set only when authenticated with SENTRA_TOKEN.
+	TokenID     string `json:"token_id,omitempty"`
+	ProjectRoot string `json:"project_root,omitempty"`
+	ReadOnly    bool   `json:"read_only,omitempty"`
+}
+
+func newWhoCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:         "who",
+		Short:       "Show current logged-in user",
+		GroupID:     groupAuth,
+		Args:        exactArgs(0, "sentra who"),
+		Annotations: jsonCapable(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runWho()
+		},
+	}
+}
+
 func runWho() error {
+	if serviceTokenFromEnv() != "" {
+		return runWhoServiceToken()
+	}
+
 	// Prefer the access token claims (email/sub) when available.
 	if s, ok, err := auth.LoadSession(); err != nil {
 		return err
 	} else if ok {
-		if c, err := auth.ParseAccessTokenClaims(s.AccessToken); err == nil {
+		if c, err := auth.ParseAccessTokenClaims(s.AccessToken); err == nil && (c.Email != "" || c.Sub != "") {
+			if jsonOutput() {
+				return writeJSON(whoJSON{Schema: "sentra.who/v1", UserID: c.Sub, Email: c.Email})
+			}
 			if c.Email != "" {
 				fmt.Println(c.Email)
 				return nil
@@ -28,9 +65,66 @@ func runWho() error {
 	if cfg, ok, err := auth.LoadConfig(); err != nil {
 		return err
 	} else if ok && cfg.UserID != "" {
+		if jsonOutput() {
+			return writeJSON(whoJSON{Schema: "sentra.who/v1", UserID: cfg.UserID})
+		}
 		fmt.Println(cfg.UserID)
 		return nil
 	}
 
-	return errors.New("not logged in (run: sentra login)")
+	return errNotLoggedIn
+}
+
+// runWhoServiceToken asks the server, since service tokens carry no readable claims.
+func runWhoServiceToken() error {
+	serverURL, err := serverURLFromEnv()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(commandContext(), http.MethodGet, serverURL+api.PathUsersMe, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+serviceTokenFromEnv())
+
+	client := newHTTPClient(15 * time.Second)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusUnauthorized {
+		return withExitCode(ExitAuth, fmt.Errorf("SENTRA_TOKEN rejected (revoked, expired or invalid)"))
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("who failed")
+	}
+
+	var me struct {
+		ID          string `json:"id"`
+		Email       string `json:"email"`
+		TokenID     string `json:"token_id"`
+		ProjectRoot string `json:"project_root"`
+		ReadOnly    bool   `json:"read_only"`
+	}
+	if err := json.Unmarshal(body, &me); err != nil {
+		return err
+	}
+
+	if jsonOutput() {
+		return writeJSON(whoJSON{Schema: "sentra.who/v1", UserID: me.ID, Email: me.Email, TokenID: me.TokenID, ProjectRoot: me.ProjectRoot, ReadOnly: me.ReadOnly})
+	}
+	scope := strings.TrimSpace(me.ProjectRoot)
+	if scope == "" {
+		scope = "all projects"
+	}
+	access := "read-write"
+	if me.ReadOnly {
+		access = "read-only"
+	}
+	fmt.Printf("service token %s (%s, %s)\n", me.TokenID, scope, access)
+	return nil
 }
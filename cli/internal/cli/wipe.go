@@ -8,46 +8,107 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/mgeovany/sentra/cli/internal/profile"
 	"github.com/mgeovany/sentra/cli/internal/storage"
+	"github.com/spf13/cobra"
 	"github.com/zalando/go-keyring"
 )
 
-func runWipe(args []string) error {
-	if len(args) != 0 {
-		return errors.New("usage: sentra wipe")
+func newWipeCmd() *cobra.Command {
+	var all bool
+	cmd := &cobra.Command{
+		Use:     "wipe",
+		Short:   "Delete local Sentra state for the active profile",
+		GroupID: groupMaint,
+		Args:    exactArgs(0, "sentra wipe [--all]"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runWipe(all)
+		},
 	}
+	cmd.Flags().BoolVar(&all, "all", false, "Wipe every profile, not just the active one")
+	return cmd
+}
 
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return err
+func runWipe(all bool) error {
+	names := []string{profile.Active()}
+	if all {
+		var err error
+		names, err = profile.List()
+		if err != nil {
+			return err
+		}
+	}
+
+	dirs := make([]string, 0, len(names))
+	for _, name := range names {
+		dir, err := profile.DirFor(name)
+		if err != nil {
+			return err
+		}
+		dirs = append(dirs, dir)
 	}
-	sentraDir := filepath.Join(homeDir, ".sentra")
 
-	if err := confirmWipe(sentraDir); err != nil {
+	if err := confirmWipe(names, dirs); err != nil {
 		return err
 	}
 
+	for i, name := range names {
+		if err := wipeProfile(name, dirs[i]); err != nil {
+			return err
+		}
+		fmt.Printf("✔ deleted %s\n", dirs[i])
+	}
+	if all {
+		root, err := profile.RootDir()
+		if err != nil {
+			return err
+		}
+		if err := os.RemoveAll(root); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println("✔ wiped local Sentra state")
+	return nil
+}
+
+func wipeProfile(name string, dir string) error {
 	// Best-effort: delete storage secret (keychain) using config, if present.
-	_ = storage.DeleteConfig()
+	// Storage config is resolved through the active profile.
+	_ = withProfile(name, storage.DeleteConfig)
+	deleteVaultKeyCache(name)
 
 	// Best-effort: delete keychain items.
-	_ = keyring.Delete("sentra", "session")
-	_ = keyring.Delete("sentra", "session-key")
-	_ = keyring.Delete("sentra", "device-ed25519")
+	service := profile.KeyringServiceFor(name)
+	_ = keyring.Delete(service, "session")
+	_ = keyring.Delete(service, "session-key")
+	_ = keyring.Delete(service, "device-ed25519")
 
-	// Remove all local state.
-	if err := os.RemoveAll(sentraDir); err != nil {
-		return err
+	if name != profile.Default {
+		return os.RemoveAll(dir)
 	}
 
-	fmt.Println("✔ wiped local Sentra state")
-	fmt.Printf("✔ deleted %s\n", sentraDir)
+	// The default profile shares ~/.sentra with the other profiles; keep theirs.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, e := range entries {
+		if e.Name() == "profiles" || e.Name() == "profiles.json" {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(dir, e.Name())); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func confirmWipe(sentraDir string) error {
-	sentraDir = strings.TrimSpace(sentraDir)
-	if sentraDir == "" {
+func confirmWipe(names []string, dirs []string) error {
+	if len(dirs) == 0 {
 		return errors.New("invalid state dir")
 	}
 
@@ -59,9 +120,12 @@ func confirmWipe(sentraDir string) error {
 		}
 	}
 
-	fmt.Println("This will delete ALL local Sentra data:")
-	fmt.Printf("- %s (commits, index, config, cache)\n", sentraDir)
-	fmt.Println("- keychain items: sentra/session, sentra/session-key, sentra/device-ed25519")
+	fmt.Println("This will delete ALL local Sentra data for:")
+	for i, name := range names {
+		service := profile.KeyringServiceFor(name)
+		fmt.Printf("- profile %s: %s (commits, index, config, cache)\n", name, dirs[i])
+		fmt.Printf("  keychain items: %s/session, %s/session-key, %s/device-ed25519, %s/vault-key:*\n", service, service, service, service)
+	}
 	fmt.Println()
 	fmt.Print("Type WIPE to continue: ")
 
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/mgeovany/sentra/cli/internal/profile"
 )
 
 type Commit struct {
@@ -23,11 +24,11 @@ type Commit struct {
 }
 
 func Dir() (string, error) {
-	homeDir, err := os.UserHomeDir()
+	dir, err := profile.Dir()
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(homeDir, ".sentra", "commits"), nil
+	return filepath.Join(dir, "commits"), nil
 }
 
 func New(message string, files map[string]string) Commit {
@@ -0,0 +1,85 @@
+package heads
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/mgeovany/sentra/cli/internal/profile"
+)
+
+// Heads records, per project root, the remote commit this machine last
+// synced or pushed. `sentra status --remote` compares it with the remote
+// head to tell whether a checkout is behind.
+type Heads struct {
+	ServerURL string          `json:"serverURL"`
+	Projects  map[string]Head `json:"projects"`
+	Version   int             `json:"version"`
+}
+
+type Head struct {
+	CommitID string `json:"commitID"`
+	SyncedAt string `json:"syncedAt"`
+}
+
+func DefaultPath() (string, error) {
+	dir, err := profile.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "heads.json"), nil
+}
+
+func Load(filePath string) (Heads, bool, error) {
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Heads{Projects: map[string]Head{}, Version: 1}, false, nil
+		}
+		return Heads{}, false, err
+	}
+
+	var h Heads
+	if err := json.Unmarshal(b, &h); err != nil {
+		return Heads{}, false, err
+	}
+
+	if h.Version == 0 {
+		h.Version = 1
+	}
+	if h.Projects == nil {
+		h.Projects = map[string]Head{}
+	}
+
+	return h, true, nil
+}
+
+func Save(filePath string, h Heads) error {
+	if h.Version == 0 {
+		h.Version = 1
+	}
+	if h.Projects == nil {
+		h.Projects = map[string]Head{}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := filePath + ".tmp"
+	if err := os.WriteFile(tmpPath, b, 0o644); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	return nil
+}
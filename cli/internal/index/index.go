@@ -5,6 +5,8 @@ import (
 	"os"
 	"path/filepath"
 	"time"
+
+	"github.com/mgeovany/sentra/cli/internal/profile"
 )
 
 type Index struct {
@@ -15,11 +17,11 @@ type Index struct {
 }
 
 func DefaultPath() (string, error) {
-	homeDir, err := os.UserHomeDir()
+	dir, err := profile.Dir()
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(homeDir, ".sentra", "index.json"), nil
+	return filepath.Join(dir, "index.json"), nil
 }
 
 func Load(filePath string) (Index, bool, error) {
@@ -0,0 +1,239 @@
+// Package profile resolves which named account (server, session, machine
+// identity, storage and local state) the CLI is operating on.
+//
+// The default profile keeps the original layout (~/.sentra, keychain service
+// "sentra") so existing installs need no migration. Named profiles live under
+// ~/.sentra/profiles/<name> and use the keychain service "sentra:<name>".
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const Default = "default"
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// override is set from --profile for the current invocation.
+var override string
+
+// SetOverride selects a profile for this process only, taking precedence
+// over SENTRA_PROFILE and the saved current profile.
+func SetOverride(name string) {
+	override = strings.TrimSpace(name)
+}
+
+func ValidName(name string) bool {
+	return validName.MatchString(name)
+}
+
+// Active returns the profile in effect: --profile, then SENTRA_PROFILE, then
+// the one chosen with `sentra profile use`, then "default".
+func Active() string {
+	if override != "" {
+		return override
+	}
+	if v := strings.TrimSpace(os.Getenv("SENTRA_PROFILE")); v != "" {
+		return v
+	}
+	if v, err := loadCurrent(); err == nil && v != "" {
+		return v
+	}
+	return Default
+}
+
+// RootDir is ~/.sentra, shared by all profiles.
+func RootDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".sentra"), nil
+}
+
+// Dir is where the active profile keeps its files.
+func Dir() (string, error) {
+	return DirFor(Active())
+}
+
+func DirFor(name string) (string, error) {
+	root, err := RootDir()
+	if err != nil {
+		return "", err
+	}
+	if name == "" || name == Default {
+		return root, nil
+	}
+	if !ValidName(name) {
+		return "", fmt.Errorf("invalid profile name: %q", name)
+	}
+	return filepath.Join(root, "profiles", name), nil
+}
+
+// KeyringService is the OS keychain service for the active profile.
+func KeyringService() string {
+	return KeyringServiceFor(Active())
+}
+
+func KeyringServiceFor(name string) string {
+	if name == "" || name == Default {
+		return "sentra"
+	}
+	return "sentra:" + name
+}
+
+// Exists reports whether name is the default profile or has been added.
+func Exists(name string) (bool, error) {
+	if name == Default {
+		return true, nil
+	}
+	dir, err := DirFor(name)
+	if err != nil {
+		return false, err
+	}
+	st, err := os.Stat(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return st.IsDir(), nil
+}
+
+// List returns all profiles, "default" first.
+func List() ([]string, error) {
+	root, err := RootDir()
+	if err != nil {
+		return nil, err
+	}
+	out := []string{Default}
+	entries, err := os.ReadDir(filepath.Join(root, "profiles"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return out, nil
+		}
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() && ValidName(e.Name()) && e.Name() != Default {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return append(out, names...), nil
+}
+
+func Add(name string) error {
+	if name == Default {
+		return errors.New("the default profile always exists")
+	}
+	if !ValidName(name) {
+		return fmt.Errorf("invalid profile name: %q (use a-z, 0-9, '-' or '_', up to 32 chars)", name)
+	}
+	dir, err := DirFor(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dir); err == nil {
+		return fmt.Errorf("profile already exists: %s", name)
+	}
+	return os.MkdirAll(dir, 0o700)
+}
+
+// Remove deletes a named profile's files. Keychain items are the caller's job,
+// since they are owned by the auth and storage packages.
+func Remove(name string) error {
+	if name == Default {
+		return errors.New("cannot remove the default profile")
+	}
+	ok, err := Exists(name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("unknown profile: %s", name)
+	}
+	dir, err := DirFor(name)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if cur, _ := loadCurrent(); cur == name {
+		return Use(Default)
+	}
+	return nil
+}
+
+// Current returns the profile saved with Use, ignoring --profile and SENTRA_PROFILE.
+func Current() string {
+	if v, err := loadCurrent(); err == nil && v != "" {
+		return v
+	}
+	return Default
+}
+
+// Use makes name the profile for future invocations.
+func Use(name string) error {
+	ok, err := Exists(name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("unknown profile: %s", name)
+	}
+	p, err := currentPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(profilesFile{Current: name}, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	return os.WriteFile(p, b, 0o600)
+}
+
+type profilesFile struct {
+	Current string `json:"current"`
+}
+
+func currentPath() (string, error) {
+	root, err := RootDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, "profiles.json"), nil
+}
+
+func loadCurrent() (string, error) {
+	p, err := currentPath()
+	if err != nil {
+		return "", err
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	var f profilesFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(f.Current), nil
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 )
@@ -29,7 +30,7 @@ func Scan(scanRoot string) ([]Project, error) {
 		return nil, errors.New("scan root is not a directory")
 	}
 
-	projectRoots, err := findProjectRoots(scanRoot)
+	projectRoots, err := findProjectRoots(scanRoot, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -38,7 +39,7 @@ func Scan(scanRoot string) ([]Project, error) {
 
 	projects := make([]Project, 0, len(projectRoots))
 	for _, root := range projectRoots {
-		envFiles, err := scanProjectEnvFiles(root)
+		envFiles, err := scanProjectEnvFiles(root, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -48,11 +49,35 @@ func Scan(scanRoot string) ([]Project, error) {
 	return projects, nil
 }
 
-func findProjectRoots(scanRoot string) ([]string, error) {
+// Dirs returns every directory Scan reads: those searched for project roots
+// and the non-ignored directories inside each project. Watching them is
+// enough to see every env file Scan reports.
+func Dirs(scanRoot string) ([]string, error) {
+	var dirs []string
+	onDir := func(dir string) { dirs = append(dirs, dir) }
+
+	projectRoots, err := findProjectRoots(scanRoot, onDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, root := range projectRoots {
+		if _, err := scanProjectEnvFiles(root, onDir); err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(dirs)
+	return slices.Compact(dirs), nil
+}
+
+// findProjectRoots calls onDir, when set, for each directory it reads.
+func findProjectRoots(scanRoot string, onDir func(string)) ([]string, error) {
 	var roots []string
 
 	var walk func(dir string) error
 	walk = func(dir string) error {
+		if onDir != nil {
+			onDir(dir)
+		}
 		entries, err := os.ReadDir(dir)
 		if err != nil {
 			return err
@@ -91,12 +116,16 @@ func findProjectRoots(scanRoot string) ([]string, error) {
 	return roots, nil
 }
 
-func scanProjectEnvFiles(projectRoot string) ([]EnvFile, error) {
+// scanProjectEnvFiles calls onDir, when set, for each directory it reads.
+func scanProjectEnvFiles(projectRoot string, onDir func(string)) ([]EnvFile, error) {
 	var envFiles []EnvFile
 	var ignoreStack []gitIgnoreFile
 
 	var walk func(dir string) error
 	walk = func(dir string) error {
+		if onDir != nil {
+			onDir(dir)
+		}
 		// load .gitignore for this directory
 		ignoreFile, ok, err := loadGitIgnoreFile(dir)
 		if err != nil {
@@ -138,7 +167,7 @@ func scanProjectEnvFiles(projectRoot string) ([]EnvFile, error) {
 
 			// Always detect env files, even if gitignored.
 			// Most repos intentionally ignore `.env` files.
-			if isEnvFileName(name) {
+			if IsEnvFileName(name) {
 				h, err := hashEnvFile(relFromProject, fullPath)
 				if err != nil {
 					return err
@@ -184,7 +213,8 @@ func isIgnoredDirName(name string) bool {
 	return ok
 }
 
-func isEnvFileName(name string) bool {
+// IsEnvFileName reports whether a file name is an env config Scan picks up.
+func IsEnvFileName(name string) bool {
 	// Only count real env configs.
 	// Accepted:
 	// - .env
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+
+	"github.com/mgeovany/sentra/cli/internal/profile"
 )
 
 type State struct {
@@ -14,11 +16,11 @@ type State struct {
 }
 
 func DefaultPath() (string, error) {
-	homeDir, err := os.UserHomeDir()
+	dir, err := profile.Dir()
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(homeDir, ".sentra", "state.json"), nil
+	return filepath.Join(dir, "state.json"), nil
 }
 
 func Load(filePath string) (State, bool, error) {
@@ -0,0 +1,223 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrNotConfigured is returned when a blob needs credentials that this machine does not have.
+var ErrNotConfigured = errors.New("storage not configured (run: sentra storage setup)")
+
+// BlobStore holds encrypted env blobs for BYOS. Keys are slash-separated
+// relative paths ("sentra/v1/<user>/<root>/..."); content is ciphertext.
+type BlobStore interface {
+	// Put stores size bytes read from r. Ciphertexts can be large, so
+	// implementations stream instead of buffering.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get streams an object; the caller closes the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	Exists(ctx context.Context, key string) (bool, error)
+	// List returns every object whose key starts with prefix, a "/"-terminated directory.
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	// Location is recorded with each pushed file so other machines can find the blob.
+	Location() Location
+}
+
+// Toucher is implemented by stores that can refresh an object's ModTime
+// without rewriting it. A push that reuses an object touches it, so
+// `sentra storage gc` treats it as new for another grace period.
+type Toucher interface {
+	Touch(ctx context.Context, key string) error
+}
+
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Location mirrors the storage_* columns the server stores per file.
+//
+// Bucket is the container: an S3 bucket, a directory, or a WebDAV collection path.
+// Endpoint is the server: an S3 endpoint, a WebDAV origin, or an SFTP host:port.
+type Location struct {
+	Provider string
+	Bucket   string
+	Endpoint string
+	Region   string
+}
+
+// Backend is a registered BlobStore implementation.
+type Backend struct {
+	// Name is the wire value stored as storage_provider.
+	Name string
+	// Title is shown by `sentra storage setup`.
+	Title string
+
+	Validate func(c Config) error
+	Open     func(c Config) (BlobStore, error)
+	// OpenLocation opens a blob pushed elsewhere. local is this machine's
+	// storage config when it uses the same backend, nil otherwise.
+	OpenLocation func(local *Config, loc Location) (BlobStore, error)
+}
+
+var backends []Backend
+
+// Register adds a backend. Backends register themselves from init.
+func Register(b Backend) {
+	if _, ok := LookupBackend(b.Name); ok {
+		panic("storage: backend registered twice: " + b.Name)
+	}
+	backends = append(backends, b)
+}
+
+// Backends returns the registered backends in registration order.
+func Backends() []Backend {
+	out := make([]Backend, len(backends))
+	copy(out, backends)
+	return out
+}
+
+func LookupBackend(name string) (Backend, bool) {
+	name = strings.TrimSpace(name)
+	for _, b := range backends {
+		if b.Name == name {
+			return b, true
+		}
+	}
+	return Backend{}, false
+}
+
+// Open returns the BlobStore for the active profile.
+//
+// Rule: if ~/.sentra/storage.json exists, BYOS is active.
+// Dev fallback: if SENTRA_S3_BUCKET and SENTRA_S3_ENDPOINT are set, BYOS is active.
+func Open() (store BlobStore, enabled bool, err error) {
+	c, ok, err := LoadConfig()
+	if err != nil {
+		return nil, false, err
+	}
+	if ok {
+		store, err = OpenConfig(c)
+		if err != nil {
+			return nil, false, err
+		}
+		return store, true, nil
+	}
+
+	cfg, ok := s3ConfigFromEnv()
+	if !ok {
+		return nil, false, nil
+	}
+	store, err = newS3Store(cfg)
+	if err != nil {
+		return nil, false, err
+	}
+	return store, true, nil
+}
+
+// OpenConfig is used by `sentra storage setup` to test before saving.
+func OpenConfig(c Config) (BlobStore, error) {
+	b, ok := LookupBackend(c.BackendName())
+	if !ok {
+		return nil, fmt.Errorf("unsupported storage backend %q", c.BackendName())
+	}
+	if err := b.Validate(c); err != nil {
+		return nil, err
+	}
+	return b.Open(c)
+}
+
+// OpenForLocation returns a store that can read a blob recorded at loc.
+// An empty provider means s3 (files pushed before backends were pluggable).
+func OpenForLocation(loc Location) (BlobStore, error) {
+	name := strings.TrimSpace(loc.Provider)
+	if name == "" {
+		name = BackendS3
+	}
+	b, ok := LookupBackend(name)
+	if !ok {
+		return nil, fmt.Errorf("unsupported storage provider %q", name)
+	}
+
+	c, ok, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	var local *Config
+	if ok && c.BackendName() == b.Name {
+		if err := b.Validate(c); err != nil {
+			return nil, err
+		}
+		local = &c
+	}
+	return b.OpenLocation(local, Location{
+		Provider: name,
+		Bucket:   strings.TrimSpace(loc.Bucket),
+		Endpoint: strings.TrimSpace(loc.Endpoint),
+		Region:   strings.TrimSpace(loc.Region),
+	})
+}
+
+// Test round-trips a small object through the store.
+func Test(ctx context.Context, s BlobStore) error {
+	key := "sentra/test/" + uuid.NewString() + ".bin"
+	payload := []byte("sentra-test")
+
+	if err := s.Put(ctx, key, bytes.NewReader(payload), int64(len(payload))); err != nil {
+		return err
+	}
+
+	rc, err := s.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	out, err := io.ReadAll(rc)
+	_ = rc.Close()
+	if err != nil {
+		return err
+	}
+	if string(out) != string(payload) {
+		return fmt.Errorf("download mismatch")
+	}
+
+	// Best-effort cleanup.
+	_ = s.Delete(ctx, key)
+	return nil
+}
+
+// checkKey rejects keys that could escape the store's base path. Keys for
+// reads come from the server, so path-based backends must not trust them.
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return fmt.Errorf("invalid storage key: %q", key)
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return fmt.Errorf("invalid storage key: %q", key)
+		}
+	}
+	return nil
+}
+
+// checkPrefix validates a List prefix: a key-like path ending in "/".
+func checkPrefix(prefix string) error {
+	if !strings.HasSuffix(prefix, "/") {
+		return fmt.Errorf("invalid storage prefix: %q", prefix)
+	}
+	return checkKey(strings.TrimSuffix(prefix, "/"))
+}
+
+// notFound normalizes "missing blob" errors across backends.
+func notFound(key string) error {
+	return fmt.Errorf("object not found: %s: %w", key, os.ErrNotExist)
+}
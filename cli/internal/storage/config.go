@@ -9,14 +9,25 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/mgeovany/sentra/cli/internal/profile"
 )
 
+// Backend names double as the storage_provider recorded with each pushed file.
+const (
+	BackendS3     = "s3"
+	BackendFS     = "fs"
+	BackendWebDAV = "webdav"
+	BackendSFTP   = "sftp"
+)
+
+// Provider is the S3-compatible service preset picked during setup.
 type Provider string
 
 const (
 	ProviderAWSS3   Provider = "aws_s3"
 	ProviderR2      Provider = "cloudflare_r2"
 	ProviderMinIO   Provider = "minio"
+	ProviderGCS     Provider = "gcs"
 	ProviderCustom  Provider = "custom_s3"
 	ProviderUnknown Provider = "unknown"
 )
@@ -41,8 +52,13 @@ type Config struct {
 	Version int    `json:"version"`
 	ID      string `json:"id"`
 
-	Provider Provider `json:"provider"`
-	Bucket   string   `json:"bucket"`
+	// Backend selects the BlobStore; empty means s3 (configs written before
+	// backends were pluggable).
+	Backend string `json:"backend,omitempty"`
+
+	// s3
+	Provider Provider `json:"provider,omitempty"`
+	Bucket   string   `json:"bucket,omitempty"`
 	Region   string   `json:"region,omitempty"`
 	Endpoint string   `json:"endpoint,omitempty"`
 	UseSSL   bool     `json:"use_ssl"`
@@ -60,15 +76,31 @@ type Config struct {
 	SecretKey      string         `json:"secret_access_key,omitempty"`
 	SessionToken   string         `json:"session_token,omitempty"`
 
+	// fs: base directory; sftp: remote base directory.
+	Path string `json:"path,omitempty"`
+
+	// webdav: base collection URL.
+	URL string `json:"url,omitempty"`
+
+	// webdav, sftp. The password follows SecretLocation like the S3 secret key.
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+
+	// sftp
+	Host           string `json:"host,omitempty"`
+	Port           int    `json:"port,omitempty"`
+	KeyFile        string `json:"key_file,omitempty"`
+	KnownHostsFile string `json:"known_hosts_file,omitempty"`
+
 	CreatedAt string `json:"created_at"`
 }
 
 func DefaultPath() (string, error) {
-	home, err := os.UserHomeDir()
+	dir, err := profile.Dir()
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(home, ".sentra", "storage.json"), nil
+	return filepath.Join(dir, "storage.json"), nil
 }
 
 func LoadConfig() (Config, bool, error) {
@@ -145,36 +177,18 @@ func DeleteConfig() error {
 	return nil
 }
 
+// BackendName returns the configured backend, defaulting to s3.
+func (c Config) BackendName() string {
+	if b := strings.TrimSpace(c.Backend); b != "" {
+		return b
+	}
+	return BackendS3
+}
+
 func (c Config) Validate() error {
-	if strings.TrimSpace(c.Bucket) == "" {
-		return fmt.Errorf("missing bucket")
-	}
-	if strings.TrimSpace(c.Endpoint) == "" {
-		return fmt.Errorf("missing endpoint")
-	}
-	switch c.AuthMethod {
-	case AuthAWSProfile:
-		return nil
-	case AuthEnvOnly:
-		return nil
-	case AuthStatic:
-		if strings.TrimSpace(c.AccessKeyID) == "" {
-			return fmt.Errorf("missing access key id")
-		}
-		if c.SecretLocation == SecretKeyring {
-			if strings.TrimSpace(c.SecretRef) == "" {
-				return fmt.Errorf("missing secret_ref")
-			}
-			return nil
-		}
-		if c.SecretLocation == SecretFile {
-			if strings.TrimSpace(c.SecretKey) == "" {
-				return fmt.Errorf("missing secret key")
-			}
-			return nil
-		}
-		return fmt.Errorf("invalid secret_location")
-	default:
-		return fmt.Errorf("invalid auth_method")
+	b, ok := LookupBackend(c.BackendName())
+	if !ok {
+		return fmt.Errorf("unsupported storage backend %q", c.BackendName())
 	}
+	return b.Validate(c)
 }
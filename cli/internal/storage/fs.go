@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func init() {
+	Register(Backend{
+		Name:         BackendFS,
+		Title:        "Local or NAS directory",
+		Validate:     validateFS,
+		Open:         openFS,
+		OpenLocation: openFSLocation,
+	})
+}
+
+// fsStore keeps blobs under a base directory, one file per key. A network
+// share mounted on several machines makes it a shared BYOS target.
+type fsStore struct {
+	dir string
+}
+
+func validateFS(c Config) error {
+	dir := strings.TrimSpace(c.Path)
+	if dir == "" {
+		return fmt.Errorf("missing path")
+	}
+	if !filepath.IsAbs(dir) {
+		return fmt.Errorf("path must be absolute: %s", dir)
+	}
+	return nil
+}
+
+func openFS(c Config) (BlobStore, error) {
+	return &fsStore{dir: filepath.Clean(strings.TrimSpace(c.Path))}, nil
+}
+
+// openFSLocation prefers the local config: the same share is often mounted
+// at different paths on different machines.
+func openFSLocation(local *Config, loc Location) (BlobStore, error) {
+	if local != nil {
+		return openFS(*local)
+	}
+	if loc.Bucket == "" || !filepath.IsAbs(loc.Bucket) {
+		return nil, ErrNotConfigured
+	}
+	if _, err := os.Stat(loc.Bucket); err != nil {
+		return nil, ErrNotConfigured
+	}
+	return &fsStore{dir: filepath.Clean(loc.Bucket)}, nil
+}
+
+func (s *fsStore) Location() Location {
+	return Location{Provider: BackendFS, Bucket: s.dir}
+}
+
+func (s *fsStore) path(key string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+func (s *fsStore) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return err
+	}
+
+	// Write to a temp file and rename so readers never see a partial blob.
+	f, err := os.CreateTemp(filepath.Dir(p), ".sentra-*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if n, err := io.Copy(f, r); err != nil || n != size {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		if err == nil {
+			err = fmt.Errorf("short write: %d of %d bytes", n, size)
+		}
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func (s *fsStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, notFound(key)
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s *fsStore) Delete(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *fsStore) Exists(ctx context.Context, key string) (bool, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(p); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *fsStore) Touch(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := os.Chtimes(p, now, now); err != nil {
+		if os.IsNotExist(err) {
+			return notFound(key)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *fsStore) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	if err := checkPrefix(prefix); err != nil {
+		return nil, err
+	}
+	root := filepath.Join(s.dir, filepath.FromSlash(prefix))
+	var out []ObjectInfo
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".sentra-") {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.dir, p)
+		if err != nil {
+			return err
+		}
+		out = append(out, ObjectInfo{Key: filepath.ToSlash(rel), Size: fi.Size(), ModTime: fi.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFSStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := openFS(Config{Path: dir})
+	if err != nil {
+		t.Fatalf("openFS: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		data string
+	}{
+		{name: "top level", key: "blob", data: "ciphertext"},
+		{name: "nested", key: "sentra/v1/user/root/file.env", data: "nested ciphertext"},
+		{name: "empty", key: "sentra/v1/user/root/empty", data: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := store.Exists(ctx, tt.key)
+			if err != nil || ok {
+				t.Fatalf("Exists before Put = %v, %v; want false, nil", ok, err)
+			}
+			if _, err := store.Get(ctx, tt.key); !errors.Is(err, os.ErrNotExist) {
+				t.Fatalf("Get before Put: err = %v; want os.ErrNotExist", err)
+			}
+
+			if err := store.Put(ctx, tt.key, strings.NewReader(tt.data), int64(len(tt.data))); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			ok, err = store.Exists(ctx, tt.key)
+			if err != nil || !ok {
+				t.Fatalf("Exists after Put = %v, %v; want true, nil", ok, err)
+			}
+			rc, err := store.Get(ctx, tt.key)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			got, err := io.ReadAll(rc)
+			_ = rc.Close()
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if string(got) != tt.data {
+				t.Fatalf("Get = %q; want %q", got, tt.data)
+			}
+
+			if err := store.Delete(ctx, tt.key); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			ok, err = store.Exists(ctx, tt.key)
+			if err != nil || ok {
+				t.Fatalf("Exists after Delete = %v, %v; want false, nil", ok, err)
+			}
+			if err := store.Delete(ctx, tt.key); err != nil {
+				t.Fatalf("Delete of a missing key: %v", err)
+			}
+		})
+	}
+}
+
+func TestFSStoreShortWrite(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := openFS(Config{Path: dir})
+	if err != nil {
+		t.Fatalf("openFS: %v", err)
+	}
+
+	if err := store.Put(ctx, "a/b", strings.NewReader("abc"), 10); err == nil {
+		t.Fatal("Put with a short reader succeeded")
+	}
+	if ok, _ := store.Exists(ctx, "a/b"); ok {
+		t.Fatal("short write left a blob behind")
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "a"))
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("short write left %d files behind", len(entries))
+	}
+}
+
+func TestFSStoreRejectsTraversal(t *testing.T) {
+	ctx := context.Background()
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "store")
+	store, err := openFS(Config{Path: dir})
+	if err != nil {
+		t.Fatalf("openFS: %v", err)
+	}
+
+	keys := []string{
+		"",
+		"../outside",
+		"a/../../outside",
+		"a/./b",
+		"a//b",
+		"a/",
+		"/etc/passwd",
+		`..\outside`,
+		"..",
+	}
+	for _, key := range keys {
+		t.Run(key, func(t *testing.T) {
+			if err := store.Put(ctx, key, strings.NewReader("x"), 1); err == nil {
+				t.Errorf("Put(%q) succeeded", key)
+			}
+			if _, err := store.Get(ctx, key); err == nil || errors.Is(err, os.ErrNotExist) {
+				t.Errorf("Get(%q) err = %v; want an invalid key error", key, err)
+			}
+			if _, err := store.Exists(ctx, key); err == nil {
+				t.Errorf("Exists(%q) succeeded", key)
+			}
+			if err := store.Delete(ctx, key); err == nil {
+				t.Errorf("Delete(%q) succeeded", key)
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(parent, "outside")); !os.IsNotExist(err) {
+		t.Fatalf("a blob was written outside the store: %v", err)
+	}
+	if _, err := store.List(ctx, "../"); err == nil {
+		t.Fatal("List(\"../\") succeeded")
+	}
+}
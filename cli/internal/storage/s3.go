@@ -1,7 +1,6 @@
 package storage
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -9,11 +8,20 @@ import (
 	"strings"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 )
 
+func init() {
+	Register(Backend{
+		Name:         BackendS3,
+		Title:        "S3-compatible (AWS S3, Cloudflare R2, MinIO, Google Cloud Storage, custom)",
+		Validate:     validateS3,
+		Open:         openS3,
+		OpenLocation: openS3Location,
+	})
+}
+
 type S3Config struct {
 	Endpoint string
 	Region   string
@@ -39,65 +47,115 @@ func NewS3Client(cfg S3Config) (*minio.Client, error) {
 	return c, nil
 }
 
-// ResolveS3 returns a configured S3 client.
-//
-// Rule: if ~/.sentra/storage.json exists, BYOS is active.
-// Dev fallback: if SENTRA_S3_BUCKET and SENTRA_S3_ENDPOINT are set, BYOS is active.
-func ResolveS3() (cfg S3Config, client *minio.Client, enabled bool, err error) {
-	if c, ok, err := LoadConfig(); err != nil {
-		return S3Config{}, nil, false, err
-	} else if ok {
-		cfg, err = s3ConfigFromStored(c)
+type s3Store struct {
+	cfg    S3Config
+	client *minio.Client
+}
+
+func newS3Store(cfg S3Config) (*s3Store, error) {
+	client, err := NewS3Client(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &s3Store{cfg: cfg, client: client}, nil
+}
+
+func openS3(c Config) (BlobStore, error) {
+	cfg, err := s3ConfigFromStored(c)
+	if err != nil {
+		return nil, err
+	}
+	return newS3Store(cfg)
+}
+
+// openS3Location keeps this machine's credentials but prefers the bucket,
+// endpoint and region recorded at push time. MinIO clients are bound to
+// endpoint/region at construction, so the client is built after the overrides.
+func openS3Location(local *Config, loc Location) (BlobStore, error) {
+	var cfg S3Config
+	if local != nil {
+		var err error
+		cfg, err = s3ConfigFromStored(*local)
 		if err != nil {
-			return S3Config{}, nil, false, err
+			return nil, err
 		}
-		client, err = NewS3Client(cfg)
-		if err != nil {
-			return S3Config{}, nil, false, err
+	} else {
+		var ok bool
+		cfg, ok = s3ConfigFromEnv()
+		if !ok {
+			return nil, ErrNotConfigured
 		}
-		return cfg, client, true, nil
 	}
 
-	// Dev env fallback
+	if loc.Bucket != "" {
+		cfg.Bucket = loc.Bucket
+	}
+	if loc.Endpoint != "" {
+		cfg.Endpoint = loc.Endpoint
+	}
+	if loc.Region != "" {
+		cfg.Region = loc.Region
+	}
+	return newS3Store(cfg)
+}
+
+// s3ConfigFromEnv is the dev fallback used when no storage.json exists.
+func s3ConfigFromEnv() (S3Config, bool) {
 	endpoint := strings.TrimSpace(os.Getenv("SENTRA_S3_ENDPOINT"))
 	bucket := strings.TrimSpace(os.Getenv("SENTRA_S3_BUCKET"))
 	if endpoint == "" || bucket == "" {
-		return S3Config{}, nil, false, nil
+		return S3Config{}, false
 	}
 	region := strings.TrimSpace(os.Getenv("SENTRA_S3_REGION"))
 	if region == "" {
 		region = "us-east-1"
 	}
 
-	cfg = S3Config{
+	return S3Config{
 		Endpoint: endpoint,
 		Region:   region,
 		Bucket:   bucket,
 		UseSSL:   strings.TrimSpace(os.Getenv("SENTRA_S3_USE_SSL")) != "false",
 		Creds:    credentials.NewEnvAWS(),
-	}
-	client, err = NewS3Client(cfg)
-	if err != nil {
-		return S3Config{}, nil, false, err
-	}
-	return cfg, client, true, nil
+	}, true
 }
 
-// ResolveS3FromConfig is used by `sentra storage setup` to test before saving.
-func ResolveS3FromConfig(c Config) (cfg S3Config, client *minio.Client, enabled bool, err error) {
-	cfg, err = s3ConfigFromStored(c)
-	if err != nil {
-		return S3Config{}, nil, false, err
+func validateS3(c Config) error {
+	if strings.TrimSpace(c.Bucket) == "" {
+		return fmt.Errorf("missing bucket")
 	}
-	client, err = NewS3Client(cfg)
-	if err != nil {
-		return S3Config{}, nil, false, err
+	if strings.TrimSpace(c.Endpoint) == "" {
+		return fmt.Errorf("missing endpoint")
+	}
+	switch c.AuthMethod {
+	case AuthAWSProfile:
+		return nil
+	case AuthEnvOnly:
+		return nil
+	case AuthStatic:
+		if strings.TrimSpace(c.AccessKeyID) == "" {
+			return fmt.Errorf("missing access key id")
+		}
+		if c.SecretLocation == SecretKeyring {
+			if strings.TrimSpace(c.SecretRef) == "" {
+				return fmt.Errorf("missing secret_ref")
+			}
+			return nil
+		}
+		if c.SecretLocation == SecretFile {
+			if strings.TrimSpace(c.SecretKey) == "" {
+				return fmt.Errorf("missing secret key")
+			}
+			return nil
+		}
+		return fmt.Errorf("invalid secret_location")
+	default:
+		return fmt.Errorf("invalid auth_method")
 	}
-	return cfg, client, true, nil
 }
 
 func s3ConfigFromStored(c Config) (S3Config, error) {
-	if err := c.Validate(); err != nil {
+	if err := validateS3(c); err != nil {
 		return S3Config{}, err
 	}
 
@@ -148,51 +206,82 @@ func s3ConfigFromStored(c Config) (S3Config, error) {
 	}, nil
 }
 
-func TestS3(ctx context.Context, client *minio.Client, cfg S3Config) error {
-	key := "sentra/test/" + uuid.NewString() + ".bin"
-	payload := []byte("sentra-test")
+func (s *s3Store) Location() Location {
+	return Location{Provider: BackendS3, Bucket: s.cfg.Bucket, Endpoint: s.cfg.Endpoint, Region: s.cfg.Region}
+}
 
-	if err := PutObject(ctx, client, cfg, key, payload); err != nil {
-		return err
-	}
+// Put and Get move whole objects and can take a while; the caller's ctx
+// bounds them instead of the per-request timeout used elsewhere.
+func (s *s3Store) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	_, err := s.client.PutObject(ctx, s.cfg.Bucket, key, r, size, minio.PutObjectOptions{})
+	return err
+}
 
-	out, err := GetObject(ctx, client, cfg, key)
+func (s *s3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	obj, err := s.client.GetObject(ctx, s.cfg.Bucket, key, minio.GetObjectOptions{})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if string(out) != string(payload) {
-		return fmt.Errorf("download mismatch")
+	// GetObject is lazy; Stat surfaces a missing key before the caller reads.
+	if _, err := obj.Stat(); err != nil {
+		_ = obj.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, notFound(key)
+		}
+		return nil, err
 	}
-
-	// Best-effort cleanup.
-	_ = client.RemoveObject(ctx, cfg.Bucket, key, minio.RemoveObjectOptions{})
-	return nil
+	return obj, nil
 }
 
-func PutObject(ctx context.Context, client *minio.Client, cfg S3Config, key string, content []byte) error {
+func (s *s3Store) Delete(ctx context.Context, key string) error {
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
-	r := bytes.NewReader(content)
-	_, err := client.PutObject(ctx, cfg.Bucket, key, r, int64(len(content)), minio.PutObjectOptions{})
-	return err
+	return s.client.RemoveObject(ctx, s.cfg.Bucket, key, minio.RemoveObjectOptions{})
 }
 
-func GetObject(ctx context.Context, client *minio.Client, cfg S3Config, key string) ([]byte, error) {
+func (s *s3Store) Exists(ctx context.Context, key string) (bool, error) {
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
-
-	obj, err := client.GetObject(ctx, cfg.Bucket, key, minio.GetObjectOptions{})
+	_, err := s.client.StatObject(ctx, s.cfg.Bucket, key, minio.StatObjectOptions{})
 	if err != nil {
-		return nil, err
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return false, nil
+		}
+		return false, err
 	}
-	defer func() { _ = obj.Close() }()
+	return true, nil
+}
 
-	b, err := io.ReadAll(obj)
-	if err != nil {
+// Touch copies the object onto itself, which S3 only allows when the
+// metadata changes; the copy gets a new LastModified.
+func (s *s3Store) Touch(ctx context.Context, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	_, err := s.client.CopyObject(ctx,
+		minio.CopyDestOptions{
+			Bucket:          s.cfg.Bucket,
+			Object:          key,
+			ReplaceMetadata: true,
+			UserMetadata:    map[string]string{"sentra-touched": time.Now().UTC().Format(time.RFC3339)},
+		},
+		minio.CopySrcOptions{Bucket: s.cfg.Bucket, Object: key},
+	)
+	if err != nil && minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return notFound(key)
+	}
+	return err
+}
+
+func (s *s3Store) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	if err := checkPrefix(prefix); err != nil {
 		return nil, err
 	}
-	if len(b) == 0 {
-		return nil, fmt.Errorf("empty object: %s", key)
+	var out []ObjectInfo
+	for obj := range s.client.ListObjects(ctx, s.cfg.Bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		out = append(out, ObjectInfo{Key: obj.Key, Size: obj.Size, ModTime: obj.LastModified})
 	}
-	return b, nil
+	return out, nil
 }
@@ -2,14 +2,17 @@ package storage
 
 import (
 	"encoding/json"
+	"fmt"
 	"strings"
 
+	"github.com/mgeovany/sentra/cli/internal/profile"
 	"github.com/zalando/go-keyring"
 )
 
-const (
-	keyringService = "sentra"
-)
+// keyringService scopes keychain items to the active profile.
+func keyringService() string {
+	return profile.KeyringService()
+}
 
 type storedSecret struct {
 	SecretAccessKey string `json:"secret_access_key"`
@@ -30,12 +33,12 @@ func SaveSecret(ref string, secretKey string, sessionToken string) error {
 	if err != nil {
 		return err
 	}
-	return keyring.Set(keyringService, ref, string(b))
+	return keyring.Set(keyringService(), ref, string(b))
 }
 
 func LoadSecret(ref string) (secretKey string, sessionToken string, ok bool, err error) {
 	ref = strings.TrimSpace(ref)
-	v, err := keyring.Get(keyringService, ref)
+	v, err := keyring.Get(keyringService(), ref)
 	if err != nil {
 		if err == keyring.ErrNotFound {
 			return "", "", false, nil
@@ -60,13 +63,35 @@ func DeleteSecret(ref string) error {
 	if ref == "" {
 		return nil
 	}
-	err := keyring.Delete(keyringService, ref)
+	err := keyring.Delete(keyringService(), ref)
 	if err == keyring.ErrNotFound {
 		return nil
 	}
 	return err
 }
 
+// password resolves the WebDAV/SFTP password. The keychain entry reuses the
+// S3 secret slot, so SaveSecret(ref, password, "") stores it.
+func (c Config) password() (string, error) {
+	switch c.SecretLocation {
+	case SecretNone, "":
+		return "", nil
+	case SecretKeyring:
+		pw, _, ok, err := loadSecret(strings.TrimSpace(c.SecretRef))
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return "", fmt.Errorf("missing credentials in keychain for %s", c.SecretRef)
+		}
+		return pw, nil
+	case SecretFile:
+		return c.Password, nil
+	default:
+		return "", fmt.Errorf("invalid secret_location")
+	}
+}
+
 // Internal aliases for backward compatibility.
 var loadSecret = LoadSecret
 var deleteSecret = DeleteSecret
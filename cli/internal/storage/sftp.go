@@ -0,0 +1,311 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func init() {
+	Register(Backend{
+		Name:         BackendSFTP,
+		Title:        "SFTP (any SSH server)",
+		Validate:     validateSFTP,
+		Open:         openSFTP,
+		OpenLocation: openSFTPLocation,
+	})
+}
+
+// sftpStore dials lazily and reuses one SSH connection for the life of the
+// process; CLI invocations are short.
+type sftpStore struct {
+	addr string
+	dir  string
+	ssh  *ssh.ClientConfig
+
+	mu     sync.Mutex
+	client *sftp.Client
+}
+
+func validateSFTP(c Config) error {
+	if strings.TrimSpace(c.Host) == "" {
+		return fmt.Errorf("missing host")
+	}
+	if strings.TrimSpace(c.Username) == "" {
+		return fmt.Errorf("missing username")
+	}
+	if dir := strings.TrimSpace(c.Path); dir == "" || !strings.HasPrefix(dir, "/") {
+		return fmt.Errorf("path must be an absolute remote directory")
+	}
+	if c.Port < 0 || c.Port > 65535 {
+		return fmt.Errorf("invalid port: %d", c.Port)
+	}
+	if c.SecretLocation == SecretKeyring && strings.TrimSpace(c.SecretRef) == "" {
+		return fmt.Errorf("missing secret_ref")
+	}
+	return nil
+}
+
+func openSFTP(c Config) (BlobStore, error) {
+	port := c.Port
+	if port == 0 {
+		port = 22
+	}
+
+	knownHostsFile := strings.TrimSpace(c.KnownHostsFile)
+	if knownHostsFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		knownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	// Never trust unknown host keys: the server receives ciphertext only,
+	// but a spoofed host could still drop or replace blobs.
+	hostKeys, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read known_hosts (%s): %w", knownHostsFile, err)
+	}
+
+	auths, err := sftpAuthMethods(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sftpStore{
+		addr: net.JoinHostPort(strings.TrimSpace(c.Host), strconv.Itoa(port)),
+		dir:  path.Clean(strings.TrimSpace(c.Path)),
+		ssh: &ssh.ClientConfig{
+			User:            strings.TrimSpace(c.Username),
+			Auth:            auths,
+			HostKeyCallback: hostKeys,
+			Timeout:         15 * time.Second,
+		},
+	}, nil
+}
+
+// openSFTPLocation needs SSH credentials, so only a local sftp config works.
+func openSFTPLocation(local *Config, loc Location) (BlobStore, error) {
+	if local == nil {
+		return nil, ErrNotConfigured
+	}
+	return openSFTP(*local)
+}
+
+// sftpAuthMethods tries, in order: ssh-agent, the configured key file, password.
+func sftpAuthMethods(c Config) ([]ssh.AuthMethod, error) {
+	var out []ssh.AuthMethod
+	if sock := strings.TrimSpace(os.Getenv("SSH_AUTH_SOCK")); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			out = append(out, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+	if kf := strings.TrimSpace(c.KeyFile); kf != "" {
+		b, err := os.ReadFile(kf)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(b)
+		if err != nil {
+			if _, ok := err.(*ssh.PassphraseMissingError); ok {
+				return nil, fmt.Errorf("key file %s is passphrase-protected; add it to ssh-agent instead", kf)
+			}
+			return nil, err
+		}
+		out = append(out, ssh.PublicKeys(signer))
+	}
+	pw, err := c.password()
+	if err != nil {
+		return nil, err
+	}
+	if pw != "" {
+		out = append(out, ssh.Password(pw))
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no ssh credentials (start ssh-agent, or configure a key file or password)")
+	}
+	return out, nil
+}
+
+func (s *sftpStore) Location() Location {
+	return Location{Provider: BackendSFTP, Bucket: s.dir, Endpoint: s.addr}
+}
+
+func (s *sftpStore) conn() (*sftp.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client != nil {
+		return s.client, nil
+	}
+	sc, err := ssh.Dial("tcp", s.addr, s.ssh)
+	if err != nil {
+		return nil, err
+	}
+	c, err := sftp.NewClient(sc)
+	if err != nil {
+		_ = sc.Close()
+		return nil, err
+	}
+	s.client = c
+	return c, nil
+}
+
+func (s *sftpStore) path(key string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	return path.Join(s.dir, key), nil
+}
+
+func (s *sftpStore) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	c, err := s.conn()
+	if err != nil {
+		return err
+	}
+	if err := c.MkdirAll(path.Dir(p)); err != nil {
+		return err
+	}
+
+	// Upload then rename so readers never see a partial blob.
+	tmp := p + ".tmp"
+	f, err := c.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	if n, err := f.ReadFrom(r); err != nil || n != size {
+		_ = f.Close()
+		_ = c.Remove(tmp)
+		if err == nil {
+			err = fmt.Errorf("short write: %d of %d bytes", n, size)
+		}
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = c.Remove(tmp)
+		return err
+	}
+	if err := c.PosixRename(tmp, p); err != nil {
+		_ = c.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func (s *sftpStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	c, err := s.conn()
+	if err != nil {
+		return nil, err
+	}
+	f, err := c.Open(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, notFound(key)
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s *sftpStore) Delete(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	c, err := s.conn()
+	if err != nil {
+		return err
+	}
+	if err := c.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *sftpStore) Exists(ctx context.Context, key string) (bool, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return false, err
+	}
+	c, err := s.conn()
+	if err != nil {
+		return false, err
+	}
+	if _, err := c.Stat(p); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *sftpStore) Touch(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	c, err := s.conn()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := c.Chtimes(p, now, now); err != nil {
+		if os.IsNotExist(err) {
+			return notFound(key)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *sftpStore) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	if err := checkPrefix(prefix); err != nil {
+		return nil, err
+	}
+	c, err := s.conn()
+	if err != nil {
+		return nil, err
+	}
+	root := path.Join(s.dir, prefix)
+	var out []ObjectInfo
+	w := c.Walk(root)
+	for w.Step() {
+		if err := w.Err(); err != nil {
+			if os.IsNotExist(err) && w.Path() == root {
+				return nil, nil
+			}
+			return nil, err
+		}
+		fi := w.Stat()
+		if fi.IsDir() || strings.HasSuffix(fi.Name(), ".tmp") {
+			continue
+		}
+		out = append(out, ObjectInfo{
+			Key:     strings.TrimPrefix(w.Path(), s.dir+"/"),
+			Size:    fi.Size(),
+			ModTime: fi.ModTime(),
+		})
+	}
+	return out, nil
+}
@@ -0,0 +1,321 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+func init() {
+	Register(Backend{
+		Name:         BackendWebDAV,
+		Title:        "WebDAV (Nextcloud, ownCloud, NAS)",
+		Validate:     validateWebDAV,
+		Open:         openWebDAV,
+		OpenLocation: openWebDAVLocation,
+	})
+}
+
+type webdavStore struct {
+	base     *url.URL
+	username string
+	password string
+	client   *http.Client
+}
+
+func validateWebDAV(c Config) error {
+	raw := strings.TrimSpace(c.URL)
+	if raw == "" {
+		return fmt.Errorf("missing url")
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return fmt.Errorf("invalid url: %s", raw)
+	}
+	if c.SecretLocation == SecretKeyring && strings.TrimSpace(c.SecretRef) == "" {
+		return fmt.Errorf("missing secret_ref")
+	}
+	return nil
+}
+
+func openWebDAV(c Config) (BlobStore, error) {
+	u, err := url.Parse(strings.TrimSpace(c.URL))
+	if err != nil {
+		return nil, err
+	}
+	pw, err := c.password()
+	if err != nil {
+		return nil, err
+	}
+	return newWebDAVStore(u, strings.TrimSpace(c.Username), pw), nil
+}
+
+// openWebDAVLocation uses the local config (for credentials) when there is
+// one; otherwise it tries the recorded URL anonymously.
+func openWebDAVLocation(local *Config, loc Location) (BlobStore, error) {
+	if local != nil {
+		return openWebDAV(*local)
+	}
+	if loc.Endpoint == "" {
+		return nil, ErrNotConfigured
+	}
+	u, err := url.Parse(loc.Endpoint)
+	if err != nil || u.Host == "" {
+		return nil, ErrNotConfigured
+	}
+	u.Path = loc.Bucket
+	return newWebDAVStore(u, "", ""), nil
+}
+
+func newWebDAVStore(u *url.URL, username, password string) *webdavStore {
+	base := *u
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	// No overall client timeout: it would also cut off large uploads and
+	// downloads while the body is still streaming.
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.ResponseHeaderTimeout = 30 * time.Second
+	return &webdavStore{
+		base:     &base,
+		username: username,
+		password: password,
+		client:   &http.Client{Transport: t},
+	}
+}
+
+func (s *webdavStore) Location() Location {
+	return Location{
+		Provider: BackendWebDAV,
+		Bucket:   s.base.Path,
+		Endpoint: s.base.Scheme + "://" + s.base.Host,
+	}
+}
+
+func (s *webdavStore) url(key string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	u := *s.base
+	u.Path += key
+	return u.String(), nil
+}
+
+func (s *webdavStore) do(ctx context.Context, method, target string, body []byte) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	return s.doStream(ctx, method, target, r, int64(len(body)))
+}
+
+func (s *webdavStore) doStream(ctx context.Context, method, target string, body io.Reader, size int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	if s.username != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+	return s.client.Do(req)
+}
+
+// mkcol creates the parent collections of key. WebDAV servers answer 405
+// for collections that already exist.
+func (s *webdavStore) mkcol(ctx context.Context, key string) error {
+	segs := strings.Split(key, "/")
+	u := *s.base
+	for _, seg := range segs[:len(segs)-1] {
+		u.Path += seg + "/"
+		resp, err := s.do(ctx, "MKCOL", u.String(), nil)
+		if err != nil {
+			return err
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode == http.StatusMethodNotAllowed {
+			continue
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("webdav MKCOL %s: %s", u.Path, resp.Status)
+		}
+	}
+	return nil
+}
+
+func (s *webdavStore) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	target, err := s.url(key)
+	if err != nil {
+		return err
+	}
+	if err := s.mkcol(ctx, key); err != nil {
+		return err
+	}
+	resp, err := s.doStream(ctx, http.MethodPut, target, r, size)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webdav PUT: %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *webdavStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	target, err := s.url(key)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		_ = resp.Body.Close()
+		return nil, notFound(key)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("webdav GET: %s", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+func (s *webdavStore) Delete(ctx context.Context, key string) error {
+	target, err := s.url(key)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodDelete, target, nil)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webdav DELETE: %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *webdavStore) Exists(ctx context.Context, key string) (bool, error) {
+	target, err := s.url(key)
+	if err != nil {
+		return false, err
+	}
+	resp, err := s.do(ctx, http.MethodHead, target, nil)
+	if err != nil {
+		return false, err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return false, fmt.Errorf("webdav HEAD: %s", resp.Status)
+	}
+	return true, nil
+}
+
+type webdavMultistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Prop struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+				ContentLength int64  `xml:"getcontentlength"`
+				LastModified  string `xml:"getlastmodified"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+const webdavPropfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getcontentlength/><d:getlastmodified/></d:prop></d:propfind>`
+
+// List walks collections with Depth: 1 PROPFIND requests; many servers
+// refuse Depth: infinity.
+func (s *webdavStore) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	if err := checkPrefix(prefix); err != nil {
+		return nil, err
+	}
+	var out []ObjectInfo
+	queue := []string{s.base.Path + prefix}
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue = queue[1:]
+
+		u := *s.base
+		u.Path = dir
+		req, err := http.NewRequestWithContext(ctx, "PROPFIND", u.String(), strings.NewReader(webdavPropfindBody))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Depth", "1")
+		req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+		if s.username != "" {
+			req.SetBasicAuth(s.username, s.password)
+		}
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if resp.StatusCode != http.StatusMultiStatus {
+			return nil, fmt.Errorf("webdav PROPFIND %s: %s", dir, resp.Status)
+		}
+
+		var ms webdavMultistatus
+		if err := xml.Unmarshal(body, &ms); err != nil {
+			return nil, err
+		}
+		for _, r := range ms.Responses {
+			href, err := url.Parse(r.Href)
+			if err != nil {
+				return nil, err
+			}
+			p := href.Path
+			if strings.TrimSuffix(p, "/") == strings.TrimSuffix(dir, "/") || !strings.HasPrefix(p, s.base.Path) {
+				continue
+			}
+			if len(r.Propstat) == 0 {
+				continue
+			}
+			prop := r.Propstat[0].Prop
+			if prop.ResourceType.Collection != nil {
+				if !strings.HasSuffix(p, "/") {
+					p += "/"
+				}
+				queue = append(queue, p)
+				continue
+			}
+			mod, _ := http.ParseTime(prop.LastModified)
+			out = append(out, ObjectInfo{
+				Key:     strings.TrimPrefix(p, s.base.Path),
+				Size:    prop.ContentLength,
+				ModTime: mod,
+			})
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/webdav"
+)
+
+// newWebDAVServer serves an in-memory WebDAV tree under /dav/, requiring
+// basic auth as user:pass.
+func newWebDAVServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	dav := &webdav.Handler{
+		Prefix:     "/dav",
+		FileSystem: webdav.NewMemFS(),
+		LockSystem: webdav.NewMemLS(),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		dav.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func openTestWebDAV(t *testing.T, srv *httptest.Server) BlobStore {
+	t.Helper()
+	store, err := openWebDAV(Config{URL: srv.URL + "/dav", Username: "user", SecretLocation: SecretFile, Password: "pass"})
+	if err != nil {
+		t.Fatalf("openWebDAV: %v", err)
+	}
+	return store
+}
+
+func TestWebDAVStore(t *testing.T) {
+	ctx := context.Background()
+	store := openTestWebDAV(t, newWebDAVServer(t))
+
+	tests := []struct {
+		name string
+		key  string
+		data string
+	}{
+		{name: "top level", key: "blob", data: "ciphertext"},
+		{name: "nested", key: "sentra/v1/user/root/file.env", data: "nested ciphertext"},
+		{name: "existing collections", key: "sentra/v1/user/root/other.env", data: "more ciphertext"},
+		{name: "empty", key: "sentra/v1/user/root/empty", data: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := store.Exists(ctx, tt.key)
+			if err != nil || ok {
+				t.Fatalf("Exists before Put = %v, %v; want false, nil", ok, err)
+			}
+			if _, err := store.Get(ctx, tt.key); !errors.Is(err, os.ErrNotExist) {
+				t.Fatalf("Get before Put: err = %v; want os.ErrNotExist", err)
+			}
+
+			if err := store.Put(ctx, tt.key, strings.NewReader(tt.data), int64(len(tt.data))); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			ok, err = store.Exists(ctx, tt.key)
+			if err != nil || !ok {
+				t.Fatalf("Exists after Put = %v, %v; want true, nil", ok, err)
+			}
+			rc, err := store.Get(ctx, tt.key)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			got, err := io.ReadAll(rc)
+			_ = rc.Close()
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if string(got) != tt.data {
+				t.Fatalf("Get = %q; want %q", got, tt.data)
+			}
+
+			if err := store.Delete(ctx, tt.key); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			ok, err = store.Exists(ctx, tt.key)
+			if err != nil || ok {
+				t.Fatalf("Exists after Delete = %v, %v; want false, nil", ok, err)
+			}
+			if err := store.Delete(ctx, tt.key); err != nil {
+				t.Fatalf("Delete of a missing key: %v", err)
+			}
+		})
+	}
+}
+
+func TestWebDAVStoreList(t *testing.T) {
+	ctx := context.Background()
+	store := openTestWebDAV(t, newWebDAVServer(t))
+
+	blobs := map[string]string{
+		"sentra/v1/user/a/one":     "1",
+		"sentra/v1/user/a/two":     "22",
+		"sentra/v1/user/b/c/three": "333",
+		"elsewhere/four":           "4444",
+	}
+	for key, data := range blobs {
+		if err := store.Put(ctx, key, strings.NewReader(data), int64(len(data))); err != nil {
+			t.Fatalf("Put(%q): %v", key, err)
+		}
+	}
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{prefix: "sentra/v1/", want: []string{"sentra/v1/user/a/one", "sentra/v1/user/a/two", "sentra/v1/user/b/c/three"}},
+		{prefix: "sentra/v1/user/b/", want: []string{"sentra/v1/user/b/c/three"}},
+		{prefix: "missing/", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			objs, err := store.List(ctx, tt.prefix)
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			var keys []string
+			for _, o := range objs {
+				keys = append(keys, o.Key)
+				if want := int64(len(blobs[o.Key])); o.Size != want {
+					t.Errorf("%s: Size = %d; want %d", o.Key, o.Size, want)
+				}
+				if o.ModTime.IsZero() {
+					t.Errorf("%s: ModTime is zero", o.Key)
+				}
+			}
+			slices.Sort(keys)
+			if !slices.Equal(keys, tt.want) {
+				t.Fatalf("List(%q) = %v; want %v", tt.prefix, keys, tt.want)
+			}
+		})
+	}
+
+	if _, err := store.List(ctx, "../"); err == nil {
+		t.Fatal("List(\"../\") succeeded")
+	}
+}
+
+func TestWebDAVStoreErrors(t *testing.T) {
+	ctx := context.Background()
+	srv := newWebDAVServer(t)
+
+	// Without credentials every request is refused, which must surface as
+	// an error rather than a missing blob.
+	anon, err := openWebDAV(Config{URL: srv.URL + "/dav"})
+	if err != nil {
+		t.Fatalf("openWebDAV: %v", err)
+	}
+	if err := anon.Put(ctx, "a/b", strings.NewReader("x"), 1); err == nil {
+		t.Fatal("unauthorized Put succeeded")
+	}
+	if _, err := anon.Get(ctx, "a/b"); err == nil || errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("unauthorized Get: err = %v; want a non-ErrNotExist error", err)
+	}
+	if _, err := anon.Exists(ctx, "a/b"); err == nil {
+		t.Fatal("unauthorized Exists succeeded")
+	}
+	if _, err := anon.List(ctx, "a/"); err == nil {
+		t.Fatal("unauthorized List succeeded")
+	}
+
+	store := openTestWebDAV(t, srv)
+	for _, key := range []string{"", "../outside", "a//b", "/etc/passwd", `..\outside`} {
+		if err := store.Put(ctx, key, strings.NewReader("x"), 1); err == nil {
+			t.Errorf("Put(%q) succeeded", key)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+)
+
+var enabled bool
+
+// Setup installs the global tracer provider and the W3C trace context
+// propagator. Spans go to the OTLP/HTTP endpoint in
+// OTEL_EXPORTER_OTLP_ENDPOINT (or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT), or are
+// appended as JSON to the file in SENTRA_TRACE_FILE. With neither set,
+// tracing stays a no-op. The returned function flushes and stops the
+// exporter; commands are short-lived, so it must run before exit.
+func Setup(ctx context.Context, service string, version string) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+
+	var exporter sdktrace.SpanExporter
+	var closeFile func() error
+	switch {
+	case strings.TrimSpace(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")) != "" || strings.TrimSpace(os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")) != "":
+		exp, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return noop, err
+		}
+		exporter = exp
+	case strings.TrimSpace(os.Getenv("SENTRA_TRACE_FILE")) != "":
+		p := filepath.Clean(strings.TrimSpace(os.Getenv("SENTRA_TRACE_FILE")))
+		if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+			return noop, err
+		}
+		f, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return noop, err
+		}
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			_ = f.Close()
+			return noop, err
+		}
+		exporter = exp
+		closeFile = f.Close
+	default:
+		return noop, nil
+	}
+
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	res := resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(service),
+		semconv.ServiceVersion(version),
+	)
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	enabled = true
+
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if closeFile != nil {
+			err = errors.Join(err, closeFile())
+		}
+		return err
+	}, nil
+}
+
+// Transport wraps base (http.DefaultTransport when nil) so each request gets
+// a client span and carries a traceparent header to the server. Without
+// tracing it returns base unchanged.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if !enabled {
+		return base
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return otelhttp.NewTransport(base)
+}
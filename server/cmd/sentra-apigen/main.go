@@ -0,0 +1,103 @@
+// Command sentra-apigen writes the API contracts generated from
+// internal/apispec: contracts/openapi.json and shared/api/types_gen.go. The
+// push schemas are hand-written in contracts/push.v<N>.schema.json; it copies
+// each into internal/apispec/schemas and shared/api/schemas, where the server
+// and the CLI embed them. With -check it only reports files that are out of
+// date, for CI.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mgeovany/sentra/server/internal/apispec"
+)
+
+func main() {
+	root := flag.String("root", "..", "repository root")
+	check := flag.Bool("check", false, "fail if a generated file is out of date instead of writing it")
+	flag.Parse()
+
+	type output struct {
+		path string
+		data []byte
+	}
+	var outputs []output
+
+	push := map[int][]byte{}
+	for _, v := range apispec.PushVersions {
+		name := apispec.PushSchemaFile(v)
+		raw, err := os.ReadFile(filepath.Join(*root, "contracts", name))
+		if err != nil {
+			fail(err)
+		}
+		if err := checkVersion(raw, v); err != nil {
+			fail(fmt.Errorf("contracts/%s: %w", name, err))
+		}
+		push[v] = raw
+		outputs = append(outputs,
+			output{"server/internal/apispec/schemas/" + name, raw},
+			output{"shared/api/schemas/" + name, raw},
+		)
+	}
+
+	openapi, err := apispec.OpenAPI(push)
+	if err != nil {
+		fail(err)
+	}
+	types, err := apispec.GoSource(push[1])
+	if err != nil {
+		fail(err)
+	}
+	outputs = append(outputs,
+		output{"contracts/openapi.json", openapi},
+		output{"shared/api/types_gen.go", types},
+	)
+
+	stale := 0
+	for _, o := range outputs {
+		p := filepath.Join(*root, filepath.FromSlash(o.path))
+		if *check {
+			cur, err := os.ReadFile(p)
+			if err != nil || !bytes.Equal(cur, o.data) {
+				fmt.Fprintf(os.Stderr, "%s is out of date (run: go generate ./internal/apispec)\n", o.path)
+				stale++
+			}
+			continue
+		}
+		if err := os.WriteFile(p, o.data, 0o644); err != nil {
+			fail(err)
+		}
+	}
+	if stale > 0 {
+		os.Exit(1)
+	}
+}
+
+// checkVersion confirms that a push schema pins "v" to its own version, so
+// servers can pick the schema from the payload.
+func checkVersion(raw []byte, v int) error {
+	var schema struct {
+		Properties struct {
+			V struct {
+				Const *int `json:"const"`
+			} `json:"v"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return err
+	}
+	if c := schema.Properties.V.Const; c == nil || *c != v {
+		return fmt.Errorf("properties.v must be {\"const\": %d}", v)
+	}
+	return nil
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "sentra-apigen:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,89 @@
+package apispec
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// GoSource renders Types and the route paths as package api, for the
+// shared client module. v1 is the v1 push schema, checked against the Push*
+// structs.
+func GoSource(v1 []byte) ([]byte, error) {
+	if err := CheckPushTypes(v1); err != nil {
+		return nil, err
+	}
+	names := typeNames()
+
+	var b bytes.Buffer
+	b.WriteString("// Code generated by sentra-apigen from server/internal/apispec. DO NOT EDIT.\n\n")
+	b.WriteString("package api\n\n")
+
+	var body bytes.Buffer
+	usesTime := false
+	for _, t := range Types {
+		rt := reflect.TypeOf(t.Value)
+		if t.Doc != "" {
+			for _, line := range strings.Split(t.Doc, "\n") {
+				fmt.Fprintf(&body, "// %s\n", line)
+			}
+		}
+		fmt.Fprintf(&body, "type %s struct {\n", t.Name)
+		for _, f := range jsonFields(rt) {
+			typ, err := goType(f.typ, names)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", t.Name, f.goName, err)
+			}
+			if strings.Contains(typ, "time.Time") {
+				usesTime = true
+			}
+			fmt.Fprintf(&body, "\t%s %s `json:%q`\n", f.goName, typ, f.tag.Get("json"))
+		}
+		body.WriteString("}\n\n")
+	}
+
+	if usesTime {
+		b.WriteString("import \"time\"\n\n")
+	}
+	fmt.Fprintf(&b, "// Prefix is the path prefix of every API route.\nconst Prefix = %q\n\n", Prefix)
+	b.WriteString("// Route paths. Paths ending in a slash take path parameters.\nconst (\n")
+	seen := map[string]string{}
+	var order []string
+	for _, r := range Routes {
+		if _, ok := seen[r.Name]; !ok {
+			order = append(order, r.Name)
+		}
+		seen[r.Name] = Prefix + r.Pattern()
+	}
+	sort.Strings(order)
+	for _, name := range order {
+		fmt.Fprintf(&b, "\tPath%s = %q\n", name, seen[name])
+	}
+	b.WriteString(")\n\n")
+	b.Write(body.Bytes())
+
+	return format.Source(b.Bytes())
+}
+
+func goType(t reflect.Type, names map[reflect.Type]string) (string, error) {
+	if name, ok := names[t]; ok {
+		return name, nil
+	}
+	if t == timeType {
+		return "time.Time", nil
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		s, err := goType(t.Elem(), names)
+		return "*" + s, err
+	case reflect.Slice:
+		s, err := goType(t.Elem(), names)
+		return "[]" + s, err
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int32, reflect.Int64, reflect.Float64:
+		return t.Kind().String(), nil
+	}
+	return "", fmt.Errorf("unsupported type %s", t)
+}
@@ -0,0 +1,344 @@
+package apispec
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mgeovany/sentra/server/internal/webhooks"
+)
+
+// OpenAPI renders Routes and Types as an OpenAPI 3.1 document. push maps
+// each of PushVersions to its schema, which the document includes as
+// PushRequestV<N>; CheckPushTypes first confirms the Push* structs match v1.
+func OpenAPI(push map[int][]byte) ([]byte, error) {
+	if err := CheckPushTypes(push[1]); err != nil {
+		return nil, err
+	}
+
+	g := &schemaGen{names: typeNames(), schemas: map[string]any{}, done: map[reflect.Type]bool{}}
+	var versions []any
+	for _, v := range PushVersions {
+		var schema map[string]any
+		if err := json.Unmarshal(push[v], &schema); err != nil {
+			return nil, fmt.Errorf("%s: %w", PushSchemaFile(v), err)
+		}
+		delete(schema, "$schema")
+		delete(schema, "$id")
+		name := fmt.Sprintf("PushRequestV%d", v)
+		g.schemas[name] = schema
+		versions = append(versions, map[string]any{"$ref": "#/components/schemas/" + name})
+	}
+	// The request body is any accepted version, chosen by its "v" field.
+	g.schemas["PushRequest"] = map[string]any{"oneOf": versions}
+	g.done[reflect.TypeOf(PushRequest{})] = true
+
+	paths := map[string]map[string]any{}
+	for _, r := range Routes {
+		op, err := g.operation(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", r.Method, r.Path, err)
+		}
+		p := Prefix + r.Path
+		if paths[p] == nil {
+			paths[p] = map[string]any{}
+		}
+		paths[p][strings.ToLower(r.Method)] = op
+	}
+
+	// Deliveries the server sends to subscribed URLs.
+	event, err := g.content(webhooks.Event{})
+	if err != nil {
+		return nil, fmt.Errorf("webhook event: %w", err)
+	}
+	hooks := map[string]any{
+		"push": map[string]any{
+			"post": map[string]any{
+				"summary": "Sent after each push to the webhooks covering its project",
+				"description": "X-Sentra-Event is push (ping for POST " + Prefix + "/webhooks/test). " +
+					"X-Sentra-Signature is sha256=<hex HMAC-SHA256 of X-Sentra-Timestamp, \".\" and the body>, " +
+					"keyed with the webhook's secret. paths lists every file pushed with the commit, changed or not. " +
+					"Failed deliveries (no response, 429 or 5xx) are retried with the same id.",
+				"requestBody": map[string]any{"required": true, "content": event},
+				"responses": map[string]any{
+					"2XX": map[string]any{"description": "Delivered."},
+				},
+			},
+		},
+	}
+
+	doc := map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "Sentra API",
+			"version":     strings.TrimPrefix(Prefix, "/"),
+			"description": "Generated from server/internal/apispec by sentra-apigen. Do not edit.",
+		},
+		"paths":    paths,
+		"webhooks": hooks,
+		"components": map[string]any{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "A Supabase session JWT or a sentra_st_ service token.",
+				},
+				"deviceSignature": map[string]any{
+					"type": "apiKey",
+					"in":   "header",
+					"name": "X-Sentra-Signature",
+					"description": "Ed25519 signature of the request by the machine's device key, " +
+						"with X-Sentra-Machine-ID, X-Sentra-Timestamp and X-Sentra-Nonce. " +
+						"The signed path includes " + Prefix + ".",
+				},
+			},
+		},
+	}
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+func typeNames() map[reflect.Type]string {
+	names := map[reflect.Type]string{}
+	for _, t := range Types {
+		names[reflect.TypeOf(t.Value)] = t.Name
+	}
+	return names
+}
+
+type schemaGen struct {
+	names   map[reflect.Type]string
+	schemas map[string]any
+	done    map[reflect.Type]bool
+}
+
+func (g *schemaGen) operation(r Route) (map[string]any, error) {
+	op := map[string]any{
+		"operationId": strings.ToLower(r.Method[:1]) + strings.ToLower(r.Method[1:]) + r.Name,
+		"summary":     r.Summary,
+	}
+	switch r.Auth {
+	case AuthNone:
+		op["security"] = []any{}
+	case AuthDevice:
+		op["security"] = []any{map[string]any{"bearer": []any{}, "deviceSignature": []any{}}}
+	case AuthSession:
+		op["security"] = []any{map[string]any{"bearer": []any{}}}
+		op["description"] = "Service tokens are refused."
+	default:
+		op["security"] = []any{map[string]any{"bearer": []any{}}}
+	}
+
+	if len(r.Params) > 0 {
+		params := make([]any, 0, len(r.Params))
+		for _, p := range r.Params {
+			params = append(params, map[string]any{
+				"name":        p.Name,
+				"in":          p.In,
+				"required":    p.Required,
+				"description": p.Doc,
+				"schema":      map[string]any{"type": "string"},
+			})
+		}
+		op["parameters"] = params
+	}
+
+	if r.Request != nil {
+		content, err := g.content(r.Request)
+		if err != nil {
+			return nil, err
+		}
+		op["requestBody"] = map[string]any{"required": true, "content": content}
+	}
+
+	status := r.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	ok := map[string]any{"description": http.StatusText(status)}
+	if r.Response != nil {
+		content, err := g.content(r.Response)
+		if err != nil {
+			return nil, err
+		}
+		ok["content"] = content
+	}
+	if len(r.Headers) > 0 {
+		headers := map[string]any{}
+		for _, h := range r.Headers {
+			headers[h.Name] = map[string]any{"description": h.Doc, "schema": map[string]any{"type": "string"}}
+		}
+		ok["headers"] = headers
+	}
+	op["responses"] = map[string]any{
+		strconv.Itoa(status): ok,
+		"default": map[string]any{
+			"description": "Error, as a short text/plain message.",
+			"content":     map[string]any{"text/plain": map[string]any{"schema": map[string]any{"type": "string"}}},
+		},
+	}
+	return op, nil
+}
+
+func (g *schemaGen) content(v any) (map[string]any, error) {
+	switch v.(type) {
+	case Raw:
+		return map[string]any{"application/octet-stream": map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}}}, nil
+	case Object:
+		return map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object"}}}, nil
+	}
+	s, err := g.schema(reflect.TypeOf(v))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"application/json": map[string]any{"schema": s}}, nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (g *schemaGen) schema(t reflect.Type) (map[string]any, error) {
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}, nil
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schema(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}, nil
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}, nil
+	case reflect.Float64:
+		return map[string]any{"type": "number"}, nil
+	case reflect.Slice:
+		items, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "array", "items": items}, nil
+	case reflect.Struct:
+		name, ok := g.names[t]
+		if !ok {
+			return nil, fmt.Errorf("type %s is not listed in apispec.Types", t)
+		}
+		ref := map[string]any{"$ref": "#/components/schemas/" + name}
+		if g.done[t] {
+			return ref, nil
+		}
+		g.done[t] = true
+		props := map[string]any{}
+		for _, f := range jsonFields(t) {
+			s, err := g.schema(f.typ)
+			if err != nil {
+				return nil, err
+			}
+			props[f.name] = s
+		}
+		g.schemas[name] = map[string]any{"type": "object", "properties": props}
+		return ref, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+type jsonField struct {
+	goName    string
+	name      string
+	omitempty bool
+	typ       reflect.Type
+	tag       reflect.StructTag
+}
+
+// jsonFields lists the encoded fields of struct t, flattening embedded
+// structs the way encoding/json does.
+func jsonFields(t reflect.Type) []jsonField {
+	var out []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		if f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct {
+			out = append(out, jsonFields(f.Type)...)
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		out = append(out, jsonField{
+			goName:    f.Name,
+			name:      name,
+			omitempty: strings.Contains(","+opts+",", ",omitempty,"),
+			typ:       f.Type,
+			tag:       f.Tag,
+		})
+	}
+	return out
+}
+
+// CheckPushTypes confirms that the Push* structs encode only properties
+// the v1 schema allows, and always encode the ones it requires.
+func CheckPushTypes(v1 []byte) error {
+	var schema map[string]any
+	if err := json.Unmarshal(v1, &schema); err != nil {
+		return fmt.Errorf("%s: %w", PushSchemaFile(1), err)
+	}
+	return checkAgainstSchema(reflect.TypeOf(PushRequest{}), schema, "push")
+}
+
+func checkAgainstSchema(t reflect.Type, schema map[string]any, at string) error {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return checkAgainstSchema(t.Elem(), schema, at)
+	case reflect.Slice:
+		items, _ := schema["items"].(map[string]any)
+		if items == nil {
+			return fmt.Errorf("%s: schema is not an array", at)
+		}
+		return checkAgainstSchema(t.Elem(), items, at+"[]")
+	case reflect.Struct:
+	default:
+		return nil
+	}
+
+	props, _ := schema["properties"].(map[string]any)
+	if props == nil {
+		return fmt.Errorf("%s: schema is not an object", at)
+	}
+	fields := map[string]jsonField{}
+	for _, f := range jsonFields(t) {
+		fields[f.name] = f
+		sub, ok := props[f.name].(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s.%s: not in the schema", at, f.name)
+		}
+		if err := checkAgainstSchema(f.typ, sub, at+"."+f.name); err != nil {
+			return err
+		}
+	}
+	required, _ := schema["required"].([]any)
+	for _, r := range required {
+		name, _ := r.(string)
+		f, ok := fields[name]
+		if !ok {
+			return fmt.Errorf("%s.%s: required by the schema but missing", at, name)
+		}
+		if f.omitempty {
+			return fmt.Errorf("%s.%s: required by the schema but omitempty", at, name)
+		}
+	}
+	return nil
+}
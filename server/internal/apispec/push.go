@@ -0,0 +1,70 @@
+package apispec
+
+import (
+	"embed"
+	"fmt"
+)
+
+//go:embed schemas/push.v*.schema.json
+var schemaFiles embed.FS
+
+// PushVersions are the push schema versions the server accepts, oldest
+// first. Each is contracts/push.v<N>.schema.json, copied into schemas/ by
+// sentra-apigen so the server module embeds it.
+var PushVersions = []int{1, 2}
+
+// PushSchemaFile is the file name of push schema version v.
+func PushSchemaFile(v int) string {
+	return fmt.Sprintf("push.v%d.schema.json", v)
+}
+
+// PushSchema returns the embedded push schema for version v.
+func PushSchema(v int) ([]byte, bool) {
+	b, err := schemaFiles.ReadFile("schemas/" + PushSchemaFile(v))
+	return b, err == nil
+}
+
+// PushRequest is the Go shape of a v1 push. The server validates pushes
+// against the schema, not this struct; the generator checks that the two
+// agree before emitting it for clients.
+type PushRequest struct {
+	V       int         `json:"v"`
+	Project PushProject `json:"project"`
+	Machine PushMachine `json:"machine"`
+	Commit  PushCommit  `json:"commit"`
+	Files   []PushFile  `json:"files"`
+}
+
+type PushProject struct {
+	Root string `json:"root,omitempty"`
+	ID   string `json:"id,omitempty"`
+}
+
+type PushMachine struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+type PushCommit struct {
+	ClientID       string `json:"client_id"`
+	Message        string `json:"message"`
+	ParentClientID string `json:"parent_client_id,omitempty"`
+}
+
+type PushFile struct {
+	Path      string       `json:"path"`
+	SHA256    string       `json:"sha256"`
+	Size      int          `json:"size"`
+	Encrypted bool         `json:"encrypted"`
+	Cipher    string       `json:"cipher"`
+	Blob      string       `json:"blob,omitempty"`
+	Storage   *PushStorage `json:"storage,omitempty"`
+}
+
+type PushStorage struct {
+	Provider string `json:"provider"`
+	Bucket   string `json:"bucket,omitempty"`
+	Key      string `json:"key"`
+	Endpoint string `json:"endpoint,omitempty"`
+	Region   string `json:"region,omitempty"`
+}
@@ -0,0 +1,200 @@
+// Package apispec describes the versioned HTTP API: its routes and wire
+// types. contracts/openapi.json and the shared Go client types
+// (shared/api) are generated from it by cmd/sentra-apigen, and httpapi.New
+// refuses to start if its routes and this table disagree.
+package apispec
+
+//go:generate go run ../../cmd/sentra-apigen -root ../../..
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mgeovany/sentra/server/internal/auth"
+	"github.com/mgeovany/sentra/server/internal/repo"
+	"github.com/mgeovany/sentra/server/internal/webhooks"
+)
+
+// Prefix is prepended to every API route. The unprefixed paths are still
+// served for CLIs released before it.
+const Prefix = "/v1"
+
+// Auth is what a route requires of the caller.
+type Auth string
+
+const (
+	AuthNone Auth = "none"
+	// AuthBearer accepts a user session or a service token.
+	AuthBearer Auth = "bearer"
+	// AuthSession refuses service tokens.
+	AuthSession Auth = "session"
+	// AuthDevice also requires the X-Sentra-* device signature headers.
+	AuthDevice Auth = "device"
+)
+
+// Param is a query, path or header parameter.
+type Param struct {
+	Name     string
+	In       string
+	Required bool
+	Doc      string
+}
+
+// Raw marks an application/octet-stream body.
+type Raw struct{}
+
+// Object marks a JSON object body this table does not describe further.
+type Object struct{}
+
+// Route is one method on one path. Path is relative to Prefix; segments in
+// braces are path parameters.
+type Route struct {
+	// Name becomes the Path<Name> constant of the generated client.
+	Name     string
+	Method   string
+	Path     string
+	Summary  string
+	Auth     Auth
+	Params   []Param
+	Request  any
+	Response any
+	// Status is the success status code (200 when zero).
+	Status int
+	// Headers are response headers the success response may carry.
+	Headers []Param
+}
+
+// Pattern is the ServeMux pattern that serves r: the path up to its first
+// parameter.
+func (r Route) Pattern() string {
+	if i := strings.Index(r.Path, "{"); i >= 0 {
+		return r.Path[:i]
+	}
+	return r.Path
+}
+
+func query(name string, required bool, doc string) Param {
+	return Param{Name: name, In: "query", Required: required, Doc: doc}
+}
+
+func path(name string, doc string) Param {
+	return Param{Name: name, In: "path", Required: true, Doc: doc}
+}
+
+var rootParam = query("root", true, "Project root path, as pushed.")
+var atParam = query("at", false, "Commit ID; the latest commit when omitted.")
+var projectParam = path("id", "Project ID, as GET /projects reports it.")
+var commitsParams = []Param{
+	query("root", false, "Project root path; every project when omitted."),
+	query("limit", false, "Page size, 1-500. Every matching commit is returned when omitted."),
+	query("cursor", false, "X-Next-Cursor of the previous page; requires limit."),
+	query("since", false, "Only commits at or after this RFC 3339 time."),
+	query("until", false, "Only commits at or before this RFC 3339 time."),
+	query("machine_id", false, "Only commits pushed from this machine."),
+	query("path", false, "Only commits that touch this file path."),
+	query("message", false, "Only commits whose message contains this text, ignoring case."),
+}
+var webhookParam = query("id", true, "Webhook ID.")
+var blobParams = []Param{path("root", "Project root path."), path("id", "Lowercase hex SHA-256 of the ciphertext.")}
+
+// Routes is the API, in documentation order.
+var Routes = []Route{
+	{Name: "UsersMe", Method: "GET", Path: "/users/me", Summary: "The authenticated caller", Auth: AuthBearer, Response: auth.User{}},
+
+	{Name: "Projects", Method: "GET", Path: "/projects", Summary: "List projects", Auth: AuthBearer, Response: []repo.ProjectInfo{}},
+	{Name: "Project", Method: "PATCH", Path: "/projects/{id}", Summary: "Rename, relabel or archive a project", Auth: AuthSession, Params: []Param{projectParam}, Request: repo.ProjectPatch{}, Response: repo.ProjectInfo{}},
+	{Name: "Project", Method: "DELETE", Path: "/projects/{id}", Summary: "Delete a project with its commits and files", Auth: AuthSession, Params: []Param{projectParam}, Response: repo.ProjectDeletion{}},
+	{Name: "ProjectHeads", Method: "GET", Path: "/projects/heads", Summary: "Newest commit of every project", Auth: AuthBearer, Response: []repo.ProjectHead{}},
+	{Name: "Commits", Method: "GET", Path: "/commits", Summary: "List commits, newest first", Auth: AuthBearer, Params: commitsParams, Response: []repo.CommitInfo{},
+		Headers: []Param{{Name: "X-Next-Cursor", Doc: "Set when another page follows; pass it back as cursor."}}},
+	{Name: "Files", Method: "GET", Path: "/files", Summary: "List a project's files", Auth: AuthBearer, Params: []Param{rootParam, atParam}, Response: []repo.FileInfo{}},
+	{Name: "Export", Method: "GET", Path: "/export", Summary: "Encrypted files of a project", Auth: AuthBearer, Params: []Param{rootParam, atParam}, Response: []repo.ExportFile{}},
+
+	{Name: "Push", Method: "POST", Path: "/push", Summary: "Push one commit of one project", Auth: AuthDevice,
+		Params:  []Param{{Name: "X-Idempotency-Key", In: "header", Doc: "Retries with the same key return the first result."}},
+		Request: PushRequest{}, Response: repo.PushResult{}},
+	{Name: "Blobs", Method: "PUT", Path: "/blobs/{root}/{id}", Summary: "Upload a hosted blob", Auth: AuthBearer, Params: blobParams, Request: Raw{}, Status: 201},
+	{Name: "Blobs", Method: "HEAD", Path: "/blobs/{root}/{id}", Summary: "Check that a hosted blob exists", Auth: AuthBearer, Params: blobParams},
+	{Name: "Blobs", Method: "GET", Path: "/blobs/{root}/{id}", Summary: "Download a hosted blob", Auth: AuthBearer, Params: blobParams, Response: Raw{}},
+
+	{Name: "MachinesRegister", Method: "POST", Path: "/machines/register", Summary: "Register this machine's device key", Auth: AuthDevice, Request: Object{}, Response: Object{}},
+	{Name: "VaultKey", Method: "GET", Path: "/vault/key", Summary: "The caller's wrapped vault key", Auth: AuthBearer, Response: Object{}},
+	{Name: "VaultKey", Method: "PUT", Path: "/vault/key", Summary: "Store the caller's wrapped vault key", Auth: AuthBearer, Request: Object{}, Response: Object{}},
+
+	{Name: "Tokens", Method: "GET", Path: "/tokens", Summary: "List service tokens", Auth: AuthSession, Response: []repo.ServiceToken{}},
+	{Name: "Tokens", Method: "POST", Path: "/tokens", Summary: "Create a service token", Auth: AuthSession, Request: Object{}, Response: Object{}, Status: 201},
+	{Name: "Tokens", Method: "DELETE", Path: "/tokens", Summary: "Revoke a service token", Auth: AuthSession, Params: []Param{query("id", true, "Token ID.")}, Status: 204},
+
+	{Name: "Webhooks", Method: "GET", Path: "/webhooks", Summary: "List webhooks", Auth: AuthSession, Response: []repo.Webhook{}},
+	{Name: "Webhooks", Method: "POST", Path: "/webhooks", Summary: "Subscribe a URL to push events", Auth: AuthSession, Request: webhooks.Request{}, Response: repo.WebhookTarget{}, Status: 201},
+	{Name: "Webhooks", Method: "DELETE", Path: "/webhooks", Summary: "Delete a webhook", Auth: AuthSession, Params: []Param{webhookParam}, Status: 204},
+	{Name: "WebhooksTest", Method: "POST", Path: "/webhooks/test", Summary: "Send a ping event to a webhook once", Auth: AuthSession, Params: []Param{webhookParam}, Response: webhooks.Delivery{}},
+
+	{Name: "StorageRefs", Method: "GET", Path: "/storage/refs", Summary: "Every BYOS storage key the caller references", Auth: AuthSession, Response: Object{}},
+	{Name: "StorageMigrate", Method: "POST", Path: "/storage/migrate", Summary: "Record new storage locations for files", Auth: AuthSession, Request: Object{}, Response: Object{}},
+
+	{Name: "DeviceCode", Method: "POST", Path: "/device/code", Summary: "Start a device login", Auth: AuthNone, Response: Object{}},
+	{Name: "DeviceToken", Method: "POST", Path: "/device/token", Summary: "Poll a device login", Auth: AuthNone, Request: Object{}, Response: Object{}},
+}
+
+// Type names a wire type in the generated documents.
+type Type struct {
+	Name  string
+	Doc   string
+	Value any
+}
+
+// Types are emitted to shared/api, and name the OpenAPI schemas.
+var Types = []Type{
+	{Name: "User", Doc: "User is the caller as GET /v1/users/me reports it.", Value: auth.User{}},
+	{Name: "Project", Doc: "Project is one entry of GET /v1/projects.", Value: repo.ProjectInfo{}},
+	{Name: "ProjectPatch", Doc: "ProjectPatch is the body of PATCH /v1/projects/{id}. Nil fields are left as\nthey are.", Value: repo.ProjectPatch{}},
+	{Name: "ProjectDeletion", Doc: "ProjectDeletion is the response of DELETE /v1/projects/{id}. Storage lists\nthe BYOS objects only the deleted files referenced.", Value: repo.ProjectDeletion{}},
+	{Name: "StorageObject", Doc: "StorageObject is where a file's ciphertext lives outside the database.", Value: repo.StorageObject{}},
+	{Name: "ProjectHead", Doc: "ProjectHead is the newest commit of a project (GET /v1/projects/heads).", Value: repo.ProjectHead{}},
+	{Name: "Commit", Doc: "Commit is one entry of GET /v1/commits.", Value: repo.CommitInfo{}},
+	{Name: "File", Doc: "File is one entry of GET /v1/files.", Value: repo.FileInfo{}},
+	{Name: "ExportFile", Doc: "ExportFile is an encrypted file from GET /v1/export. The ciphertext is\ninline in BlobB64 or stored where the Storage fields point.", Value: repo.ExportFile{}},
+	{Name: "ServiceToken", Doc: "ServiceToken is one entry of GET /v1/tokens.", Value: repo.ServiceToken{}},
+	{Name: "Webhook", Doc: "Webhook is one entry of GET /v1/webhooks.", Value: repo.Webhook{}},
+	{Name: "WebhookRequest", Doc: "WebhookRequest is the body of POST /v1/webhooks. An empty ProjectRoot\ncovers every project.", Value: webhooks.Request{}},
+	{Name: "WebhookCreated", Doc: "WebhookCreated is the response of POST /v1/webhooks. Secret signs every\ndelivery and is returned only here.", Value: repo.WebhookTarget{}},
+	{Name: "WebhookDelivery", Doc: "WebhookDelivery is the response of POST /v1/webhooks/test.", Value: webhooks.Delivery{}},
+	{Name: "WebhookEvent", Doc: "WebhookEvent is the body the server POSTs to a webhook. Deliveries carry\nX-Sentra-Signature: sha256=<hex HMAC-SHA256 of X-Sentra-Timestamp, \".\" and\nthe body, keyed with the webhook's secret>. Paths lists every file pushed\nwith the commit, changed or not.", Value: webhooks.Event{}},
+	{Name: "WebhookEventProject", Value: webhooks.EventProject{}},
+	{Name: "WebhookEventCommit", Value: webhooks.EventCommit{}},
+	{Name: "WebhookEventMachine", Value: webhooks.EventMachine{}},
+	{Name: "PushRequest", Doc: "PushRequest is the body of a v1 POST /v1/push (contracts/push.v1.schema.json).", Value: PushRequest{}},
+	{Name: "PushProject", Value: PushProject{}},
+	{Name: "PushMachine", Value: PushMachine{}},
+	{Name: "PushCommit", Value: PushCommit{}},
+	{Name: "PushFile", Value: PushFile{}},
+	{Name: "PushStorage", Value: PushStorage{}},
+	{Name: "PushResult", Doc: "PushResult is the response of POST /v1/push.", Value: repo.PushResult{}},
+}
+
+// CheckServed reports the differences between Routes and the patterns a
+// router serves under Prefix.
+func CheckServed(served map[string]bool) error {
+	var missing, extra []string
+	want := map[string]bool{}
+	for _, r := range Routes {
+		p := r.Pattern()
+		want[p] = true
+		if !served[p] {
+			missing = append(missing, p)
+		}
+	}
+	for p := range served {
+		if !want[p] {
+			extra = append(extra, p)
+		}
+	}
+	if len(missing) == 0 && len(extra) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+	return fmt.Errorf("apispec: routes out of date: not served %v, not documented %v", missing, extra)
+}
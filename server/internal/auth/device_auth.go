@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// userCodeAlphabet avoids vowels and look-alike characters (RFC 8628 §6.1).
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+// NewDeviceCode returns the secret the CLI polls with (sentra login --device).
+// Only its hash is stored.
+func NewDeviceCode() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func HashDeviceCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewUserCode returns a short code like "BDFG-HJKL" for the user to type in a browser.
+func NewUserCode() (string, error) {
+	// 240 is the largest multiple of len(userCodeAlphabet) below 256; rejecting
+	// larger bytes keeps every character equally likely.
+	const limit = 240
+	out := make([]byte, 0, 9)
+	buf := make([]byte, 16)
+	for len(out) < 9 {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, v := range buf {
+			if len(out) == 9 {
+				break
+			}
+			if v >= limit {
+				continue
+			}
+			if len(out) == 4 {
+				out = append(out, '-')
+			}
+			out = append(out, userCodeAlphabet[int(v)%len(userCodeAlphabet)])
+		}
+	}
+	return string(out), nil
+}
+
+// NormalizeUserCode accepts user input in any case, with or without the dash.
+// It returns "" when the input cannot be a user code.
+func NormalizeUserCode(in string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(in) {
+		if r == '-' || r == ' ' {
+			continue
+		}
+		if !strings.ContainsRune(userCodeAlphabet, r) {
+			return ""
+		}
+		b.WriteRune(r)
+	}
+	s := b.String()
+	if len(s) != 8 {
+		return ""
+	}
+	return s[:4] + "-" + s[4:]
+}
+
+// NewPKCEVerifier returns an RFC 7636 code verifier for the browser leg of
+// the device flow, which the server completes on the user's behalf.
+func NewPKCEVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func PKCEChallengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// NewOAuthState returns an opaque value tying the OAuth callback to a device request.
+func NewOAuthState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
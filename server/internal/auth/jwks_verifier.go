@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rsa"
@@ -8,6 +9,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"math/big"
 	"net/http"
 	"strings"
@@ -23,6 +25,11 @@ type User struct {
 	ID    string `json:"id"`
 	Email string `json:"email,omitempty"`
 	Role  string `json:"role,omitempty"`
+
+	// Set only for service tokens.
+	TokenID     string `json:"token_id,omitempty"`
+	ProjectRoot string `json:"project_root,omitempty"`
+	ReadOnly    bool   `json:"read_only,omitempty"`
 }
 
 type Verifier interface {
@@ -100,7 +107,7 @@ func (v *JWKSVerifier) Verify(tokenString string) (User, error) {
 	}
 
 	// If key not found / stale keys, refresh once.
-	if refreshErr := v.refresh(); refreshErr != nil {
+	if refreshErr := v.refresh(context.Background()); refreshErr != nil {
 		return User{}, refreshErr
 	}
 
@@ -188,7 +195,40 @@ type jwk struct {
 	Y   string `json:"y"`
 }
 
-func (v *JWKSVerifier) refresh() error {
+// jwksMaxAge is how old the cached key set may get before Ready refetches it.
+const jwksMaxAge = time.Hour
+
+// Ready reports whether tokens can be verified: a key set with at least one
+// usable key is cached. An empty or stale cache is refreshed first; a stale
+// cache that cannot be refreshed still verifies tokens, so it stays ready.
+func (v *JWKSVerifier) Ready(ctx context.Context) error {
+	if v.jwksURL == "" {
+		return ErrAuthNotConfigured
+	}
+	v.mu.RLock()
+	n, last := len(v.keys), v.lastFetch
+	v.mu.RUnlock()
+	if n > 0 && time.Since(last) < jwksMaxAge {
+		return nil
+	}
+
+	err := v.refresh(ctx)
+	v.mu.RLock()
+	n = len(v.keys)
+	v.mu.RUnlock()
+	if n > 0 {
+		if err != nil {
+			slog.WarnContext(ctx, "jwks refresh failed; using cached keys", "err", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return errors.New("jwks has no usable keys")
+}
+
+func (v *JWKSVerifier) refresh(ctx context.Context) error {
 	// Simple throttling.
 	v.mu.RLock()
 	last := v.lastFetch
@@ -197,7 +237,11 @@ func (v *JWKSVerifier) refresh() error {
 		return nil
 	}
 
-	resp, err := v.httpClient.Get(v.jwksURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.jwksURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.httpClient.Do(req)
 	if err != nil {
 		return err
 	}
@@ -225,6 +269,7 @@ func (v *JWKSVerifier) refresh() error {
 			}
 			pub, err := jwkToRSAPublicKey(k.N, k.E)
 			if err != nil {
+				slog.Warn("jwks key skipped", "kid", k.Kid, "kty", k.Kty, "err", err)
 				continue
 			}
 			keys[k.Kid] = pub
@@ -235,6 +280,7 @@ func (v *JWKSVerifier) refresh() error {
 			}
 			pub, err := jwkToECPublicKey(k.X, k.Y)
 			if err != nil {
+				slog.Warn("jwks key skipped", "kid", k.Kid, "kty", k.Kty, "err", err)
 				continue
 			}
 			keys[k.Kid] = pub
@@ -245,6 +291,7 @@ func (v *JWKSVerifier) refresh() error {
 	v.keys = keys
 	v.lastFetch = time.Now()
 	v.mu.Unlock()
+	slog.Info("jwks refreshed", "keys", len(keys))
 
 	return nil
 }
@@ -4,52 +4,82 @@ import (
 	"context"
 	"errors"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
+
+	"github.com/mgeovany/sentra/server/internal/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+var tracer = otel.Tracer("github.com/mgeovany/sentra/server/internal/auth")
+
 type contextKey string
 
 const userKey contextKey = "sentra.user"
 
 type Middleware struct {
-	verifier Verifier
+	verifier      Verifier
+	serviceTokens Verifier
 }
 
 func NewMiddleware(verifier Verifier) Middleware {
 	if verifier == nil {
 		verifier = DisabledVerifier{}
 	}
-	return Middleware{verifier: verifier}
+	return Middleware{verifier: verifier, serviceTokens: DisabledVerifier{}}
+}
+
+// WithServiceTokens accepts ServiceTokenPrefix tokens alongside user JWTs.
+func (m Middleware) WithServiceTokens(verifier Verifier) Middleware {
+	if verifier == nil {
+		verifier = DisabledVerifier{}
+	}
+	m.serviceTokens = verifier
+	return m
 }
 
 func (m Middleware) Require(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracer.Start(r.Context(), "auth.Require")
+		defer span.End()
+		r = r.WithContext(ctx)
+
 		token := bearerToken(r.Header.Get("Authorization"))
 		if token == "" {
-			log.Printf("auth missing token method=%s path=%s", r.Method, r.URL.Path)
+			slog.WarnContext(r.Context(), "auth missing token", "method", r.Method, "path", r.URL.Path)
 			w.WriteHeader(http.StatusUnauthorized)
 			_, _ = io.WriteString(w, "unauthorized")
 			return
 		}
 
-		u, err := m.verifier.Verify(token)
+		verifier := m.verifier
+		if IsServiceTokenString(token) {
+			verifier = m.serviceTokens
+		}
+		if verifier == nil {
+			verifier = DisabledVerifier{}
+		}
+
+		u, err := verifier.Verify(token)
 		if err != nil {
 			if errors.Is(err, ErrAuthNotConfigured) {
-				log.Printf("auth not configured method=%s path=%s", r.Method, r.URL.Path)
+				slog.ErrorContext(r.Context(), "auth not configured", "method", r.Method, "path", r.URL.Path)
 				w.WriteHeader(http.StatusServiceUnavailable)
 				_, _ = io.WriteString(w, "auth not configured")
 				return
 			}
 
-			log.Printf("auth verify failed method=%s path=%s err=%v", r.Method, r.URL.Path, err)
+			slog.WarnContext(r.Context(), "auth verify failed", "method", r.Method, "path", r.URL.Path, "err", err)
 			w.WriteHeader(http.StatusUnauthorized)
 			_, _ = io.WriteString(w, "unauthorized")
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), userKey, u)
+		logging.SetUserID(r.Context(), u.ID)
+		span.SetAttributes(attribute.Bool("sentra.service_token", IsServiceTokenString(token)))
+		ctx = context.WithValue(r.Context(), userKey, u)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// ServiceTokenPrefix marks non-interactive tokens issued by the server
+// (sentra tokens create). Anything else is treated as a Supabase JWT.
+const ServiceTokenPrefix = "sst_"
+
+// IsServiceToken reports whether the request was authenticated with a
+// service token rather than a user session.
+func (u User) IsServiceToken() bool {
+	return strings.TrimSpace(u.TokenID) != ""
+}
+
+// CanAccessProject reports whether the principal may read or write root.
+// User sessions and unscoped tokens see every project of the user.
+func (u User) CanAccessProject(root string) bool {
+	scope := strings.TrimSpace(u.ProjectRoot)
+	return scope == "" || scope == strings.TrimSpace(root)
+}
+
+func IsServiceTokenString(token string) bool {
+	return strings.HasPrefix(strings.TrimSpace(token), ServiceTokenPrefix)
+}
+
+// NewServiceToken returns a fresh random token. Only its hash is stored.
+func NewServiceToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return ServiceTokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func HashServiceToken(token string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(token)))
+	return hex.EncodeToString(sum[:])
+}
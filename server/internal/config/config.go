@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strings"
 )
 
 type Config struct {
@@ -10,6 +11,30 @@ type Config struct {
 
 	SupabaseURL            string
 	SupabaseServiceRoleKey string
+
+	// PublicURL is the externally reachable base URL, used for device login links.
+	// When empty it is derived from each request.
+	PublicURL string
+
+	// LogLevel is debug, info (default), warn or error.
+	LogLevel string
+
+	// MetricsToken protects /metrics when set.
+	MetricsToken string
+
+	// LimitStore holds rate limit buckets and signed-request nonces: memory
+	// (default), postgres or redis. Use a shared store with more than one
+	// instance.
+	LimitStore string
+	RedisURL   string
+
+	// LimitsFile is a JSON file of per-route rate limits, quotas and
+	// per-user overrides (see limits.Config). Empty uses the defaults.
+	LimitsFile string
+
+	// WebhookAllowPrivate lets webhooks use http and reach loopback and
+	// private addresses. For local development only.
+	WebhookAllowPrivate bool
 }
 
 func FromEnv() Config {
@@ -35,5 +60,16 @@ func FromEnv() Config {
 
 		SupabaseURL:            os.Getenv("SUPABASE_URL"),
 		SupabaseServiceRoleKey: os.Getenv("SUPABASE_SERVICE_ROLE_KEY"),
+
+		PublicURL: os.Getenv("SENTRA_PUBLIC_URL"),
+
+		LogLevel:     os.Getenv("LOG_LEVEL"),
+		MetricsToken: os.Getenv("SENTRA_METRICS_TOKEN"),
+
+		LimitStore: strings.ToLower(strings.TrimSpace(os.Getenv("SENTRA_LIMIT_STORE"))),
+		RedisURL:   os.Getenv("SENTRA_REDIS_URL"),
+		LimitsFile: os.Getenv("SENTRA_LIMITS_FILE"),
+
+		WebhookAllowPrivate: strings.EqualFold(strings.TrimSpace(os.Getenv("SENTRA_WEBHOOK_ALLOW_PRIVATE")), "true"),
 	}
 }
@@ -1,11 +1,43 @@
 package health
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"io"
+	"log/slog"
 	"net/http"
+	"sync"
+	"time"
 )
 
-func Register(mux *http.ServeMux) {
+// ErrNotConfigured is returned by a probe whose component is disabled, for
+// example the database when Supabase credentials are missing.
+var ErrNotConfigured = errors.New("not configured")
+
+const probeTimeout = 3 * time.Second
+
+// Check is one component /readyz probes.
+type Check struct {
+	Name  string
+	Probe func(ctx context.Context) error
+}
+
+type componentStatus struct {
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	LatencyMS int64  `json:"latency_ms"`
+}
+
+type readyResponse struct {
+	Status     string                     `json:"status"`
+	Components map[string]componentStatus `json:"components"`
+}
+
+// Register serves the liveness endpoints (/health, /healthz, /livez), which
+// only say the process is up, and /readyz, which probes every check and
+// answers 503 unless all of them pass.
+func Register(mux *http.ServeMux, checks ...Check) {
 	ok := func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		w.WriteHeader(http.StatusOK)
@@ -15,11 +47,64 @@ func Register(mux *http.ServeMux) {
 	mux.HandleFunc("/health", ok)
 	mux.HandleFunc("/healthz", ok)
 	mux.HandleFunc("/livez", ok)
-	mux.HandleFunc("/readyz", ok)
+	mux.Handle("/readyz", readyHandler(checks))
 }
 
-func Handler() http.Handler {
+func Handler(checks ...Check) http.Handler {
 	mux := http.NewServeMux()
-	Register(mux)
+	Register(mux, checks...)
 	return mux
 }
+
+func readyHandler(checks []Check) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), probeTimeout)
+		defer cancel()
+
+		res := readyResponse{Status: "ok", Components: make(map[string]componentStatus, len(checks))}
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for _, c := range checks {
+			wg.Go(func() {
+				s := probe(ctx, c)
+				mu.Lock()
+				defer mu.Unlock()
+				res.Components[c.Name] = s
+				if s.Status != "ok" {
+					res.Status = "fail"
+				}
+			})
+		}
+		wg.Wait()
+
+		status := http.StatusOK
+		if res.Status != "ok" {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(res)
+	})
+}
+
+// probe runs one check. The response only classifies failures; the error
+// itself can name internal hosts, so it goes to the log.
+func probe(ctx context.Context, c Check) componentStatus {
+	start := time.Now()
+	err := c.Probe(ctx)
+	s := componentStatus{Status: "ok", LatencyMS: time.Since(start).Milliseconds()}
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrNotConfigured):
+		s.Status = "disabled"
+	case errors.Is(err, context.DeadlineExceeded):
+		s.Status, s.Error = "fail", "timeout"
+	default:
+		s.Status, s.Error = "fail", "unavailable"
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "readiness check failed", "component", c.Name, "err", err)
+	}
+	return s
+}
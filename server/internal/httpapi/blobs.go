@@ -0,0 +1,152 @@
+package httpapi
+
+import (
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mgeovany/sentra/server/internal/apispec"
+	"github.com/mgeovany/sentra/server/internal/auth"
+	"github.com/mgeovany/sentra/server/internal/metrics"
+	"github.com/mgeovany/sentra/server/internal/repo"
+)
+
+// maxBlobBytes bounds one uploaded ciphertext: the push schema's 64 MiB file
+// size plus sentra-v3 segment overhead.
+const maxBlobBytes = 65 << 20
+
+// blobsHandler serves /blobs/<root>/<id> for hosted storage: PUT uploads a
+// ciphertext, GET downloads it and HEAD checks whether it exists. A push then
+// references the blob with storage provider "sentra" and key "<root>/<id>".
+func blobsHandler(store repo.BlobStore) http.Handler {
+	if store == nil {
+		store = repo.DisabledBlobStore{}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := auth.UserFromContext(r.Context())
+		if !ok || strings.TrimSpace(user.ID) == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		root, id, ok := parseBlobPath(r.URL.Path)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if denyProject(w, r, user, root) {
+			return
+		}
+
+		switch r.Method {
+		case http.MethodHead:
+			exists, err := store.BlobExists(r.Context(), user.ID, root, id)
+			if err != nil {
+				writeBlobError(w, r, "blob head", user.ID, root, err)
+				return
+			}
+			if !exists {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+
+		case http.MethodGet:
+			body, size, err := store.OpenBlob(r.Context(), user.ID, root, id)
+			if err != nil {
+				writeBlobError(w, r, "blob download", user.ID, root, err)
+				return
+			}
+			defer func() { _ = body.Close() }()
+			w.Header().Set("Content-Type", "application/octet-stream")
+			if size > 0 {
+				w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+			}
+			w.WriteHeader(http.StatusOK)
+			_, _ = io.Copy(w, body)
+
+		case http.MethodPut:
+			if user.ReadOnly {
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = io.WriteString(w, "read-only token")
+				return
+			}
+			if r.ContentLength <= 0 {
+				w.WriteHeader(http.StatusLengthRequired)
+				return
+			}
+			if r.ContentLength > maxBlobBytes {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				_, _ = io.WriteString(w, "blob too large")
+				return
+			}
+			body := http.MaxBytesReader(w, r.Body, maxBlobBytes)
+			if err := store.PutBlob(r.Context(), user.ID, root, id, body, r.ContentLength); err != nil {
+				writeBlobError(w, r, "blob upload", user.ID, root, err)
+				return
+			}
+			metrics.ObserveBlobUpload(r.ContentLength)
+			slog.InfoContext(r.Context(), "blob uploaded", "user_id", user.ID, "root", root, "blob_id", id, "size", r.ContentLength)
+			w.WriteHeader(http.StatusCreated)
+
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// parseBlobPath splits "/blobs/<root>/<id>". Roots are single path segments;
+// ids are lowercase hex SHA-256-sized content addresses.
+func parseBlobPath(p string) (root string, id string, ok bool) {
+	rest := strings.TrimPrefix(strings.TrimPrefix(p, apispec.Prefix), "/blobs/")
+	i := strings.LastIndex(rest, "/")
+	if i <= 0 {
+		return "", "", false
+	}
+	root, id = rest[:i], rest[i+1:]
+	if strings.Contains(root, "/") || strings.TrimSpace(root) != root || len(root) > 300 {
+		return "", "", false
+	}
+	if !validBlobID(id) {
+		return "", "", false
+	}
+	return root, id, true
+}
+
+func validBlobID(id string) bool {
+	if len(id) != 64 || strings.ToLower(id) != id {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+func writeBlobError(w http.ResponseWriter, r *http.Request, op string, userID string, root string, err error) {
+	switch {
+	case errors.Is(err, repo.ErrBlobNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		return
+	case errors.Is(err, repo.ErrDBNotConfigured):
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = io.WriteString(w, "db not configured")
+		return
+	}
+	var quotaErr *repo.QuotaError
+	if errors.As(err, &quotaErr) {
+		writeQuotaExceeded(w, quotaErr)
+		return
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		_, _ = io.WriteString(w, "blob too large")
+		return
+	}
+	slog.ErrorContext(r.Context(), op+" failed", "user_id", userID, "root", root, "err", err)
+	writeHTTPError(w, http.StatusInternalServerError, op+" failed", err)
+}
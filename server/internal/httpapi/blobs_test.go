@@ -0,0 +1,121 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mgeovany/sentra/server/internal/repo"
+)
+
+// fakeBlobStore keeps blobs in memory, keyed by user, root and id.
+type fakeBlobStore struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func newFakeBlobStore() *fakeBlobStore {
+	return &fakeBlobStore{blobs: map[string][]byte{}}
+}
+
+func (s *fakeBlobStore) BlobExists(ctx context.Context, userID string, root string, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.blobs[userID+"/"+root+"/"+id]
+	return ok, nil
+}
+
+func (s *fakeBlobStore) PutBlob(ctx context.Context, userID string, root string, id string, body io.Reader, size int64) error {
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blobs[userID+"/"+root+"/"+id] = b
+	return nil
+}
+
+func (s *fakeBlobStore) OpenBlob(ctx context.Context, userID string, root string, id string) (io.ReadCloser, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.blobs[userID+"/"+root+"/"+id]
+	if !ok {
+		return nil, 0, repo.ErrBlobNotFound
+	}
+	return io.NopCloser(bytes.NewReader(b)), int64(len(b)), nil
+}
+
+func (s *fakeBlobStore) DeleteBlob(ctx context.Context, userID string, root string, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.blobs, userID+"/"+root+"/"+id)
+	return nil
+}
+
+func (s *fakeBlobStore) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.blobs)
+}
+
+// countingReader counts the bytes read from it.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+const testBlobID = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+
+func TestBlobUploadQuotas(t *testing.T) {
+	tests := []struct {
+		name   string
+		quota  repo.Quota
+		usage  repo.Usage
+		size   int
+		status int
+		added  int64
+	}{
+		{name: "under quota", quota: repo.Quota{DailyPushBytes: 100, StorageBytes: 100}, size: 40, status: http.StatusCreated, added: 40},
+		{name: "daily quota", quota: repo.Quota{DailyPushBytes: 100}, usage: repo.Usage{PushedBytesToday: 70}, size: 40, status: http.StatusTooManyRequests},
+		{name: "storage quota", quota: repo.Quota{StorageBytes: 100}, usage: repo.Usage{StorageBytes: 70}, size: 40, status: http.StatusInsufficientStorage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blobs := newFakeBlobStore()
+			usage := &fakeUsage{usage: tt.usage}
+			store := repo.NewQuotaBlobStore(blobs, usage, func(string) repo.Quota { return tt.quota })
+			h := authed(fakeVerifier{"tok": {ID: "user-1"}}, blobsHandler(store))
+
+			body := &countingReader{r: strings.NewReader(strings.Repeat("x", tt.size))}
+			req := httptest.NewRequest(http.MethodPut, "/v1/blobs/app/"+testBlobID, body)
+			req.ContentLength = int64(tt.size)
+			req.Header.Set("Authorization", "Bearer tok")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d; want %d (body %q)", rec.Code, tt.status, rec.Body.String())
+			}
+			if tt.status != http.StatusCreated {
+				if body.n != 0 || blobs.len() != 0 {
+					t.Fatalf("rejected upload read %d bytes and stored %d blobs", body.n, blobs.len())
+				}
+			}
+			if usage.added != tt.added {
+				t.Fatalf("recorded %d pushed bytes; want %d", usage.added, tt.added)
+			}
+		})
+	}
+}
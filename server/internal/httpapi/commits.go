@@ -2,10 +2,14 @@ package httpapi
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mgeovany/sentra/server/internal/auth"
 	"github.com/mgeovany/sentra/server/internal/repo"
@@ -28,18 +32,28 @@ func commitsHandler(store repo.CommitStore) http.Handler {
 			return
 		}
 
-		root := strings.TrimSpace(r.URL.Query().Get("root"))
-		if root == "" {
+		q, msg := parseCommitQuery(r.URL.Query())
+		if msg != "" {
 			w.WriteHeader(http.StatusBadRequest)
-			_, _ = io.WriteString(w, "missing root")
+			_, _ = io.WriteString(w, msg)
+			return
+		}
+		if q.Root == "" {
+			// Project-scoped tokens only ever see their own project.
+			q.Root = strings.TrimSpace(user.ProjectRoot)
+		}
+		if denyProject(w, r, user, q.Root) {
 			return
 		}
 
-		commits, err := store.ListCommits(r.Context(), user.ID, root)
+		page, err := store.ListCommits(r.Context(), user.ID, q)
 		if err != nil {
-			log.Printf("commits list failed user_id=%s root=%s err=%v", user.ID, root, err)
-			switch err {
-			case repo.ErrDBNotConfigured:
+			slog.ErrorContext(r.Context(), "commits list failed", "user_id", user.ID, "root", q.Root, "err", err)
+			switch {
+			case errors.Is(err, repo.ErrInvalidCursor):
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = io.WriteString(w, "invalid cursor")
+			case errors.Is(err, repo.ErrDBNotConfigured):
 				w.WriteHeader(http.StatusServiceUnavailable)
 				_, _ = io.WriteString(w, "db not configured")
 			default:
@@ -49,8 +63,66 @@ func commitsHandler(store repo.CommitStore) http.Handler {
 			return
 		}
 
+		commits := page.Commits
+		if commits == nil {
+			commits = []repo.CommitInfo{}
+		}
+		// The body stays a plain array for CLIs that predate paging.
+		if page.NextCursor != "" {
+			w.Header().Set(nextCursorHeader, page.NextCursor)
+		}
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 		w.WriteHeader(http.StatusOK)
 		_ = json.NewEncoder(w).Encode(commits)
 	})
 }
+
+// nextCursorHeader carries the cursor of the next page of /commits.
+const nextCursorHeader = "X-Next-Cursor"
+
+// maxCommitsLimit bounds one page of /commits.
+const maxCommitsLimit = 500
+
+// parseCommitQuery reads the /commits filters, returning a message for the
+// client when one is invalid. Without limit every matching commit is
+// returned, as before paging existed.
+func parseCommitQuery(v url.Values) (repo.CommitQuery, string) {
+	q := repo.CommitQuery{
+		Root:      strings.TrimSpace(v.Get("root")),
+		Cursor:    strings.TrimSpace(v.Get("cursor")),
+		MachineID: strings.TrimSpace(v.Get("machine_id")),
+		Path:      strings.TrimPrefix(strings.TrimSpace(v.Get("path")), "./"),
+		Message:   strings.TrimSpace(v.Get("message")),
+	}
+	if s := strings.TrimSpace(v.Get("limit")); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxCommitsLimit {
+			return q, "invalid limit (1-500)"
+		}
+		q.Limit = n
+	}
+	if q.Cursor != "" && q.Limit == 0 {
+		return q, "cursor requires limit"
+	}
+	for _, f := range []struct {
+		name string
+		dst  *time.Time
+	}{{"since", &q.Since}, {"until", &q.Until}} {
+		s := strings.TrimSpace(v.Get(f.name))
+		if s == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return q, "invalid " + f.name + " (RFC 3339 time)"
+		}
+		*f.dst = t
+	}
+	if !q.Since.IsZero() && !q.Until.IsZero() && q.Until.Before(q.Since) {
+		return q, "until is before since"
+	}
+	if len(q.Root) > 300 || len(q.Path) > 512 || len(q.Message) > 200 || len(q.MachineID) > 100 {
+		return q, "filter too long"
+	}
+	return q, ""
+}
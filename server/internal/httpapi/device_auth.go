@@ -0,0 +1,429 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mgeovany/sentra/server/internal/auth"
+	"github.com/mgeovany/sentra/server/internal/repo"
+	"github.com/mgeovany/sentra/server/internal/supabase"
+)
+
+// Device authorization (sentra login --device), modelled on RFC 8628:
+//
+//	POST /device/code      CLI gets a device_code (secret) and a user_code (shown to the user)
+//	GET  /device           browser page where the user confirms the user_code
+//	POST /device           starts the OAuth login for that code
+//	GET  /device/callback  OAuth return; stores the session on the request
+//	POST /device/token     CLI polls with device_code until the session is ready
+const (
+	deviceAuthTTL      = 10 * time.Minute
+	deviceAuthInterval = 5 * time.Second
+
+	deviceCSRFCookie  = "sentra_device_csrf"
+	deviceStateCookie = "sentra_device_state"
+)
+
+// OAuthProvider completes the browser leg of the device flow on the user's behalf.
+type OAuthProvider interface {
+	AuthorizeURL(provider, redirectTo, codeChallenge string) string
+	ExchangePKCE(ctx context.Context, authCode, codeVerifier string) (supabase.TokenResponse, error)
+}
+
+type deviceCodeResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+type deviceTokenRequest struct {
+	DeviceCode string `json:"device_code"`
+}
+
+func deviceCodeHandler(store repo.DeviceAuthStore, oauth OAuthProvider, publicURL string) http.Handler {
+	if store == nil {
+		store = repo.DisabledDeviceAuthStore{}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if oauth == nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = io.WriteString(w, "device login not configured")
+			return
+		}
+
+		deviceCode, err := auth.NewDeviceCode()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		userCode, err := auth.NewUserCode()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		purgeExpiredDeviceAuth(r, store)
+		d, err := store.Create(r.Context(), userCode, auth.HashDeviceCode(deviceCode), time.Now().UTC().Add(deviceAuthTTL))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "device auth create failed", "err", err)
+			writeDeviceAuthStoreError(w, err, "device code failed")
+			return
+		}
+
+		verify := baseURL(r, publicURL) + "/device"
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(deviceCodeResponse{
+			DeviceCode:              deviceCode,
+			UserCode:                d.UserCode,
+			VerificationURI:         verify,
+			VerificationURIComplete: verify + "?user_code=" + url.QueryEscape(d.UserCode),
+			ExpiresIn:               int(deviceAuthTTL.Seconds()),
+			Interval:                int(deviceAuthInterval.Seconds()),
+		})
+	})
+}
+
+// purgeExpiredDeviceAuth deletes expired requests, so tokens of an approval
+// nobody collected do not outlive it. Failures only get logged.
+func purgeExpiredDeviceAuth(r *http.Request, store repo.DeviceAuthStore) {
+	n, err := store.DeleteExpired(r.Context(), time.Now().UTC())
+	if err != nil {
+		slog.WarnContext(r.Context(), "device auth purge failed", "err", err)
+		return
+	}
+	if n > 0 {
+		slog.InfoContext(r.Context(), "device auth requests purged", "count", n)
+	}
+}
+
+func deviceTokenHandler(store repo.DeviceAuthStore) http.Handler {
+	if store == nil {
+		store = repo.DisabledDeviceAuthStore{}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, 4<<10) // 4 KiB
+		var req deviceTokenRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.DeviceCode) == "" {
+			writeDeviceTokenError(w, "invalid_request")
+			return
+		}
+
+		purgeExpiredDeviceAuth(r, store)
+		d, ok, err := store.FindByDeviceCode(r.Context(), auth.HashDeviceCode(req.DeviceCode))
+		if err != nil {
+			writeDeviceAuthStoreError(w, err, "device token failed")
+			return
+		}
+		if !ok {
+			writeDeviceTokenError(w, "invalid_grant")
+			return
+		}
+
+		now := time.Now().UTC()
+		if d.Expired(now) {
+			writeDeviceTokenError(w, "expired_token")
+			return
+		}
+
+		switch d.Status {
+		case repo.DeviceAuthDenied:
+			writeDeviceTokenError(w, "access_denied")
+		case repo.DeviceAuthApproved:
+			consumed, ok, err := store.Consume(r.Context(), d.ID)
+			if err != nil {
+				writeDeviceAuthStoreError(w, err, "device token failed")
+				return
+			}
+			if !ok || len(consumed.Session) == 0 {
+				// Another poll collected it first.
+				writeDeviceTokenError(w, "invalid_grant")
+				return
+			}
+			slog.InfoContext(r.Context(), "device auth completed", "device_request_id", d.ID)
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Header().Set("Cache-Control", "no-store")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write(consumed.Session)
+		default:
+			if d.LastPolledAt != nil && now.Sub(*d.LastPolledAt) < deviceAuthInterval {
+				writeDeviceTokenError(w, "slow_down")
+				return
+			}
+			if err := store.TouchPoll(r.Context(), d.ID, now); err != nil {
+				writeDeviceAuthStoreError(w, err, "device token failed")
+				return
+			}
+			writeDeviceTokenError(w, "authorization_pending")
+		}
+	})
+}
+
+func deviceVerifyHandler(store repo.DeviceAuthStore, oauth OAuthProvider, publicURL string) http.Handler {
+	if store == nil {
+		store = repo.DisabledDeviceAuthStore{}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if oauth == nil {
+			renderDevicePage(w, http.StatusServiceUnavailable, devicePage{Title: "Device login unavailable", Message: "This server is not configured for device login."})
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			csrf, err := auth.NewOAuthState()
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{
+				Name:     deviceCSRFCookie,
+				Value:    csrf,
+				Path:     "/device",
+				MaxAge:   int(deviceAuthTTL.Seconds()),
+				HttpOnly: true,
+				Secure:   r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https"),
+				SameSite: http.SameSiteStrictMode,
+			})
+			renderDevicePage(w, http.StatusOK, devicePage{
+				Title:    "Sentra device login",
+				Form:     true,
+				UserCode: auth.NormalizeUserCode(r.URL.Query().Get("user_code")),
+				CSRF:     csrf,
+			})
+		case http.MethodPost:
+			r.Body = http.MaxBytesReader(w, r.Body, 4<<10) // 4 KiB
+			if err := r.ParseForm(); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			cookie, err := r.Cookie(deviceCSRFCookie)
+			if err != nil || cookie.Value == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(r.PostForm.Get("csrf"))) != 1 {
+				renderDevicePage(w, http.StatusForbidden, devicePage{Title: "Login failed", Message: "This form expired. Reload the page and try again."})
+				return
+			}
+
+			userCode := auth.NormalizeUserCode(r.PostForm.Get("user_code"))
+			d, ok, err := store.FindByUserCode(r.Context(), userCode)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "device auth lookup failed", "err", err)
+				renderDevicePage(w, http.StatusServiceUnavailable, devicePage{Title: "Login failed", Message: "Please try again in a moment."})
+				return
+			}
+			if userCode == "" || !ok || d.Status != repo.DeviceAuthPending || d.Expired(time.Now().UTC()) {
+				renderDevicePage(w, http.StatusBadRequest, devicePage{Title: "Code not recognised", Message: "Check the code in your terminal, or run `sentra login --device` again.", Form: true, CSRF: cookie.Value})
+				return
+			}
+
+			verifier, err := auth.NewPKCEVerifier()
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			state, err := auth.NewOAuthState()
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if err := store.BeginAuthorization(r.Context(), d.ID, state, verifier); err != nil {
+				slog.ErrorContext(r.Context(), "device auth begin failed", "device_request_id", d.ID, "err", err)
+				renderDevicePage(w, http.StatusServiceUnavailable, devicePage{Title: "Login failed", Message: "Please try again in a moment."})
+				return
+			}
+
+			// Lax so it survives the top-level redirect back from the OAuth provider.
+			http.SetCookie(w, &http.Cookie{
+				Name:     deviceStateCookie,
+				Value:    state,
+				Path:     "/device",
+				MaxAge:   int(deviceAuthTTL.Seconds()),
+				HttpOnly: true,
+				Secure:   r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https"),
+				SameSite: http.SameSiteLaxMode,
+			})
+
+			callback := baseURL(r, publicURL) + "/device/callback?state=" + url.QueryEscape(state)
+			http.Redirect(w, r, oauth.AuthorizeURL("google", callback, auth.PKCEChallengeS256(verifier)), http.StatusSeeOther)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func deviceCallbackHandler(store repo.DeviceAuthStore, oauth OAuthProvider) http.Handler {
+	if store == nil {
+		store = repo.DisabledDeviceAuthStore{}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if oauth == nil {
+			renderDevicePage(w, http.StatusServiceUnavailable, devicePage{Title: "Device login unavailable", Message: "This server is not configured for device login."})
+			return
+		}
+
+		q := r.URL.Query()
+		state := strings.TrimSpace(q.Get("state"))
+		cookie, err := r.Cookie(deviceStateCookie)
+		if state == "" || err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+			renderDevicePage(w, http.StatusBadRequest, devicePage{Title: "Login failed", Message: "Invalid callback state. Start again from your terminal."})
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: deviceStateCookie, Value: "", Path: "/device", MaxAge: -1})
+
+		d, ok, err := store.FindByState(r.Context(), state)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "device auth callback lookup failed", "err", err)
+			renderDevicePage(w, http.StatusServiceUnavailable, devicePage{Title: "Login failed", Message: "Please try again in a moment."})
+			return
+		}
+		if !ok || d.Status != repo.DeviceAuthPending || d.Expired(time.Now().UTC()) {
+			renderDevicePage(w, http.StatusBadRequest, devicePage{Title: "Login expired", Message: "Run `sentra login --device` again."})
+			return
+		}
+
+		code := strings.TrimSpace(q.Get("code"))
+		if code == "" {
+			_ = store.Deny(r.Context(), d.ID)
+			msg := strings.TrimSpace(q.Get("error_description"))
+			if msg == "" {
+				msg = "Authentication was cancelled."
+			}
+			renderDevicePage(w, http.StatusBadRequest, devicePage{Title: "Login failed", Message: msg})
+			return
+		}
+
+		tr, err := oauth.ExchangePKCE(r.Context(), code, d.CodeVerifier)
+		if err != nil {
+			slog.WarnContext(r.Context(), "device auth exchange failed", "device_request_id", d.ID, "err", err)
+			renderDevicePage(w, http.StatusBadGateway, devicePage{Title: "Login failed", Message: "Could not complete sign-in. Start again from your terminal."})
+			return
+		}
+		session, err := json.Marshal(tr)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if err := store.Approve(r.Context(), d.ID, session); err != nil {
+			slog.ErrorContext(r.Context(), "device auth approve failed", "device_request_id", d.ID, "err", err)
+			renderDevicePage(w, http.StatusServiceUnavailable, devicePage{Title: "Login failed", Message: "Please try again in a moment."})
+			return
+		}
+
+		slog.InfoContext(r.Context(), "device auth approved", "device_request_id", d.ID)
+		renderDevicePage(w, http.StatusOK, devicePage{Title: "Device approved", Message: "You can close this tab and return to your terminal."})
+	})
+}
+
+func writeDeviceTokenError(w http.ResponseWriter, code string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": code})
+}
+
+func writeDeviceAuthStoreError(w http.ResponseWriter, err error, publicMsg string) {
+	switch err {
+	case repo.ErrDBNotConfigured:
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = io.WriteString(w, "db not configured")
+	case repo.ErrDBMisconfigured:
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = io.WriteString(w, "db misconfigured")
+	default:
+		writeHTTPError(w, http.StatusInternalServerError, publicMsg, err)
+	}
+}
+
+// baseURL prefers the configured public URL; behind a proxy the request host may be internal.
+func baseURL(r *http.Request, publicURL string) string {
+	if v := strings.TrimRight(strings.TrimSpace(publicURL), "/"); v != "" {
+		return v
+	}
+	scheme := "http"
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+type devicePage struct {
+	Title    string
+	Message  string
+	Form     bool
+	UserCode string
+	CSRF     string
+}
+
+var devicePageTemplate = template.Must(template.New("device").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="UTF-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1.0">
+	<title>{{.Title}}</title>
+	<style>
+		* { margin: 0; padding: 0; box-sizing: border-box; }
+		body {
+			font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif;
+			background: white; color: black;
+			display: flex; align-items: center; justify-content: center;
+			min-height: 100vh; padding: 20px;
+		}
+		.container { text-align: center; max-width: 480px; width: 100%; }
+		h1 { font-size: 24px; font-weight: 400; margin-bottom: 16px; letter-spacing: 0.5px; }
+		p { font-size: 14px; line-height: 1.6; margin-bottom: 12px; font-weight: 300; }
+		input[type=text] { font-size: 24px; letter-spacing: 4px; text-align: center; text-transform: uppercase; padding: 8px; width: 100%; margin: 12px 0; }
+		button { font-size: 14px; padding: 10px 20px; cursor: pointer; }
+		.hint { font-size: 12px; margin-top: 24px; opacity: 0.6; }
+	</style>
+</head>
+<body>
+	<div class="container">
+		<h1>{{.Title}}</h1>
+		{{if .Message}}<p>{{.Message}}</p>{{end}}
+		{{if .Form}}
+		<form method="post" action="/device">
+			<p>Enter the code shown by <code>sentra login --device</code>.</p>
+			<input type="text" name="user_code" value="{{.UserCode}}" placeholder="XXXX-XXXX" maxlength="9" autocomplete="off" autofocus required>
+			<input type="hidden" name="csrf" value="{{.CSRF}}">
+			<button type="submit">Continue</button>
+		</form>
+		<p class="hint">Only continue if you started this login yourself and the code matches your terminal.</p>
+		{{end}}
+	</div>
+</body>
+</html>`))
+
+func renderDevicePage(w http.ResponseWriter, status int, p devicePage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(status)
+	_ = devicePageTemplate.Execute(w, p)
+}
@@ -0,0 +1,458 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mgeovany/sentra/server/internal/auth"
+	"github.com/mgeovany/sentra/server/internal/ratelimit"
+	"github.com/mgeovany/sentra/server/internal/repo"
+	"github.com/mgeovany/sentra/server/internal/supabase"
+)
+
+// fakeDeviceStore keeps device requests in memory.
+type fakeDeviceStore struct {
+	mu   sync.Mutex
+	reqs map[string]*repo.DeviceAuthRequest
+	// hashes maps device code hashes to request ids.
+	hashes map[string]string
+	// purgeErr, when set, makes DeleteExpired fail.
+	purgeErr error
+	nextID   int
+}
+
+func newFakeDeviceStore() *fakeDeviceStore {
+	return &fakeDeviceStore{reqs: map[string]*repo.DeviceAuthRequest{}, hashes: map[string]string{}}
+}
+
+func (s *fakeDeviceStore) Create(ctx context.Context, userCode, deviceCodeHash string, expiresAt time.Time) (repo.DeviceAuthRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	d := &repo.DeviceAuthRequest{ID: "req-" + strconv.Itoa(s.nextID), UserCode: userCode, Status: repo.DeviceAuthPending, CreatedAt: time.Now().UTC(), ExpiresAt: expiresAt}
+	s.reqs[d.ID] = d
+	s.hashes[deviceCodeHash] = d.ID
+	return *d, nil
+}
+
+func (s *fakeDeviceStore) find(match func(*repo.DeviceAuthRequest) bool) (repo.DeviceAuthRequest, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, d := range s.reqs {
+		if match(d) {
+			return *d, true, nil
+		}
+	}
+	return repo.DeviceAuthRequest{}, false, nil
+}
+
+func (s *fakeDeviceStore) FindByUserCode(ctx context.Context, userCode string) (repo.DeviceAuthRequest, bool, error) {
+	return s.find(func(d *repo.DeviceAuthRequest) bool { return d.UserCode == userCode })
+}
+
+func (s *fakeDeviceStore) FindByState(ctx context.Context, state string) (repo.DeviceAuthRequest, bool, error) {
+	return s.find(func(d *repo.DeviceAuthRequest) bool { return d.OAuthState != "" && d.OAuthState == state })
+}
+
+func (s *fakeDeviceStore) FindByDeviceCode(ctx context.Context, deviceCodeHash string) (repo.DeviceAuthRequest, bool, error) {
+	s.mu.Lock()
+	id := s.hashes[deviceCodeHash]
+	s.mu.Unlock()
+	return s.find(func(d *repo.DeviceAuthRequest) bool { return d.ID == id })
+}
+
+func (s *fakeDeviceStore) update(id string, f func(*repo.DeviceAuthRequest)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if d, ok := s.reqs[id]; ok {
+		f(d)
+	}
+	return nil
+}
+
+func (s *fakeDeviceStore) BeginAuthorization(ctx context.Context, id, state, codeVerifier string) error {
+	return s.update(id, func(d *repo.DeviceAuthRequest) { d.OAuthState, d.CodeVerifier = state, codeVerifier })
+}
+
+func (s *fakeDeviceStore) Approve(ctx context.Context, id string, session json.RawMessage) error {
+	return s.update(id, func(d *repo.DeviceAuthRequest) { d.Status, d.Session = repo.DeviceAuthApproved, session })
+}
+
+func (s *fakeDeviceStore) Deny(ctx context.Context, id string) error {
+	return s.update(id, func(d *repo.DeviceAuthRequest) { d.Status = repo.DeviceAuthDenied })
+}
+
+func (s *fakeDeviceStore) TouchPoll(ctx context.Context, id string, at time.Time) error {
+	return s.update(id, func(d *repo.DeviceAuthRequest) { d.LastPolledAt = &at })
+}
+
+func (s *fakeDeviceStore) Consume(ctx context.Context, id string) (repo.DeviceAuthRequest, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.reqs[id]
+	if !ok || d.Status != repo.DeviceAuthApproved {
+		return repo.DeviceAuthRequest{}, false, nil
+	}
+	delete(s.reqs, id)
+	return *d, true, nil
+}
+
+func (s *fakeDeviceStore) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.purgeErr != nil {
+		return 0, s.purgeErr
+	}
+	n := 0
+	for id, d := range s.reqs {
+		if d.Expired(now) {
+			delete(s.reqs, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// expire moves every request's expiry into the past.
+func (s *fakeDeviceStore) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, d := range s.reqs {
+		d.ExpiresAt = time.Now().Add(-time.Second)
+	}
+}
+
+// rewindPolls makes the last poll of every request look older than the
+// poll interval.
+func (s *fakeDeviceStore) rewindPolls() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, d := range s.reqs {
+		if d.LastPolledAt != nil {
+			t := d.LastPolledAt.Add(-2 * deviceAuthInterval)
+			d.LastPolledAt = &t
+		}
+	}
+}
+
+// fakeOAuth checks the PKCE pair and returns a session named after the code.
+type fakeOAuth struct {
+	mu        sync.Mutex
+	challenge string
+}
+
+func (o *fakeOAuth) AuthorizeURL(provider, redirectTo, codeChallenge string) string {
+	o.mu.Lock()
+	o.challenge = codeChallenge
+	o.mu.Unlock()
+	return "https://oauth.example/authorize?redirect_to=" + url.QueryEscape(redirectTo)
+}
+
+func (o *fakeOAuth) ExchangePKCE(ctx context.Context, authCode, codeVerifier string) (supabase.TokenResponse, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if auth.PKCEChallengeS256(codeVerifier) != o.challenge {
+		return supabase.TokenResponse{}, errors.New("pkce mismatch")
+	}
+	return supabase.TokenResponse{AccessToken: "at-" + authCode, TokenType: "bearer"}, nil
+}
+
+// deviceClient drives the device flow against the full API, as the CLI and
+// a browser would.
+type deviceClient struct {
+	t     *testing.T
+	h     http.Handler
+	store *fakeDeviceStore
+	addr  string
+}
+
+func newDeviceClient(t *testing.T) *deviceClient {
+	t.Helper()
+	t.Setenv("SENTRA_LOOPBACK_ONLY", "1")
+	store := newFakeDeviceStore()
+	h := New(Deps{DeviceAuth: store, OAuth: &fakeOAuth{}, PublicURL: "http://127.0.0.1:8080", Limiter: ratelimit.NewMemoryLimiter()})
+	return &deviceClient{t: t, h: h, store: store, addr: "127.0.0.1:50000"}
+}
+
+func (c *deviceClient) do(method, target string, body string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.RemoteAddr = c.addr
+	if strings.HasPrefix(body, "{") {
+		req.Header.Set("Content-Type", "application/json")
+	} else if body != "" {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	for _, ck := range cookies {
+		req.AddCookie(ck)
+	}
+	rec := httptest.NewRecorder()
+	c.h.ServeHTTP(rec, req)
+	return rec
+}
+
+func (c *deviceClient) code() deviceCodeResponse {
+	c.t.Helper()
+	rec := c.do(http.MethodPost, "/v1/device/code", "")
+	if rec.Code != http.StatusOK {
+		c.t.Fatalf("device code: status %d", rec.Code)
+	}
+	var out deviceCodeResponse
+	if err := json.NewDecoder(rec.Body).Decode(&out); err != nil {
+		c.t.Fatalf("device code: %v", err)
+	}
+	return out
+}
+
+// poll returns the token endpoint's error code, or "" with the session.
+func (c *deviceClient) poll(deviceCode string) (string, string) {
+	c.t.Helper()
+	rec := c.do(http.MethodPost, "/v1/device/token", `{"device_code":"`+deviceCode+`"}`)
+	if rec.Code == http.StatusOK {
+		return "", rec.Body.String()
+	}
+	var e struct {
+		Error string `json:"error"`
+	}
+	_ = json.NewDecoder(rec.Body).Decode(&e)
+	return e.Error, ""
+}
+
+// csrf loads the verification page and returns its CSRF cookie.
+func (c *deviceClient) csrf() *http.Cookie {
+	c.t.Helper()
+	rec := c.do(http.MethodGet, "/device", "")
+	for _, ck := range rec.Result().Cookies() {
+		if ck.Name == deviceCSRFCookie {
+			return ck
+		}
+	}
+	c.t.Fatal("no CSRF cookie on the verification page")
+	return nil
+}
+
+// approve submits userCode with csrf and returns the response.
+func (c *deviceClient) approve(userCode string, formCSRF string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	form := url.Values{"user_code": {userCode}, "csrf": {formCSRF}}
+	if cookie == nil {
+		return c.do(http.MethodPost, "/device", form.Encode())
+	}
+	return c.do(http.MethodPost, "/device", form.Encode(), cookie)
+}
+
+func stateCookie(t *testing.T, rec *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+	for _, ck := range rec.Result().Cookies() {
+		if ck.Name == deviceStateCookie {
+			return ck
+		}
+	}
+	t.Fatalf("no state cookie (status %d)", rec.Code)
+	return nil
+}
+
+func TestDeviceFlow(t *testing.T) {
+	c := newDeviceClient(t)
+	dc := c.code()
+
+	if e, _ := c.poll(dc.DeviceCode); e != "authorization_pending" {
+		t.Fatalf("first poll = %q; want authorization_pending", e)
+	}
+	if e, _ := c.poll(dc.DeviceCode); e != "slow_down" {
+		t.Fatalf("immediate second poll = %q; want slow_down", e)
+	}
+	c.store.rewindPolls()
+	if e, _ := c.poll(dc.DeviceCode); e != "authorization_pending" {
+		t.Fatalf("poll after the interval = %q; want authorization_pending", e)
+	}
+
+	csrf := c.csrf()
+	rec := c.approve(dc.UserCode, csrf.Value, csrf)
+	if rec.Code != http.StatusSeeOther || !strings.HasPrefix(rec.Header().Get("Location"), "https://oauth.example/") {
+		t.Fatalf("approve: status %d, location %q", rec.Code, rec.Header().Get("Location"))
+	}
+	state := stateCookie(t, rec)
+
+	rec = c.do(http.MethodGet, "/device/callback?state="+url.QueryEscape(state.Value)+"&code=abc", "", state)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("callback: status %d", rec.Code)
+	}
+
+	e, session := c.poll(dc.DeviceCode)
+	if e != "" || !strings.Contains(session, `"access_token":"at-abc"`) {
+		t.Fatalf("poll after approval = %q, %q; want the session", e, session)
+	}
+	if e, _ := c.poll(dc.DeviceCode); e != "invalid_grant" {
+		t.Fatalf("poll after collection = %q; want invalid_grant", e)
+	}
+}
+
+func TestDeviceFlowExpiredCodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		purgeErr error
+		approved bool
+		want     string
+	}{
+		{name: "purged", want: "invalid_grant"},
+		{name: "approved but never collected is purged", approved: true, want: "invalid_grant"},
+		{name: "expired while the purge fails", purgeErr: errors.New("db down"), want: "expired_token"},
+		{name: "approved and expired while the purge fails", purgeErr: errors.New("db down"), approved: true, want: "expired_token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newDeviceClient(t)
+			dc := c.code()
+			if tt.approved {
+				d, _, _ := c.store.FindByUserCode(context.Background(), dc.UserCode)
+				_ = c.store.Approve(context.Background(), d.ID, json.RawMessage(`{"access_token":"stale"}`))
+			}
+			c.store.purgeErr = tt.purgeErr
+			c.store.expire()
+
+			if e, session := c.poll(dc.DeviceCode); e != tt.want {
+				t.Fatalf("poll = %q, %q; want %q", e, session, tt.want)
+			}
+		})
+	}
+
+	// An expired code cannot be approved either.
+	c := newDeviceClient(t)
+	dc := c.code()
+	c.store.purgeErr = errors.New("db down")
+	c.store.expire()
+	csrf := c.csrf()
+	if rec := c.approve(dc.UserCode, csrf.Value, csrf); rec.Code != http.StatusBadRequest {
+		t.Fatalf("approving an expired code: status %d; want 400", rec.Code)
+	}
+}
+
+func TestDeviceFlowCSRFAndState(t *testing.T) {
+	t.Run("CSRF form value mismatch", func(t *testing.T) {
+		c := newDeviceClient(t)
+		dc := c.code()
+		csrf := c.csrf()
+		if rec := c.approve(dc.UserCode, "forged", csrf); rec.Code != http.StatusForbidden {
+			t.Fatalf("status %d; want 403", rec.Code)
+		}
+	})
+	t.Run("CSRF cookie missing", func(t *testing.T) {
+		c := newDeviceClient(t)
+		dc := c.code()
+		csrf := c.csrf()
+		if rec := c.approve(dc.UserCode, csrf.Value, nil); rec.Code != http.StatusForbidden {
+			t.Fatalf("status %d; want 403", rec.Code)
+		}
+	})
+	t.Run("CSRF cookie of another page", func(t *testing.T) {
+		c := newDeviceClient(t)
+		dc := c.code()
+		first, second := c.csrf(), c.csrf()
+		if rec := c.approve(dc.UserCode, first.Value, second); rec.Code != http.StatusForbidden {
+			t.Fatalf("status %d; want 403", rec.Code)
+		}
+	})
+	t.Run("unknown user code", func(t *testing.T) {
+		c := newDeviceClient(t)
+		c.code()
+		csrf := c.csrf()
+		if rec := c.approve("BBBB-BBBB", csrf.Value, csrf); rec.Code != http.StatusBadRequest {
+			t.Fatalf("status %d; want 400", rec.Code)
+		}
+	})
+
+	stateTests := []struct {
+		name   string
+		query  func(state string) string
+		cookie func(state *http.Cookie) *http.Cookie
+	}{
+		{
+			name:   "state cookie mismatch",
+			query:  func(state string) string { return state },
+			cookie: func(*http.Cookie) *http.Cookie { return &http.Cookie{Name: deviceStateCookie, Value: "attacker"} },
+		},
+		{
+			name:   "state cookie missing",
+			query:  func(state string) string { return state },
+			cookie: func(*http.Cookie) *http.Cookie { return nil },
+		},
+		{
+			name:   "state query missing",
+			query:  func(string) string { return "" },
+			cookie: func(c *http.Cookie) *http.Cookie { return c },
+		},
+	}
+	for _, tt := range stateTests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newDeviceClient(t)
+			dc := c.code()
+			csrf := c.csrf()
+			state := stateCookie(t, c.approve(dc.UserCode, csrf.Value, csrf))
+
+			target := "/device/callback?code=abc&state=" + url.QueryEscape(tt.query(state.Value))
+			var rec *httptest.ResponseRecorder
+			if ck := tt.cookie(state); ck != nil {
+				rec = c.do(http.MethodGet, target, "", ck)
+			} else {
+				rec = c.do(http.MethodGet, target, "")
+			}
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("callback: status %d; want 400", rec.Code)
+			}
+			if e, _ := c.poll(dc.DeviceCode); e != "authorization_pending" {
+				t.Fatalf("poll after a refused callback = %q; want authorization_pending", e)
+			}
+		})
+	}
+
+	t.Run("cancelled login is denied", func(t *testing.T) {
+		c := newDeviceClient(t)
+		dc := c.code()
+		csrf := c.csrf()
+		state := stateCookie(t, c.approve(dc.UserCode, csrf.Value, csrf))
+		rec := c.do(http.MethodGet, "/device/callback?error=access_denied&state="+url.QueryEscape(state.Value), "", state)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("callback: status %d; want 400", rec.Code)
+		}
+		if e, _ := c.poll(dc.DeviceCode); e != "access_denied" {
+			t.Fatalf("poll = %q; want access_denied", e)
+		}
+	})
+}
+
+func TestDeviceFlowRequiresLoopback(t *testing.T) {
+	routes := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodPost, "/v1/device/code"},
+		{http.MethodPost, "/v1/device/token"},
+		{http.MethodGet, "/device"},
+		{http.MethodPost, "/device"},
+		{http.MethodGet, "/device/callback?state=x&code=y"},
+	}
+
+	c := newDeviceClient(t)
+	c.addr = "203.0.113.9:50000"
+	for _, r := range routes {
+		if rec := c.do(r.method, r.path, ""); rec.Code != http.StatusForbidden {
+			t.Errorf("%s %s from a remote address: status %d; want 403", r.method, r.path, rec.Code)
+		}
+	}
+
+	// SENTRA_LOOPBACK_ONLY=0 opens the routes to remote clients.
+	t.Setenv("SENTRA_LOOPBACK_ONLY", "0")
+	store := newFakeDeviceStore()
+	c = &deviceClient{t: t, h: New(Deps{DeviceAuth: store, OAuth: &fakeOAuth{}, Limiter: ratelimit.NewMemoryLimiter()}), store: store, addr: "203.0.113.9:50000"}
+	if dc := c.code(); dc.DeviceCode == "" {
+		t.Fatal("no device code with SENTRA_LOOPBACK_ONLY=0")
+	}
+}
@@ -4,58 +4,64 @@ import (
 	"bytes"
 	"context"
 	"io"
+	"log/slog"
 	"net/http"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/mgeovany/sentra/server/internal/auth"
+	"github.com/mgeovany/sentra/server/internal/metrics"
+	"github.com/mgeovany/sentra/server/internal/ratelimit"
 	"github.com/mgeovany/sentra/server/internal/repo"
 	"github.com/mgeovany/sentra/server/internal/validate"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type ctxKeySignedBody struct{}
 
 const maxPushBodyBytes = 12 << 20 // 12 MiB
 
-type nonceCache struct {
-	mu sync.Mutex
-	m  map[string]time.Time
-}
-
-func (c *nonceCache) seenOrMark(key string, ttl time.Duration) bool {
-	now := time.Now()
-	exp := now.Add(ttl)
-
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if c.m == nil {
-		c.m = make(map[string]time.Time)
-	}
-	// Lazy cleanup.
-	for k, v := range c.m {
-		if now.After(v) {
-			delete(c.m, k)
-		}
+// nonceTTL outlives the signature timestamp window, so a nonce cannot be
+// replayed after it is forgotten.
+const nonceTTL = 10 * time.Minute
+
+// defaultNonces serves routes whose Deps leave Nonces nil.
+var defaultNonces ratelimit.NonceStore = ratelimit.NewMemoryNonceStore()
+
+// replayed marks the nonce in key and reports a replay. Unlike rate
+// limiting, a failing store rejects the request: without it, replay
+// protection is off.
+func replayed(w http.ResponseWriter, r *http.Request, nonces ratelimit.NonceStore, key string) bool {
+	seen, err := nonces.SeenOrMark(r.Context(), key, nonceTTL)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "nonce store unavailable", "err", err)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = io.WriteString(w, "replay protection unavailable")
+		return true
 	}
-	if t, ok := c.m[key]; ok && now.Before(t) {
+	if seen {
+		metrics.NonceReplayed()
+		markSpan(r, attribute.Bool("sentra.nonce_replay", true))
+		slog.WarnContext(r.Context(), "signed request replayed", "path", r.URL.Path)
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = io.WriteString(w, "unauthorized")
 		return true
 	}
-	c.m[key] = exp
 	return false
 }
 
-var recentNonces nonceCache
-
-func requireDeviceSignature(store repo.MachineStore, next http.Handler) http.Handler {
+func requireDeviceSignature(store repo.MachineStore, nonces ratelimit.NonceStore, next http.Handler) http.Handler {
 	if store == nil {
 		store = repo.DisabledMachineStore{}
 	}
+	if nonces == nil {
+		nonces = defaultNonces
+	}
 	if next == nil {
 		next = http.NotFoundHandler()
 	}
 
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	return traced("requireDeviceSignature", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		r.Body = http.MaxBytesReader(w, r.Body, maxPushBodyBytes)
 
 		user, ok := auth.UserFromContext(r.Context())
@@ -119,14 +125,12 @@ func requireDeviceSignature(store repo.MachineStore, next http.Handler) http.Han
 
 		// Anti-replay: nonce must be unique for a short TTL.
 		key := strings.TrimSpace(user.ID) + "\n" + machineID + "\n" + nonce
-		if recentNonces.seenOrMark(key, 10*time.Minute) {
-			w.WriteHeader(http.StatusUnauthorized)
-			_, _ = io.WriteString(w, "unauthorized")
+		if replayed(w, r, nonces, key) {
 			return
 		}
 
 		r = r.WithContext(context.WithValue(r.Context(), ctxKeySignedBody{}, body))
 
 		next.ServeHTTP(w, r)
-	})
+	}))
 }
@@ -3,11 +3,12 @@ package httpapi
 import (
 	"encoding/json"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
 
 	"github.com/mgeovany/sentra/server/internal/auth"
+	"github.com/mgeovany/sentra/server/internal/metrics"
 	"github.com/mgeovany/sentra/server/internal/repo"
 )
 
@@ -34,11 +35,14 @@ func exportHandler(store repo.ExportStore) http.Handler {
 			_, _ = io.WriteString(w, "missing root")
 			return
 		}
+		if denyProject(w, r, user, root) {
+			return
+		}
 		at := strings.TrimSpace(r.URL.Query().Get("at"))
 
 		files, err := store.Export(r.Context(), user.ID, root, at)
 		if err != nil {
-			log.Printf("export failed user_id=%s root=%s err=%v", user.ID, root, err)
+			slog.ErrorContext(r.Context(), "export failed", "user_id", user.ID, "root", root, "err", err)
 			switch err {
 			case repo.ErrDBNotConfigured:
 				w.WriteHeader(http.StatusServiceUnavailable)
@@ -50,8 +54,16 @@ func exportHandler(store repo.ExportStore) http.Handler {
 			return
 		}
 
+		b, err := json.Marshal(files)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = io.WriteString(w, "export failed")
+			return
+		}
+		metrics.ObserveExport(len(b))
+
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 		w.WriteHeader(http.StatusOK)
-		_ = json.NewEncoder(w).Encode(files)
+		_, _ = w.Write(append(b, '\n'))
 	})
 }
@@ -3,7 +3,7 @@ package httpapi
 import (
 	"encoding/json"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
 
@@ -34,11 +34,14 @@ func filesHandler(store repo.FileStore) http.Handler {
 			_, _ = io.WriteString(w, "missing root")
 			return
 		}
+		if denyProject(w, r, user, root) {
+			return
+		}
 		at := strings.TrimSpace(r.URL.Query().Get("at"))
 
 		files, err := store.ListFiles(r.Context(), user.ID, root, at)
 		if err != nil {
-			log.Printf("files list failed user_id=%s root=%s err=%v", user.ID, root, err)
+			slog.ErrorContext(r.Context(), "files list failed", "user_id", user.ID, "root", root, "err", err)
 			switch err {
 			case repo.ErrDBNotConfigured:
 				w.WriteHeader(http.StatusServiceUnavailable)
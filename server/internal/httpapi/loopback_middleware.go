@@ -21,7 +21,7 @@ func requireLoopback(next http.Handler) http.Handler {
 		return next
 	}
 
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	return traced("requireLoopback", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		host, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
 		if err != nil {
 			w.WriteHeader(http.StatusForbidden)
@@ -33,5 +33,5 @@ func requireLoopback(next http.Handler) http.Handler {
 			return
 		}
 		next.ServeHTTP(w, r)
-	})
+	}))
 }
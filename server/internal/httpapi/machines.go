@@ -3,12 +3,12 @@ package httpapi
 import (
 	"encoding/json"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/mgeovany/sentra/server/internal/auth"
+	"github.com/mgeovany/sentra/server/internal/ratelimit"
 	"github.com/mgeovany/sentra/server/internal/repo"
 	"github.com/mgeovany/sentra/server/internal/validate"
 )
@@ -20,10 +20,13 @@ type registerMachineRequest struct {
 	DeviceKeyType string `json:"device_key_type"`
 }
 
-func registerMachineHandler(store repo.MachineStore) http.Handler {
+func registerMachineHandler(store repo.MachineStore, nonces ratelimit.NonceStore) http.Handler {
 	if store == nil {
 		store = repo.DisabledMachineStore{}
 	}
+	if nonces == nil {
+		nonces = defaultNonces
+	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -90,16 +93,14 @@ func registerMachineHandler(store repo.MachineStore) http.Handler {
 			return
 		}
 		key := strings.TrimSpace(user.ID) + "\n" + req.MachineID + "\n" + nonce
-		if recentNonces.seenOrMark(key, 10*time.Minute) {
-			w.WriteHeader(http.StatusUnauthorized)
-			_, _ = io.WriteString(w, "unauthorized")
+		if replayed(w, r, nonces, key) {
 			return
 		}
 
 		// If already registered, do not allow changing the stored device key.
 		existing, ok, err := store.DevicePubKey(r.Context(), user.ID, req.MachineID)
 		if err != nil {
-			log.Printf("machines/register device key lookup failed user_id=%q machine_id=%q err=%v", user.ID, req.MachineID, err)
+			slog.ErrorContext(r.Context(), "machine device key lookup failed", "user_id", user.ID, "machine_id", req.MachineID, "err", err)
 			switch err {
 			case repo.ErrDBNotConfigured:
 				w.WriteHeader(http.StatusServiceUnavailable)
@@ -122,7 +123,7 @@ func registerMachineHandler(store repo.MachineStore) http.Handler {
 		err = store.Register(r.Context(), user.ID, req.MachineID, req.MachineName, req.DevicePubKey)
 		if err != nil {
 			// Server-side logging for debugging/observability.
-			log.Printf("machines/register failed user_id=%q machine_id=%q machine_name=%q err=%v", user.ID, req.MachineID, req.MachineName, err)
+			slog.ErrorContext(r.Context(), "machine register failed", "user_id", user.ID, "machine_id", req.MachineID, "machine_name", req.MachineName, "err", err)
 			switch err {
 			case repo.ErrDBNotConfigured:
 				w.WriteHeader(http.StatusServiceUnavailable)
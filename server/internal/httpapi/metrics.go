@@ -0,0 +1,28 @@
+package httpapi
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/mgeovany/sentra/server/internal/metrics"
+)
+
+// metricsHandler serves /metrics. When token is set, scrapers must send it
+// as a bearer token; deployments that turn off the loopback restriction
+// should set one.
+func metricsHandler(token string) http.Handler {
+	h := metrics.Handler()
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,58 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/mgeovany/sentra/server/internal/auth"
+	"github.com/mgeovany/sentra/server/internal/repo"
+)
+
+// projectHeadsHandler serves GET /projects/heads: the newest commit of each
+// project, small enough for `sentra status --remote` to poll.
+func projectHeadsHandler(store repo.ProjectHeadStore) http.Handler {
+	if store == nil {
+		store = repo.DisabledProjectHeadStore{}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		user, ok := auth.UserFromContext(r.Context())
+		if !ok || strings.TrimSpace(user.ID) == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		heads, err := store.ListProjectHeads(r.Context(), user.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "project heads failed", "user_id", user.ID, "err", err)
+			switch err {
+			case repo.ErrDBNotConfigured:
+				w.WriteHeader(http.StatusServiceUnavailable)
+				_, _ = io.WriteString(w, "db not configured")
+			default:
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = io.WriteString(w, "project heads failed")
+			}
+			return
+		}
+
+		out := make([]repo.ProjectHead, 0, len(heads))
+		for _, h := range heads {
+			if user.CanAccessProject(h.RootPath) {
+				out = append(out, h)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(out)
+	})
+}
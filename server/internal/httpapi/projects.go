@@ -2,11 +2,14 @@ package httpapi
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
 
+	"github.com/google/uuid"
+	"github.com/mgeovany/sentra/server/internal/apispec"
 	"github.com/mgeovany/sentra/server/internal/auth"
 	"github.com/mgeovany/sentra/server/internal/repo"
 )
@@ -30,7 +33,7 @@ func projectsHandler(store repo.ProjectStore) http.Handler {
 
 		projects, err := store.ListProjects(r.Context(), user.ID)
 		if err != nil {
-			log.Printf("projects list failed user_id=%s err=%v", user.ID, err)
+			slog.ErrorContext(r.Context(), "projects list failed", "user_id", user.ID, "err", err)
 			switch err {
 			case repo.ErrDBNotConfigured:
 				w.WriteHeader(http.StatusServiceUnavailable)
@@ -42,8 +45,134 @@ func projectsHandler(store repo.ProjectStore) http.Handler {
 			return
 		}
 
+		if strings.TrimSpace(user.ProjectRoot) != "" {
+			scoped := make([]repo.ProjectInfo, 0, 1)
+			for _, p := range projects {
+				if user.CanAccessProject(p.RootPath) {
+					scoped = append(scoped, p)
+				}
+			}
+			projects = scoped
+		}
+
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 		w.WriteHeader(http.StatusOK)
 		_ = json.NewEncoder(w).Encode(projects)
 	})
 }
+
+// projectHandler serves /projects/<id>: PATCH renames, relabels or
+// (un)archives a project and DELETE removes it with its commits and files.
+// Hosted blobs only the deleted files used are removed here; BYOS objects
+// are returned for the client to delete, since only it holds the bucket
+// credentials.
+func projectHandler(store repo.ProjectStore, blobs repo.BlobStore) http.Handler {
+	if store == nil {
+		store = repo.DisabledProjectStore{}
+	}
+	if blobs == nil {
+		blobs = repo.DisabledBlobStore{}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := auth.UserFromContext(r.Context())
+		if !ok || strings.TrimSpace(user.ID) == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, apispec.Prefix), "/projects/")
+		if _, err := uuid.Parse(id); err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPatch:
+			r.Body = http.MaxBytesReader(w, r.Body, 16<<10) // 16 KiB
+			var patch repo.ProjectPatch
+			if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if patch.Root != nil {
+				root := strings.TrimSpace(*patch.Root)
+				if root == "" || len(root) > 300 {
+					w.WriteHeader(http.StatusBadRequest)
+					_, _ = io.WriteString(w, "invalid project root")
+					return
+				}
+				patch.Root = &root
+			}
+			if patch.DisplayName != nil {
+				name := strings.TrimSpace(*patch.DisplayName)
+				if len(name) > 100 {
+					w.WriteHeader(http.StatusBadRequest)
+					_, _ = io.WriteString(w, "invalid display name")
+					return
+				}
+				patch.DisplayName = &name
+			}
+			if patch.Root == nil && patch.DisplayName == nil && patch.Archived == nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = io.WriteString(w, "nothing to change")
+				return
+			}
+
+			project, err := store.UpdateProject(r.Context(), user.ID, id, patch)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "project update failed", "user_id", user.ID, "project_id", id, "err", err)
+				writeProjectStoreError(w, err, "project update failed")
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+			_ = json.NewEncoder(w).Encode(project)
+
+		case http.MethodDelete:
+			deleted, err := store.DeleteProject(r.Context(), user.ID, id)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "project delete failed", "user_id", user.ID, "project_id", id, "err", err)
+				writeProjectStoreError(w, err, "project delete failed")
+				return
+			}
+
+			byos := make([]repo.StorageObject, 0, len(deleted.Storage))
+			for _, o := range deleted.Storage {
+				if o.Provider != "sentra" {
+					byos = append(byos, o)
+					continue
+				}
+				root, blobID, _ := strings.Cut(o.Key, "/")
+				if err := blobs.DeleteBlob(r.Context(), user.ID, root, blobID); err != nil {
+					// The rows are gone already; an orphaned blob only costs storage.
+					slog.WarnContext(r.Context(), "hosted blob delete failed", "user_id", user.ID, "project_id", id, "err", err)
+				}
+			}
+			deleted.Storage = byos
+
+			slog.InfoContext(r.Context(), "project deleted", "user_id", user.ID, "project_id", id, "commits", deleted.Commits, "files", deleted.Files)
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+			_ = json.NewEncoder(w).Encode(deleted)
+
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func writeProjectStoreError(w http.ResponseWriter, err error, publicMsg string) {
+	switch {
+	case errors.Is(err, repo.ErrProjectNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, "project not found")
+	case errors.Is(err, repo.ErrProjectExists):
+		w.WriteHeader(http.StatusConflict)
+		_, _ = io.WriteString(w, "a project with that root already exists")
+	case errors.Is(err, repo.ErrDBNotConfigured):
+		writeHTTPError(w, http.StatusServiceUnavailable, "db not configured", err)
+	default:
+		writeHTTPError(w, http.StatusInternalServerError, publicMsg, err)
+	}
+}
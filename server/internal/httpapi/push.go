@@ -4,17 +4,21 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
-	"log"
+	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/mgeovany/sentra/server/internal/auth"
+	"github.com/mgeovany/sentra/server/internal/metrics"
 	"github.com/mgeovany/sentra/server/internal/repo"
 	"github.com/mgeovany/sentra/server/internal/validate"
+	"github.com/mgeovany/sentra/server/internal/webhooks"
 )
 
-func pushHandler(store repo.PushStore, idem repo.IdempotencyStore) http.Handler {
+func pushHandler(store repo.PushStore, idem repo.IdempotencyStore, hooks *webhooks.Dispatcher) http.Handler {
 	if store == nil {
 		store = repo.DisabledPushStore{}
 	}
@@ -40,11 +44,18 @@ func pushHandler(store repo.PushStore, idem repo.IdempotencyStore) http.Handler
 			return
 		}
 
-		if err := validateJSONAgainstPushSchema(body); err != nil {
+		version, err := validateJSONAgainstPushSchema(body)
+		if err != nil {
+			var unsupported *unsupportedPushVersionError
+			if errors.As(err, &unsupported) {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = io.WriteString(w, unsupported.Error())
+				return
+			}
 			// Keep response minimal, but log the reason for debugging.
 			// Never log secrets: payload is expected to be encrypted blobs.
 			// (Still avoid printing the full body.)
-			log.Printf("push payload rejected err=%q", err.Error())
+			slog.WarnContext(r.Context(), "push payload rejected", "err", err)
 			w.WriteHeader(http.StatusBadRequest)
 			_, _ = io.WriteString(w, "invalid push payload")
 			return
@@ -57,6 +68,30 @@ func pushHandler(store repo.PushStore, idem repo.IdempotencyStore) http.Handler
 			return
 		}
 
+		if !hostedBlobsMatchRoot(body) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, "hosted blobs must belong to the pushed project root")
+			return
+		}
+
+		if strings.TrimSpace(user.ProjectRoot) != "" {
+			var scope struct {
+				Project struct {
+					Root string `json:"root"`
+				} `json:"project"`
+			}
+			_ = json.Unmarshal(body, &scope)
+			root := strings.TrimSpace(scope.Project.Root)
+			if root == "" {
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = io.WriteString(w, "project-scoped tokens must push by project root")
+				return
+			}
+			if denyProject(w, r, user, root) {
+				return
+			}
+		}
+
 		idemKey := strings.TrimSpace(r.Header.Get("X-Idempotency-Key"))
 		const idemScope = "push"
 		if idemKey != "" {
@@ -71,7 +106,7 @@ func pushHandler(store repo.PushStore, idem repo.IdempotencyStore) http.Handler
 				// Fail open if idempotency storage is not configured or misbehaving.
 				// Idempotency is an optimization; the push RPC must still be safe to retry.
 				if !errors.Is(err, repo.ErrDBNotConfigured) {
-					log.Printf("idempotency create failed user_id=%q key=%q err=%q", user.ID, idemKey, err.Error())
+					slog.ErrorContext(r.Context(), "idempotency create failed", "user_id", user.ID, "idempotency_key", idemKey, "err", err)
 				}
 				created = true
 			}
@@ -79,7 +114,7 @@ func pushHandler(store repo.PushStore, idem repo.IdempotencyStore) http.Handler
 				rec, found, getErr := idem.Get(r.Context(), user.ID, idemScope, idemKey)
 				if getErr != nil {
 					// Fail open if storage is broken.
-					log.Printf("idempotency get failed user_id=%q key=%q err=%q", user.ID, idemKey, getErr.Error())
+					slog.ErrorContext(r.Context(), "idempotency get failed", "user_id", user.ID, "idempotency_key", idemKey, "err", getErr)
 					created = true
 				}
 				if created {
@@ -107,12 +142,15 @@ func pushHandler(store repo.PushStore, idem repo.IdempotencyStore) http.Handler
 
 		res, err := store.Push(r.Context(), user.ID, payload)
 		if err != nil {
-			log.Printf("push store failed user_id=%q err=%q", user.ID, err.Error())
+			slog.ErrorContext(r.Context(), "push store failed", "user_id", user.ID, "push_version", version, "err", err)
 			if idemKey != "" {
 				_ = idem.Delete(r.Context(), user.ID, idemScope, idemKey)
 			}
-			switch err {
-			case repo.ErrDBNotConfigured:
+			var quotaErr *repo.QuotaError
+			switch {
+			case errors.As(err, &quotaErr):
+				writeQuotaExceeded(w, quotaErr)
+			case err == repo.ErrDBNotConfigured:
 				writeHTTPError(w, http.StatusServiceUnavailable, "db not configured", err)
 			default:
 				writeHTTPError(w, http.StatusInternalServerError, "push failed", err)
@@ -123,9 +161,64 @@ func pushHandler(store repo.PushStore, idem repo.IdempotencyStore) http.Handler
 		if idemKey != "" {
 			_ = idem.SetDone(r.Context(), user.ID, idemScope, idemKey, res)
 		}
+		var counted struct {
+			Files []json.RawMessage `json:"files"`
+		}
+		_ = json.Unmarshal(body, &counted)
+		metrics.ObservePush(len(body), len(counted.Files))
+		// A deduped push stored nothing new, so there is nothing to announce.
+		if !res.Deduped {
+			hooks.Notify(user.ID, webhooks.PushEvent(res, body))
+		}
 
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 		w.WriteHeader(http.StatusOK)
 		_ = json.NewEncoder(w).Encode(res)
 	})
 }
+
+// writeQuotaExceeded answers 429 with Retry-After set to the next UTC
+// midnight for the daily push quota, and 507 for the storage quota, which
+// only frees up when files are deleted.
+func writeQuotaExceeded(w http.ResponseWriter, err *repo.QuotaError) {
+	status := http.StatusInsufficientStorage
+	if err.Kind == repo.QuotaDailyPush {
+		now := time.Now().UTC()
+		midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(midnight.Sub(now).Seconds()))))
+		status = http.StatusTooManyRequests
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = io.WriteString(w, err.Error())
+}
+
+// hostedBlobsMatchRoot checks that every hosted blob ("sentra" storage, key
+// "<root>/<id>") was uploaded under the project being pushed, so reads can be
+// authorized by project. Hosted blobs therefore require a push by root.
+func hostedBlobsMatchRoot(body []byte) bool {
+	var p struct {
+		Project struct {
+			Root string `json:"root"`
+		} `json:"project"`
+		Files []struct {
+			Storage *struct {
+				Provider string `json:"provider"`
+				Key      string `json:"key"`
+			} `json:"storage"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal(body, &p); err != nil {
+		return false
+	}
+	root := strings.TrimSpace(p.Project.Root)
+	for _, f := range p.Files {
+		if f.Storage == nil || f.Storage.Provider != "sentra" {
+			continue
+		}
+		if blobRoot, _, _ := strings.Cut(f.Storage.Key, "/"); root == "" || blobRoot != root {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,137 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mgeovany/sentra/server/internal/repo"
+	"github.com/mgeovany/sentra/server/internal/webhooks"
+)
+
+// fakePushStore accepts every push and counts them.
+type fakePushStore struct {
+	mu     sync.Mutex
+	pushes int
+}
+
+func (s *fakePushStore) Push(ctx context.Context, userID string, payload any) (repo.PushResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pushes++
+	return repo.PushResult{}, nil
+}
+
+// fakeUsage is a UsageStore with fixed usage that records additions.
+type fakeUsage struct {
+	mu    sync.Mutex
+	usage repo.Usage
+	added int64
+}
+
+func (u *fakeUsage) Usage(ctx context.Context, userID string) (repo.Usage, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.usage, nil
+}
+
+func (u *fakeUsage) AddPushedBytes(ctx context.Context, userID string, n int64) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.added += n
+	u.usage.PushedBytesToday += n
+	return nil
+}
+
+// pushFile is one file of a v2 push body.
+type pushFile struct {
+	provider string
+	size     int64
+}
+
+// pushBody builds a valid v2 push of files to root.
+func pushBody(root string, files ...pushFile) string {
+	var out []map[string]any
+	for i, f := range files {
+		id := fmt.Sprintf("%064x", i+1)
+		storage := map[string]any{"provider": f.provider, "key": root + "/" + id}
+		if f.provider != "sentra" {
+			storage["bucket"] = "bucket"
+		}
+		out = append(out, map[string]any{
+			"path":      fmt.Sprintf("app%d/.env", i),
+			"sha256":    id,
+			"size":      f.size,
+			"encrypted": true,
+			"cipher":    "sentra-v3",
+			"storage":   storage,
+		})
+	}
+	b, _ := json.Marshal(map[string]any{
+		"v":       2,
+		"project": map[string]any{"root": root},
+		"machine": map[string]any{"id": "6f1c2b1e-6d1f-4a53-9a57-1b1b2f4b9a10"},
+		"commit":  map[string]any{"client_id": "0b6f3c1e-51a8-4c6b-8a61-3e0c35f3c2a4", "message": "update"},
+		"files":   out,
+	})
+	return string(b)
+}
+
+func TestPushQuotas(t *testing.T) {
+	tests := []struct {
+		name       string
+		quota      repo.Quota
+		usage      repo.Usage
+		files      []pushFile
+		status     int
+		retryAfter bool
+		added      int64
+	}{
+		{name: "under quota", quota: repo.Quota{DailyPushBytes: 100, StorageBytes: 100}, usage: repo.Usage{StorageBytes: 10, PushedBytesToday: 10}, files: []pushFile{{"s3", 40}}, status: http.StatusOK, added: 40},
+		{name: "unlimited", files: []pushFile{{"s3", 1 << 20}}, status: http.StatusOK, added: 1 << 20},
+		{name: "daily quota", quota: repo.Quota{DailyPushBytes: 100}, usage: repo.Usage{PushedBytesToday: 90}, files: []pushFile{{"s3", 20}}, status: http.StatusTooManyRequests, retryAfter: true},
+		{name: "storage quota", quota: repo.Quota{StorageBytes: 100}, usage: repo.Usage{StorageBytes: 90}, files: []pushFile{{"s3", 20}}, status: http.StatusInsufficientStorage},
+		{name: "hosted files were counted at upload", quota: repo.Quota{DailyPushBytes: 100}, usage: repo.Usage{PushedBytesToday: 90}, files: []pushFile{{"sentra", 50}, {"s3", 5}}, status: http.StatusOK, added: 5},
+		{name: "hosted files count against storage", quota: repo.Quota{StorageBytes: 100}, usage: repo.Usage{StorageBytes: 90}, files: []pushFile{{"sentra", 20}}, status: http.StatusInsufficientStorage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pushes := &fakePushStore{}
+			usage := &fakeUsage{usage: tt.usage}
+			store := repo.NewQuotaPushStore(pushes, usage, func(string) repo.Quota { return tt.quota })
+			h := authed(fakeVerifier{"tok": {ID: "user-1"}}, pushHandler(store, nil, webhooks.NewDispatcher(nil, false)))
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/push", strings.NewReader(pushBody("app", tt.files...)))
+			req.Header.Set("Authorization", "Bearer tok")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d; want %d (body %q)", rec.Code, tt.status, rec.Body.String())
+			}
+			if ra := rec.Header().Get("Retry-After"); tt.retryAfter {
+				if n, err := strconv.Atoi(ra); err != nil || n < 1 || n > 86400 {
+					t.Fatalf("Retry-After = %q; want seconds until UTC midnight", ra)
+				}
+			} else if ra != "" {
+				t.Fatalf("Retry-After = %q; want none", ra)
+			}
+			wantPushes := 0
+			if tt.status == http.StatusOK {
+				wantPushes = 1
+			}
+			if pushes.pushes != wantPushes {
+				t.Fatalf("store saw %d pushes; want %d", pushes.pushes, wantPushes)
+			}
+			if usage.added != tt.added {
+				t.Fatalf("recorded %d pushed bytes; want %d", usage.added, tt.added)
+			}
+		})
+	}
+}
@@ -1,140 +1,89 @@
 package httpapi
 
 import (
-	"math"
+	"log/slog"
+	"net"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/mgeovany/sentra/server/internal/auth"
+	"github.com/mgeovany/sentra/server/internal/limits"
+	"github.com/mgeovany/sentra/server/internal/metrics"
+	"github.com/mgeovany/sentra/server/internal/ratelimit"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-type tokenBucket struct {
-	tokens float64
-	last   time.Time
-}
-
-type tokenBuckets struct {
-	mu sync.Mutex
-	m  map[string]tokenBucket
-}
-
-func (b *tokenBuckets) take(key string, now time.Time, ratePerSec, burst float64) (ok bool, retryAfter time.Duration) {
-	b.mu.Lock()
-	defer b.mu.Unlock()
-	if b.m == nil {
-		b.m = make(map[string]tokenBucket)
-	}
+// defaultLimiter serves routes whose Deps leave Limiter nil.
+var defaultLimiter ratelimit.Limiter = ratelimit.NewMemoryLimiter()
 
-	// Lazy cleanup to keep memory bounded.
-	for k, v := range b.m {
-		if now.Sub(v.last) > 30*time.Minute {
-			delete(b.m, k)
-		}
-	}
-
-	v := b.m[key]
-	if v.last.IsZero() {
-		v.tokens = burst
-		v.last = now
+// takeToken spends one token for key. A limiter that fails lets the request
+// through: a store outage should not take the API down with it.
+func takeToken(r *http.Request, limiter ratelimit.Limiter, key string, limit ratelimit.Limit) (ratelimit.Decision, bool) {
+	d, err := limiter.Take(r.Context(), key, limit)
+	if err != nil {
+		slog.WarnContext(r.Context(), "rate limiter unavailable", "err", err)
+		return ratelimit.Decision{Allowed: true}, false
 	}
+	return d, true
+}
 
-	// Refill.
-	if now.After(v.last) {
-		v.tokens = math.Min(burst, v.tokens+now.Sub(v.last).Seconds()*ratePerSec)
-		v.last = now
+// requireRateLimit applies the limit configured for route: per user once
+// authenticated, per client IP otherwise. Every limited response carries
+// X-RateLimit-Limit (the burst), X-RateLimit-Remaining and X-RateLimit-Reset
+// (seconds until the bucket is full), which the CLI uses to pace itself.
+func requireRateLimit(cfg *limits.Config, limiter ratelimit.Limiter, route string, next http.Handler) http.Handler {
+	if cfg == nil {
+		cfg = limits.Default()
 	}
-
-	if v.tokens >= 1 {
-		v.tokens -= 1
-		b.m[key] = v
-		return true, 0
+	if limiter == nil {
+		limiter = defaultLimiter
 	}
-
-	missing := 1 - v.tokens
-	seconds := missing / ratePerSec
-	retryAfter = time.Duration(math.Ceil(seconds)) * time.Second
-	// No token consumed, but update last so idle cleanup works.
-	b.m[key] = v
-	return false, retryAfter
-}
-
-var pushBuckets tokenBuckets
-
-var machineRegisterBuckets tokenBuckets
-
-func requireMachineRegisterRateLimit(next http.Handler) http.Handler {
 	if next == nil {
 		next = http.NotFoundHandler()
 	}
 
-	// Registration should be rare; keep tight to reduce abuse.
-	rpm := int64(30)
-	burst := float64(10)
-	ratePerSec := float64(rpm) / 60.0
-
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		user, ok := auth.UserFromContext(r.Context())
-		if !ok || strings.TrimSpace(user.ID) == "" {
-			w.WriteHeader(http.StatusUnauthorized)
-			return
+	return traced("requireRateLimit", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject := ""
+		if user, ok := auth.UserFromContext(r.Context()); ok {
+			subject = strings.TrimSpace(user.ID)
 		}
-
-		allowed, retryAfter := machineRegisterBuckets.take(user.ID, time.Now().UTC(), ratePerSec, burst)
-		if !allowed {
-			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
-			w.WriteHeader(http.StatusTooManyRequests)
-			_, _ = w.Write([]byte("rate limit exceeded; retry later"))
+		limit, limited := cfg.Route(route, subject)
+		if !limited {
+			next.ServeHTTP(w, r)
 			return
 		}
 
-		next.ServeHTTP(w, r)
-	})
-}
-
-func requirePushRateLimit(next http.Handler) http.Handler {
-	if next == nil {
-		next = http.NotFoundHandler()
-	}
-
-	// Default is tuned for local (loopback-only) pushes.
-	// A single "sentra push" can fan out into many per-project requests.
-	rpm := int64(300)
-	if v := strings.TrimSpace(os.Getenv("SENTRA_PUSH_RPM")); v != "" {
-		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
-			rpm = n
+		key := subject
+		if key == "" {
+			key = clientIP(r)
 		}
-	}
-
-	burst := float64(60)
-	if v := strings.TrimSpace(os.Getenv("SENTRA_PUSH_BURST")); v != "" {
-		if n, err := strconv.ParseFloat(v, 64); err == nil && n > 0 {
-			burst = n
+		d, ok := takeToken(r, limiter, route+":"+key, limit)
+		if ok {
+			h := w.Header()
+			h.Set("X-RateLimit-Limit", strconv.Itoa(int(limit.Burst)))
+			h.Set("X-RateLimit-Remaining", strconv.Itoa(d.Remaining))
+			h.Set("X-RateLimit-Reset", strconv.Itoa(int(d.Reset/time.Second)))
 		}
-	}
-
-	ratePerSec := float64(rpm) / 60.0
-
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		user, ok := auth.UserFromContext(r.Context())
-		if !ok || strings.TrimSpace(user.ID) == "" {
-			w.WriteHeader(http.StatusUnauthorized)
-			return
-		}
-
-		allowed, retryAfter := pushBuckets.take(user.ID, time.Now().UTC(), ratePerSec, burst)
-		if !allowed {
+		if !d.Allowed {
+			metrics.RateLimited(route)
+			markSpan(r, attribute.Bool("sentra.rate_limited", true))
 			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+			w.Header().Set("Retry-After", strconv.Itoa(int(d.RetryAfter/time.Second)))
 			w.WriteHeader(http.StatusTooManyRequests)
 			_, _ = w.Write([]byte("rate limit exceeded; retry later"))
 			return
 		}
 
 		next.ServeHTTP(w, r)
-	})
+	}))
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
+	if err != nil || host == "" {
+		host = strings.TrimSpace(r.RemoteAddr)
+	}
+	return host
 }
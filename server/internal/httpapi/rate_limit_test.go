@@ -0,0 +1,105 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mgeovany/sentra/server/internal/auth"
+	"github.com/mgeovany/sentra/server/internal/limits"
+	"github.com/mgeovany/sentra/server/internal/ratelimit"
+)
+
+// fakeVerifier accepts the tokens it maps to users.
+type fakeVerifier map[string]auth.User
+
+func (v fakeVerifier) Verify(token string) (auth.User, error) {
+	u, ok := v[token]
+	if !ok {
+		return auth.User{}, errors.New("unknown token")
+	}
+	return u, nil
+}
+
+// authed puts the user of the request's bearer token in its context.
+func authed(users fakeVerifier, h http.Handler) http.Handler {
+	return auth.NewMiddleware(users).Require(h)
+}
+
+func TestRequireRateLimit(t *testing.T) {
+	cfg := limits.Default()
+	cfg.Routes[limits.RouteProjects] = limits.RouteLimit{RPM: 60, Burst: 2}
+	cfg.Users = map[string]limits.UserOverride{
+		"vip": {Routes: map[string]limits.RouteLimit{limits.RouteProjects: {RPM: 60, Burst: 3}}},
+	}
+	users := fakeVerifier{"tok-a": {ID: "user-a"}, "tok-b": {ID: "user-b"}, "tok-vip": {ID: "vip"}}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	h := authed(users, requireRateLimit(cfg, ratelimit.NewMemoryLimiter(), limits.RouteProjects, ok))
+
+	steps := []struct {
+		name       string
+		token      string
+		status     int
+		limit      string
+		remaining  string
+		reset      string
+		retryAfter string
+	}{
+		{name: "first", token: "tok-a", status: http.StatusOK, limit: "2", remaining: "1", reset: "1"},
+		{name: "burst", token: "tok-a", status: http.StatusOK, limit: "2", remaining: "0", reset: "2"},
+		{name: "limited", token: "tok-a", status: http.StatusTooManyRequests, limit: "2", remaining: "0", reset: "2", retryAfter: "1"},
+		{name: "other user has its own bucket", token: "tok-b", status: http.StatusOK, limit: "2", remaining: "1", reset: "1"},
+		{name: "override first", token: "tok-vip", status: http.StatusOK, limit: "3", remaining: "2", reset: "1"},
+		{name: "override burst", token: "tok-vip", status: http.StatusOK, limit: "3", remaining: "1", reset: "2"},
+		{name: "override still allows", token: "tok-vip", status: http.StatusOK, limit: "3", remaining: "0", reset: "3"},
+		{name: "override limited", token: "tok-vip", status: http.StatusTooManyRequests, limit: "3", remaining: "0", reset: "3", retryAfter: "1"},
+	}
+	for _, s := range steps {
+		req := httptest.NewRequest(http.MethodGet, "/v1/projects", nil)
+		req.Header.Set("Authorization", "Bearer "+s.token)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		got := rec.Result()
+		if got.StatusCode != s.status {
+			t.Fatalf("%s: status = %d; want %d", s.name, got.StatusCode, s.status)
+		}
+		headers := []struct{ name, want string }{
+			{"X-RateLimit-Limit", s.limit},
+			{"X-RateLimit-Remaining", s.remaining},
+			{"X-RateLimit-Reset", s.reset},
+			{"Retry-After", s.retryAfter},
+		}
+		for _, hdr := range headers {
+			if v := got.Header.Get(hdr.name); v != hdr.want {
+				t.Fatalf("%s: %s = %q; want %q", s.name, hdr.name, v, hdr.want)
+			}
+		}
+	}
+}
+
+func TestRequireRateLimitByClientIP(t *testing.T) {
+	cfg := limits.Default()
+	cfg.Routes[limits.RouteDeviceCode] = limits.RouteLimit{RPM: 60, Burst: 1}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	h := requireRateLimit(cfg, ratelimit.NewMemoryLimiter(), limits.RouteDeviceCode, ok)
+
+	steps := []struct {
+		addr   string
+		status int
+	}{
+		{addr: "198.51.100.7:5000", status: http.StatusOK},
+		{addr: "198.51.100.7:5001", status: http.StatusTooManyRequests},
+		{addr: "198.51.100.8:5000", status: http.StatusOK},
+	}
+	for _, s := range steps {
+		req := httptest.NewRequest(http.MethodPost, "/v1/auth/device/code", nil)
+		req.RemoteAddr = s.addr
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != s.status {
+			t.Fatalf("%s: status = %d; want %d", s.addr, rec.Code, s.status)
+		}
+	}
+}
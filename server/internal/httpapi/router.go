@@ -4,9 +4,13 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/mgeovany/sentra/server/internal/apispec"
 	"github.com/mgeovany/sentra/server/internal/auth"
 	"github.com/mgeovany/sentra/server/internal/health"
+	"github.com/mgeovany/sentra/server/internal/limits"
+	"github.com/mgeovany/sentra/server/internal/ratelimit"
 	"github.com/mgeovany/sentra/server/internal/repo"
+	"github.com/mgeovany/sentra/server/internal/webhooks"
 )
 
 type Deps struct {
@@ -15,30 +19,100 @@ type Deps struct {
 	Vault    repo.VaultKeyStore
 	Idem     repo.IdempotencyStore
 	Projects repo.ProjectStore
+	Heads    repo.ProjectHeadStore
 	Commits  repo.CommitStore
 	Files    repo.FileStore
 	Export   repo.ExportStore
 	Push     repo.PushStore
+	Tokens   repo.ServiceTokenStore
+	Refs     repo.StorageRefStore
+	Relocate repo.FileLocationStore
+	Blobs    repo.BlobStore
+	Webhooks repo.WebhookStore
+
+	// Hooks delivers push events to Webhooks. When nil, pushes notify
+	// nobody, while /webhooks/test still reaches public https URLs.
+	Hooks *webhooks.Dispatcher
+
+	// Limits sets per-route rate limits; nil uses limits.Default. Limiter
+	// and Nonces default to this process's memory when nil. Share them
+	// (Postgres or Redis) when running more than one instance.
+	Limits  *limits.Config
+	Limiter ratelimit.Limiter
+	Nonces  ratelimit.NonceStore
+
+	// Device login (sentra login --device). OAuth is nil when Supabase is not configured.
+	DeviceAuth repo.DeviceAuthStore
+	OAuth      OAuthProvider
+	PublicURL  string
+
+	// Ready lists the components /readyz probes.
+	Ready []health.Check
+	// MetricsToken, when set, is required as a bearer token on /metrics.
+	MetricsToken string
 }
 
 func New(deps Deps) http.Handler {
 	mux := http.NewServeMux()
 
-	health.Register(mux)
+	hooks := deps.Hooks
+	if hooks == nil {
+		hooks = webhooks.NewDispatcher(deps.Webhooks, false)
+	}
 
-	mux.Handle("/users/me", deps.Auth.Require(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	health.Register(mux, deps.Ready...)
+	mux.Handle("/metrics", requireLoopback(metricsHandler(deps.MetricsToken)))
+
+	// limit applies the configured rate limit for a route (see limits.Config).
+	limit := func(route string, next http.Handler) http.Handler {
+		return requireRateLimit(deps.Limits, deps.Limiter, route, next)
+	}
+
+	// api serves an apispec route under apispec.Prefix and, for CLIs
+	// released before the prefix, at its bare path.
+	served := map[string]bool{}
+	api := func(pattern string, h http.Handler) {
+		mux.Handle(apispec.Prefix+pattern, h)
+		mux.Handle(pattern, h)
+		served[pattern] = true
+	}
+
+	api("/users/me", deps.Auth.Require(limit(limits.RouteUsersMe, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user, _ := auth.UserFromContext(r.Context())
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 		_ = json.NewEncoder(w).Encode(user)
-	})))
+	}))))
+
+	api("/projects", requireLoopback(deps.Auth.Require(limit(limits.RouteProjects, traced("projectsHandler", projectsHandler(deps.Projects))))))
+	// Renaming or deleting a project changes the whole account's history, so service tokens are refused.
+	api("/projects/", requireLoopback(deps.Auth.Require(requireUserSession(limit(limits.RouteProject, traced("projectHandler", projectHandler(deps.Projects, deps.Blobs)))))))
+	api("/projects/heads", requireLoopback(deps.Auth.Require(limit(limits.RouteProjectHeads, traced("projectHeadsHandler", projectHeadsHandler(deps.Heads))))))
+	api("/commits", requireLoopback(deps.Auth.Require(limit(limits.RouteCommits, traced("commitsHandler", commitsHandler(deps.Commits))))))
+	api("/files", requireLoopback(deps.Auth.Require(limit(limits.RouteFiles, traced("filesHandler", filesHandler(deps.Files))))))
+	api("/export", requireLoopback(deps.Auth.Require(limit(limits.RouteExport, traced("exportHandler", exportHandler(deps.Export))))))
+	api("/machines/register", requireLoopback(deps.Auth.Require(requireWriteAccess(limit(limits.RouteMachineRegister, traced("registerMachineHandler", registerMachineHandler(deps.Machines, deps.Nonces)))))))
+	api("/vault/key", requireLoopback(deps.Auth.Require(limit(limits.RouteVaultKey, traced("vaultKeyHandler", vaultKeyHandler(deps.Vault))))))
+	// Blobs are AEAD ciphertexts that only become reachable through a signed
+	// push, so uploads skip the device signature (which buffers the body).
+	api("/blobs/", requireLoopback(deps.Auth.Require(limit(limits.RouteBlobs, traced("blobsHandler", blobsHandler(deps.Blobs))))))
+	api("/push", requireLoopback(deps.Auth.Require(requireWriteAccess(limit(limits.RoutePush, requireDeviceSignature(deps.Machines, deps.Nonces, traced("pushHandler", pushHandler(deps.Push, deps.Idem, deps.Hooks))))))))
+	api("/device/code", requireLoopback(limit(limits.RouteDeviceCode, traced("deviceCodeHandler", deviceCodeHandler(deps.DeviceAuth, deps.OAuth, deps.PublicURL)))))
+	api("/device/token", requireLoopback(limit(limits.RouteDeviceToken, traced("deviceTokenHandler", deviceTokenHandler(deps.DeviceAuth)))))
+	// The verification page and the OAuth callback are opened by browsers
+	// from URLs the server hands out, so they stay unversioned.
+	mux.Handle("/device", requireLoopback(limit(limits.RouteDeviceCode, traced("deviceVerifyHandler", deviceVerifyHandler(deps.DeviceAuth, deps.OAuth, deps.PublicURL)))))
+	mux.Handle("/device/callback", requireLoopback(limit(limits.RouteDeviceCallback, traced("deviceCallbackHandler", deviceCallbackHandler(deps.DeviceAuth, deps.OAuth)))))
+	// Storage GC sees every key in the account, so service tokens are refused.
+	api("/storage/refs", requireLoopback(deps.Auth.Require(requireUserSession(limit(limits.RouteStorageRefs, traced("storageRefsHandler", storageRefsHandler(deps.Refs)))))))
+	api("/storage/migrate", requireLoopback(deps.Auth.Require(requireUserSession(limit(limits.RouteStorageMigrate, traced("storageMigrateHandler", storageMigrateHandler(deps.Relocate)))))))
+	api("/tokens", requireLoopback(deps.Auth.Require(requireUserSession(limit(limits.RouteTokens, traced("serviceTokensHandler", serviceTokensHandler(deps.Tokens)))))))
+	// Webhook secrets are shown at creation, so service tokens are refused.
+	api("/webhooks", requireLoopback(deps.Auth.Require(requireUserSession(limit(limits.RouteWebhooks, traced("webhooksHandler", webhooksHandler(deps.Webhooks, hooks)))))))
+	api("/webhooks/test", requireLoopback(deps.Auth.Require(requireUserSession(limit(limits.RouteWebhooks, traced("webhookTestHandler", webhookTestHandler(deps.Webhooks, hooks)))))))
 
-	mux.Handle("/projects", requireLoopback(deps.Auth.Require(projectsHandler(deps.Projects))))
-	mux.Handle("/commits", requireLoopback(deps.Auth.Require(commitsHandler(deps.Commits))))
-	mux.Handle("/files", requireLoopback(deps.Auth.Require(filesHandler(deps.Files))))
-	mux.Handle("/export", requireLoopback(deps.Auth.Require(exportHandler(deps.Export))))
-	mux.Handle("/machines/register", requireLoopback(deps.Auth.Require(requireMachineRegisterRateLimit(registerMachineHandler(deps.Machines)))))
-	mux.Handle("/vault/key", requireLoopback(deps.Auth.Require(vaultKeyHandler(deps.Vault))))
-	mux.Handle("/push", requireLoopback(deps.Auth.Require(requirePushRateLimit(requireDeviceSignature(deps.Machines, pushHandler(deps.Push, deps.Idem))))))
+	if err := apispec.CheckServed(served); err != nil {
+		panic(err)
+	}
 
-	return mux
+	return withRequestLog(mux)
 }
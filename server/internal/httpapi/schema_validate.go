@@ -1,107 +1,20 @@
 package httpapi
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"strings"
 
 	"github.com/google/uuid"
+	"github.com/mgeovany/sentra/server/internal/apispec"
 	"github.com/santhosh-tekuri/jsonschema/v5"
 )
 
-var pushRequestSchema *jsonschema.Schema
+// pushSchemas holds a compiled schema per accepted push version.
+var pushSchemas = map[int]*jsonschema.Schema{}
 
 func init() {
-	const raw = `{
-  "$schema": "https://json-schema.org/draft/2020-12/schema",
-  "title": "Sentra PushRequest v1",
-  "type": "object",
-  "additionalProperties": false,
-  "required": ["v", "project", "machine", "commit", "files"],
-  "properties": {
-    "v": {"type": "integer", "const": 1},
-    "project": {
-      "type": "object",
-      "additionalProperties": false,
-      "properties": {
-        "id": {"type": "string", "format": "uuid"},
-        "root": {"type": "string", "minLength": 1, "maxLength": 300}
-      },
-      "oneOf": [
-        {"required": ["id"], "not": {"required": ["root"]}},
-        {"required": ["root"], "not": {"required": ["id"]}}
-      ]
-    },
-    "machine": {
-      "type": "object",
-      "additionalProperties": false,
-      "required": ["id"],
-      "properties": {
-        "id": {"type": "string", "format": "uuid"},
-        "name": {"type": "string", "minLength": 1, "maxLength": 255}
-      }
-    },
-    "commit": {
-      "type": "object",
-      "additionalProperties": false,
-      "required": ["client_id", "message"],
-      "properties": {
-        "client_id": {
-          "type": "string",
-          "description": "Client-generated UUID for idempotency. The server must treat (user_id, project, client_id) as unique and return the existing commit on retries.",
-          "format": "uuid"
-        },
-        "message": {"type": "string", "minLength": 1, "maxLength": 500},
-        "parent_client_id": {"type": "string", "format": "uuid"}
-      }
-    },
-    "files": {
-      "type": "array",
-      "minItems": 1,
-      "maxItems": 200,
-      "items": {
-        "type": "object",
-        "additionalProperties": false,
-		"required": ["path", "sha256", "size", "encrypted", "cipher"],
-		"properties": {
-		  "path": {
-			"type": "string",
-			"minLength": 1,
-			"maxLength": 500,
-			"pattern": "^(?:[A-Za-z0-9._-]+/)*\\.env(?:\\.[A-Za-z0-9._-]+)*$"
-		  },
-          "sha256": {
-            "type": "string",
-            "description": "SHA-256 (hex, lowercase) of the plaintext .env contents before encryption. Used for integrity and deduplication; the server cannot verify it without decrypting.",
-            "pattern": "^[a-f0-9]{64}$"
-          },
-          "size": {"type": "integer", "minimum": 1, "maximum": 1048576},
-          "encrypted": {"type": "boolean", "const": true},
-		  "cipher": {"type": "string", "enum": ["ed25519+aes-256-gcm-v1", "age-v1", "sentra-v1"]},
-		  "blob": {"type": "string", "minLength": 1, "maxLength": 8000000},
-		  "storage": {
-			"type": "object",
-			"additionalProperties": false,
-			"required": ["provider", "bucket", "key"],
-			"properties": {
-			  "provider": {"type": "string", "enum": ["s3"]},
-			  "bucket": {"type": "string", "minLength": 1, "maxLength": 255},
-			  "key": {"type": "string", "minLength": 1, "maxLength": 1024},
-			  "endpoint": {"type": "string", "minLength": 1, "maxLength": 500},
-			  "region": {"type": "string", "minLength": 1, "maxLength": 100}
-			}
-		  }
-		}
-		,
-		"oneOf": [
-		  {"required": ["blob"]},
-		  {"required": ["storage"]}
-		]
-	  }
-	}
-  }
-}`
-
 	compiler := jsonschema.NewCompiler()
 	compiler.AssertFormat = true
 	compiler.Formats["uuid"] = func(v any) bool {
@@ -113,23 +26,53 @@ func init() {
 		return err == nil
 	}
 
-	if err := compiler.AddResource("sentra://push.v1.schema.json", strings.NewReader(raw)); err != nil {
-		panic(err)
-	}
-	s, err := compiler.Compile("sentra://push.v1.schema.json")
-	if err != nil {
-		panic(err)
+	for _, v := range apispec.PushVersions {
+		raw, ok := apispec.PushSchema(v)
+		if !ok {
+			panic(fmt.Sprintf("push schema v%d is not embedded", v))
+		}
+		url := "sentra://" + apispec.PushSchemaFile(v)
+		if err := compiler.AddResource(url, bytes.NewReader(raw)); err != nil {
+			panic(err)
+		}
+		s, err := compiler.Compile(url)
+		if err != nil {
+			panic(err)
+		}
+		pushSchemas[v] = s
 	}
-	pushRequestSchema = s
 }
 
-func validateJSONAgainstPushSchema(body []byte) error {
-	if pushRequestSchema == nil {
-		return fmt.Errorf("push schema unavailable")
+// unsupportedPushVersionError is a push whose "v" names no schema the
+// server knows; its message is safe to return to the client.
+type unsupportedPushVersionError struct {
+	version int
+}
+
+func (e *unsupportedPushVersionError) Error() string {
+	supported := make([]string, 0, len(apispec.PushVersions))
+	for _, v := range apispec.PushVersions {
+		supported = append(supported, fmt.Sprint(v))
 	}
+	return fmt.Sprintf("unsupported push schema version %d (supported: %s)", e.version, strings.Join(supported, ", "))
+}
+
+// validateJSONAgainstPushSchema picks the schema named by the payload's
+// "v" field and validates the payload against it, returning the version.
+func validateJSONAgainstPushSchema(body []byte) (int, error) {
 	var v any
 	if err := json.Unmarshal(body, &v); err != nil {
-		return err
+		return 0, err
+	}
+	obj, _ := v.(map[string]any)
+	n, ok := obj["v"].(float64)
+	if !ok || n != float64(int(n)) {
+		return 0, fmt.Errorf("push payload has no integer \"v\"")
+	}
+	version := int(n)
+	schema, ok := pushSchemas[version]
+	if !ok {
+		return 0, &unsupportedPushVersionError{version: version}
 	}
-	return pushRequestSchema.Validate(v)
+	return version, schema.Validate(v)
 }
@@ -0,0 +1,202 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mgeovany/sentra/server/internal/auth"
+	"github.com/mgeovany/sentra/server/internal/repo"
+)
+
+const maxServiceTokenDays = 365
+
+type createServiceTokenRequest struct {
+	Name          string `json:"name"`
+	ProjectRoot   string `json:"project_root"`
+	ReadOnly      bool   `json:"read_only"`
+	ExpiresInDays int    `json:"expires_in_days"`
+}
+
+type createServiceTokenResponse struct {
+	repo.ServiceToken
+	// Token is returned exactly once, at creation.
+	Token string `json:"token"`
+}
+
+// serviceTokenVerifier resolves ServiceTokenPrefix tokens for auth.Middleware.
+type serviceTokenVerifier struct {
+	store repo.ServiceTokenStore
+}
+
+func NewServiceTokenVerifier(store repo.ServiceTokenStore) auth.Verifier {
+	if store == nil {
+		store = repo.DisabledServiceTokenStore{}
+	}
+	return serviceTokenVerifier{store: store}
+}
+
+func (v serviceTokenVerifier) Verify(token string) (auth.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t, ok, err := v.store.Lookup(ctx, auth.HashServiceToken(token))
+	if err != nil {
+		if errors.Is(err, repo.ErrDBNotConfigured) {
+			return auth.User{}, auth.ErrAuthNotConfigured
+		}
+		return auth.User{}, err
+	}
+	if !ok || !t.Active(time.Now()) {
+		return auth.User{}, errors.New("invalid service token")
+	}
+	return auth.User{
+		ID:          t.UserID,
+		Role:        "service_token",
+		TokenID:     t.ID,
+		ProjectRoot: strings.TrimSpace(t.ProjectRoot),
+		ReadOnly:    t.ReadOnly,
+	}, nil
+}
+
+// requireUserSession rejects service tokens (e.g. token management, vault key changes).
+func requireUserSession(next http.Handler) http.Handler {
+	return traced("requireUserSession", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, ok := auth.UserFromContext(r.Context()); ok && user.IsServiceToken() {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = io.WriteString(w, "requires a user session")
+			return
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// requireWriteAccess rejects read-only service tokens.
+func requireWriteAccess(next http.Handler) http.Handler {
+	return traced("requireWriteAccess", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, ok := auth.UserFromContext(r.Context()); ok && user.ReadOnly {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = io.WriteString(w, "read-only token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// denyProject writes 403 when a project-scoped token asks for another project.
+func denyProject(w http.ResponseWriter, r *http.Request, user auth.User, root string) bool {
+	if user.CanAccessProject(root) {
+		return false
+	}
+	slog.WarnContext(r.Context(), "service token project denied", "user_id", user.ID, "token_id", user.TokenID, "root", root)
+	w.WriteHeader(http.StatusForbidden)
+	_, _ = io.WriteString(w, "token not valid for this project")
+	return true
+}
+
+func serviceTokensHandler(store repo.ServiceTokenStore) http.Handler {
+	if store == nil {
+		store = repo.DisabledServiceTokenStore{}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := auth.UserFromContext(r.Context())
+		if !ok || strings.TrimSpace(user.ID) == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			tokens, err := store.List(r.Context(), user.ID)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "service tokens list failed", "user_id", user.ID, "err", err)
+				writeServiceTokenStoreError(w, err, "tokens failed")
+				return
+			}
+			if tokens == nil {
+				tokens = []repo.ServiceToken{}
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+			_ = json.NewEncoder(w).Encode(tokens)
+		case http.MethodPost:
+			r.Body = http.MaxBytesReader(w, r.Body, 16<<10) // 16 KiB
+			var req createServiceTokenRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			req.Name = strings.TrimSpace(req.Name)
+			req.ProjectRoot = strings.TrimSpace(req.ProjectRoot)
+			if len(req.Name) > 100 || len(req.ProjectRoot) > 300 || req.ExpiresInDays < 0 || req.ExpiresInDays > maxServiceTokenDays {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = io.WriteString(w, "invalid token request")
+				return
+			}
+
+			secret, err := auth.NewServiceToken()
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			t := repo.ServiceToken{UserID: user.ID, Name: req.Name, ProjectRoot: req.ProjectRoot, ReadOnly: req.ReadOnly}
+			if req.ExpiresInDays > 0 {
+				exp := time.Now().UTC().Add(time.Duration(req.ExpiresInDays) * 24 * time.Hour)
+				t.ExpiresAt = &exp
+			}
+
+			created, err := store.Create(r.Context(), t, auth.HashServiceToken(secret))
+			if err != nil {
+				slog.ErrorContext(r.Context(), "service token create failed", "user_id", user.ID, "err", err)
+				writeServiceTokenStoreError(w, err, "token create failed")
+				return
+			}
+			slog.InfoContext(r.Context(), "service token created", "user_id", user.ID, "token_id", created.ID, "read_only", created.ReadOnly)
+
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(createServiceTokenResponse{ServiceToken: created, Token: secret})
+		case http.MethodDelete:
+			id := strings.TrimSpace(r.URL.Query().Get("id"))
+			if _, err := uuid.Parse(id); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = io.WriteString(w, "invalid id")
+				return
+			}
+			if err := store.Revoke(r.Context(), user.ID, id); err != nil {
+				if errors.Is(err, repo.ErrTokenNotFound) {
+					w.WriteHeader(http.StatusNotFound)
+					_, _ = io.WriteString(w, "not found")
+					return
+				}
+				slog.ErrorContext(r.Context(), "service token revoke failed", "user_id", user.ID, "token_id", id, "err", err)
+				writeServiceTokenStoreError(w, err, "token revoke failed")
+				return
+			}
+			slog.InfoContext(r.Context(), "service token revoked", "user_id", user.ID, "token_id", id)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func writeServiceTokenStoreError(w http.ResponseWriter, err error, publicMsg string) {
+	switch err {
+	case repo.ErrDBNotConfigured:
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = io.WriteString(w, "db not configured")
+	case repo.ErrDBMisconfigured:
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = io.WriteString(w, "db misconfigured")
+	default:
+		writeHTTPError(w, http.StatusInternalServerError, publicMsg, err)
+	}
+}
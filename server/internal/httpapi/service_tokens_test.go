@@ -0,0 +1,154 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mgeovany/sentra/server/internal/auth"
+	"github.com/mgeovany/sentra/server/internal/repo"
+)
+
+// fakeTokenStore looks service tokens up by hash.
+type fakeTokenStore struct {
+	repo.DisabledServiceTokenStore
+	byHash map[string]repo.ServiceToken
+}
+
+func (s fakeTokenStore) Lookup(ctx context.Context, tokenHash string) (repo.ServiceToken, bool, error) {
+	t, ok := s.byHash[tokenHash]
+	return t, ok, nil
+}
+
+// fakeMachines knows one device key per user and machine.
+type fakeMachines struct {
+	repo.DisabledMachineStore
+	pub string
+}
+
+func (m fakeMachines) DevicePubKey(ctx context.Context, userID, machineID string) (string, bool, error) {
+	return m.pub, m.pub != "", nil
+}
+
+// tokenServer serves the full API for user-1 with the service tokens
+// below, plus the session token "session".
+type tokenServer struct {
+	h      http.Handler
+	priv   ed25519.PrivateKey
+	blobs  *fakeBlobStore
+	nonces atomic.Int64
+}
+
+const testMachineID = "6f1c2b1e-6d1f-4a53-9a57-1b1b2f4b9a10"
+
+func newTokenServer(t *testing.T) *tokenServer {
+	t.Helper()
+	t.Setenv("SENTRA_LOOPBACK_ONLY", "1")
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	past := time.Now().Add(-time.Hour)
+	tokens := map[string]repo.ServiceToken{
+		"sst_scoped":   {ID: "t-scoped", UserID: "user-1", ProjectRoot: "app"},
+		"sst_all":      {ID: "t-all", UserID: "user-1"},
+		"sst_readonly": {ID: "t-ro", UserID: "user-1", ProjectRoot: "app", ReadOnly: true},
+		"sst_revoked":  {ID: "t-revoked", UserID: "user-1", RevokedAt: &past},
+		"sst_expired":  {ID: "t-expired", UserID: "user-1", ExpiresAt: &past},
+	}
+	byHash := map[string]repo.ServiceToken{}
+	for tok, st := range tokens {
+		byHash[auth.HashServiceToken(tok)] = st
+	}
+
+	s := &tokenServer{priv: priv, blobs: newFakeBlobStore()}
+	middleware := auth.NewMiddleware(fakeVerifier{"session": {ID: "user-1"}}).
+		WithServiceTokens(NewServiceTokenVerifier(fakeTokenStore{byHash: byHash}))
+	s.h = New(Deps{
+		Auth:     middleware,
+		Machines: fakeMachines{pub: base64.RawURLEncoding.EncodeToString(pub)},
+		Push:     &fakePushStore{},
+		Blobs:    s.blobs,
+	})
+	return s
+}
+
+func (s *tokenServer) do(method string, path string, token string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.RemoteAddr = "127.0.0.1:50000"
+	req.Header.Set("Authorization", "Bearer "+token)
+	if method == http.MethodPost && path == "/v1/push" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		nonce := fmt.Sprintf("nonce-%d", s.nonces.Add(1))
+		msg := "v2\n" + ts + "\nPOST\n" + path + "\n" + testMachineID + "\n" + nonce + "\n" + body
+		req.Header.Set("X-Sentra-Machine-ID", testMachineID)
+		req.Header.Set("X-Sentra-Timestamp", ts)
+		req.Header.Set("X-Sentra-Nonce", nonce)
+		req.Header.Set("X-Sentra-Signature", base64.RawURLEncoding.EncodeToString(ed25519.Sign(s.priv, []byte(msg))))
+	}
+	rec := httptest.NewRecorder()
+	s.h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServiceTokenAccess(t *testing.T) {
+	s := newTokenServer(t)
+	// A blob in each project, uploaded with the session.
+	for _, root := range []string{"app", "other"} {
+		if rec := s.do(http.MethodPut, "/v1/blobs/"+root+"/"+testBlobID, "session", "ciphertext"); rec.Code != http.StatusCreated {
+			t.Fatalf("seed %s: status %d", root, rec.Code)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{name: "scoped upload to its root", token: "sst_scoped", method: http.MethodPut, path: "/v1/blobs/app/" + testBlobID, body: "scoped", status: http.StatusCreated},
+		{name: "scoped upload to another root", token: "sst_scoped", method: http.MethodPut, path: "/v1/blobs/other/" + testBlobID, body: "refused", status: http.StatusForbidden},
+		{name: "scoped download from another root", token: "sst_scoped", method: http.MethodGet, path: "/v1/blobs/other/" + testBlobID, status: http.StatusForbidden},
+		{name: "scoped head on another root", token: "sst_scoped", method: http.MethodHead, path: "/v1/blobs/other/" + testBlobID, status: http.StatusForbidden},
+		{name: "scoped push of its root", token: "sst_scoped", method: http.MethodPost, path: "/v1/push", body: pushBody("app", pushFile{"s3", 10}), status: http.StatusOK},
+		{name: "scoped push of another root", token: "sst_scoped", method: http.MethodPost, path: "/v1/push", body: pushBody("other", pushFile{"s3", 10}), status: http.StatusForbidden},
+		{name: "unscoped token reaches every root", token: "sst_all", method: http.MethodPut, path: "/v1/blobs/other/" + testBlobID, body: "unscoped", status: http.StatusCreated},
+		{name: "unscoped push", token: "sst_all", method: http.MethodPost, path: "/v1/push", body: pushBody("other", pushFile{"s3", 10}), status: http.StatusOK},
+		{name: "read-only upload", token: "sst_readonly", method: http.MethodPut, path: "/v1/blobs/app/" + testBlobID, body: "refused", status: http.StatusForbidden},
+		{name: "read-only download", token: "sst_readonly", method: http.MethodGet, path: "/v1/blobs/app/" + testBlobID, status: http.StatusOK},
+		{name: "read-only push", token: "sst_readonly", method: http.MethodPost, path: "/v1/push", body: pushBody("app", pushFile{"s3", 10}), status: http.StatusForbidden},
+		{name: "revoked token", token: "sst_revoked", method: http.MethodGet, path: "/v1/blobs/app/" + testBlobID, status: http.StatusUnauthorized},
+		{name: "revoked token push", token: "sst_revoked", method: http.MethodPost, path: "/v1/push", body: pushBody("app", pushFile{"s3", 10}), status: http.StatusUnauthorized},
+		{name: "expired token", token: "sst_expired", method: http.MethodPut, path: "/v1/blobs/app/" + testBlobID, body: "refused", status: http.StatusUnauthorized},
+		{name: "unknown token", token: "sst_nope", method: http.MethodGet, path: "/v1/blobs/app/" + testBlobID, status: http.StatusUnauthorized},
+		{name: "tokens cannot manage tokens", token: "sst_all", method: http.MethodGet, path: "/v1/tokens", status: http.StatusForbidden},
+		{name: "tokens cannot list storage refs", token: "sst_all", method: http.MethodGet, path: "/v1/storage/refs", status: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(tt.method, tt.path, tt.token, tt.body)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d; want %d (body %q)", rec.Code, tt.status, rec.Body.String())
+			}
+		})
+	}
+
+	// Refused uploads must not have replaced a blob.
+	want := map[string]string{"app": "scoped", "other": "unscoped"}
+	for root, data := range want {
+		if got := string(s.blobs.blobs["user-1/"+root+"/"+testBlobID]); got != data {
+			t.Fatalf("%s blob = %q; want %q", root, got, data)
+		}
+	}
+}
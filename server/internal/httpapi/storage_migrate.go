@@ -0,0 +1,124 @@
+package httpapi
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/mgeovany/sentra/server/internal/auth"
+	"github.com/mgeovany/sentra/server/internal/repo"
+)
+
+// maxRelocateFiles bounds one `sentra storage migrate` batch; the CLI also
+// keeps inline blobs under maxPushBodyBytes per request.
+const maxRelocateFiles = 200
+
+var relocateProviders = map[string]bool{"sentra": true, "s3": true, "fs": true, "webdav": true, "sftp": true}
+
+type relocateRequest struct {
+	Root  string              `json:"root"`
+	Files []repo.FileLocation `json:"files"`
+}
+
+type relocateResponse struct {
+	Updated int `json:"updated"`
+}
+
+func storageMigrateHandler(store repo.FileLocationStore) http.Handler {
+	if store == nil {
+		store = repo.DisabledFileLocationStore{}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		user, ok := auth.UserFromContext(r.Context())
+		if !ok || strings.TrimSpace(user.ID) == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxPushBodyBytes)
+		var req relocateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		req.Root = strings.TrimSpace(req.Root)
+		if req.Root == "" || len(req.Files) == 0 || len(req.Files) > maxRelocateFiles {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, "invalid migrate request")
+			return
+		}
+		if denyProject(w, r, user, req.Root) {
+			return
+		}
+		for _, f := range req.Files {
+			if !validFileLocation(req.Root, f) {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = io.WriteString(w, "invalid file location")
+				return
+			}
+		}
+
+		updated, err := store.RelocateFiles(r.Context(), user.ID, req.Root, req.Files)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "storage migrate failed", "user_id", user.ID, "root", req.Root, "err", err)
+			switch err {
+			case repo.ErrDBNotConfigured:
+				w.WriteHeader(http.StatusServiceUnavailable)
+				_, _ = io.WriteString(w, "db not configured")
+			default:
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = io.WriteString(w, "storage migrate failed")
+			}
+			return
+		}
+		slog.InfoContext(r.Context(), "storage migrated", "user_id", user.ID, "root", req.Root, "files", len(req.Files), "updated", updated)
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(relocateResponse{Updated: updated})
+	})
+}
+
+// validFileLocation mirrors the push schema: exactly one of an inline blob or
+// a storage pointer. Hosted blobs must belong to root.
+func validFileLocation(root string, f repo.FileLocation) bool {
+	if _, err := uuid.Parse(strings.TrimSpace(f.CommitID)); err != nil {
+		return false
+	}
+	if p := strings.TrimSpace(f.FilePath); p == "" || len(p) > 1024 {
+		return false
+	}
+	if b, err := hex.DecodeString(f.SHA256); err != nil || len(b) != 32 {
+		return false
+	}
+
+	inline := f.BlobB64 != ""
+	stored := f.StorageKey != ""
+	if inline == stored {
+		return false
+	}
+	if inline {
+		return len(f.BlobB64) <= 8000000 && f.StorageProvider == "" && f.StorageBucket == ""
+	}
+	if f.StorageProvider == "sentra" {
+		// Hosted blob: "<root>/<id>", uploaded through PUT /blobs.
+		blobRoot, id, ok := strings.Cut(f.StorageKey, "/")
+		return ok && blobRoot == root && validBlobID(id) &&
+			f.StorageBucket == "" && f.StorageEndpoint == "" && f.StorageRegion == ""
+	}
+	return relocateProviders[f.StorageProvider] &&
+		f.StorageBucket != "" && len(f.StorageBucket) <= 255 &&
+		len(f.StorageKey) <= 1024 &&
+		len(f.StorageEndpoint) <= 500 &&
+		len(f.StorageRegion) <= 100
+}
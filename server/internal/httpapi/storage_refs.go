@@ -0,0 +1,56 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/mgeovany/sentra/server/internal/auth"
+	"github.com/mgeovany/sentra/server/internal/repo"
+)
+
+type storageRefsResponse struct {
+	Keys []string `json:"keys"`
+}
+
+func storageRefsHandler(store repo.StorageRefStore) http.Handler {
+	if store == nil {
+		store = repo.DisabledStorageRefStore{}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		user, ok := auth.UserFromContext(r.Context())
+		if !ok || strings.TrimSpace(user.ID) == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		keys, err := store.ListStorageKeys(r.Context(), user.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "storage refs failed", "user_id", user.ID, "err", err)
+			switch err {
+			case repo.ErrDBNotConfigured:
+				w.WriteHeader(http.StatusServiceUnavailable)
+				_, _ = io.WriteString(w, "db not configured")
+			default:
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = io.WriteString(w, "storage refs failed")
+			}
+			return
+		}
+		if keys == nil {
+			keys = []string{}
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(storageRefsResponse{Keys: keys})
+	})
+}
@@ -0,0 +1,27 @@
+package httpapi
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/mgeovany/sentra/server/internal/httpapi")
+
+// traced runs next inside a span called name. Middleware and handlers wrap
+// themselves in it, so a trace shows each layer of a route's chain nested
+// in the one before it.
+func traced(name string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracer.Start(r.Context(), name)
+		defer span.End()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// markSpan sets attributes on the span of the current layer.
+func markSpan(r *http.Request, attrs ...attribute.KeyValue) {
+	trace.SpanFromContext(r.Context()).SetAttributes(attrs...)
+}
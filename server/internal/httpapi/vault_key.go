@@ -44,6 +44,11 @@ func vaultKeyHandler(store repo.VaultKeyStore) http.Handler {
 			_, _ = w.Write(doc)
 			return
 		case http.MethodPut:
+			if user.IsServiceToken() {
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = io.WriteString(w, "requires a user session")
+				return
+			}
 			r.Body = http.MaxBytesReader(w, r.Body, 32<<10) // 32 KiB
 			body, err := io.ReadAll(r.Body)
 			if err != nil {
@@ -0,0 +1,176 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/mgeovany/sentra/server/internal/auth"
+	"github.com/mgeovany/sentra/server/internal/repo"
+	"github.com/mgeovany/sentra/server/internal/webhooks"
+)
+
+// maxWebhooks bounds the webhooks of one user, and so the deliveries one
+// push fans out to.
+const maxWebhooks = 20
+
+func webhooksHandler(store repo.WebhookStore, hooks *webhooks.Dispatcher) http.Handler {
+	if store == nil {
+		store = repo.DisabledWebhookStore{}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := auth.UserFromContext(r.Context())
+		if !ok || strings.TrimSpace(user.ID) == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			list, err := store.List(r.Context(), user.ID)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "webhooks list failed", "user_id", user.ID, "err", err)
+				writeServiceTokenStoreError(w, err, "webhooks failed")
+				return
+			}
+			if list == nil {
+				list = []repo.Webhook{}
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+			_ = json.NewEncoder(w).Encode(list)
+		case http.MethodPost:
+			r.Body = http.MaxBytesReader(w, r.Body, 16<<10) // 16 KiB
+			var req webhooks.Request
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			req.URL = strings.TrimSpace(req.URL)
+			req.ProjectRoot = strings.TrimSpace(req.ProjectRoot)
+			if err := hooks.CheckURL(req.URL); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = io.WriteString(w, err.Error())
+				return
+			}
+			if len(req.ProjectRoot) > 300 {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = io.WriteString(w, "invalid webhook request")
+				return
+			}
+
+			existing, err := store.List(r.Context(), user.ID)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "webhooks list failed", "user_id", user.ID, "err", err)
+				writeServiceTokenStoreError(w, err, "webhook create failed")
+				return
+			}
+			if len(existing) >= maxWebhooks {
+				w.WriteHeader(http.StatusConflict)
+				_, _ = io.WriteString(w, "too many webhooks (delete one first)")
+				return
+			}
+
+			secret, err := webhooks.NewSecret()
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			created, err := store.Create(r.Context(), repo.Webhook{UserID: user.ID, URL: req.URL, ProjectRoot: req.ProjectRoot}, secret)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "webhook create failed", "user_id", user.ID, "err", err)
+				writeServiceTokenStoreError(w, err, "webhook create failed")
+				return
+			}
+			slog.InfoContext(r.Context(), "webhook created", "user_id", user.ID, "webhook_id", created.ID, "root", created.ProjectRoot)
+
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(created)
+		case http.MethodDelete:
+			id, ok := webhookID(w, r)
+			if !ok {
+				return
+			}
+			if err := store.Delete(r.Context(), user.ID, id); err != nil {
+				if errors.Is(err, repo.ErrWebhookNotFound) {
+					w.WriteHeader(http.StatusNotFound)
+					_, _ = io.WriteString(w, "not found")
+					return
+				}
+				slog.ErrorContext(r.Context(), "webhook delete failed", "user_id", user.ID, "webhook_id", id, "err", err)
+				writeServiceTokenStoreError(w, err, "webhook delete failed")
+				return
+			}
+			slog.InfoContext(r.Context(), "webhook deleted", "user_id", user.ID, "webhook_id", id)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// webhookTestHandler sends one ping event to a webhook, without retries, and
+// reports what the receiver answered.
+func webhookTestHandler(store repo.WebhookStore, hooks *webhooks.Dispatcher) http.Handler {
+	if store == nil {
+		store = repo.DisabledWebhookStore{}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		user, ok := auth.UserFromContext(r.Context())
+		if !ok || strings.TrimSpace(user.ID) == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		id, ok := webhookID(w, r)
+		if !ok {
+			return
+		}
+
+		targets, err := store.Targets(r.Context(), user.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "webhook targets lookup failed", "user_id", user.ID, "err", err)
+			writeServiceTokenStoreError(w, err, "webhook test failed")
+			return
+		}
+		var target *repo.WebhookTarget
+		for i := range targets {
+			if targets[i].ID == id {
+				target = &targets[i]
+				break
+			}
+		}
+		if target == nil {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, "not found")
+			return
+		}
+
+		res := hooks.Deliver(r.Context(), *target, webhooks.PingEvent(target.ProjectRoot))
+		slog.InfoContext(r.Context(), "webhook tested", "user_id", user.ID, "webhook_id", id, "status", res.StatusCode, "err", res.Error)
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(res)
+	})
+}
+
+func webhookID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := strings.TrimSpace(r.URL.Query().Get("id"))
+	if _, err := uuid.Parse(id); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, "invalid id")
+		return "", false
+	}
+	return id, true
+}
@@ -0,0 +1,182 @@
+package limits
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mgeovany/sentra/server/internal/ratelimit"
+	"github.com/mgeovany/sentra/server/internal/repo"
+)
+
+// Route names used in the config, in metrics and as rate limit key
+// prefixes.
+const (
+	RouteUsersMe         = "users_me"
+	RouteProjects        = "projects"
+	RouteProjectHeads    = "projects_heads"
+	RouteProject         = "project"
+	RouteCommits         = "commits"
+	RouteFiles           = "files"
+	RouteExport          = "export"
+	RouteVaultKey        = "vault_key"
+	RouteMachineRegister = "machine_register"
+	RoutePush            = "push"
+	RouteBlobs           = "blobs"
+	RouteTokens          = "tokens"
+	RouteWebhooks        = "webhooks"
+	RouteStorageRefs     = "storage_refs"
+	RouteStorageMigrate  = "storage_migrate"
+	RouteDeviceCode      = "device_code"
+	RouteDeviceToken     = "device_token"
+	RouteDeviceCallback  = "device_callback"
+)
+
+// RouteLimit is a token bucket per user (per client IP on unauthenticated
+// routes). RPM 0 turns the limit off.
+type RouteLimit struct {
+	RPM   int64   `json:"rpm"`
+	Burst float64 `json:"burst"`
+}
+
+// UserOverride replaces route limits and individual quotas for one user.
+type UserOverride struct {
+	Routes         map[string]RouteLimit `json:"routes"`
+	DailyPushBytes *int64                `json:"daily_push_bytes"`
+	StorageBytes   *int64                `json:"storage_bytes"`
+}
+
+// Config is the declarative limits file (SENTRA_LIMITS_FILE). Routes missing
+// from the file keep their defaults.
+type Config struct {
+	Routes map[string]RouteLimit   `json:"routes"`
+	Quotas repo.Quota              `json:"quotas"`
+	Users  map[string]UserOverride `json:"users"`
+}
+
+// Default returns the built-in limits. SENTRA_PUSH_RPM and SENTRA_PUSH_BURST
+// still tune push, as before the limits file existed.
+func Default() *Config {
+	push := RouteLimit{RPM: 300, Burst: 60}
+	if v := strings.TrimSpace(os.Getenv("SENTRA_PUSH_RPM")); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			push.RPM = n
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv("SENTRA_PUSH_BURST")); v != "" {
+		if n, err := strconv.ParseFloat(v, 64); err == nil && n > 0 {
+			push.Burst = n
+		}
+	}
+
+	return &Config{
+		Routes: map[string]RouteLimit{
+			RouteUsersMe:      {RPM: 120, Burst: 30},
+			RouteProjects:     {RPM: 120, Burst: 30},
+			RouteProjectHeads: {RPM: 120, Burst: 30},
+			RouteProject:      {RPM: 30, Burst: 10},
+			RouteCommits:      {RPM: 120, Burst: 30},
+			RouteFiles:        {RPM: 120, Burst: 30},
+			RouteExport:       {RPM: 60, Burst: 20},
+			RouteVaultKey:     {RPM: 60, Burst: 20},
+			// Registration should be rare; keep tight to reduce abuse.
+			RouteMachineRegister: {RPM: 30, Burst: 10},
+			// Tuned for local pushes: a single "sentra push" can fan out
+			// into many per-project requests.
+			RoutePush: push,
+			// One upload or download per file.
+			RouteBlobs:          {RPM: 600, Burst: 120},
+			RouteTokens:         {RPM: 30, Burst: 10},
+			RouteWebhooks:       {RPM: 30, Burst: 10},
+			RouteStorageRefs:    {RPM: 30, Burst: 10},
+			RouteStorageMigrate: {RPM: 30, Burst: 10},
+			// Per client IP; /device shares the device_code bucket.
+			RouteDeviceCode:     {RPM: 10, Burst: 5},
+			RouteDeviceToken:    {RPM: 60, Burst: 20},
+			RouteDeviceCallback: {RPM: 30, Burst: 10},
+		},
+	}
+}
+
+// Load reads a limits file over Default. An empty path returns Default.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return cfg, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file Config
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("invalid limits file %s: %w", path, err)
+	}
+
+	if err := checkRoutes(file.Routes); err != nil {
+		return nil, fmt.Errorf("invalid limits file %s: %w", path, err)
+	}
+	for name, l := range file.Routes {
+		cfg.Routes[name] = l
+	}
+	if file.Quotas.DailyPushBytes < 0 || file.Quotas.StorageBytes < 0 {
+		return nil, fmt.Errorf("invalid limits file %s: quotas must not be negative", path)
+	}
+	cfg.Quotas = file.Quotas
+	for id, u := range file.Users {
+		if err := checkRoutes(u.Routes); err != nil {
+			return nil, fmt.Errorf("invalid limits file %s: user %s: %w", path, id, err)
+		}
+	}
+	cfg.Users = file.Users
+	return cfg, nil
+}
+
+func checkRoutes(routes map[string]RouteLimit) error {
+	known := Default().Routes
+	for name, l := range routes {
+		if _, ok := known[name]; !ok {
+			return fmt.Errorf("unknown route %q", name)
+		}
+		if l.RPM < 0 || l.Burst < 0 || (l.RPM > 0 && l.Burst < 1) {
+			return fmt.Errorf("route %q: rpm must be >= 0 and burst >= 1", name)
+		}
+	}
+	return nil
+}
+
+// Route returns the limit for route and user (empty for unauthenticated
+// routes). ok is false when the route is unlimited.
+func (c *Config) Route(route string, userID string) (limit ratelimit.Limit, ok bool) {
+	l, found := c.Routes[route]
+	if u, has := c.Users[userID]; has && userID != "" {
+		if ul, set := u.Routes[route]; set {
+			l, found = ul, true
+		}
+	}
+	if !found || l.RPM <= 0 {
+		return ratelimit.Limit{}, false
+	}
+	return ratelimit.PerMinute(l.RPM, l.Burst), true
+}
+
+// Quota returns the push quotas for a user.
+func (c *Config) Quota(userID string) repo.Quota {
+	q := c.Quotas
+	if u, ok := c.Users[userID]; ok {
+		if u.DailyPushBytes != nil {
+			q.DailyPushBytes = *u.DailyPushBytes
+		}
+		if u.StorageBytes != nil {
+			q.StorageBytes = *u.StorageBytes
+		}
+	}
+	return q
+}
@@ -0,0 +1,144 @@
+package limits
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mgeovany/sentra/server/internal/ratelimit"
+	"github.com/mgeovany/sentra/server/internal/repo"
+)
+
+func TestLoadPushEnvFallback(t *testing.T) {
+	tests := []struct {
+		name  string
+		rpm   string
+		burst string
+		want  RouteLimit
+	}{
+		{name: "unset", want: RouteLimit{RPM: 300, Burst: 60}},
+		{name: "both set", rpm: "120", burst: "15", want: RouteLimit{RPM: 120, Burst: 15}},
+		{name: "rpm only", rpm: "90", want: RouteLimit{RPM: 90, Burst: 60}},
+		{name: "invalid values keep the defaults", rpm: "fast", burst: "-3", want: RouteLimit{RPM: 300, Burst: 60}},
+		{name: "zero keeps the default", rpm: "0", burst: "0", want: RouteLimit{RPM: 300, Burst: 60}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SENTRA_PUSH_RPM", tt.rpm)
+			t.Setenv("SENTRA_PUSH_BURST", tt.burst)
+			cfg, err := Load("")
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if got := cfg.Routes[RoutePush]; got != tt.want {
+				t.Fatalf("push limit = %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// A limits file entry for push wins over the environment.
+	t.Setenv("SENTRA_PUSH_RPM", "120")
+	cfg, err := Load(writeFile(t, `{"routes": {"push": {"rpm": 10, "burst": 2}}}`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got, want := cfg.Routes[RoutePush], (RouteLimit{RPM: 10, Burst: 2}); got != want {
+		t.Fatalf("push limit = %+v; want %+v", got, want)
+	}
+}
+
+func TestLoadUserOverrides(t *testing.T) {
+	t.Setenv("SENTRA_PUSH_RPM", "")
+	t.Setenv("SENTRA_PUSH_BURST", "")
+	cfg, err := Load(writeFile(t, `{
+		"routes": {"blobs": {"rpm": 60, "burst": 10}, "tokens": {"rpm": 0, "burst": 0}},
+		"quotas": {"daily_push_bytes": 1000, "storage_bytes": 5000},
+		"users": {
+			"heavy": {
+				"routes": {"push": {"rpm": 1200, "burst": 200}},
+				"storage_bytes": 0
+			},
+			"capped": {"daily_push_bytes": 10}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	routes := []struct {
+		name   string
+		route  string
+		user   string
+		want   ratelimit.Limit
+		capped bool
+	}{
+		{name: "file overrides a default", route: RouteBlobs, user: "someone", want: ratelimit.PerMinute(60, 10), capped: true},
+		{name: "default kept", route: RouteProjects, user: "someone", want: ratelimit.PerMinute(120, 30), capped: true},
+		{name: "rpm 0 turns a route off", route: RouteTokens, user: "someone", capped: false},
+		{name: "user override", route: RoutePush, user: "heavy", want: ratelimit.PerMinute(1200, 200), capped: true},
+		{name: "user override is per route", route: RouteBlobs, user: "heavy", want: ratelimit.PerMinute(60, 10), capped: true},
+		{name: "other users keep the route limit", route: RoutePush, user: "capped", want: ratelimit.PerMinute(300, 60), capped: true},
+		{name: "unauthenticated", route: RoutePush, user: "", want: ratelimit.PerMinute(300, 60), capped: true},
+		{name: "unknown route", route: "nope", user: "heavy", capped: false},
+	}
+	for _, tt := range routes {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := cfg.Route(tt.route, tt.user)
+			if ok != tt.capped || got != tt.want {
+				t.Fatalf("Route(%q, %q) = %+v, %v; want %+v, %v", tt.route, tt.user, got, ok, tt.want, tt.capped)
+			}
+		})
+	}
+
+	quotas := []struct {
+		user string
+		want repo.Quota
+	}{
+		{user: "someone", want: repo.Quota{DailyPushBytes: 1000, StorageBytes: 5000}},
+		{user: "heavy", want: repo.Quota{DailyPushBytes: 1000, StorageBytes: 0}},
+		{user: "capped", want: repo.Quota{DailyPushBytes: 10, StorageBytes: 5000}},
+	}
+	for _, tt := range quotas {
+		if got := cfg.Quota(tt.user); got != tt.want {
+			t.Errorf("Quota(%q) = %+v; want %+v", tt.user, got, tt.want)
+		}
+	}
+}
+
+func TestLoadRejectsInvalidFiles(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		want string
+	}{
+		{name: "unknown field", file: `{"route": {}}`, want: "unknown field"},
+		{name: "unknown route", file: `{"routes": {"pusj": {"rpm": 1, "burst": 1}}}`, want: `unknown route "pusj"`},
+		{name: "burst below one", file: `{"routes": {"push": {"rpm": 10, "burst": 0.5}}}`, want: "burst >= 1"},
+		{name: "negative rpm", file: `{"routes": {"push": {"rpm": -1, "burst": 1}}}`, want: "rpm must be >= 0"},
+		{name: "negative quota", file: `{"quotas": {"storage_bytes": -1}}`, want: "must not be negative"},
+		{name: "bad user route", file: `{"users": {"u1": {"routes": {"nope": {"rpm": 1, "burst": 1}}}}}`, want: "user u1"},
+		{name: "not json", file: `rpm=10`, want: "invalid limits file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeFile(t, tt.file))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Load err = %v; want it to mention %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatal("Load of a missing file succeeded")
+	}
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "limits.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write limits file: %v", err)
+	}
+	return path
+}
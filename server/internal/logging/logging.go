@@ -0,0 +1,68 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// New returns a JSON logger at level that redacts secrets (see Redact) and
+// adds the request ID of the context to every record.
+func New(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(NewHandler(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})))
+}
+
+// ParseLevel maps LOG_LEVEL values (debug, info, warn, error) to a level.
+// Unknown values fall back to info.
+func ParseLevel(s string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
+
+type contextKey string
+
+const requestKey contextKey = "sentra.request"
+
+// request is the per-request state shared between the access log, which
+// wraps everything, and the auth middleware, which only runs further in.
+type request struct {
+	id string
+
+	mu     sync.Mutex
+	userID string
+}
+
+// WithRequestID starts the request-scoped logging state for id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestKey, &request{id: id})
+}
+
+func RequestID(ctx context.Context) string {
+	if r, ok := ctx.Value(requestKey).(*request); ok {
+		return r.id
+	}
+	return ""
+}
+
+// SetUserID records the authenticated user for the request's access log.
+func SetUserID(ctx context.Context, userID string) {
+	if r, ok := ctx.Value(requestKey).(*request); ok {
+		r.mu.Lock()
+		r.userID = userID
+		r.mu.Unlock()
+	}
+}
+
+func UserID(ctx context.Context) string {
+	if r, ok := ctx.Value(requestKey).(*request); ok {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.userID
+	}
+	return ""
+}
@@ -0,0 +1,141 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute key fragments whose values are never logged.
+// Keys ending in "_id" name public identifiers (token_id, machine_id) and
+// are kept.
+var sensitiveKeys = []string{
+	"authorization",
+	"token",
+	"signature",
+	"secret",
+	"password",
+	"cookie",
+	"apikey",
+	"api_key",
+	"vault_key",
+	"device_code",
+	"user_code",
+	"verifier",
+	"ciphertext",
+	"blob",
+	"body",
+}
+
+var secretPatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`(?i)\bbearer\s+[^\s"',;]+`), "Bearer " + redacted},
+	// Supabase access tokens and the service role key.
+	{regexp.MustCompile(`eyJ[A-Za-z0-9_-]{4,}\.[A-Za-z0-9_-]{4,}\.[A-Za-z0-9_-]*`), redacted},
+	// Service tokens (auth.ServiceTokenPrefix).
+	{regexp.MustCompile(`\bsst_[A-Za-z0-9_-]+`), redacted},
+	// Long base64 or hex runs: ciphertexts, keys, signatures.
+	{regexp.MustCompile(`[A-Za-z0-9+/_-]{100,}={0,2}`), redacted},
+}
+
+// Redact removes tokens, signatures and blob contents from s. It is applied
+// to every message and string value, including errors that embed upstream
+// response bodies.
+func Redact(s string) string {
+	for _, p := range secretPatterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	return s
+}
+
+func sensitiveKey(key string) bool {
+	k := strings.ToLower(key)
+	if k == "id" || strings.HasSuffix(k, "_id") {
+		return false
+	}
+	for _, frag := range sensitiveKeys {
+		if strings.Contains(k, frag) {
+			return true
+		}
+	}
+	return false
+}
+
+// handler redacts every record before it reaches next and adds the request
+// ID and trace IDs from the context.
+type handler struct {
+	next slog.Handler
+}
+
+func NewHandler(next slog.Handler) slog.Handler {
+	return handler{next: next}
+}
+
+func (h handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h handler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, Redact(r.Message), r.PC)
+	if id := RequestID(ctx); id != "" {
+		out.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		out.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clean := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		clean[i] = redactAttr(a)
+	}
+	return handler{next: h.next.WithAttrs(clean)}
+}
+
+func (h handler) WithGroup(name string) slog.Handler {
+	return handler{next: h.next.WithGroup(name)}
+}
+
+func redactAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if sensitiveKey(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Redact(a.Value.String()))
+	case slog.KindGroup:
+		group := a.Value.Group()
+		clean := make([]any, len(group))
+		for i, g := range group {
+			clean[i] = redactAttr(g)
+		}
+		return slog.Group(a.Key, clean...)
+	case slog.KindAny:
+		// Anything else is rendered as text first, so a struct or byte
+		// slice cannot carry a secret past the patterns.
+		switch v := a.Value.Any().(type) {
+		case error:
+			return slog.String(a.Key, Redact(v.Error()))
+		case []byte:
+			return slog.String(a.Key, fmt.Sprintf("[%d bytes]", len(v)))
+		default:
+			return slog.String(a.Key, Redact(fmt.Sprintf("%+v", v)))
+		}
+	}
+	return a
+}
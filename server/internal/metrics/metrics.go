@@ -0,0 +1,161 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds every server metric plus the Go runtime and process
+// collectors. It is separate from the prometheus default registry so only
+// what is registered here is exposed.
+var Registry = prometheus.NewRegistry()
+
+// sizeBuckets cover a few hundred bytes (one small env file) to the 12 MiB
+// push body limit.
+var sizeBuckets = prometheus.ExponentialBuckets(256, 4, 9)
+
+var (
+	requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sentra_http_requests_total",
+		Help: "HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sentra_http_request_duration_seconds",
+		Help:    "HTTP request latency by route and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	pushBytes = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "sentra_push_request_bytes",
+		Help:    "Size of accepted push payloads.",
+		Buckets: sizeBuckets,
+	})
+
+	pushFiles = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "sentra_push_files",
+		Help:    "Files per accepted push.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 8),
+	})
+
+	exportBytes = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "sentra_export_response_bytes",
+		Help:    "Size of export responses.",
+		Buckets: sizeBuckets,
+	})
+
+	blobBytes = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "sentra_blob_upload_bytes",
+		Help:    "Size of hosted blob uploads.",
+		Buckets: sizeBuckets,
+	})
+
+	rateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sentra_rate_limit_rejections_total",
+		Help: "Requests rejected with 429 by limiter.",
+	}, []string{"limiter"})
+
+	nonceReplays = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "sentra_nonce_replay_rejections_total",
+		Help: "Signed requests rejected because their nonce was already used.",
+	})
+
+	webhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sentra_webhook_deliveries_total",
+		Help: "Webhook delivery attempts by result: ok, retry, failed or dropped.",
+	}, []string{"result"})
+
+	upstreamDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sentra_upstream_request_duration_seconds",
+		Help:    "Latency of calls to Supabase by operation and status code (\"error\" when no response).",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"service", "op", "code"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requests, requestDuration,
+		pushBytes, pushFiles, exportBytes, blobBytes,
+		rateLimited, nonceReplays,
+		webhookDeliveries,
+		upstreamDuration,
+	)
+}
+
+// Handler serves Registry in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ObserveRequest records one HTTP request. route is the ServeMux pattern
+// that matched, so the label set stays bounded.
+func ObserveRequest(route string, method string, code int, d time.Duration) {
+	if route == "" {
+		route = "unmatched"
+	}
+	requests.WithLabelValues(route, method, strconv.Itoa(code)).Inc()
+	requestDuration.WithLabelValues(route, method).Observe(d.Seconds())
+}
+
+func ObservePush(bytes int, files int) {
+	pushBytes.Observe(float64(bytes))
+	pushFiles.Observe(float64(files))
+}
+
+func ObserveExport(bytes int) {
+	exportBytes.Observe(float64(bytes))
+}
+
+func ObserveBlobUpload(bytes int64) {
+	blobBytes.Observe(float64(bytes))
+}
+
+func RateLimited(limiter string) {
+	rateLimited.WithLabelValues(limiter).Inc()
+}
+
+func NonceReplayed() {
+	nonceReplays.Inc()
+}
+
+// WebhookDelivered records one webhook delivery attempt, or a dropped event.
+func WebhookDelivered(result string) {
+	webhookDeliveries.WithLabelValues(result).Inc()
+}
+
+// ObserveUpstream records a call to Supabase. code is 0 when the call
+// failed without a response.
+func ObserveUpstream(path string, code int, d time.Duration) {
+	c := "error"
+	if code > 0 {
+		c = strconv.Itoa(code)
+	}
+	upstreamDuration.WithLabelValues("supabase", UpstreamOp(path), c).Observe(d.Seconds())
+}
+
+// UpstreamOp names a Supabase call by API and function or table, dropping
+// object paths and ids: "rpc/sentra_push_v1", "rest/sentra_blobs",
+// "storage/object", "auth/token".
+func UpstreamOp(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 3 {
+		return "other"
+	}
+	// parts: <api> "v1" <rest...>
+	api, rest := parts[0], parts[2:]
+	switch {
+	case api == "rest" && rest[0] == "rpc" && len(rest) > 1:
+		return "rpc/" + rest[1]
+	case api == "rest", api == "storage", api == "auth":
+		return api + "/" + rest[0]
+	}
+	return "other"
+}
@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// MemoryLimiter keeps token buckets in this process. It is the default and
+// is exact for a single instance; with several instances each one enforces
+// the limit on its own share of the traffic.
+type MemoryLimiter struct {
+	shards [shardCount]limiterShard
+}
+
+type limiterShard struct {
+	mu      sync.Mutex
+	buckets map[string]tokenBucket
+	expiry  wheel
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	l := &MemoryLimiter{}
+	for i := range l.shards {
+		l.shards[i].buckets = make(map[string]tokenBucket)
+		l.shards[i].expiry = newWheel(time.Minute, 64)
+	}
+	return l
+}
+
+func (l *MemoryLimiter) Take(ctx context.Context, key string, limit Limit) (Decision, error) {
+	return l.take(key, time.Now().UTC(), limit), nil
+}
+
+func (l *MemoryLimiter) take(key string, now time.Time, limit Limit) Decision {
+	s := &l.shards[shardIndex(key)]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expiry.advance(now, func(k string) {
+		b, ok := s.buckets[k]
+		if !ok {
+			return
+		}
+		if now.Sub(b.last) > bucketIdleTTL {
+			delete(s.buckets, k)
+			return
+		}
+		s.expiry.schedule(k, b.last.Add(bucketIdleTTL))
+	})
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = tokenBucket{tokens: limit.Burst, last: now}
+		s.expiry.schedule(key, now.Add(bucketIdleTTL))
+	}
+
+	// Refill.
+	if now.After(b.last) {
+		b.tokens = math.Min(limit.Burst, b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+		b.last = now
+	}
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens -= 1
+	}
+	s.buckets[key] = b
+	return decide(allowed, b.tokens, limit)
+}
+
+// MemoryNonceStore keeps nonces in this process. A replay sent to another
+// instance is not caught; use a shared store when running more than one.
+type MemoryNonceStore struct {
+	shards [shardCount]nonceShard
+}
+
+type nonceShard struct {
+	mu      sync.Mutex
+	expires map[string]time.Time
+	expiry  wheel
+}
+
+func NewMemoryNonceStore() *MemoryNonceStore {
+	n := &MemoryNonceStore{}
+	for i := range n.shards {
+		n.shards[i].expires = make(map[string]time.Time)
+		n.shards[i].expiry = newWheel(10*time.Second, 128)
+	}
+	return n
+}
+
+func (n *MemoryNonceStore) SeenOrMark(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	s := &n.shards[shardIndex(key)]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expiry.advance(now, func(k string) {
+		exp, ok := s.expires[k]
+		if !ok {
+			return
+		}
+		if !now.Before(exp) {
+			delete(s.expires, k)
+			return
+		}
+		s.expiry.schedule(k, exp)
+	})
+
+	if exp, ok := s.expires[key]; ok && now.Before(exp) {
+		return true, nil
+	}
+	exp := now.Add(ttl)
+	s.expires[key] = exp
+	s.expiry.schedule(key, exp)
+	return false, nil
+}
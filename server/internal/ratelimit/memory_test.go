@@ -0,0 +1,142 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMemoryLimiterRefillAndBurst(t *testing.T) {
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	limit := Limit{Rate: 1, Burst: 3}
+
+	steps := []struct {
+		name       string
+		at         time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+		reset      time.Duration
+	}{
+		{name: "first request spends from a full bucket", at: 0, allowed: true, remaining: 2, reset: time.Second},
+		{name: "burst", at: 0, allowed: true, remaining: 1, reset: 2 * time.Second},
+		{name: "burst exhausted", at: 0, allowed: true, remaining: 0, reset: 3 * time.Second},
+		{name: "empty bucket refuses", at: 0, allowed: false, remaining: 0, retryAfter: time.Second, reset: 3 * time.Second},
+		{name: "half a token is not enough", at: 500 * time.Millisecond, allowed: false, remaining: 0, retryAfter: time.Second, reset: 3 * time.Second},
+		{name: "one token refilled", at: time.Second, allowed: true, remaining: 0, reset: 3 * time.Second},
+		{name: "refill stops at burst", at: time.Minute, allowed: true, remaining: 2, reset: time.Second},
+	}
+
+	l := NewMemoryLimiter()
+	for _, s := range steps {
+		d := l.take("push:user", start.Add(s.at), limit)
+		if d.Allowed != s.allowed || d.Remaining != s.remaining || d.RetryAfter != s.retryAfter || d.Reset != s.reset {
+			t.Fatalf("%s: got %+v; want allowed=%v remaining=%d retryAfter=%v reset=%v",
+				s.name, d, s.allowed, s.remaining, s.retryAfter, s.reset)
+		}
+	}
+
+	if d := l.take("push:other", start, limit); !d.Allowed || d.Remaining != 2 {
+		t.Fatalf("another key shares the bucket: %+v", d)
+	}
+}
+
+func TestMemoryLimiterDropsIdleBuckets(t *testing.T) {
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	limit := Limit{Rate: 0.001, Burst: 1}
+
+	tests := []struct {
+		name string
+		// touch is when the key is used again after its first request; zero
+		// leaves it idle.
+		touch time.Duration
+		// later is when the shard is next advanced.
+		later time.Duration
+		kept  bool
+	}{
+		{name: "idle past the TTL", later: bucketIdleTTL + 2*time.Minute, kept: false},
+		{name: "not idle long enough", later: bucketIdleTTL - 2*time.Minute, kept: true},
+		{name: "touched since it was scheduled", touch: 20 * time.Minute, later: bucketIdleTTL + 2*time.Minute, kept: true},
+		{name: "after a pause longer than the wheel", later: 5 * time.Hour, kept: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewMemoryLimiter()
+			l.take("k", start, limit)
+			if tt.touch > 0 {
+				l.take("k", start.Add(tt.touch), limit)
+			}
+			// Another key in the same shard advances its wheel.
+			other := keyInShard(t, shardIndex("k"), "k")
+			l.take(other, start.Add(tt.later), limit)
+
+			s := &l.shards[shardIndex("k")]
+			if _, ok := s.buckets["k"]; ok != tt.kept {
+				t.Fatalf("bucket kept = %v; want %v", ok, tt.kept)
+			}
+		})
+	}
+}
+
+func TestWheelSchedulesPastHorizonInLastSlot(t *testing.T) {
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	w := newWheel(time.Minute, 4)
+	w.advance(start, nil)
+	w.schedule("far", start.Add(time.Hour))
+
+	var due []string
+	w.advance(start.Add(3*time.Minute), func(k string) { due = append(due, k) })
+	if len(due) != 0 {
+		t.Fatalf("due before the last slot: %v", due)
+	}
+	w.advance(start.Add(4*time.Minute), func(k string) { due = append(due, k) })
+	if len(due) != 1 || due[0] != "far" {
+		t.Fatalf("due = %v; want [far]", due)
+	}
+}
+
+func TestMemoryNonceStore(t *testing.T) {
+	ctx := context.Background()
+	n := NewMemoryNonceStore()
+
+	tests := []struct {
+		name string
+		key  string
+		ttl  time.Duration
+		seen bool
+	}{
+		{name: "first use", key: "user:nonce-a", ttl: time.Minute, seen: false},
+		{name: "replay", key: "user:nonce-a", ttl: time.Minute, seen: true},
+		{name: "replay again", key: "user:nonce-a", ttl: time.Minute, seen: true},
+		{name: "another nonce", key: "user:nonce-b", ttl: time.Minute, seen: false},
+		{name: "same nonce of another user", key: "other:nonce-a", ttl: time.Minute, seen: false},
+	}
+	for _, tt := range tests {
+		seen, err := n.SeenOrMark(ctx, tt.key, tt.ttl)
+		if err != nil || seen != tt.seen {
+			t.Fatalf("%s: SeenOrMark = %v, %v; want %v, nil", tt.name, seen, err, tt.seen)
+		}
+	}
+
+	if seen, _ := n.SeenOrMark(ctx, "user:short", time.Millisecond); seen {
+		t.Fatal("fresh nonce reported as seen")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if seen, _ := n.SeenOrMark(ctx, "user:short", time.Minute); seen {
+		t.Fatal("expired nonce reported as a replay")
+	}
+}
+
+// keyInShard returns a key other than not that hashes to shard.
+func keyInShard(t *testing.T, shard int, not string) string {
+	t.Helper()
+	for i := 0; i < 10000; i++ {
+		k := "probe-" + strconv.Itoa(i)
+		if k != not && shardIndex(k) == shard {
+			return k
+		}
+	}
+	t.Fatal("no key found for shard")
+	return ""
+}
@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit is a token bucket: Burst requests at once, refilled at Rate per
+// second.
+type Limit struct {
+	Rate  float64
+	Burst float64
+}
+
+// PerMinute returns a Limit of rpm requests per minute with the given burst.
+func PerMinute(rpm int64, burst float64) Limit {
+	return Limit{Rate: float64(rpm) / 60.0, Burst: burst}
+}
+
+// Decision is the outcome of one Take. Remaining and Reset feed the
+// X-RateLimit-* headers.
+type Decision struct {
+	Allowed bool
+	// RetryAfter is when the next token is available. Zero when Allowed.
+	RetryAfter time.Duration
+	// Remaining is the number of whole tokens left after this request.
+	Remaining int
+	// Reset is when the bucket is full again.
+	Reset time.Duration
+}
+
+// Limiter spends one token from the bucket for key. Keys are namespaced by
+// the caller ("push:<user id>"), so one Limiter serves every route.
+type Limiter interface {
+	Take(ctx context.Context, key string, limit Limit) (Decision, error)
+}
+
+// NonceStore remembers signed-request nonces for ttl. SeenOrMark reports
+// whether key was already marked; otherwise it marks it. Shared stores
+// (Postgres, Redis) catch a replay that lands on another instance.
+type NonceStore interface {
+	SeenOrMark(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// bucketIdleTTL is how long an untouched bucket is kept. A bucket idle this
+// long has refilled for any practical limit, so dropping it is invisible.
+const bucketIdleTTL = 30 * time.Minute
+
+// decide builds the Decision for a bucket holding tokens after the request
+// was allowed or refused.
+func decide(allowed bool, tokens float64, limit Limit) Decision {
+	d := Decision{Allowed: allowed, Remaining: int(math.Max(0, math.Floor(tokens)))}
+	if !allowed {
+		d.RetryAfter = retryAfter(tokens, limit.Rate)
+	}
+	if missing := limit.Burst - tokens; missing > 0 && limit.Rate > 0 {
+		d.Reset = time.Duration(math.Ceil(missing/limit.Rate)) * time.Second
+	}
+	return d
+}
+
+// retryAfter rounds the wait for the missing fraction of a token up to
+// whole seconds, as Retry-After needs.
+func retryAfter(tokens float64, rate float64) time.Duration {
+	if rate <= 0 {
+		return bucketIdleTTL
+	}
+	return time.Duration(math.Ceil((1-tokens)/rate)) * time.Second
+}
@@ -0,0 +1,198 @@
+package ratelimit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeConn is a net.Conn that answers with canned RESP replies and records
+// what was written.
+type fakeConn struct {
+	net.Conn
+	replies io.Reader
+	written bytes.Buffer
+	closed  bool
+}
+
+func (c *fakeConn) Read(p []byte) (int, error)         { return c.replies.Read(p) }
+func (c *fakeConn) Write(p []byte) (int, error)        { return c.written.Write(p) }
+func (c *fakeConn) Close() error                       { c.closed = true; return nil }
+func (c *fakeConn) SetDeadline(t time.Time) error      { return nil }
+func (c *fakeConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *fakeConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// fakeRedis returns a client whose only idle connection is conn.
+func fakeRedis(replies string) (*RedisClient, *fakeConn) {
+	conn := &fakeConn{replies: strings.NewReader(replies)}
+	c := &RedisClient{addr: "127.0.0.1:0"}
+	c.idle = []*redisConn{{c: conn, r: bufio.NewReader(conn)}}
+	return c, conn
+}
+
+func TestReadReply(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   string
+		want    any
+		wantErr error
+	}{
+		{name: "simple string", reply: "+OK\r\n", want: "OK"},
+		{name: "error", reply: "-ERR unknown command\r\n", wantErr: RedisError("ERR unknown command")},
+		{name: "integer", reply: ":42\r\n", want: int64(42)},
+		{name: "negative integer", reply: ":-1\r\n", want: int64(-1)},
+		{name: "bulk string", reply: "$5\r\nhello\r\n", want: "hello"},
+		{name: "bulk string with CRLF inside", reply: "$4\r\na\r\nb\r\n", want: "a\r\nb"},
+		{name: "empty bulk string", reply: "$0\r\n\r\n", want: ""},
+		{name: "nil bulk string", reply: "$-1\r\n", want: nil},
+		{name: "nil array", reply: "*-1\r\n", want: nil},
+		{name: "array", reply: "*2\r\n:1\r\n$3\r\n2.5\r\n", want: []any{int64(1), "2.5"}},
+		{name: "array with nil and error", reply: "*2\r\n$-1\r\n-WRONGTYPE\r\n", want: []any{nil, RedisError("WRONGTYPE")}},
+		{name: "nested array", reply: "*1\r\n*1\r\n+x\r\n", want: []any{[]any{"x"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readReply(bufio.NewReader(strings.NewReader(tt.reply)))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v; want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readReply: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("reply = %#v; want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadReplyMalformed(t *testing.T) {
+	replies := []string{
+		"",
+		"+OK\n",
+		"?what\r\n",
+		":abc\r\n",
+		"$x\r\n",
+		"$5\r\nabc\r\n",
+		"*x\r\n",
+		"*2\r\n:1\r\n",
+	}
+	for _, reply := range replies {
+		t.Run(reply, func(t *testing.T) {
+			_, err := readReply(bufio.NewReader(strings.NewReader(reply)))
+			var rerr RedisError
+			if err == nil || errors.As(err, &rerr) {
+				t.Fatalf("err = %v; want a protocol error", err)
+			}
+		})
+	}
+}
+
+func TestRedisClientDo(t *testing.T) {
+	ctx := context.Background()
+
+	c, conn := fakeRedis("+PONG\r\n")
+	if got, err := c.Do(ctx, "PING"); err != nil || got != "PONG" {
+		t.Fatalf("Do = %v, %v; want PONG, nil", got, err)
+	}
+	if got, want := conn.written.String(), "*1\r\n$4\r\nPING\r\n"; got != want {
+		t.Fatalf("wrote %q; want %q", got, want)
+	}
+	if len(c.idle) != 1 || conn.closed {
+		t.Fatal("connection not returned to the pool")
+	}
+
+	// An error reply leaves the stream in sync, so the connection is reused.
+	c, conn = fakeRedis("-ERR bad\r\n")
+	if _, err := c.Do(ctx, "GET", "k"); !errors.Is(err, RedisError("ERR bad")) {
+		t.Fatalf("Do err = %v; want the error reply", err)
+	}
+	if len(c.idle) != 1 || conn.closed {
+		t.Fatal("connection dropped after an error reply")
+	}
+
+	// A protocol error may leave a partial reply behind, so the connection
+	// is closed.
+	c, conn = fakeRedis("$5\r\nab")
+	if _, err := c.Do(ctx, "GET", "k"); err == nil {
+		t.Fatal("Do succeeded on a truncated reply")
+	}
+	if len(c.idle) != 0 || !conn.closed {
+		t.Fatal("connection reused after a truncated reply")
+	}
+}
+
+func TestRedisLimiterTake(t *testing.T) {
+	ctx := context.Background()
+	limit := Limit{Rate: 1, Burst: 5}
+
+	tests := []struct {
+		name    string
+		reply   string
+		want    Decision
+		wantErr bool
+	}{
+		{name: "allowed", reply: "*2\r\n:1\r\n$3\r\n3.5\r\n", want: Decision{Allowed: true, Remaining: 3, Reset: 2 * time.Second}},
+		{name: "refused", reply: "*2\r\n:0\r\n$4\r\n0.25\r\n", want: Decision{Allowed: false, RetryAfter: time.Second, Reset: 5 * time.Second}},
+		{name: "script error", reply: "-NOSCRIPT\r\n", wantErr: true},
+		{name: "short array", reply: "*1\r\n:1\r\n", wantErr: true},
+		{name: "bad token count", reply: "*2\r\n:1\r\n$3\r\nabc\r\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, conn := fakeRedis(tt.reply)
+			got, err := NewRedisLimiter(c).Take(ctx, "push:user", limit)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Take = %+v; want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Take: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("Take = %+v; want %+v", got, tt.want)
+			}
+			if !strings.Contains(conn.written.String(), "sentra:rl:push:user") {
+				t.Fatalf("bucket key not sent: %q", conn.written.String())
+			}
+		})
+	}
+}
+
+func TestRedisNonceStore(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		reply string
+		seen  bool
+	}{
+		{name: "marked", reply: "+OK\r\n", seen: false},
+		{name: "replay", reply: "$-1\r\n", seen: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, conn := fakeRedis(tt.reply)
+			seen, err := NewRedisNonceStore(c).SeenOrMark(ctx, "user:n1", 90*time.Second)
+			if err != nil || seen != tt.seen {
+				t.Fatalf("SeenOrMark = %v, %v; want %v, nil", seen, err, tt.seen)
+			}
+			want := "*6\r\n$3\r\nSET\r\n$20\r\nsentra:nonce:user:n1\r\n$1\r\n1\r\n$2\r\nNX\r\n$2\r\nPX\r\n$5\r\n90000\r\n"
+			if got := conn.written.String(); got != want {
+				t.Fatalf("wrote %q; want %q", got, want)
+			}
+		})
+	}
+}
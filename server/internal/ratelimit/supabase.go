@@ -0,0 +1,108 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/mgeovany/sentra/server/internal/supabase"
+)
+
+// SupabaseLimiter keeps buckets in Postgres (table sentra_rate_limits), so
+// every instance shares them.
+type SupabaseLimiter struct {
+	client *supabase.Client
+	fn     string
+}
+
+// NewSupabaseLimiter calls fn(p_key text, p_rate double precision, p_burst
+// double precision), which refills and spends from the bucket in one
+// statement under a row lock and returns one row {"allowed": bool,
+// "tokens": double precision}, the tokens left after the request. It also
+// deletes buckets idle for 30 minutes.
+func NewSupabaseLimiter(client *supabase.Client, fn string) SupabaseLimiter {
+	if fn == "" {
+		fn = "sentra_rate_limit_take_v1"
+	}
+	return SupabaseLimiter{client: client, fn: fn}
+}
+
+func (s SupabaseLimiter) Take(ctx context.Context, key string, limit Limit) (Decision, error) {
+	if s.client == nil {
+		return Decision{}, fmt.Errorf("rate limit store not configured")
+	}
+
+	body := map[string]any{
+		"p_key":   key,
+		"p_rate":  limit.Rate,
+		"p_burst": limit.Burst,
+	}
+	headers := map[string]string{
+		"Accept": "application/json",
+	}
+	resp, respBody, err := s.client.PostJSON(ctx, s.client.RPCURL(s.fn), body, headers)
+	if err != nil {
+		return Decision{}, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return Decision{}, fmt.Errorf("supabase rpc rate limit failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	// PostgREST returns an array for RPC results.
+	var rows []struct {
+		Allowed bool    `json:"allowed"`
+		Tokens  float64 `json:"tokens"`
+	}
+	if err := supabase.UnmarshalJSON(respBody, &rows); err != nil {
+		return Decision{}, err
+	}
+	if len(rows) == 0 {
+		return Decision{}, fmt.Errorf("supabase rpc rate limit returned empty result")
+	}
+	return decide(rows[0].Allowed, rows[0].Tokens, limit), nil
+}
+
+// SupabaseNonceStore keeps nonces in Postgres (table sentra_nonces).
+type SupabaseNonceStore struct {
+	client *supabase.Client
+	fn     string
+}
+
+// NewSupabaseNonceStore calls fn(p_key text, p_ttl_seconds integer), which
+// inserts the nonce unless an unexpired row exists and returns true when it
+// did exist (a replay). It also deletes expired rows.
+func NewSupabaseNonceStore(client *supabase.Client, fn string) SupabaseNonceStore {
+	if fn == "" {
+		fn = "sentra_nonce_mark_v1"
+	}
+	return SupabaseNonceStore{client: client, fn: fn}
+}
+
+func (s SupabaseNonceStore) SeenOrMark(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if s.client == nil {
+		return false, fmt.Errorf("nonce store not configured")
+	}
+
+	body := map[string]any{
+		"p_key":         key,
+		"p_ttl_seconds": int64(math.Ceil(ttl.Seconds())),
+	}
+	headers := map[string]string{
+		"Accept": "application/json",
+	}
+	resp, respBody, err := s.client.PostJSON(ctx, s.client.RPCURL(s.fn), body, headers)
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return false, fmt.Errorf("supabase rpc nonce mark failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var seen bool
+	if err := supabase.UnmarshalJSON(respBody, &seen); err != nil {
+		return false, err
+	}
+	return seen, nil
+}
@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"hash/fnv"
+	"time"
+)
+
+// shardCount spreads keys over independent locks so concurrent requests for
+// different users rarely contend.
+const shardCount = 32
+
+func shardIndex(key string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % shardCount)
+}
+
+// wheel schedules keys by expiry in fixed ticks. Advancing it only visits
+// the slots that came due since the last call, so cleanup costs O(expired
+// keys) instead of a sweep over every key on each request.
+//
+// A key is scheduled once, when it is created. When its slot comes due the
+// owner checks the real expiry and reschedules keys that were touched since,
+// so a busy key is never in more than one slot.
+type wheel struct {
+	tick  time.Duration
+	slots []map[string]struct{}
+	// next is the first tick not swept yet; zero until first use.
+	next int64
+}
+
+func newWheel(tick time.Duration, slots int) wheel {
+	return wheel{tick: tick, slots: make([]map[string]struct{}, slots)}
+}
+
+func (w *wheel) tickOf(t time.Time) int64 {
+	return t.UnixNano() / int64(w.tick)
+}
+
+// schedule adds key to the slot of at. Times past the wheel's horizon land
+// in its last slot and are rescheduled from there.
+func (w *wheel) schedule(key string, at time.Time) {
+	t := w.tickOf(at)
+	if t < w.next {
+		t = w.next
+	}
+	if last := w.next + int64(len(w.slots)) - 1; t > last {
+		t = last
+	}
+	i := t % int64(len(w.slots))
+	if w.slots[i] == nil {
+		w.slots[i] = make(map[string]struct{})
+	}
+	w.slots[i][key] = struct{}{}
+}
+
+// advance sweeps every tick that ended before now and calls due for each key
+// in it. due may call schedule; rescheduled keys go to a later tick.
+func (w *wheel) advance(now time.Time, due func(key string)) {
+	cur := w.tickOf(now)
+	if w.next == 0 {
+		w.next = cur
+		return
+	}
+	// After a long pause one revolution visits every slot.
+	if n := int64(len(w.slots)); cur-w.next > n {
+		w.next = cur - n
+	}
+	for w.next < cur {
+		i := w.next % int64(len(w.slots))
+		keys := w.slots[i]
+		w.slots[i] = nil
+		w.next++
+		for k := range keys {
+			due(k)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package repo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// HostedBlob is one object in hosted blob storage. RootHash is the hex
+// SHA-256 of the project root, as in the object path.
+type HostedBlob struct {
+	UserID    string
+	RootHash  string
+	ID        string
+	UpdatedAt time.Time
+}
+
+// BlobLister enumerates hosted blobs so SweepHostedBlobs can delete the ones
+// no file row references. SupabaseBlobStore implements it.
+type BlobLister interface {
+	ListBlobUsers(ctx context.Context) ([]string, error)
+	ListUserBlobs(ctx context.Context, userID string) ([]HostedBlob, error)
+	DeleteHostedBlob(ctx context.Context, b HostedBlob) error
+}
+
+// SweepHostedBlobs deletes hosted blobs that no file row references and that
+// were last written more than grace ago. Uploads precede the push that
+// references them, so grace must cover the longest push. It returns how many
+// blobs were deleted.
+//
+// Blobs are listed before the references are read, so a push that
+// references an old blob before its user's references are read keeps it. A
+// push that reuses an old unreferenced blob (same content, so no new upload)
+// between that read and the delete can still lose it.
+func SweepHostedBlobs(ctx context.Context, blobs BlobLister, refs StorageRefStore, grace time.Duration) (int, error) {
+	users, err := blobs.ListBlobUsers(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var deleted int
+	var errs []error
+	for _, userID := range users {
+		n, err := sweepUserBlobs(ctx, blobs, refs, userID, time.Now().Add(-grace))
+		deleted += n
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return deleted, errors.Join(errs...)
+}
+
+func sweepUserBlobs(ctx context.Context, blobs BlobLister, refs StorageRefStore, userID string, cutoff time.Time) (int, error) {
+	listed, err := blobs.ListUserBlobs(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	var old []HostedBlob
+	for _, b := range listed {
+		if b.UpdatedAt.Before(cutoff) {
+			old = append(old, b)
+		}
+	}
+	if len(old) == 0 {
+		return 0, nil
+	}
+
+	keys, err := refs.ListStorageKeys(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	// Hosted storage keys are "<root>/<id>"; objects are stored under the
+	// root's hash. Keys of other providers never match.
+	referenced := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		root, id, ok := strings.Cut(k, "/")
+		if !ok {
+			continue
+		}
+		sum := sha256.Sum256([]byte(root))
+		referenced[hex.EncodeToString(sum[:])+"/"+id] = true
+	}
+
+	var deleted int
+	for _, b := range old {
+		if referenced[b.RootHash+"/"+b.ID] {
+			continue
+		}
+		if err := blobs.DeleteHostedBlob(ctx, b); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// RunBlobSweeper runs SweepHostedBlobs every interval until ctx is done.
+func RunBlobSweeper(ctx context.Context, blobs BlobLister, refs StorageRefStore, interval time.Duration, grace time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		n, err := SweepHostedBlobs(ctx, blobs, refs, grace)
+		if err != nil && ctx.Err() == nil {
+			slog.WarnContext(ctx, "hosted blob sweep failed", "deleted", n, "err", err)
+			continue
+		}
+		if n > 0 {
+			slog.InfoContext(ctx, "hosted blob sweep", "deleted", n)
+		}
+	}
+}
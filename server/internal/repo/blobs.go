@@ -0,0 +1,314 @@
+package repo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mgeovany/sentra/server/internal/supabase"
+)
+
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore holds hosted-mode ciphertexts. The CLI uploads each blob before
+// the push that references it, so push requests carry only metadata. Blobs
+// are namespaced by user and project root; id is chosen by the client.
+type BlobStore interface {
+	BlobExists(ctx context.Context, userID string, root string, id string) (bool, error)
+	PutBlob(ctx context.Context, userID string, root string, id string, body io.Reader, size int64) error
+	// OpenBlob returns the blob and its size. The caller closes it.
+	OpenBlob(ctx context.Context, userID string, root string, id string) (io.ReadCloser, int64, error)
+	// DeleteBlob removes a blob. Deleting a missing blob is not an error.
+	DeleteBlob(ctx context.Context, userID string, root string, id string) error
+}
+
+type DisabledBlobStore struct{}
+
+func (DisabledBlobStore) BlobExists(ctx context.Context, userID string, root string, id string) (bool, error) {
+	return false, ErrDBNotConfigured
+}
+
+func (DisabledBlobStore) PutBlob(ctx context.Context, userID string, root string, id string, body io.Reader, size int64) error {
+	return ErrDBNotConfigured
+}
+
+func (DisabledBlobStore) OpenBlob(ctx context.Context, userID string, root string, id string) (io.ReadCloser, int64, error) {
+	return nil, 0, ErrDBNotConfigured
+}
+
+func (DisabledBlobStore) DeleteBlob(ctx context.Context, userID string, root string, id string) error {
+	return ErrDBNotConfigured
+}
+
+type SupabaseBlobStore struct {
+	client *supabase.Client
+	bucket string
+}
+
+// NewSupabaseBlobStore stores blobs in a private Supabase Storage bucket
+// (created by the sentra_blobs migration) as <user_id>/<sha256(root)>/<id>.
+func NewSupabaseBlobStore(client *supabase.Client, bucket string) SupabaseBlobStore {
+	if bucket == "" {
+		bucket = "sentra-blobs"
+	}
+	return SupabaseBlobStore{client: client, bucket: bucket}
+}
+
+func (s SupabaseBlobStore) objectPath(userID string, root string, id string) (string, error) {
+	userID = strings.TrimSpace(userID)
+	root = strings.TrimSpace(root)
+	id = strings.TrimSpace(id)
+	if userID == "" || root == "" || id == "" {
+		return "", fmt.Errorf("invalid blob request")
+	}
+	// Roots are user-chosen directory names; hashing keeps object paths plain.
+	sum := sha256.Sum256([]byte(root))
+	return s.bucket + "/" + userID + "/" + hex.EncodeToString(sum[:]) + "/" + id, nil
+}
+
+func (s SupabaseBlobStore) BlobExists(ctx context.Context, userID string, root string, id string) (bool, error) {
+	if s.client == nil {
+		return false, ErrDBNotConfigured
+	}
+	p, err := s.objectPath(userID, root, id)
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, s.client.StorageURL("object/authenticated/"+p), nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := s.client.DoStream(req)
+	if err != nil {
+		return false, err
+	}
+	_ = resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return true, nil
+	case storageNotFound(resp.StatusCode):
+		return false, nil
+	}
+	return false, fmt.Errorf("supabase storage head failed: status=%d", resp.StatusCode)
+}
+
+func (s SupabaseBlobStore) PutBlob(ctx context.Context, userID string, root string, id string, body io.Reader, size int64) error {
+	if s.client == nil {
+		return ErrDBNotConfigured
+	}
+	p, err := s.objectPath(userID, root, id)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.client.StorageURL("object/"+p), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	// Ids are content addresses, so replacing an existing object is harmless.
+	req.Header.Set("x-upsert", "true")
+
+	resp, err := s.client.DoStream(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("supabase storage upload failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+func (s SupabaseBlobStore) OpenBlob(ctx context.Context, userID string, root string, id string) (io.ReadCloser, int64, error) {
+	if s.client == nil {
+		return nil, 0, ErrDBNotConfigured
+	}
+	p, err := s.objectPath(userID, root, id)
+	if err != nil {
+		return nil, 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.client.StorageURL("object/authenticated/"+p), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := s.client.DoStream(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		_ = resp.Body.Close()
+		if storageNotFound(resp.StatusCode) {
+			return nil, 0, ErrBlobNotFound
+		}
+		return nil, 0, fmt.Errorf("supabase storage download failed: status=%d", resp.StatusCode)
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+func (s SupabaseBlobStore) DeleteBlob(ctx context.Context, userID string, root string, id string) error {
+	if s.client == nil {
+		return ErrDBNotConfigured
+	}
+	p, err := s.objectPath(userID, root, id)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.client.StorageURL("object/"+p), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.DoStream(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 || storageNotFound(resp.StatusCode) {
+		return nil
+	}
+	return fmt.Errorf("supabase storage delete failed: status=%d", resp.StatusCode)
+}
+
+// Ping checks that the blob bucket exists and is reachable.
+func (s SupabaseBlobStore) Ping(ctx context.Context) error {
+	if s.client == nil {
+		return ErrDBNotConfigured
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.client.StorageURL("bucket/"+s.bucket), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.DoStream(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("supabase storage bucket check failed: status=%d", resp.StatusCode)
+	}
+	return nil
+}
+
+// storageNotFound reports a missing object. Supabase Storage answers 400
+// instead of 404 for missing objects on some versions.
+func storageNotFound(status int) bool {
+	return status == http.StatusNotFound || status == http.StatusBadRequest
+}
+
+// ListBlobUsers returns the user ids that have hosted blobs.
+func (s SupabaseBlobStore) ListBlobUsers(ctx context.Context) ([]string, error) {
+	entries, err := s.list(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	var users []string
+	for _, e := range entries {
+		if e.ID == nil {
+			users = append(users, e.Name)
+		}
+	}
+	return users, nil
+}
+
+// ListUserBlobs returns every hosted blob of a user, across projects.
+func (s SupabaseBlobStore) ListUserBlobs(ctx context.Context, userID string) ([]HostedBlob, error) {
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return nil, fmt.Errorf("invalid blob list request")
+	}
+	roots, err := s.list(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var out []HostedBlob
+	for _, r := range roots {
+		if r.ID != nil {
+			continue
+		}
+		objects, err := s.list(ctx, userID+"/"+r.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range objects {
+			if o.ID == nil {
+				continue
+			}
+			out = append(out, HostedBlob{UserID: userID, RootHash: r.Name, ID: o.Name, UpdatedAt: o.UpdatedAt})
+		}
+	}
+	return out, nil
+}
+
+// DeleteHostedBlob removes a blob listed by ListUserBlobs. Deleting a missing
+// blob is not an error.
+func (s SupabaseBlobStore) DeleteHostedBlob(ctx context.Context, b HostedBlob) error {
+	if s.client == nil {
+		return ErrDBNotConfigured
+	}
+	if b.UserID == "" || b.RootHash == "" || b.ID == "" {
+		return fmt.Errorf("invalid blob request")
+	}
+	p := s.bucket + "/" + b.UserID + "/" + b.RootHash + "/" + b.ID
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.client.StorageURL("object/"+p), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.DoStream(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 || storageNotFound(resp.StatusCode) {
+		return nil
+	}
+	return fmt.Errorf("supabase storage delete failed: status=%d", resp.StatusCode)
+}
+
+// storageEntry is one item of a Supabase Storage listing. Folders have no id.
+type storageEntry struct {
+	Name      string    `json:"name"`
+	ID        *string   `json:"id"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// list returns the entries directly under prefix, a page at a time.
+func (s SupabaseBlobStore) list(ctx context.Context, prefix string) ([]storageEntry, error) {
+	if s.client == nil {
+		return nil, ErrDBNotConfigured
+	}
+	const page = 1000
+	headers := map[string]string{
+		"Accept": "application/json",
+	}
+	var out []storageEntry
+	for offset := 0; ; offset += page {
+		body := map[string]any{
+			"prefix": prefix,
+			"limit":  page,
+			"offset": offset,
+			"sortBy": map[string]string{"column": "name", "order": "asc"},
+		}
+		resp, respBody, err := s.client.PostJSON(ctx, s.client.StorageURL("object/list/"+s.bucket), body, headers)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return nil, fmt.Errorf("supabase storage list failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+		}
+		var entries []storageEntry
+		if err := supabase.UnmarshalJSON(respBody, &entries); err != nil {
+			return nil, err
+		}
+		out = append(out, entries...)
+		if len(entries) < page {
+			return out, nil
+		}
+	}
+}
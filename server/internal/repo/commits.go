@@ -2,9 +2,12 @@ package repo
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/mgeovany/sentra/server/internal/supabase"
 )
@@ -23,14 +26,42 @@ type CommitInfo struct {
 	FileCount   int    `json:"file_count"`
 }
 
+// ErrInvalidCursor is returned for a CommitQuery.Cursor this store did not
+// issue.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// CommitQuery selects commits, newest first. Zero fields do not filter.
+type CommitQuery struct {
+	// Root limits the commits to one project; empty lists every project.
+	Root string
+	// Limit caps the page size; zero returns every matching commit.
+	Limit int
+	// Cursor continues after the last commit of a previous page.
+	Cursor string
+	Since  time.Time
+	Until  time.Time
+	// MachineID keeps commits pushed from that machine.
+	MachineID string
+	// Path keeps commits that touch that file path.
+	Path string
+	// Message keeps commits whose message contains it, ignoring case.
+	Message string
+}
+
+// CommitPage is one page of commits. NextCursor is empty on the last page.
+type CommitPage struct {
+	Commits    []CommitInfo
+	NextCursor string
+}
+
 type CommitStore interface {
-	ListCommits(ctx context.Context, userID string, root string) ([]CommitInfo, error)
+	ListCommits(ctx context.Context, userID string, q CommitQuery) (CommitPage, error)
 }
 
 type DisabledCommitStore struct{}
 
-func (DisabledCommitStore) ListCommits(ctx context.Context, userID string, root string) ([]CommitInfo, error) {
-	return nil, ErrDBNotConfigured
+func (DisabledCommitStore) ListCommits(ctx context.Context, userID string, q CommitQuery) (CommitPage, error) {
+	return CommitPage{}, ErrDBNotConfigured
 }
 
 type SupabaseCommitStore struct {
@@ -38,46 +69,110 @@ type SupabaseCommitStore struct {
 	fn     string
 }
 
+// NewSupabaseCommitStore calls fn(p_user_id uuid, p_root text, p_limit
+// integer, p_before_created_at timestamptz, p_before_commit_id uuid, p_since
+// timestamptz, p_until timestamptz, p_machine_id text, p_path text,
+// p_message text). Null arguments do not filter. It returns the matching
+// commits ordered by (created_at, commit_id) descending, starting below the
+// "before" pair when it is set; p_message matches with ilike. The function
+// is defined in supabase/migrations/20261018170000_commits_v2.sql; see
+// supabase/UPGRADING.md for databases that only have sentra_commits_v1.
 func NewSupabaseCommitStore(client *supabase.Client, fn string) SupabaseCommitStore {
 	if fn == "" {
-		fn = "sentra_commits_v1"
+		fn = "sentra_commits_v2"
 	}
 	return SupabaseCommitStore{client: client, fn: fn}
 }
 
-func (s SupabaseCommitStore) ListCommits(ctx context.Context, userID string, root string) ([]CommitInfo, error) {
+func (s SupabaseCommitStore) ListCommits(ctx context.Context, userID string, q CommitQuery) (CommitPage, error) {
 	if s.client == nil {
-		return nil, ErrDBNotConfigured
+		return CommitPage{}, ErrDBNotConfigured
 	}
 	userID = strings.TrimSpace(userID)
-	root = strings.TrimSpace(root)
-	if userID == "" || root == "" {
-		return nil, fmt.Errorf("invalid commits request")
+	if userID == "" || q.Limit < 0 {
+		return CommitPage{}, fmt.Errorf("invalid commits request")
 	}
 
-	url := s.client.RPCURL(s.fn)
 	body := map[string]any{
-		"p_user_id": userID,
-		"p_root":    root,
+		"p_user_id":    userID,
+		"p_root":       nullIfEmpty(q.Root),
+		"p_machine_id": nullIfEmpty(q.MachineID),
+		"p_path":       nullIfEmpty(q.Path),
+		"p_message":    nullIfEmpty(q.Message),
+		"p_since":      nullIfZero(q.Since),
+		"p_until":      nullIfZero(q.Until),
+	}
+	if q.Limit > 0 {
+		// One extra row tells whether another page follows.
+		body["p_limit"] = q.Limit + 1
+	}
+	if q.Cursor != "" {
+		createdAt, commitID, err := decodeCommitCursor(q.Cursor)
+		if err != nil {
+			return CommitPage{}, err
+		}
+		body["p_before_created_at"] = createdAt
+		body["p_before_commit_id"] = commitID
 	}
 	headers := map[string]string{
 		"Accept": "application/json",
 		"Prefer": "return=representation",
 	}
 
-	resp, respBody, err := s.client.PostJSON(ctx, url, body, headers)
+	resp, respBody, err := s.client.PostJSON(ctx, s.client.RPCURL(s.fn), body, headers)
 	if err != nil {
-		return nil, err
+		return CommitPage{}, err
 	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("supabase rpc commits failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+		return CommitPage{}, fmt.Errorf("supabase rpc commits failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(respBody)))
 	}
 
 	var out []CommitInfo
 	if err := supabase.UnmarshalJSON(respBody, &out); err != nil {
-		return nil, err
+		return CommitPage{}, err
+	}
+	page := CommitPage{Commits: out}
+	if q.Limit > 0 && len(out) > q.Limit {
+		page.Commits = out[:q.Limit]
+		last := page.Commits[q.Limit-1]
+		page.NextCursor = encodeCommitCursor(last.CreatedAt, last.CommitID)
+	}
+	return page, nil
+}
+
+// Cursors are the (created_at, commit_id) of the last commit returned,
+// which is the keyset the RPC orders by.
+func encodeCommitCursor(createdAt string, commitID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strings.TrimSpace(createdAt) + "|" + strings.TrimSpace(commitID)))
+}
+
+func decodeCommitCursor(cursor string) (string, string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(cursor))
+	if err != nil {
+		return "", "", ErrInvalidCursor
+	}
+	createdAt, commitID, ok := strings.Cut(string(b), "|")
+	if !ok || commitID == "" {
+		return "", "", ErrInvalidCursor
+	}
+	if _, err := time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return "", "", ErrInvalidCursor
+	}
+	return createdAt, commitID, nil
+}
+
+func nullIfEmpty(s string) any {
+	if s = strings.TrimSpace(s); s != "" {
+		return s
+	}
+	return nil
+}
+
+func nullIfZero(t time.Time) any {
+	if t.IsZero() {
+		return nil
 	}
-	return out, nil
+	return t.UTC().Format(time.RFC3339Nano)
 }
 
 var _ = http.MethodPost
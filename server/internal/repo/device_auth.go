@@ -0,0 +1,427 @@
+package repo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mgeovany/sentra/server/internal/supabase"
+)
+
+type DeviceAuthStatus string
+
+const (
+	DeviceAuthPending  DeviceAuthStatus = "pending"
+	DeviceAuthApproved DeviceAuthStatus = "approved"
+	DeviceAuthDenied   DeviceAuthStatus = "denied"
+)
+
+// DeviceAuthRequest is one `sentra login --device` attempt. The device code is
+// stored hashed; Session holds the Supabase tokens between browser approval
+// and the CLI's next poll, and is deleted when the CLI collects it.
+type DeviceAuthRequest struct {
+	ID           string           `json:"id"`
+	UserCode     string           `json:"user_code"`
+	Status       DeviceAuthStatus `json:"status"`
+	OAuthState   string           `json:"oauth_state,omitempty"`
+	CodeVerifier string           `json:"code_verifier,omitempty"`
+	Session      json.RawMessage  `json:"session,omitempty"`
+	CreatedAt    time.Time        `json:"created_at"`
+	ExpiresAt    time.Time        `json:"expires_at"`
+	LastPolledAt *time.Time       `json:"last_polled_at,omitempty"`
+}
+
+func (d DeviceAuthRequest) Expired(now time.Time) bool {
+	return !now.Before(d.ExpiresAt)
+}
+
+type DeviceAuthStore interface {
+	Create(ctx context.Context, userCode, deviceCodeHash string, expiresAt time.Time) (DeviceAuthRequest, error)
+	FindByUserCode(ctx context.Context, userCode string) (DeviceAuthRequest, bool, error)
+	FindByState(ctx context.Context, state string) (DeviceAuthRequest, bool, error)
+	FindByDeviceCode(ctx context.Context, deviceCodeHash string) (DeviceAuthRequest, bool, error)
+	BeginAuthorization(ctx context.Context, id, state, codeVerifier string) error
+	Approve(ctx context.Context, id string, session json.RawMessage) error
+	Deny(ctx context.Context, id string) error
+	TouchPoll(ctx context.Context, id string, at time.Time) error
+	// Consume deletes an approved request and returns it, so a session is handed out at most once.
+	Consume(ctx context.Context, id string) (DeviceAuthRequest, bool, error)
+	// DeleteExpired deletes every request that expired before now, including
+	// approved ones whose session was never collected.
+	DeleteExpired(ctx context.Context, now time.Time) (int, error)
+}
+
+type DisabledDeviceAuthStore struct{}
+
+func (DisabledDeviceAuthStore) Create(ctx context.Context, userCode, deviceCodeHash string, expiresAt time.Time) (DeviceAuthRequest, error) {
+	return DeviceAuthRequest{}, ErrDBNotConfigured
+}
+
+func (DisabledDeviceAuthStore) FindByUserCode(ctx context.Context, userCode string) (DeviceAuthRequest, bool, error) {
+	return DeviceAuthRequest{}, false, ErrDBNotConfigured
+}
+
+func (DisabledDeviceAuthStore) FindByState(ctx context.Context, state string) (DeviceAuthRequest, bool, error) {
+	return DeviceAuthRequest{}, false, ErrDBNotConfigured
+}
+
+func (DisabledDeviceAuthStore) FindByDeviceCode(ctx context.Context, deviceCodeHash string) (DeviceAuthRequest, bool, error) {
+	return DeviceAuthRequest{}, false, ErrDBNotConfigured
+}
+
+func (DisabledDeviceAuthStore) BeginAuthorization(ctx context.Context, id, state, codeVerifier string) error {
+	return ErrDBNotConfigured
+}
+
+func (DisabledDeviceAuthStore) Approve(ctx context.Context, id string, session json.RawMessage) error {
+	return ErrDBNotConfigured
+}
+
+func (DisabledDeviceAuthStore) Deny(ctx context.Context, id string) error {
+	return ErrDBNotConfigured
+}
+
+func (DisabledDeviceAuthStore) TouchPoll(ctx context.Context, id string, at time.Time) error {
+	return ErrDBNotConfigured
+}
+
+func (DisabledDeviceAuthStore) Consume(ctx context.Context, id string) (DeviceAuthRequest, bool, error) {
+	return DeviceAuthRequest{}, false, ErrDBNotConfigured
+}
+
+func (DisabledDeviceAuthStore) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	return 0, ErrDBNotConfigured
+}
+
+type SupabaseDeviceAuthStore struct {
+	client *supabase.Client
+	table  string
+}
+
+func NewSupabaseDeviceAuthStore(client *supabase.Client, table string) SupabaseDeviceAuthStore {
+	if table == "" {
+		table = "device_auth_requests"
+	}
+	return SupabaseDeviceAuthStore{client: client, table: table}
+}
+
+const deviceAuthColumns = "id,user_code,status,oauth_state,code_verifier,session,created_at,expires_at,last_polled_at"
+
+func (s SupabaseDeviceAuthStore) Create(ctx context.Context, userCode, deviceCodeHash string, expiresAt time.Time) (DeviceAuthRequest, error) {
+	if s.client == nil {
+		return DeviceAuthRequest{}, ErrDBNotConfigured
+	}
+	userCode = strings.TrimSpace(userCode)
+	deviceCodeHash = strings.TrimSpace(deviceCodeHash)
+	if userCode == "" || deviceCodeHash == "" || expiresAt.IsZero() {
+		return DeviceAuthRequest{}, fmt.Errorf("invalid device auth payload")
+	}
+
+	u, err := url.Parse(s.client.PostgRESTURL(s.table))
+	if err != nil {
+		return DeviceAuthRequest{}, err
+	}
+	q := u.Query()
+	q.Set("select", deviceAuthColumns)
+	u.RawQuery = q.Encode()
+
+	payload := map[string]any{
+		"user_code":        userCode,
+		"device_code_hash": deviceCodeHash,
+		"status":           string(DeviceAuthPending),
+		"expires_at":       expiresAt.UTC().Format(time.RFC3339),
+	}
+	headers := map[string]string{
+		"Prefer": "return=representation",
+	}
+
+	resp, body, err := s.client.PostJSON(ctx, u.String(), payload, headers)
+	if err != nil {
+		return DeviceAuthRequest{}, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			return DeviceAuthRequest{}, ErrDBMisconfigured
+		}
+		return DeviceAuthRequest{}, fmt.Errorf("supabase insert device_auth_requests failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var out []DeviceAuthRequest
+	if err := json.Unmarshal(body, &out); err != nil {
+		return DeviceAuthRequest{}, err
+	}
+	if len(out) == 0 {
+		return DeviceAuthRequest{}, fmt.Errorf("supabase insert device_auth_requests returned no rows")
+	}
+	return out[0], nil
+}
+
+func (s SupabaseDeviceAuthStore) FindByUserCode(ctx context.Context, userCode string) (DeviceAuthRequest, bool, error) {
+	return s.findOne(ctx, "user_code", userCode)
+}
+
+func (s SupabaseDeviceAuthStore) FindByState(ctx context.Context, state string) (DeviceAuthRequest, bool, error) {
+	return s.findOne(ctx, "oauth_state", state)
+}
+
+func (s SupabaseDeviceAuthStore) FindByDeviceCode(ctx context.Context, deviceCodeHash string) (DeviceAuthRequest, bool, error) {
+	return s.findOne(ctx, "device_code_hash", deviceCodeHash)
+}
+
+func (s SupabaseDeviceAuthStore) BeginAuthorization(ctx context.Context, id, state, codeVerifier string) error {
+	if strings.TrimSpace(state) == "" || strings.TrimSpace(codeVerifier) == "" {
+		return fmt.Errorf("invalid device auth begin payload")
+	}
+	q := url.Values{}
+	q.Set("status", "eq."+string(DeviceAuthPending))
+	_, err := s.patch(ctx, id, q, map[string]any{
+		"oauth_state":   strings.TrimSpace(state),
+		"code_verifier": strings.TrimSpace(codeVerifier),
+	})
+	return err
+}
+
+func (s SupabaseDeviceAuthStore) Approve(ctx context.Context, id string, session json.RawMessage) error {
+	if len(session) == 0 {
+		return fmt.Errorf("invalid device auth approve payload")
+	}
+	q := url.Values{}
+	q.Set("status", "eq."+string(DeviceAuthPending))
+	n, err := s.patch(ctx, id, q, map[string]any{
+		"status":        string(DeviceAuthApproved),
+		"session":       supabase.RawJSON(session),
+		"oauth_state":   nil,
+		"code_verifier": nil,
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("device auth request is no longer pending")
+	}
+	return nil
+}
+
+func (s SupabaseDeviceAuthStore) Deny(ctx context.Context, id string) error {
+	q := url.Values{}
+	q.Set("status", "eq."+string(DeviceAuthPending))
+	_, err := s.patch(ctx, id, q, map[string]any{
+		"status":        string(DeviceAuthDenied),
+		"oauth_state":   nil,
+		"code_verifier": nil,
+	})
+	return err
+}
+
+func (s SupabaseDeviceAuthStore) TouchPoll(ctx context.Context, id string, at time.Time) error {
+	_, err := s.patch(ctx, id, url.Values{}, map[string]any{
+		"last_polled_at": at.UTC().Format(time.RFC3339Nano),
+	})
+	return err
+}
+
+func (s SupabaseDeviceAuthStore) Consume(ctx context.Context, id string) (DeviceAuthRequest, bool, error) {
+	if s.client == nil {
+		return DeviceAuthRequest{}, false, ErrDBNotConfigured
+	}
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return DeviceAuthRequest{}, false, fmt.Errorf("invalid device auth consume request")
+	}
+
+	u, err := url.Parse(s.client.PostgRESTURL(s.table))
+	if err != nil {
+		return DeviceAuthRequest{}, false, err
+	}
+	q := u.Query()
+	q.Set("id", "eq."+id)
+	q.Set("status", "eq."+string(DeviceAuthApproved))
+	q.Set("select", deviceAuthColumns)
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, u.String(), nil)
+	if err != nil {
+		return DeviceAuthRequest{}, false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Prefer", "return=representation")
+	req.Header.Set("apikey", s.client.APIKey())
+	req.Header.Set("Authorization", "Bearer "+s.client.APIKey())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return DeviceAuthRequest{}, false, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			return DeviceAuthRequest{}, false, ErrDBMisconfigured
+		}
+		return DeviceAuthRequest{}, false, fmt.Errorf("supabase delete device_auth_requests failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var out []DeviceAuthRequest
+	if err := json.Unmarshal(body, &out); err != nil {
+		return DeviceAuthRequest{}, false, err
+	}
+	if len(out) == 0 {
+		return DeviceAuthRequest{}, false, nil
+	}
+	return out[0], true, nil
+}
+
+func (s SupabaseDeviceAuthStore) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	if s.client == nil {
+		return 0, ErrDBNotConfigured
+	}
+
+	u, err := url.Parse(s.client.PostgRESTURL(s.table))
+	if err != nil {
+		return 0, err
+	}
+	q := u.Query()
+	q.Set("expires_at", "lt."+now.UTC().Format(time.RFC3339Nano))
+	q.Set("select", "id")
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Prefer", "return=representation")
+	req.Header.Set("apikey", s.client.APIKey())
+	req.Header.Set("Authorization", "Bearer "+s.client.APIKey())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			return 0, ErrDBMisconfigured
+		}
+		return 0, fmt.Errorf("supabase delete expired device_auth_requests failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var out []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return 0, err
+	}
+	return len(out), nil
+}
+
+func (s SupabaseDeviceAuthStore) findOne(ctx context.Context, column, value string) (DeviceAuthRequest, bool, error) {
+	if s.client == nil {
+		return DeviceAuthRequest{}, false, ErrDBNotConfigured
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return DeviceAuthRequest{}, false, nil
+	}
+
+	u, err := url.Parse(s.client.PostgRESTURL(s.table))
+	if err != nil {
+		return DeviceAuthRequest{}, false, err
+	}
+	q := u.Query()
+	q.Set(column, "eq."+value)
+	q.Set("select", deviceAuthColumns)
+	q.Set("limit", "1")
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return DeviceAuthRequest{}, false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("apikey", s.client.APIKey())
+	req.Header.Set("Authorization", "Bearer "+s.client.APIKey())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return DeviceAuthRequest{}, false, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	b, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			return DeviceAuthRequest{}, false, ErrDBMisconfigured
+		}
+		return DeviceAuthRequest{}, false, fmt.Errorf("supabase select device_auth_requests failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+
+	var out []DeviceAuthRequest
+	if err := json.Unmarshal(b, &out); err != nil {
+		return DeviceAuthRequest{}, false, err
+	}
+	if len(out) == 0 {
+		return DeviceAuthRequest{}, false, nil
+	}
+	return out[0], true, nil
+}
+
+// patch updates the row id (further narrowed by q) and returns how many rows changed.
+func (s SupabaseDeviceAuthStore) patch(ctx context.Context, id string, q url.Values, payload map[string]any) (int, error) {
+	if s.client == nil {
+		return 0, ErrDBNotConfigured
+	}
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return 0, fmt.Errorf("invalid device auth update request")
+	}
+
+	u, err := url.Parse(s.client.PostgRESTURL(s.table))
+	if err != nil {
+		return 0, err
+	}
+	q.Set("id", "eq."+id)
+	q.Set("select", "id")
+	u.RawQuery = q.Encode()
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, u.String(), bytes.NewReader(b))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Prefer", "return=representation")
+	req.Header.Set("apikey", s.client.APIKey())
+	req.Header.Set("Authorization", "Bearer "+s.client.APIKey())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			return 0, ErrDBMisconfigured
+		}
+		return 0, fmt.Errorf("supabase update device_auth_requests failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var out []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return 0, err
+	}
+	return len(out), nil
+}
@@ -0,0 +1,86 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mgeovany/sentra/server/internal/supabase"
+)
+
+// FileLocation moves one stored file row to a copy of the same ciphertext:
+// either inline (BlobB64) or in user-managed storage (Storage*). SHA256 must
+// match the row's plaintext hash or the row is left untouched.
+type FileLocation struct {
+	CommitID        string `json:"commit_id"`
+	FilePath        string `json:"file_path"`
+	SHA256          string `json:"sha256"`
+	BlobB64         string `json:"blob_b64,omitempty"`
+	StorageProvider string `json:"storage_provider,omitempty"`
+	StorageBucket   string `json:"storage_bucket,omitempty"`
+	StorageKey      string `json:"storage_key,omitempty"`
+	StorageEndpoint string `json:"storage_endpoint,omitempty"`
+	StorageRegion   string `json:"storage_region,omitempty"`
+}
+
+type FileLocationStore interface {
+	// RelocateFiles returns how many rows were updated.
+	RelocateFiles(ctx context.Context, userID string, root string, files []FileLocation) (int, error)
+}
+
+type DisabledFileLocationStore struct{}
+
+func (DisabledFileLocationStore) RelocateFiles(ctx context.Context, userID string, root string, files []FileLocation) (int, error) {
+	return 0, ErrDBNotConfigured
+}
+
+type SupabaseFileLocationStore struct {
+	client *supabase.Client
+	fn     string
+}
+
+// NewSupabaseFileLocationStore calls fn(p_user_id uuid, p_root text, p_files jsonb),
+// which sets blob_b64 or the storage_* columns (clearing the other) on each
+// matching (commit_id, file_path, sha256) row and returns the update count.
+// It is defined in supabase/migrations/20261018200000_relocate_files.sql.
+func NewSupabaseFileLocationStore(client *supabase.Client, fn string) SupabaseFileLocationStore {
+	if fn == "" {
+		fn = "sentra_relocate_files_v1"
+	}
+	return SupabaseFileLocationStore{client: client, fn: fn}
+}
+
+func (s SupabaseFileLocationStore) RelocateFiles(ctx context.Context, userID string, root string, files []FileLocation) (int, error) {
+	if s.client == nil {
+		return 0, ErrDBNotConfigured
+	}
+	userID = strings.TrimSpace(userID)
+	root = strings.TrimSpace(root)
+	if userID == "" || root == "" {
+		return 0, fmt.Errorf("invalid relocate request")
+	}
+
+	url := s.client.RPCURL(s.fn)
+	body := map[string]any{
+		"p_user_id": userID,
+		"p_root":    root,
+		"p_files":   files,
+	}
+	headers := map[string]string{
+		"Accept": "application/json",
+	}
+
+	resp, respBody, err := s.client.PostJSON(ctx, url, body, headers)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return 0, fmt.Errorf("supabase rpc relocate failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var updated int
+	if err := supabase.UnmarshalJSON(respBody, &updated); err != nil {
+		return 0, err
+	}
+	return updated, nil
+}
@@ -0,0 +1,77 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mgeovany/sentra/server/internal/supabase"
+)
+
+// ProjectHead is the newest commit of a project. The CLI compares it with
+// the commit it last synced or pushed to tell whether a checkout is behind.
+type ProjectHead struct {
+	RootPath    string `json:"root_path"`
+	CommitID    string `json:"commit_id"`
+	CreatedAt   string `json:"created_at"`
+	MachineID   string `json:"machine_id"`
+	MachineName string `json:"machine_name"`
+}
+
+type ProjectHeadStore interface {
+	ListProjectHeads(ctx context.Context, userID string) ([]ProjectHead, error)
+}
+
+type DisabledProjectHeadStore struct{}
+
+func (DisabledProjectHeadStore) ListProjectHeads(ctx context.Context, userID string) ([]ProjectHead, error) {
+	return nil, ErrDBNotConfigured
+}
+
+type SupabaseProjectHeadStore struct {
+	client *supabase.Client
+	fn     string
+}
+
+// NewSupabaseProjectHeadStore calls fn(p_user_id uuid), which returns one row
+// per project of the user: {"root_path", "commit_id", "created_at",
+// "machine_id", "machine_name"} of its newest commit. It is defined in
+// supabase/migrations/20261018190000_project_heads.sql.
+func NewSupabaseProjectHeadStore(client *supabase.Client, fn string) SupabaseProjectHeadStore {
+	if fn == "" {
+		fn = "sentra_project_heads_v1"
+	}
+	return SupabaseProjectHeadStore{client: client, fn: fn}
+}
+
+func (s SupabaseProjectHeadStore) ListProjectHeads(ctx context.Context, userID string) ([]ProjectHead, error) {
+	if s.client == nil {
+		return nil, ErrDBNotConfigured
+	}
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return nil, fmt.Errorf("invalid project heads request")
+	}
+
+	url := s.client.RPCURL(s.fn)
+	body := map[string]any{
+		"p_user_id": userID,
+	}
+	headers := map[string]string{
+		"Accept": "application/json",
+	}
+
+	resp, respBody, err := s.client.PostJSON(ctx, url, body, headers)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("supabase rpc project heads failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var out []ProjectHead
+	if err := supabase.UnmarshalJSON(respBody, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -3,22 +3,68 @@ package repo
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/mgeovany/sentra/server/internal/supabase"
 )
 
+var (
+	ErrProjectNotFound = errors.New("project not found")
+	// ErrProjectExists is returned when a rename targets a root the user
+	// already pushed.
+	ErrProjectExists = errors.New("project root already exists")
+)
+
 type ProjectInfo struct {
-	RootPath          string `json:"root_path"`
-	LastCommitID      string `json:"last_commit_id"`
-	LastCommitMessage string `json:"last_commit_message"`
-	FileCount         int    `json:"file_count"`
+	ID                string     `json:"id,omitempty"`
+	RootPath          string     `json:"root_path"`
+	DisplayName       string     `json:"display_name,omitempty"`
+	ArchivedAt        *time.Time `json:"archived_at,omitempty"`
+	LastCommitID      string     `json:"last_commit_id"`
+	LastCommitMessage string     `json:"last_commit_message"`
+	FileCount         int        `json:"file_count"`
+}
+
+// ProjectPatch changes a project. Nil fields are left as they are.
+type ProjectPatch struct {
+	// Root renames the project, e.g. after its directory moved. Later
+	// pushes must use the new root.
+	Root        *string `json:"root,omitempty"`
+	DisplayName *string `json:"display_name,omitempty"`
+	// Archived hides the project from `sentra projects` without deleting
+	// its history.
+	Archived *bool `json:"archived,omitempty"`
+}
+
+// StorageObject is where a file's ciphertext lives outside the database.
+type StorageObject struct {
+	Provider string `json:"provider"`
+	Bucket   string `json:"bucket,omitempty"`
+	Key      string `json:"key"`
+	Endpoint string `json:"endpoint,omitempty"`
+	Region   string `json:"region,omitempty"`
+}
+
+// ProjectDeletion reports what deleting a project removed.
+type ProjectDeletion struct {
+	ID       string `json:"id"`
+	RootPath string `json:"root_path"`
+	Commits  int    `json:"commits"`
+	Files    int    `json:"files"`
+	// Storage lists the objects only the deleted files referenced. The
+	// server cannot reach BYOS buckets, so the client deletes those (or
+	// leaves them to `sentra storage gc`).
+	Storage []StorageObject `json:"storage"`
 }
 
 type ProjectStore interface {
 	ListProjects(ctx context.Context, userID string) ([]ProjectInfo, error)
+	UpdateProject(ctx context.Context, userID string, projectID string, patch ProjectPatch) (ProjectInfo, error)
+	DeleteProject(ctx context.Context, userID string, projectID string) (ProjectDeletion, error)
 }
 
 type DisabledProjectStore struct{}
@@ -27,16 +73,47 @@ func (DisabledProjectStore) ListProjects(ctx context.Context, userID string) ([]
 	return nil, ErrDBNotConfigured
 }
 
+func (DisabledProjectStore) UpdateProject(ctx context.Context, userID string, projectID string, patch ProjectPatch) (ProjectInfo, error) {
+	return ProjectInfo{}, ErrDBNotConfigured
+}
+
+func (DisabledProjectStore) DeleteProject(ctx context.Context, userID string, projectID string) (ProjectDeletion, error) {
+	return ProjectDeletion{}, ErrDBNotConfigured
+}
+
 type SupabaseProjectStore struct {
-	client *supabase.Client
-	fn     string
+	client   *supabase.Client
+	fn       string
+	updateFn string
+	deleteFn string
 }
 
-func NewSupabaseProjectStore(client *supabase.Client, fn string) SupabaseProjectStore {
+// NewSupabaseProjectStore lists projects with fn(p_user_id uuid), which
+// returns one ProjectInfo row per project of the user.
+//
+// updateFn(p_user_id uuid, p_project_id uuid, p_root text, p_display_name
+// text, p_archived boolean) applies the non-null arguments and returns the
+// project as a one-row array (empty when the user has no such project); a
+// root another of the user's projects has fails with a unique violation.
+//
+// deleteFn(p_user_id uuid, p_project_id uuid) deletes the project with its
+// commits and files and returns one row {"id", "root_path", "commits",
+// "files", "storage"}, where storage holds the {"provider", "bucket", "key",
+// "endpoint", "region"} locations no remaining file of the user references.
+//
+// The three functions are defined in
+// supabase/migrations/20261018160000_project_management.sql.
+func NewSupabaseProjectStore(client *supabase.Client, fn string, updateFn string, deleteFn string) SupabaseProjectStore {
 	if fn == "" {
-		fn = "sentra_projects_v1"
+		fn = "sentra_projects_v2"
+	}
+	if updateFn == "" {
+		updateFn = "sentra_project_update_v1"
+	}
+	if deleteFn == "" {
+		deleteFn = "sentra_project_delete_v1"
 	}
-	return SupabaseProjectStore{client: client, fn: fn}
+	return SupabaseProjectStore{client: client, fn: fn, updateFn: updateFn, deleteFn: deleteFn}
 }
 
 func (s SupabaseProjectStore) ListProjects(ctx context.Context, userID string) ([]ProjectInfo, error) {
@@ -72,4 +149,78 @@ func (s SupabaseProjectStore) ListProjects(ctx context.Context, userID string) (
 	return out, nil
 }
 
-var _ = http.MethodPost
+func (s SupabaseProjectStore) UpdateProject(ctx context.Context, userID string, projectID string, patch ProjectPatch) (ProjectInfo, error) {
+	if s.client == nil {
+		return ProjectInfo{}, ErrDBNotConfigured
+	}
+	userID = strings.TrimSpace(userID)
+	projectID = strings.TrimSpace(projectID)
+	if userID == "" || projectID == "" {
+		return ProjectInfo{}, fmt.Errorf("invalid project update request")
+	}
+
+	body := map[string]any{
+		"p_user_id":      userID,
+		"p_project_id":   projectID,
+		"p_root":         patch.Root,
+		"p_display_name": patch.DisplayName,
+		"p_archived":     patch.Archived,
+	}
+	headers := map[string]string{
+		"Accept": "application/json",
+	}
+	resp, respBody, err := s.client.PostJSON(ctx, s.client.RPCURL(s.updateFn), body, headers)
+	if err != nil {
+		return ProjectInfo{}, err
+	}
+	if resp.StatusCode == http.StatusConflict {
+		return ProjectInfo{}, ErrProjectExists
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return ProjectInfo{}, fmt.Errorf("supabase rpc project update failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var out []ProjectInfo
+	if err := supabase.UnmarshalJSON(respBody, &out); err != nil {
+		return ProjectInfo{}, err
+	}
+	if len(out) == 0 {
+		return ProjectInfo{}, ErrProjectNotFound
+	}
+	return out[0], nil
+}
+
+func (s SupabaseProjectStore) DeleteProject(ctx context.Context, userID string, projectID string) (ProjectDeletion, error) {
+	if s.client == nil {
+		return ProjectDeletion{}, ErrDBNotConfigured
+	}
+	userID = strings.TrimSpace(userID)
+	projectID = strings.TrimSpace(projectID)
+	if userID == "" || projectID == "" {
+		return ProjectDeletion{}, fmt.Errorf("invalid project delete request")
+	}
+
+	body := map[string]any{
+		"p_user_id":    userID,
+		"p_project_id": projectID,
+	}
+	headers := map[string]string{
+		"Accept": "application/json",
+	}
+	resp, respBody, err := s.client.PostJSON(ctx, s.client.RPCURL(s.deleteFn), body, headers)
+	if err != nil {
+		return ProjectDeletion{}, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return ProjectDeletion{}, fmt.Errorf("supabase rpc project delete failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var out []ProjectDeletion
+	if err := supabase.UnmarshalJSON(respBody, &out); err != nil {
+		return ProjectDeletion{}, err
+	}
+	if len(out) == 0 {
+		return ProjectDeletion{}, ErrProjectNotFound
+	}
+	return out[0], nil
+}
@@ -26,6 +26,9 @@ func (DisabledPushStore) Push(ctx context.Context, userID string, payload any) (
 	return PushResult{}, ErrDBNotConfigured
 }
 
+// SupabasePushStore stores pushes of every accepted version with
+// sentra_push_v1. The file formats the files table accepts are set in
+// supabase/migrations/20261018220000_push_formats.sql.
 type SupabasePushStore struct {
 	client *supabase.Client
 	fn     string
@@ -0,0 +1,277 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/mgeovany/sentra/server/internal/supabase"
+)
+
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Quota caps what one user may push. Zero fields are unlimited.
+type Quota struct {
+	// DailyPushBytes caps the file bytes a user pushes per UTC day.
+	DailyPushBytes int64 `json:"daily_push_bytes"`
+	// StorageBytes caps the total size of the files a user has stored.
+	StorageBytes int64 `json:"storage_bytes"`
+}
+
+// Quota kinds reported by QuotaError.
+const (
+	QuotaDailyPush = "daily_push_bytes"
+	QuotaStorage   = "storage_bytes"
+)
+
+// QuotaError is returned by QuotaPushStore and QuotaBlobStore when a push or
+// blob upload would exceed a quota. It matches ErrQuotaExceeded.
+type QuotaError struct {
+	Kind  string
+	Limit int64
+	Used  int64
+	// Push is the size of the rejected push or blob.
+	Push int64
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s quota exceeded: %d of %d bytes used, push needs %d", e.Kind, e.Used, e.Limit, e.Push)
+}
+
+func (e *QuotaError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// Usage is what counts against a user's quotas.
+type Usage struct {
+	StorageBytes     int64 `json:"storage_bytes"`
+	PushedBytesToday int64 `json:"pushed_bytes_today"`
+}
+
+type UsageStore interface {
+	Usage(ctx context.Context, userID string) (Usage, error)
+	AddPushedBytes(ctx context.Context, userID string, n int64) error
+}
+
+type DisabledUsageStore struct{}
+
+func (DisabledUsageStore) Usage(ctx context.Context, userID string) (Usage, error) {
+	return Usage{}, ErrDBNotConfigured
+}
+
+func (DisabledUsageStore) AddPushedBytes(ctx context.Context, userID string, n int64) error {
+	return ErrDBNotConfigured
+}
+
+type SupabaseUsageStore struct {
+	client  *supabase.Client
+	usageFn string
+	addFn   string
+}
+
+// NewSupabaseUsageStore calls usageFn(p_user_id uuid), which returns one row
+// {"storage_bytes", "pushed_bytes_today"}: the summed size of the user's
+// stored files and the bytes recorded today (UTC) in sentra_usage_daily.
+// addFn(p_user_id uuid, p_bytes bigint) adds to today's row.
+func NewSupabaseUsageStore(client *supabase.Client, usageFn string, addFn string) SupabaseUsageStore {
+	if usageFn == "" {
+		usageFn = "sentra_usage_v1"
+	}
+	if addFn == "" {
+		addFn = "sentra_usage_add_v1"
+	}
+	return SupabaseUsageStore{client: client, usageFn: usageFn, addFn: addFn}
+}
+
+func (s SupabaseUsageStore) Usage(ctx context.Context, userID string) (Usage, error) {
+	if s.client == nil {
+		return Usage{}, ErrDBNotConfigured
+	}
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return Usage{}, fmt.Errorf("invalid usage request")
+	}
+
+	body := map[string]any{
+		"p_user_id": userID,
+	}
+	headers := map[string]string{
+		"Accept": "application/json",
+	}
+	resp, respBody, err := s.client.PostJSON(ctx, s.client.RPCURL(s.usageFn), body, headers)
+	if err != nil {
+		return Usage{}, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return Usage{}, fmt.Errorf("supabase rpc usage failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	// PostgREST returns an array for RPC results.
+	var out []Usage
+	if err := supabase.UnmarshalJSON(respBody, &out); err != nil {
+		return Usage{}, err
+	}
+	if len(out) == 0 {
+		return Usage{}, nil
+	}
+	return out[0], nil
+}
+
+func (s SupabaseUsageStore) AddPushedBytes(ctx context.Context, userID string, n int64) error {
+	if s.client == nil {
+		return ErrDBNotConfigured
+	}
+	userID = strings.TrimSpace(userID)
+	if userID == "" || n < 0 {
+		return fmt.Errorf("invalid usage update")
+	}
+
+	body := map[string]any{
+		"p_user_id": userID,
+		"p_bytes":   n,
+	}
+	headers := map[string]string{
+		"Accept": "application/json",
+	}
+	resp, respBody, err := s.client.PostJSON(ctx, s.client.RPCURL(s.addFn), body, headers)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("supabase rpc usage add failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+// QuotaPushStore checks a push against the user's quotas before passing it
+// to next, and records the pushed bytes after. The check and the push are
+// not one transaction, so concurrent pushes can overshoot a quota by at most
+// their own size.
+//
+// Hosted files (storage provider "sentra") were counted against the daily
+// quota when QuotaBlobStore accepted their blob, so only the storage quota
+// counts them again here.
+type QuotaPushStore struct {
+	next  PushStore
+	usage UsageStore
+	quota func(userID string) Quota
+}
+
+func NewQuotaPushStore(next PushStore, usage UsageStore, quota func(userID string) Quota) QuotaPushStore {
+	if next == nil {
+		next = DisabledPushStore{}
+	}
+	if usage == nil {
+		usage = DisabledUsageStore{}
+	}
+	return QuotaPushStore{next: next, usage: usage, quota: quota}
+}
+
+func (s QuotaPushStore) Push(ctx context.Context, userID string, payload any) (PushResult, error) {
+	size, hosted := payloadFileBytes(payload)
+	pushed := size - hosted
+	q := Quota{}
+	if s.quota != nil {
+		q = s.quota(userID)
+	}
+
+	if err := checkQuota(ctx, s.usage, userID, q, pushed, size); err != nil {
+		return PushResult{}, err
+	}
+
+	res, err := s.next.Push(ctx, userID, payload)
+	if err != nil || res.Deduped {
+		return res, err
+	}
+	addPushedBytes(ctx, s.usage, userID, pushed)
+	return res, nil
+}
+
+// QuotaBlobStore checks hosted blob uploads against the user's quotas before
+// passing them to next, and records the uploaded bytes against the daily
+// quota. Like QuotaPushStore, concurrent uploads can overshoot a quota by at
+// most their own size.
+type QuotaBlobStore struct {
+	BlobStore
+	usage UsageStore
+	quota func(userID string) Quota
+}
+
+func NewQuotaBlobStore(next BlobStore, usage UsageStore, quota func(userID string) Quota) QuotaBlobStore {
+	if next == nil {
+		next = DisabledBlobStore{}
+	}
+	if usage == nil {
+		usage = DisabledUsageStore{}
+	}
+	return QuotaBlobStore{BlobStore: next, usage: usage, quota: quota}
+}
+
+func (s QuotaBlobStore) PutBlob(ctx context.Context, userID string, root string, id string, body io.Reader, size int64) error {
+	q := Quota{}
+	if s.quota != nil {
+		q = s.quota(userID)
+	}
+	if err := checkQuota(ctx, s.usage, userID, q, size, size); err != nil {
+		return err
+	}
+	if err := s.BlobStore.PutBlob(ctx, userID, root, id, body, size); err != nil {
+		return err
+	}
+	addPushedBytes(ctx, s.usage, userID, size)
+	return nil
+}
+
+// checkQuota returns a QuotaError when pushed more bytes today, or stored more
+// bytes in total, would exceed q.
+func checkQuota(ctx context.Context, usage UsageStore, userID string, q Quota, pushed int64, stored int64) error {
+	if q.DailyPushBytes <= 0 && q.StorageBytes <= 0 {
+		return nil
+	}
+	u, err := usage.Usage(ctx, userID)
+	switch {
+	case err != nil:
+		// Like idempotency, quotas fail open: a usage outage must not
+		// block every push.
+		slog.WarnContext(ctx, "quota check skipped", "user_id", userID, "err", err)
+	case q.DailyPushBytes > 0 && u.PushedBytesToday+pushed > q.DailyPushBytes:
+		return &QuotaError{Kind: QuotaDailyPush, Limit: q.DailyPushBytes, Used: u.PushedBytesToday, Push: pushed}
+	case q.StorageBytes > 0 && u.StorageBytes+stored > q.StorageBytes:
+		return &QuotaError{Kind: QuotaStorage, Limit: q.StorageBytes, Used: u.StorageBytes, Push: stored}
+	}
+	return nil
+}
+
+func addPushedBytes(ctx context.Context, usage UsageStore, userID string, n int64) {
+	if n <= 0 {
+		return
+	}
+	if err := usage.AddPushedBytes(ctx, userID, n); err != nil && !errors.Is(err, ErrDBNotConfigured) {
+		slog.WarnContext(ctx, "push usage not recorded", "user_id", userID, "err", err)
+	}
+}
+
+// payloadFileBytes sums files[].size of a decoded push payload, and
+// separately the size of its hosted files.
+func payloadFileBytes(payload any) (total int64, hosted int64) {
+	p, ok := payload.(map[string]any)
+	if !ok {
+		return 0, 0
+	}
+	files, _ := p["files"].([]any)
+	for _, f := range files {
+		m, _ := f.(map[string]any)
+		n, ok := m["size"].(float64)
+		if !ok || n <= 0 {
+			continue
+		}
+		total += int64(n)
+		if st, _ := m["storage"].(map[string]any); st != nil && st["provider"] == "sentra" {
+			hosted += int64(n)
+		}
+	}
+	return total, hosted
+}
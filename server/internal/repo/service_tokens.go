@@ -0,0 +1,266 @@
+package repo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mgeovany/sentra/server/internal/supabase"
+)
+
+var ErrTokenNotFound = errors.New("token not found")
+
+// ServiceToken is a non-interactive credential for CI. The secret itself is
+// never stored; lookups go through its SHA-256 hash.
+type ServiceToken struct {
+	ID          string     `json:"id"`
+	UserID      string     `json:"user_id"`
+	Name        string     `json:"name"`
+	ProjectRoot string     `json:"project_root,omitempty"`
+	ReadOnly    bool       `json:"read_only"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Active reports whether the token can still authenticate.
+func (t ServiceToken) Active(now time.Time) bool {
+	if t.RevokedAt != nil {
+		return false
+	}
+	return t.ExpiresAt == nil || now.Before(*t.ExpiresAt)
+}
+
+type ServiceTokenStore interface {
+	Create(ctx context.Context, t ServiceToken, tokenHash string) (ServiceToken, error)
+	List(ctx context.Context, userID string) ([]ServiceToken, error)
+	Revoke(ctx context.Context, userID, tokenID string) error
+	Lookup(ctx context.Context, tokenHash string) (ServiceToken, bool, error)
+}
+
+type DisabledServiceTokenStore struct{}
+
+func (DisabledServiceTokenStore) Create(ctx context.Context, t ServiceToken, tokenHash string) (ServiceToken, error) {
+	return ServiceToken{}, ErrDBNotConfigured
+}
+
+func (DisabledServiceTokenStore) List(ctx context.Context, userID string) ([]ServiceToken, error) {
+	return nil, ErrDBNotConfigured
+}
+
+func (DisabledServiceTokenStore) Revoke(ctx context.Context, userID, tokenID string) error {
+	return ErrDBNotConfigured
+}
+
+func (DisabledServiceTokenStore) Lookup(ctx context.Context, tokenHash string) (ServiceToken, bool, error) {
+	return ServiceToken{}, false, ErrDBNotConfigured
+}
+
+type SupabaseServiceTokenStore struct {
+	client *supabase.Client
+	table  string
+}
+
+func NewSupabaseServiceTokenStore(client *supabase.Client, table string) SupabaseServiceTokenStore {
+	if table == "" {
+		table = "service_tokens"
+	}
+	return SupabaseServiceTokenStore{client: client, table: table}
+}
+
+const serviceTokenColumns = "id,user_id,name,project_root,read_only,created_at,expires_at,revoked_at"
+
+func (s SupabaseServiceTokenStore) Create(ctx context.Context, t ServiceToken, tokenHash string) (ServiceToken, error) {
+	if s.client == nil {
+		return ServiceToken{}, ErrDBNotConfigured
+	}
+	t.UserID = strings.TrimSpace(t.UserID)
+	tokenHash = strings.TrimSpace(tokenHash)
+	if t.UserID == "" || tokenHash == "" {
+		return ServiceToken{}, fmt.Errorf("invalid service token payload")
+	}
+
+	u, err := url.Parse(s.client.PostgRESTURL(s.table))
+	if err != nil {
+		return ServiceToken{}, err
+	}
+	q := u.Query()
+	q.Set("select", serviceTokenColumns)
+	u.RawQuery = q.Encode()
+
+	payload := map[string]any{
+		"user_id":    t.UserID,
+		"name":       strings.TrimSpace(t.Name),
+		"token_hash": tokenHash,
+		"read_only":  t.ReadOnly,
+	}
+	if root := strings.TrimSpace(t.ProjectRoot); root != "" {
+		payload["project_root"] = root
+	}
+	if t.ExpiresAt != nil {
+		payload["expires_at"] = t.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	headers := map[string]string{
+		"Prefer": "return=representation",
+	}
+
+	resp, body, err := s.client.PostJSON(ctx, u.String(), payload, headers)
+	if err != nil {
+		return ServiceToken{}, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			return ServiceToken{}, ErrDBMisconfigured
+		}
+		return ServiceToken{}, fmt.Errorf("supabase insert service_tokens failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var out []ServiceToken
+	if err := json.Unmarshal(body, &out); err != nil {
+		return ServiceToken{}, err
+	}
+	if len(out) == 0 {
+		return ServiceToken{}, fmt.Errorf("supabase insert service_tokens returned no rows")
+	}
+	return out[0], nil
+}
+
+func (s SupabaseServiceTokenStore) List(ctx context.Context, userID string) ([]ServiceToken, error) {
+	if s.client == nil {
+		return nil, ErrDBNotConfigured
+	}
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return nil, fmt.Errorf("invalid service token list request")
+	}
+
+	q := url.Values{}
+	q.Set("user_id", "eq."+userID)
+	q.Set("select", serviceTokenColumns)
+	q.Set("order", "created_at.desc")
+	return s.selectTokens(ctx, q)
+}
+
+func (s SupabaseServiceTokenStore) Revoke(ctx context.Context, userID, tokenID string) error {
+	if s.client == nil {
+		return ErrDBNotConfigured
+	}
+	userID = strings.TrimSpace(userID)
+	tokenID = strings.TrimSpace(tokenID)
+	if userID == "" || tokenID == "" {
+		return fmt.Errorf("invalid service token revoke request")
+	}
+
+	u, err := url.Parse(s.client.PostgRESTURL(s.table))
+	if err != nil {
+		return err
+	}
+	q := u.Query()
+	q.Set("user_id", "eq."+userID)
+	q.Set("id", "eq."+tokenID)
+	q.Set("revoked_at", "is.null")
+	q.Set("select", "id")
+	u.RawQuery = q.Encode()
+
+	b, err := json.Marshal(map[string]any{"revoked_at": time.Now().UTC().Format(time.RFC3339)})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, u.String(), bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Prefer", "return=representation")
+	req.Header.Set("apikey", s.client.APIKey())
+	req.Header.Set("Authorization", "Bearer "+s.client.APIKey())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			return ErrDBMisconfigured
+		}
+		return fmt.Errorf("supabase revoke service_tokens failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var out []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return err
+	}
+	if len(out) == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+func (s SupabaseServiceTokenStore) Lookup(ctx context.Context, tokenHash string) (ServiceToken, bool, error) {
+	if s.client == nil {
+		return ServiceToken{}, false, ErrDBNotConfigured
+	}
+	tokenHash = strings.TrimSpace(tokenHash)
+	if tokenHash == "" {
+		return ServiceToken{}, false, nil
+	}
+
+	q := url.Values{}
+	q.Set("token_hash", "eq."+tokenHash)
+	q.Set("select", serviceTokenColumns)
+	out, err := s.selectTokens(ctx, q)
+	if err != nil {
+		return ServiceToken{}, false, err
+	}
+	if len(out) == 0 {
+		return ServiceToken{}, false, nil
+	}
+	return out[0], true, nil
+}
+
+func (s SupabaseServiceTokenStore) selectTokens(ctx context.Context, q url.Values) ([]ServiceToken, error) {
+	u, err := url.Parse(s.client.PostgRESTURL(s.table))
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("apikey", s.client.APIKey())
+	req.Header.Set("Authorization", "Bearer "+s.client.APIKey())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	b, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			return nil, ErrDBMisconfigured
+		}
+		return nil, fmt.Errorf("supabase select service_tokens failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+
+	var out []ServiceToken
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
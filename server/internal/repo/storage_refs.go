@@ -0,0 +1,77 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mgeovany/sentra/server/internal/supabase"
+)
+
+// StorageRefStore lists the BYOS object keys still referenced by a user's
+// file rows, across every project and commit. `sentra storage gc` deletes
+// objects that are not in this list.
+type StorageRefStore interface {
+	ListStorageKeys(ctx context.Context, userID string) ([]string, error)
+}
+
+type DisabledStorageRefStore struct{}
+
+func (DisabledStorageRefStore) ListStorageKeys(ctx context.Context, userID string) ([]string, error) {
+	return nil, ErrDBNotConfigured
+}
+
+type SupabaseStorageRefStore struct {
+	client *supabase.Client
+	fn     string
+}
+
+// NewSupabaseStorageRefStore calls fn(p_user_id uuid), which returns rows of
+// {"storage_key": text}: the distinct non-null storage keys of the user's files.
+// It is defined in supabase/migrations/20261018210000_storage_refs.sql.
+func NewSupabaseStorageRefStore(client *supabase.Client, fn string) SupabaseStorageRefStore {
+	if fn == "" {
+		fn = "sentra_storage_refs_v1"
+	}
+	return SupabaseStorageRefStore{client: client, fn: fn}
+}
+
+func (s SupabaseStorageRefStore) ListStorageKeys(ctx context.Context, userID string) ([]string, error) {
+	if s.client == nil {
+		return nil, ErrDBNotConfigured
+	}
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return nil, fmt.Errorf("invalid storage refs request")
+	}
+
+	url := s.client.RPCURL(s.fn)
+	body := map[string]any{
+		"p_user_id": userID,
+	}
+	headers := map[string]string{
+		"Accept": "application/json",
+	}
+
+	resp, respBody, err := s.client.PostJSON(ctx, url, body, headers)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("supabase rpc storage refs failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var rows []struct {
+		StorageKey string `json:"storage_key"`
+	}
+	if err := supabase.UnmarshalJSON(respBody, &rows); err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(rows))
+	for _, r := range rows {
+		if k := strings.TrimSpace(r.StorageKey); k != "" {
+			out = append(out, k)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,253 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mgeovany/sentra/server/internal/supabase"
+)
+
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// Webhook is a URL notified after every push of its user, or only of one
+// project when ProjectRoot is set.
+type Webhook struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"user_id"`
+	URL         string    `json:"url"`
+	ProjectRoot string    `json:"project_root,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// WebhookTarget is a webhook with the secret that signs its deliveries. The
+// secret is stored as is, since the server must sign with it, and is only
+// returned to the user when the webhook is created.
+type WebhookTarget struct {
+	Webhook
+	Secret string `json:"secret"`
+}
+
+type WebhookStore interface {
+	Create(ctx context.Context, hook Webhook, secret string) (WebhookTarget, error)
+	List(ctx context.Context, userID string) ([]Webhook, error)
+	Delete(ctx context.Context, userID, hookID string) error
+	// Targets returns every webhook of userID with its secret.
+	Targets(ctx context.Context, userID string) ([]WebhookTarget, error)
+}
+
+type DisabledWebhookStore struct{}
+
+func (DisabledWebhookStore) Create(ctx context.Context, hook Webhook, secret string) (WebhookTarget, error) {
+	return WebhookTarget{}, ErrDBNotConfigured
+}
+
+func (DisabledWebhookStore) List(ctx context.Context, userID string) ([]Webhook, error) {
+	return nil, ErrDBNotConfigured
+}
+
+func (DisabledWebhookStore) Delete(ctx context.Context, userID, hookID string) error {
+	return ErrDBNotConfigured
+}
+
+func (DisabledWebhookStore) Targets(ctx context.Context, userID string) ([]WebhookTarget, error) {
+	return nil, ErrDBNotConfigured
+}
+
+// SupabaseWebhookStore keeps webhooks in a PostgREST table with the columns
+// id, user_id, url, project_root (null for every project), secret and
+// created_at.
+type SupabaseWebhookStore struct {
+	client *supabase.Client
+	table  string
+}
+
+func NewSupabaseWebhookStore(client *supabase.Client, table string) SupabaseWebhookStore {
+	if table == "" {
+		table = "webhooks"
+	}
+	return SupabaseWebhookStore{client: client, table: table}
+}
+
+const webhookColumns = "id,user_id,url,project_root,created_at"
+
+func (s SupabaseWebhookStore) Create(ctx context.Context, hook Webhook, secret string) (WebhookTarget, error) {
+	if s.client == nil {
+		return WebhookTarget{}, ErrDBNotConfigured
+	}
+	hook.UserID = strings.TrimSpace(hook.UserID)
+	hook.URL = strings.TrimSpace(hook.URL)
+	secret = strings.TrimSpace(secret)
+	if hook.UserID == "" || hook.URL == "" || secret == "" {
+		return WebhookTarget{}, fmt.Errorf("invalid webhook payload")
+	}
+
+	u, err := url.Parse(s.client.PostgRESTURL(s.table))
+	if err != nil {
+		return WebhookTarget{}, err
+	}
+	q := u.Query()
+	q.Set("select", webhookColumns+",secret")
+	u.RawQuery = q.Encode()
+
+	payload := map[string]any{
+		"user_id": hook.UserID,
+		"url":     hook.URL,
+		"secret":  secret,
+	}
+	if root := strings.TrimSpace(hook.ProjectRoot); root != "" {
+		payload["project_root"] = root
+	}
+	headers := map[string]string{
+		"Prefer": "return=representation",
+	}
+
+	resp, body, err := s.client.PostJSON(ctx, u.String(), payload, headers)
+	if err != nil {
+		return WebhookTarget{}, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			return WebhookTarget{}, ErrDBMisconfigured
+		}
+		return WebhookTarget{}, fmt.Errorf("supabase insert webhooks failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var out []WebhookTarget
+	if err := json.Unmarshal(body, &out); err != nil {
+		return WebhookTarget{}, err
+	}
+	if len(out) == 0 {
+		return WebhookTarget{}, fmt.Errorf("supabase insert webhooks returned no rows")
+	}
+	return out[0], nil
+}
+
+func (s SupabaseWebhookStore) List(ctx context.Context, userID string) ([]Webhook, error) {
+	if s.client == nil {
+		return nil, ErrDBNotConfigured
+	}
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return nil, fmt.Errorf("invalid webhook list request")
+	}
+
+	q := url.Values{}
+	q.Set("user_id", "eq."+userID)
+	q.Set("select", webhookColumns)
+	q.Set("order", "created_at.desc")
+	var out []Webhook
+	if err := s.selectRows(ctx, q, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s SupabaseWebhookStore) Targets(ctx context.Context, userID string) ([]WebhookTarget, error) {
+	if s.client == nil {
+		return nil, ErrDBNotConfigured
+	}
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return nil, fmt.Errorf("invalid webhook targets request")
+	}
+
+	q := url.Values{}
+	q.Set("user_id", "eq."+userID)
+	q.Set("select", webhookColumns+",secret")
+	var out []WebhookTarget
+	if err := s.selectRows(ctx, q, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s SupabaseWebhookStore) Delete(ctx context.Context, userID, hookID string) error {
+	if s.client == nil {
+		return ErrDBNotConfigured
+	}
+	userID = strings.TrimSpace(userID)
+	hookID = strings.TrimSpace(hookID)
+	if userID == "" || hookID == "" {
+		return fmt.Errorf("invalid webhook delete request")
+	}
+
+	u, err := url.Parse(s.client.PostgRESTURL(s.table))
+	if err != nil {
+		return err
+	}
+	q := u.Query()
+	q.Set("user_id", "eq."+userID)
+	q.Set("id", "eq."+hookID)
+	q.Set("select", "id")
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Prefer", "return=representation")
+	req.Header.Set("apikey", s.client.APIKey())
+	req.Header.Set("Authorization", "Bearer "+s.client.APIKey())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			return ErrDBMisconfigured
+		}
+		return fmt.Errorf("supabase delete webhooks failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var out []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return err
+	}
+	if len(out) == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (s SupabaseWebhookStore) selectRows(ctx context.Context, q url.Values, out any) error {
+	u, err := url.Parse(s.client.PostgRESTURL(s.table))
+	if err != nil {
+		return err
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("apikey", s.client.APIKey())
+	req.Header.Set("Authorization", "Bearer "+s.client.APIKey())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	b, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			return ErrDBMisconfigured
+		}
+		return fmt.Errorf("supabase select webhooks failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+	return json.Unmarshal(b, out)
+}
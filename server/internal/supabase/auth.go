@@ -0,0 +1,60 @@
+package supabase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// TokenResponse is a Supabase Auth session as returned by /auth/v1/token.
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// AuthorizeURL starts a PKCE OAuth login that returns to redirectTo.
+func (c *Client) AuthorizeURL(provider, redirectTo, codeChallenge string) string {
+	if provider == "" {
+		provider = "google"
+	}
+	u := *c.baseURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/auth/v1/authorize"
+	q := url.Values{}
+	q.Set("provider", provider)
+	q.Set("redirect_to", redirectTo)
+	q.Set("flow_type", "pkce")
+	q.Set("code_challenge", codeChallenge)
+	q.Set("code_challenge_method", "s256")
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func (c *Client) ExchangePKCE(ctx context.Context, authCode, codeVerifier string) (TokenResponse, error) {
+	u := *c.baseURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/auth/v1/token"
+	u.RawQuery = "grant_type=pkce"
+
+	resp, body, err := c.PostJSON(ctx, u.String(), map[string]any{
+		"auth_code":     authCode,
+		"code_verifier": codeVerifier,
+	}, nil)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return TokenResponse{}, fmt.Errorf("supabase token exchange failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var out TokenResponse
+	if err := json.Unmarshal(body, &out); err != nil {
+		return TokenResponse{}, err
+	}
+	if strings.TrimSpace(out.AccessToken) == "" {
+		return TokenResponse{}, fmt.Errorf("supabase token exchange returned no access_token")
+	}
+	return out, nil
+}
@@ -6,16 +6,29 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/mgeovany/sentra/server/internal/metrics"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/mgeovany/sentra/server/internal/supabase")
+
 type Client struct {
 	baseURL    *url.URL
 	httpClient *http.Client
 	apiKey     string
+
+	// streamClient has no overall timeout, so large Storage transfers are
+	// bounded by the request context instead.
+	streamClient *http.Client
 }
 
 func (c *Client) APIKey() string {
@@ -23,7 +36,48 @@ func (c *Client) APIKey() string {
 }
 
 func (c *Client) Do(req *http.Request) (*http.Response, error) {
-	return c.httpClient.Do(req)
+	return c.send(c.httpClient, req)
+}
+
+// DoStream sends req with the service role credentials and without the
+// default timeout. Used for Storage uploads and downloads.
+func (c *Client) DoStream(req *http.Request) (*http.Response, error) {
+	req.Header.Set("apikey", c.apiKey)
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	return c.send(c.streamClient, req)
+}
+
+// send logs every upstream call at debug level, tagged with the request ID
+// of the API request that caused it, and records its latency and a client
+// span. For streamed responses the span ends once the headers arrive.
+func (c *Client) send(hc *http.Client, req *http.Request) (*http.Response, error) {
+	ctx, span := tracer.Start(req.Context(), "supabase "+metrics.UpstreamOp(req.URL.Path),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("server.address", req.URL.Host),
+		),
+	)
+	defer span.End()
+	req = req.WithContext(ctx)
+
+	start := time.Now()
+	resp, err := hc.Do(req)
+	elapsed := time.Since(start)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "request failed")
+		metrics.ObserveUpstream(req.URL.Path, 0, elapsed)
+		slog.DebugContext(req.Context(), "supabase request failed", "method", req.Method, "path", req.URL.Path, "latency_ms", elapsed.Milliseconds(), "err", err)
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 500 {
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+	metrics.ObserveUpstream(req.URL.Path, resp.StatusCode, elapsed)
+	slog.DebugContext(req.Context(), "supabase request", "method", req.Method, "path", req.URL.Path, "status", resp.StatusCode, "latency_ms", elapsed.Milliseconds())
+	return resp, nil
 }
 
 func New(baseURL, apiKey string) (*Client, error) {
@@ -46,7 +100,8 @@ func New(baseURL, apiKey string) (*Client, error) {
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
 		},
-		apiKey: apiKey,
+		apiKey:       apiKey,
+		streamClient: &http.Client{},
 	}, nil
 }
 
@@ -57,6 +112,13 @@ func (c *Client) PostgRESTURL(path string) string {
 	return u.String()
 }
 
+func (c *Client) StorageURL(path string) string {
+	// e.g. https://xxxx.supabase.co/storage/v1/object/<bucket>/<path>
+	u := *c.baseURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/storage/v1/" + strings.TrimPrefix(path, "/")
+	return u.String()
+}
+
 func (c *Client) RPCURL(fn string) string {
 	// e.g. https://xxxx.supabase.co/rest/v1/rpc/<fn>
 	u := *c.baseURL
@@ -64,6 +126,25 @@ func (c *Client) RPCURL(fn string) string {
 	return u.String()
 }
 
+// Ping checks that PostgREST answers with the service role key.
+func (c *Client) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, c.PostgRESTURL(""), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("apikey", c.apiKey)
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	resp, err := c.send(c.httpClient, req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("supabase rest ping failed: status=%d", resp.StatusCode)
+	}
+	return nil
+}
+
 func (c *Client) PostJSON(ctx context.Context, url string, body any, headers map[string]string) (*http.Response, []byte, error) {
 	b, err := json.Marshal(body)
 	if err != nil {
@@ -84,7 +165,7 @@ func (c *Client) PostJSON(ctx context.Context, url string, body any, headers map
 		req.Header.Set(k, v)
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.send(c.httpClient, req)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -0,0 +1,360 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mgeovany/sentra/server/internal/metrics"
+	"github.com/mgeovany/sentra/server/internal/repo"
+)
+
+// backoff is the wait before each retry of a failed delivery.
+var backoff = []time.Duration{10 * time.Second, time.Minute, 5 * time.Minute, 30 * time.Minute}
+
+// Deliveries are limited per owner (the user the webhooks belong to), so one
+// user's slow or failing receivers cannot hold up anyone else's.
+const (
+	// maxInFlightPerOwner bounds the attempts of one owner running at once.
+	maxInFlightPerOwner = 4
+	// maxHeldPerOwner bounds the deliveries of one owner that are running,
+	// queued or waiting for a retry; new events beyond it are dropped.
+	maxHeldPerOwner = 200
+)
+
+// Delivery is the outcome of one delivery attempt.
+type Delivery struct {
+	// StatusCode is the receiver's response status; zero when no response
+	// arrived.
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// OK reports whether the receiver answered 2xx.
+func (d Delivery) OK() bool {
+	return d.StatusCode >= 200 && d.StatusCode < 300
+}
+
+func (d Delivery) retryable() bool {
+	return d.StatusCode == 0 || d.StatusCode == http.StatusTooManyRequests || d.StatusCode >= 500
+}
+
+// Dispatcher delivers events in the background. A nil *Dispatcher drops
+// every event.
+//
+// Queued deliveries and retries live in memory only: they are lost when the
+// server stops or restarts.
+type Dispatcher struct {
+	store        repo.WebhookStore
+	client       *http.Client
+	allowPrivate bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	closed bool
+	owners map[string]*owner
+	// waiting holds the retries whose backoff has not passed yet.
+	waiting map[*job]*time.Timer
+}
+
+// owner is the delivery state of one user.
+type owner struct {
+	running int
+	ready   []job
+	// held counts running, ready and waiting deliveries.
+	held int
+}
+
+// job is one delivery of an event to a webhook.
+type job struct {
+	userID  string
+	target  repo.WebhookTarget
+	ev      Event
+	attempt int
+}
+
+// NewDispatcher delivers to the webhooks in store. Unless allowPrivate is
+// set, webhook URLs must be https and may only resolve to public addresses,
+// so a webhook cannot reach the server's own network.
+func NewDispatcher(store repo.WebhookStore, allowPrivate bool) *Dispatcher {
+	if store == nil {
+		store = repo.DisabledWebhookStore{}
+	}
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if !allowPrivate {
+		dialer.Control = publicOnly
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		store: store,
+		client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: 5 * time.Second},
+			// A redirect would be followed without the signature's
+			// guarantees; receivers must answer at the subscribed URL.
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		allowPrivate: allowPrivate,
+		ctx:          ctx,
+		cancel:       cancel,
+		owners:       make(map[string]*owner),
+		waiting:      make(map[*job]*time.Timer),
+	}
+}
+
+// CheckURL reports whether raw can be subscribed.
+func (d *Dispatcher) CheckURL(raw string) error {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" || u.User != nil || len(raw) > 2000 {
+		return errors.New("invalid webhook url")
+	}
+	switch {
+	case u.Scheme == "https":
+	case u.Scheme == "http" && d != nil && d.allowPrivate:
+	default:
+		return errors.New("webhook url must use https")
+	}
+	return nil
+}
+
+// Notify queues ev for every webhook of userID that covers ev's project and
+// returns at once. Failed deliveries are retried with backoff until Close.
+func (d *Dispatcher) Notify(userID string, ev Event) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+	d.wg.Add(1)
+	d.mu.Unlock()
+
+	go func() {
+		defer d.wg.Done()
+
+		ctx, cancel := context.WithTimeout(d.ctx, 10*time.Second)
+		targets, err := d.store.Targets(ctx, userID)
+		cancel()
+		if err != nil {
+			if !errors.Is(err, repo.ErrDBNotConfigured) {
+				slog.Error("webhook targets lookup failed", "user_id", userID, "event_id", ev.ID, "err", err)
+			}
+			return
+		}
+		for _, t := range targets {
+			if root := strings.TrimSpace(t.ProjectRoot); root != "" && root != ev.Project.Root {
+				continue
+			}
+			d.enqueue(job{userID: userID, target: t, ev: ev})
+		}
+	}()
+}
+
+// enqueue adds a first attempt to its owner's queue, unless the owner
+// already holds maxHeldPerOwner deliveries.
+func (d *Dispatcher) enqueue(j job) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	o := d.owners[j.userID]
+	if o == nil {
+		o = &owner{}
+		d.owners[j.userID] = o
+	}
+	if o.held >= maxHeldPerOwner {
+		metrics.WebhookDelivered("dropped")
+		slog.Warn("webhook event dropped: too many pending deliveries", "user_id", j.userID, "webhook_id", j.target.ID, "event_id", j.ev.ID)
+		return
+	}
+	o.held++
+	o.ready = append(o.ready, j)
+	d.startLocked(o)
+}
+
+// startLocked runs queued attempts of o up to maxInFlightPerOwner. d.mu must
+// be held.
+func (d *Dispatcher) startLocked(o *owner) {
+	for o.running < maxInFlightPerOwner && len(o.ready) > 0 {
+		j := o.ready[0]
+		o.ready[0] = job{}
+		o.ready = o.ready[1:]
+		o.running++
+		d.wg.Add(1)
+		go d.run(j)
+	}
+}
+
+// run makes one attempt. A retry does not keep its slot while it waits: a
+// timer puts it back in the queue when its backoff has passed.
+func (d *Dispatcher) run(j job) {
+	defer d.wg.Done()
+
+	// An attempt already started finishes (within the client timeout) even
+	// when Close is called; only the waits between them are cut.
+	res := d.Deliver(context.Background(), j.target, j.ev)
+	retry := !res.OK() && res.retryable() && j.attempt < len(backoff)
+
+	d.mu.Lock()
+	o := d.owners[j.userID]
+	o.running--
+	switch {
+	case retry && !d.closed:
+		next := j
+		next.attempt++
+		d.waiting[&next] = time.AfterFunc(backoff[j.attempt], func() { d.requeue(&next) })
+	default:
+		d.releaseLocked(j.userID, o)
+	}
+	d.startLocked(o)
+	closed := d.closed
+	d.mu.Unlock()
+
+	switch {
+	case res.OK():
+		metrics.WebhookDelivered("ok")
+		slog.Info("webhook delivered", "webhook_id", j.target.ID, "event_id", j.ev.ID, "attempt", j.attempt+1, "status", res.StatusCode)
+	case retry && closed:
+		slog.Warn("webhook retry abandoned at shutdown", "webhook_id", j.target.ID, "event_id", j.ev.ID)
+	case retry:
+		metrics.WebhookDelivered("retry")
+	default:
+		metrics.WebhookDelivered("failed")
+		slog.Warn("webhook delivery failed", "webhook_id", j.target.ID, "event_id", j.ev.ID, "attempt", j.attempt+1, "status", res.StatusCode, "err", res.Error)
+	}
+}
+
+// requeue puts a retry whose backoff has passed back in its owner's queue.
+func (d *Dispatcher) requeue(j *job) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.waiting[j]; !ok {
+		// Close dropped the retry.
+		return
+	}
+	delete(d.waiting, j)
+	o := d.owners[j.userID]
+	o.ready = append(o.ready, *j)
+	d.startLocked(o)
+}
+
+// releaseLocked forgets one delivery of userID, and the owner once it holds
+// none. d.mu must be held.
+func (d *Dispatcher) releaseLocked(userID string, o *owner) {
+	o.held--
+	if o.held == 0 {
+		delete(d.owners, userID)
+	}
+}
+
+// Deliver sends ev to t once.
+func (d *Dispatcher) Deliver(ctx context.Context, t repo.WebhookTarget, ev Event) Delivery {
+	start := time.Now()
+	res := d.deliver(ctx, t, ev)
+	res.DurationMS = time.Since(start).Milliseconds()
+	return res
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, t repo.WebhookTarget, ev Event) Delivery {
+	if err := d.CheckURL(t.URL); err != nil {
+		return Delivery{Error: err.Error()}
+	}
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return Delivery{Error: err.Error()}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSpace(t.URL), bytes.NewReader(body))
+	if err != nil {
+		return Delivery{Error: err.Error()}
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "sentra-webhooks")
+	req.Header.Set(HeaderEvent, ev.Type)
+	req.Header.Set(HeaderDelivery, ev.ID)
+	req.Header.Set(HeaderTimestamp, ts)
+	req.Header.Set(HeaderSignature, Sign(t.Secret, ts, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return Delivery{Error: err.Error()}
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return Delivery{StatusCode: resp.StatusCode}
+}
+
+// Close drops queued deliveries and pending retries, and waits for attempts
+// in flight, or for ctx to end.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	d.closed = true
+	dropped := 0
+	for _, timer := range d.waiting {
+		timer.Stop()
+		dropped++
+	}
+	clear(d.waiting)
+	for _, o := range d.owners {
+		dropped += len(o.ready)
+		o.ready = nil
+	}
+	d.mu.Unlock()
+	d.cancel()
+	if dropped > 0 {
+		slog.Warn("webhook deliveries abandoned at shutdown", "count", dropped)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// publicOnly refuses connections to loopback, private, link-local and other
+// non-public addresses, after DNS resolution.
+func publicOnly(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return fmt.Errorf("webhook address %s is not public", ip)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+// Package webhooks notifies the URLs users subscribe with POST /v1/webhooks
+// after each push. Deliveries are signed with the webhook's secret and carry
+// metadata only: never a file's contents or its ciphertext.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mgeovany/sentra/server/internal/repo"
+)
+
+// Event types.
+const (
+	EventPush = "push"
+	// EventPing is sent by POST /v1/webhooks/test.
+	EventPing = "ping"
+)
+
+// Delivery headers. The signature is "sha256=" and the hex HMAC-SHA256,
+// keyed with the webhook's secret, of the timestamp header, a dot and the
+// body.
+const (
+	HeaderEvent     = "X-Sentra-Event"
+	HeaderDelivery  = "X-Sentra-Delivery"
+	HeaderTimestamp = "X-Sentra-Timestamp"
+	HeaderSignature = "X-Sentra-Signature"
+)
+
+// SecretPrefix marks webhook signing secrets.
+const SecretPrefix = "whsec_"
+
+// Event is the JSON body of a delivery.
+type Event struct {
+	// ID is the same on every retry of one event.
+	ID        string       `json:"id"`
+	Type      string       `json:"type"`
+	CreatedAt time.Time    `json:"created_at"`
+	Project   EventProject `json:"project"`
+	Commit    EventCommit  `json:"commit"`
+	Machine   EventMachine `json:"machine"`
+	// Paths are every file pushed with the commit, i.e. every file staged
+	// for it, whether or not its contents changed.
+	Paths []string `json:"paths"`
+}
+
+type EventProject struct {
+	ID   string `json:"id,omitempty"`
+	Root string `json:"root,omitempty"`
+}
+
+type EventCommit struct {
+	ID      string `json:"id,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type EventMachine struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// PushEvent describes a stored push from its validated body. Only paths and
+// names are copied; blobs, hashes and storage keys stay behind.
+func PushEvent(res repo.PushResult, body []byte) Event {
+	var p struct {
+		Project struct {
+			Root string `json:"root"`
+		} `json:"project"`
+		Machine struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"machine"`
+		Commit struct {
+			Message string `json:"message"`
+		} `json:"commit"`
+		Files []struct {
+			Path string `json:"path"`
+		} `json:"files"`
+	}
+	_ = json.Unmarshal(body, &p)
+
+	ev := newEvent(EventPush)
+	ev.Project = EventProject{ID: strings.TrimSpace(res.ProjectID), Root: strings.TrimSpace(p.Project.Root)}
+	ev.Commit = EventCommit{ID: strings.TrimSpace(res.CommitID), Message: strings.TrimSpace(p.Commit.Message)}
+	ev.Machine = EventMachine{ID: strings.TrimSpace(p.Machine.ID), Name: strings.TrimSpace(p.Machine.Name)}
+	for _, f := range p.Files {
+		if path := strings.TrimSpace(f.Path); path != "" {
+			ev.Paths = append(ev.Paths, path)
+		}
+	}
+	return ev
+}
+
+// PingEvent is a test event for one webhook's project (empty for every
+// project).
+func PingEvent(root string) Event {
+	ev := newEvent(EventPing)
+	ev.Project.Root = strings.TrimSpace(root)
+	return ev
+}
+
+func newEvent(typ string) Event {
+	return Event{ID: uuid.NewString(), Type: typ, CreatedAt: time.Now().UTC(), Paths: []string{}}
+}
+
+// Sign returns the HeaderSignature value for body sent at timestamp.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewSecret returns a fresh signing secret.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return SecretPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Request is the body of POST /v1/webhooks.
+type Request struct {
+	URL string `json:"url"`
+	// ProjectRoot limits the webhook to pushes of one project; empty covers
+	// every project.
+	ProjectRoot string `json:"project_root,omitempty"`
+}
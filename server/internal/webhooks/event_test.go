@@ -0,0 +1,86 @@
+package webhooks
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mgeovany/sentra/server/internal/repo"
+)
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      string
+		want      string
+	}{
+		{
+			name:      "event",
+			secret:    "whsec_test",
+			timestamp: "1700000000",
+			body:      `{"id":"evt_1"}`,
+			want:      "sha256=c89214b5b5da833daed6f0b8c5bb6bd58cea9022bd80ccc78230f3942d632925",
+		},
+		{
+			name:      "empty secret and body",
+			timestamp: "0",
+			want:      "sha256=b849d5a581847b281957065739df36df2463d1977ea8d6e1e4e6cf33fadc68c3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sign(tt.secret, tt.timestamp, []byte(tt.body)); got != tt.want {
+				t.Fatalf("Sign = %s; want %s", got, tt.want)
+			}
+		})
+	}
+
+	// The timestamp is covered: replaying the body under another timestamp
+	// needs a new signature.
+	if Sign("whsec_test", "1700000001", []byte(`{"id":"evt_1"}`)) == tests[0].want {
+		t.Fatal("signature does not depend on the timestamp")
+	}
+}
+
+func TestPushEvent(t *testing.T) {
+	body := `{
+		"v": 2,
+		"project": {"root": " app "},
+		"machine": {"id": "m-1", "name": "laptop"},
+		"commit": {"client_id": "c-1", "message": "rotate keys"},
+		"files": [
+			{"path": "api/.env", "sha256": "aa", "storage": {"provider": "s3", "bucket": "b", "key": "secret-key"}},
+			{"path": ".env.local", "sha256": "bb", "blob_b64": "Y2lwaGVydGV4dA=="}
+		]
+	}`
+	ev := PushEvent(repo.PushResult{ProjectID: "p-1", CommitID: "commit-1"}, []byte(body))
+
+	if ev.Type != EventPush || ev.ID == "" || ev.CreatedAt.IsZero() {
+		t.Fatalf("event header = %+v", ev)
+	}
+	if want := (EventProject{ID: "p-1", Root: "app"}); ev.Project != want {
+		t.Fatalf("Project = %+v; want %+v", ev.Project, want)
+	}
+	if want := (EventCommit{ID: "commit-1", Message: "rotate keys"}); ev.Commit != want {
+		t.Fatalf("Commit = %+v; want %+v", ev.Commit, want)
+	}
+	if want := (EventMachine{ID: "m-1", Name: "laptop"}); ev.Machine != want {
+		t.Fatalf("Machine = %+v; want %+v", ev.Machine, want)
+	}
+	if want := []string{"api/.env", ".env.local"}; !reflect.DeepEqual(ev.Paths, want) {
+		t.Fatalf("Paths = %v; want %v", ev.Paths, want)
+	}
+	for _, leaked := range []string{"secret-key", "Y2lwaGVydGV4dA", `"aa"`} {
+		if b := mustJSON(t, ev); strings.Contains(b, leaked) {
+			t.Fatalf("event carries %s: %s", leaked, b)
+		}
+	}
+}
+
+func TestPingEventHasEmptyPaths(t *testing.T) {
+	if b := mustJSON(t, PingEvent("")); !strings.Contains(b, `"paths":[]`) {
+		t.Fatalf("ping event = %s; want an empty paths array", b)
+	}
+}
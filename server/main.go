@@ -2,7 +2,7 @@ package main
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
@@ -12,66 +12,168 @@ import (
 
 	"github.com/mgeovany/sentra/server/internal/auth"
 	"github.com/mgeovany/sentra/server/internal/config"
+	"github.com/mgeovany/sentra/server/internal/health"
 	"github.com/mgeovany/sentra/server/internal/httpapi"
+	"github.com/mgeovany/sentra/server/internal/limits"
+	"github.com/mgeovany/sentra/server/internal/logging"
+	"github.com/mgeovany/sentra/server/internal/ratelimit"
 	"github.com/mgeovany/sentra/server/internal/repo"
 	"github.com/mgeovany/sentra/server/internal/supabase"
+	"github.com/mgeovany/sentra/server/internal/telemetry"
+	"github.com/mgeovany/sentra/server/internal/webhooks"
 )
 
+// version is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
 func main() {
 	cfg := config.FromEnv()
 
+	// Everything, including the standard log package, goes through the
+	// redacting JSON handler.
+	logger := logging.New(os.Stderr, logging.ParseLevel(cfg.LogLevel))
+	slog.SetDefault(logger)
+
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	shutdownTracing, err := telemetry.Setup(ctx, "sentra-server", version)
+	if err != nil {
+		slog.Error("tracing setup failed", "err", err)
+		os.Exit(1)
+	}
+
+	notConfigured := func(context.Context) error { return health.ErrNotConfigured }
+	jwksCheck := health.Check{Name: "jwks", Probe: notConfigured}
+	dbCheck := health.Check{Name: "database", Probe: notConfigured}
+	storageCheck := health.Check{Name: "storage", Probe: notConfigured}
+
 	var verifier auth.Verifier = auth.DisabledVerifier{}
 	if cfg.SupabaseURL != "" {
-		verifier = auth.NewJWKSVerifier(cfg.SupabaseURL)
-		log.Printf("auth jwks configured")
+		jwks := auth.NewJWKSVerifier(cfg.SupabaseURL)
+		verifier = jwks
+		jwksCheck.Probe = jwks.Ready
+		slog.Info("auth jwks configured")
 	}
 
-	middleware := auth.NewMiddleware(verifier)
-
 	var machines repo.MachineStore = repo.DisabledMachineStore{}
 	var vault repo.VaultKeyStore = repo.DisabledVaultKeyStore{}
 	var idem repo.IdempotencyStore = repo.DisabledIdempotencyStore{}
 	var projects repo.ProjectStore = repo.DisabledProjectStore{}
+	var heads repo.ProjectHeadStore = repo.DisabledProjectHeadStore{}
 	var commits repo.CommitStore = repo.DisabledCommitStore{}
 	var files repo.FileStore = repo.DisabledFileStore{}
 	var export repo.ExportStore = repo.DisabledExportStore{}
 	var push repo.PushStore = repo.DisabledPushStore{}
+	var tokens repo.ServiceTokenStore = repo.DisabledServiceTokenStore{}
+	var webhookStore repo.WebhookStore = repo.DisabledWebhookStore{}
+	var refs repo.StorageRefStore = repo.DisabledStorageRefStore{}
+	var relocate repo.FileLocationStore = repo.DisabledFileLocationStore{}
+	var blobs repo.BlobStore = repo.DisabledBlobStore{}
+	var usage repo.UsageStore = repo.DisabledUsageStore{}
+	var blobLister repo.BlobLister
+	var deviceAuth repo.DeviceAuthStore = repo.DisabledDeviceAuthStore{}
+	var oauth httpapi.OAuthProvider
+	var db *supabase.Client
 	if cfg.SupabaseURL != "" && cfg.SupabaseServiceRoleKey != "" {
 		client, err := supabase.New(cfg.SupabaseURL, cfg.SupabaseServiceRoleKey)
 		if err != nil {
-			log.Printf("supabase db disabled (check SUPABASE_URL / SUPABASE_SERVICE_ROLE_KEY)")
+			slog.Warn("supabase db disabled (check SUPABASE_URL / SUPABASE_SERVICE_ROLE_KEY)", "err", err)
 		} else {
 			machines = repo.NewSupabaseMachineStore(client, "")
 			vault = repo.NewSupabaseVaultKeyStore(client, "")
 			idem = repo.NewSupabaseIdempotencyStore(client, "")
-			projects = repo.NewSupabaseProjectStore(client, "")
+			projects = repo.NewSupabaseProjectStore(client, "", "", "")
+			heads = repo.NewSupabaseProjectHeadStore(client, "")
 			commits = repo.NewSupabaseCommitStore(client, "")
 			files = repo.NewSupabaseFileStore(client, "")
 			export = repo.NewSupabaseExportStore(client, "")
 			push = repo.NewSupabasePushStore(client, "")
-			log.Printf("supabase db configured")
+			usage = repo.NewSupabaseUsageStore(client, "", "")
+			tokens = repo.NewSupabaseServiceTokenStore(client, "")
+			webhookStore = repo.NewSupabaseWebhookStore(client, "")
+			refs = repo.NewSupabaseStorageRefStore(client, "")
+			relocate = repo.NewSupabaseFileLocationStore(client, "")
+			blobStore := repo.NewSupabaseBlobStore(client, "")
+			blobs = blobStore
+			blobLister = blobStore
+			dbCheck.Probe = client.Ping
+			storageCheck.Probe = blobStore.Ping
+			deviceAuth = repo.NewSupabaseDeviceAuthStore(client, "")
+			oauth = client
+			db = client
+			slog.Info("supabase db configured")
+		}
+	}
+
+	limitsCfg, err := limits.Load(cfg.LimitsFile)
+	if err != nil {
+		slog.Error("failed to load SENTRA_LIMITS_FILE", "err", err)
+		os.Exit(1)
+	}
+	push = repo.NewQuotaPushStore(push, usage, limitsCfg.Quota)
+	blobs = repo.NewQuotaBlobStore(blobs, usage, limitsCfg.Quota)
+
+	ready := []health.Check{dbCheck, storageCheck, jwksCheck}
+	var limiter ratelimit.Limiter = ratelimit.NewMemoryLimiter()
+	var nonces ratelimit.NonceStore = ratelimit.NewMemoryNonceStore()
+	switch cfg.LimitStore {
+	case "", "memory":
+	case "postgres":
+		if db == nil {
+			slog.Error("SENTRA_LIMIT_STORE=postgres requires SUPABASE_URL and SUPABASE_SERVICE_ROLE_KEY")
+			os.Exit(1)
+		}
+		limiter = ratelimit.NewSupabaseLimiter(db, "")
+		nonces = ratelimit.NewSupabaseNonceStore(db, "")
+		slog.Info("rate limits and nonces stored in postgres")
+	case "redis":
+		rc, err := ratelimit.NewRedisClient(cfg.RedisURL)
+		if err != nil {
+			slog.Error("SENTRA_LIMIT_STORE=redis requires a valid SENTRA_REDIS_URL", "err", err)
+			os.Exit(1)
 		}
+		limiter = ratelimit.NewRedisLimiter(rc)
+		nonces = ratelimit.NewRedisNonceStore(rc)
+		ready = append(ready, health.Check{Name: "redis", Probe: rc.Ping})
+		slog.Info("rate limits and nonces stored in redis")
+	default:
+		slog.Error("unknown SENTRA_LIMIT_STORE (use memory, postgres or redis)", "value", cfg.LimitStore)
+		os.Exit(1)
 	}
 
-	h := httpapi.New(httpapi.Deps{Auth: middleware, Machines: machines, Vault: vault, Idem: idem, Projects: projects, Commits: commits, Files: files, Export: export, Push: push})
+	if cfg.WebhookAllowPrivate {
+		slog.Warn("webhooks may reach private addresses (SENTRA_WEBHOOK_ALLOW_PRIVATE)")
+	}
+	hooks := webhooks.NewDispatcher(webhookStore, cfg.WebhookAllowPrivate)
+
+	if blobLister != nil {
+		// Deletes hosted blobs whose push never landed or whose files were
+		// deleted; a day covers the slowest push.
+		go repo.RunBlobSweeper(ctx, blobLister, refs, 6*time.Hour, 24*time.Hour)
+	}
+
+	middleware := auth.NewMiddleware(verifier).WithServiceTokens(httpapi.NewServiceTokenVerifier(tokens))
+
+	h := httpapi.New(httpapi.Deps{Auth: middleware, Machines: machines, Vault: vault, Idem: idem, Projects: projects, Heads: heads, Commits: commits, Files: files, Export: export, Push: push, Tokens: tokens, Refs: refs, Relocate: relocate, Blobs: blobs, Webhooks: webhookStore, Hooks: hooks, Limits: limitsCfg, Limiter: limiter, Nonces: nonces, DeviceAuth: deviceAuth, OAuth: oauth, PublicURL: cfg.PublicURL, Ready: ready, MetricsToken: cfg.MetricsToken})
 
 	ln, err := net.Listen("tcp", net.JoinHostPort(cfg.Host, cfg.Port))
 	if err != nil {
-		log.Fatalf("failed to listen on %s:%s: %v", cfg.Host, cfg.Port, err)
+		slog.Error("failed to listen", "host", cfg.Host, "port", cfg.Port, "err", err)
+		os.Exit(1)
 	}
 
 	srv := &http.Server{
 		Handler:           h,
 		ReadHeaderTimeout: 5 * time.Second,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
 	}
 
 	go func() {
-		log.Printf("listening on %s", ln.Addr())
+		slog.Info("listening", "addr", ln.Addr().String())
 		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("listen: %v", err)
+			slog.Error("serve failed", "err", err)
+			os.Exit(1)
 		}
 	}()
 
@@ -80,4 +182,10 @@ func main() {
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	_ = srv.Shutdown(shutdownCtx)
+	if err := hooks.Close(shutdownCtx); err != nil {
+		slog.Warn("webhook deliveries still running at shutdown", "err", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Warn("tracing shutdown failed", "err", err)
+	}
 }
@@ -0,0 +1,299 @@
+// Package api is the Go client of the Sentra server API. The wire types and
+// route paths in types_gen.go are generated from the server's route table
+// (server/internal/apispec), so client and server cannot drift apart.
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client calls one server as one user.
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// NewClient returns a client for the server at baseURL authenticating with
+// token. A nil hc uses http.DefaultClient.
+func NewClient(baseURL string, token string, hc *http.Client) *Client {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return &Client{
+		baseURL: strings.TrimRight(strings.TrimSpace(baseURL), "/"),
+		token:   strings.TrimSpace(token),
+		http:    hc,
+	}
+}
+
+// Error is a response outside 2xx.
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %s: status=%d msg=%s", e.Method, e.Path, e.StatusCode, e.Message())
+}
+
+// Message is the server's error text on one line, or the status text when
+// the body is empty.
+func (e *Error) Message() string {
+	msg := strings.Join(strings.Fields(string(e.Body)), " ")
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+	return msg
+}
+
+// StatusCode returns the HTTP status of err when it is an *Error, else 0.
+func StatusCode(err error) int {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.StatusCode
+	}
+	return 0
+}
+
+func (c *Client) Projects(ctx context.Context) ([]Project, error) {
+	var out []Project
+	err := c.get(ctx, PathProjects, nil, &out)
+	return out, err
+}
+
+func (c *Client) ProjectHeads(ctx context.Context) ([]ProjectHead, error) {
+	var out []ProjectHead
+	err := c.get(ctx, PathProjectHeads, nil, &out)
+	return out, err
+}
+
+// UpdateProject applies patch to the project with the given ID and returns
+// the project as it is now.
+func (c *Client) UpdateProject(ctx context.Context, id string, patch ProjectPatch) (Project, error) {
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return Project{}, err
+	}
+	h := http.Header{}
+	h.Set("Content-Type", "application/json")
+	var out Project
+	_, err = c.do(ctx, http.MethodPatch, PathProject+url.PathEscape(strings.TrimSpace(id)), nil, body, h, &out)
+	return out, err
+}
+
+// DeleteProject deletes the project with the given ID, with its commits and
+// files.
+func (c *Client) DeleteProject(ctx context.Context, id string) (ProjectDeletion, error) {
+	var out ProjectDeletion
+	_, err := c.do(ctx, http.MethodDelete, PathProject+url.PathEscape(strings.TrimSpace(id)), nil, nil, nil, &out)
+	return out, err
+}
+
+// Commits lists every commit of root, newest first.
+func (c *Client) Commits(ctx context.Context, root string) ([]Commit, error) {
+	out, _, err := c.ListCommits(ctx, CommitQuery{Root: root})
+	return out, err
+}
+
+// CommitQuery filters GET /v1/commits. Zero fields do not filter.
+type CommitQuery struct {
+	// Root limits the commits to one project; empty lists every project.
+	Root string
+	// Limit is the page size (at most 500); zero returns every match.
+	Limit  int
+	Cursor string
+	Since  time.Time
+	Until  time.Time
+	// MachineID keeps commits pushed from that machine.
+	MachineID string
+	// Path keeps commits that touch that file path.
+	Path string
+	// Message keeps commits whose message contains it, ignoring case.
+	Message string
+}
+
+func (q CommitQuery) values() url.Values {
+	v := url.Values{}
+	set := func(k string, s string) {
+		if s = strings.TrimSpace(s); s != "" {
+			v.Set(k, s)
+		}
+	}
+	set("root", q.Root)
+	set("cursor", q.Cursor)
+	set("machine_id", q.MachineID)
+	set("path", q.Path)
+	set("message", q.Message)
+	if q.Limit > 0 {
+		v.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if !q.Since.IsZero() {
+		v.Set("since", q.Since.UTC().Format(time.RFC3339))
+	}
+	if !q.Until.IsZero() {
+		v.Set("until", q.Until.UTC().Format(time.RFC3339))
+	}
+	return v
+}
+
+// ListCommits returns one page of commits, newest first, and the cursor of
+// the next page (empty on the last one).
+func (c *Client) ListCommits(ctx context.Context, q CommitQuery) ([]Commit, string, error) {
+	var out []Commit
+	h, err := c.do(ctx, http.MethodGet, PathCommits, q.values(), nil, nil, &out)
+	if err != nil {
+		return nil, "", err
+	}
+	return out, strings.TrimSpace(h.Get("X-Next-Cursor")), nil
+}
+
+// Files lists the files of root at commit at (latest when empty).
+func (c *Client) Files(ctx context.Context, root string, at string) ([]File, error) {
+	var out []File
+	err := c.get(ctx, PathFiles, rootAt(root, at), &out)
+	return out, err
+}
+
+// Export returns the encrypted files of root at commit at (latest when
+// empty).
+func (c *Client) Export(ctx context.Context, root string, at string) ([]ExportFile, error) {
+	var out []ExportFile
+	err := c.get(ctx, PathExport, rootAt(root, at), &out)
+	return out, err
+}
+
+func (c *Client) Webhooks(ctx context.Context) ([]Webhook, error) {
+	var out []Webhook
+	err := c.get(ctx, PathWebhooks, nil, &out)
+	return out, err
+}
+
+// CreateWebhook subscribes a URL to push events. The returned secret is not
+// shown again.
+func (c *Client) CreateWebhook(ctx context.Context, req WebhookRequest) (WebhookCreated, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return WebhookCreated{}, err
+	}
+	h := http.Header{}
+	h.Set("Content-Type", "application/json")
+	var out WebhookCreated
+	_, err = c.do(ctx, http.MethodPost, PathWebhooks, nil, body, h, &out)
+	return out, err
+}
+
+func (c *Client) DeleteWebhook(ctx context.Context, id string) error {
+	_, err := c.do(ctx, http.MethodDelete, PathWebhooks, url.Values{"id": {strings.TrimSpace(id)}}, nil, nil, nil)
+	return err
+}
+
+// TestWebhook has the server send one ping event to the webhook and returns
+// what the receiver answered.
+func (c *Client) TestWebhook(ctx context.Context, id string) (WebhookDelivery, error) {
+	var out WebhookDelivery
+	_, err := c.do(ctx, http.MethodPost, PathWebhooksTest, url.Values{"id": {strings.TrimSpace(id)}}, nil, nil, &out)
+	return out, err
+}
+
+// Signer returns the device signature headers for a request. It is called
+// once per request, so every attempt gets a fresh nonce.
+type Signer func(method string, path string, body []byte) (http.Header, error)
+
+// Push sends one commit. The body is checked with ValidatePush first, so a
+// malformed payload fails with a *SchemaError instead of a bare 400.
+// Retrying with the same idempotencyKey returns the first result instead of
+// pushing twice.
+func (c *Client) Push(ctx context.Context, req PushRequest, idempotencyKey string, sign Signer) (PushResult, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return PushResult{}, err
+	}
+	if err := ValidatePush(body); err != nil {
+		return PushResult{}, err
+	}
+	h := http.Header{}
+	h.Set("Content-Type", "application/json")
+	if idempotencyKey != "" {
+		h.Set("X-Idempotency-Key", idempotencyKey)
+	}
+	if sign != nil {
+		sig, err := sign(http.MethodPost, PathPush, body)
+		if err != nil {
+			return PushResult{}, err
+		}
+		for k, v := range sig {
+			h[k] = v
+		}
+	}
+	var out PushResult
+	_, err = c.do(ctx, http.MethodPost, PathPush, nil, body, h, &out)
+	return out, err
+}
+
+func rootAt(root string, at string) url.Values {
+	q := url.Values{"root": {strings.TrimSpace(root)}}
+	if at = strings.TrimSpace(at); at != "" {
+		q.Set("at", at)
+	}
+	return q
+}
+
+func (c *Client) get(ctx context.Context, path string, query url.Values, out any) error {
+	_, err := c.do(ctx, http.MethodGet, path, query, nil, nil, out)
+	return err
+}
+
+// do sends one request and decodes a 2xx JSON body into out, returning the
+// response headers.
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, body []byte, h http.Header, out any) (http.Header, error) {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range h {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.Header, &Error{Method: method, Path: path, StatusCode: resp.StatusCode, Header: resp.Header, Body: respBody}
+	}
+	if out == nil || len(bytes.TrimSpace(respBody)) == 0 {
+		return resp.Header, nil
+	}
+	return resp.Header, json.Unmarshal(respBody, out)
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// The push schemas are copies of contracts/push.v<N>.schema.json, written
+// by sentra-apigen.
+//
+//go:embed schemas/push.v*.schema.json
+var schemaFiles embed.FS
+
+// PushVersion is the push schema version PushRequest encodes.
+const PushVersion = 1
+
+var pushSchemas struct {
+	once    sync.Once
+	schemas map[int]*jsonschema.Schema
+	err     error
+}
+
+func compilePushSchemas() (map[int]*jsonschema.Schema, error) {
+	pushSchemas.once.Do(func() {
+		entries, err := schemaFiles.ReadDir("schemas")
+		if err != nil {
+			pushSchemas.err = err
+			return
+		}
+		compiler := jsonschema.NewCompiler()
+		compiler.AssertFormat = true
+		out := map[int]*jsonschema.Schema{}
+		for _, e := range entries {
+			var v int
+			if _, err := fmt.Sscanf(e.Name(), "push.v%d.schema.json", &v); err != nil {
+				continue
+			}
+			raw, err := schemaFiles.ReadFile("schemas/" + e.Name())
+			if err != nil {
+				pushSchemas.err = err
+				return
+			}
+			url := "sentra://" + e.Name()
+			if err := compiler.AddResource(url, bytes.NewReader(raw)); err != nil {
+				pushSchemas.err = err
+				return
+			}
+			s, err := compiler.Compile(url)
+			if err != nil {
+				pushSchemas.err = err
+				return
+			}
+			out[v] = s
+		}
+		pushSchemas.schemas = out
+	})
+	return pushSchemas.schemas, pushSchemas.err
+}
+
+// SchemaError lists why a push payload does not match its schema.
+type SchemaError struct {
+	Version int // 0 when the payload names no version
+
+	Problems []SchemaProblem
+}
+
+// SchemaProblem is one failed check. Path reads like "files[0].path", or
+// "(root)" for the payload itself.
+type SchemaProblem struct {
+	Path    string
+	Message string
+}
+
+func (e *SchemaError) Error() string {
+	parts := make([]string, 0, len(e.Problems))
+	for _, p := range e.Problems {
+		parts = append(parts, p.Path+": "+p.Message)
+	}
+	if e.Version == 0 {
+		return "invalid push payload: " + strings.Join(parts, "; ")
+	}
+	return fmt.Sprintf("push payload does not match schema v%d: %s", e.Version, strings.Join(parts, "; "))
+}
+
+// ValidatePush checks a push body against the schema named by its "v"
+// field, the same way the server does before accepting it.
+func ValidatePush(body []byte) error {
+	schemas, err := compilePushSchemas()
+	if err != nil {
+		return fmt.Errorf("push schema unavailable: %w", err)
+	}
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return err
+	}
+	obj, _ := doc.(map[string]any)
+	n, ok := obj["v"].(float64)
+	if !ok || n != float64(int(n)) {
+		return &SchemaError{Problems: []SchemaProblem{{Path: "v", Message: "missing or not an integer"}}}
+	}
+	version := int(n)
+	schema, ok := schemas[version]
+	if !ok {
+		return fmt.Errorf("unsupported push schema version %d", version)
+	}
+
+	err = schema.Validate(doc)
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		return err
+	}
+	out := &SchemaError{Version: version}
+	seen := map[SchemaProblem]bool{}
+	for _, leaf := range schemaLeaves(verr) {
+		p := SchemaProblem{Path: readablePath(leaf.InstanceLocation), Message: leaf.Message}
+		if !seen[p] {
+			seen[p] = true
+			out.Problems = append(out.Problems, p)
+		}
+	}
+	return out
+}
+
+// schemaLeaves returns the innermost causes, which name the actual field
+// and check that failed rather than the enclosing "doesn't validate".
+func schemaLeaves(e *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(e.Causes) == 0 {
+		return []*jsonschema.ValidationError{e}
+	}
+	var out []*jsonschema.ValidationError
+	for _, c := range e.Causes {
+		out = append(out, schemaLeaves(c)...)
+	}
+	return out
+}
+
+// readablePath turns a JSON pointer such as "/files/0/path" into
+// "files[0].path".
+func readablePath(ptr string) string {
+	if ptr == "" || ptr == "/" {
+		return "(root)"
+	}
+	var b strings.Builder
+	for _, tok := range strings.Split(strings.TrimPrefix(ptr, "/"), "/") {
+		tok = strings.NewReplacer("~1", "/", "~0", "~").Replace(tok)
+		if _, err := strconv.Atoi(tok); err == nil {
+			b.WriteString("[" + tok + "]")
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(tok)
+	}
+	return b.String()
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VerifyWebhook checks a delivery's X-Sentra-Signature against secret, and
+// that its X-Sentra-Timestamp is within tolerance of now (zero skips the
+// time check). Receivers should reject a delivery that fails either.
+func VerifyWebhook(secret string, timestamp string, signature string, body []byte, tolerance time.Duration) error {
+	hexSig, ok := strings.CutPrefix(strings.TrimSpace(signature), "sha256=")
+	if !ok {
+		return errors.New("webhook signature must start with sha256=")
+	}
+	got, err := hex.DecodeString(hexSig)
+	if err != nil {
+		return errors.New("webhook signature is not hex")
+	}
+	timestamp = strings.TrimSpace(timestamp)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return errors.New("webhook signature mismatch")
+	}
+
+	if tolerance > 0 {
+		sec, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return errors.New("invalid webhook timestamp")
+		}
+		if d := time.Since(time.Unix(sec, 0)); d > tolerance || d < -tolerance {
+			return errors.New("webhook timestamp outside tolerance")
+		}
+	}
+	return nil
+}
@@ -1,3 +1,5 @@
 module github.com/mgeovany/sentra/shared
 
 go 1.25.5
+
+require github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
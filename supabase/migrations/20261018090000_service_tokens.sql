@@ -0,0 +1,21 @@
+-- Service tokens: scoped, revocable credentials for CI (sentra tokens create).
+-- Only the SHA-256 of the token is stored; the plaintext is shown once at creation.
+
+create table if not exists public.service_tokens (
+  id uuid primary key default gen_random_uuid(),
+  user_id uuid not null references auth.users(id) on delete cascade,
+  name text not null default '',
+  token_hash text not null,
+  project_root text,
+  read_only boolean not null default false,
+  created_at timestamptz not null default now(),
+  expires_at timestamptz,
+  revoked_at timestamptz,
+  constraint service_tokens_token_hash_key unique (token_hash)
+);
+
+create index if not exists idx_service_tokens_user_id
+  on public.service_tokens (user_id, created_at desc);
+
+-- Accessed only by the server with the service role key.
+alter table public.service_tokens enable row level security;
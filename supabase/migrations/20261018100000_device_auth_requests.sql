@@ -0,0 +1,27 @@
+-- Device authorization requests: `sentra login --device` for machines without a local browser.
+-- The CLI polls with a device code (stored as SHA-256); the user approves the short
+-- user code in any browser. `session` holds the Supabase tokens only until the CLI's
+-- next poll, when the row is deleted.
+
+create table if not exists public.device_auth_requests (
+  id uuid primary key default gen_random_uuid(),
+  device_code_hash text not null,
+  user_code text not null,
+  status text not null default 'pending',
+  oauth_state text,
+  code_verifier text,
+  session jsonb,
+  created_at timestamptz not null default now(),
+  expires_at timestamptz not null,
+  last_polled_at timestamptz,
+  constraint device_auth_requests_device_code_hash_key unique (device_code_hash),
+  constraint device_auth_requests_user_code_key unique (user_code),
+  constraint device_auth_requests_oauth_state_key unique (oauth_state),
+  constraint device_auth_requests_status_check check (status in ('pending', 'approved', 'denied'))
+);
+
+create index if not exists idx_device_auth_requests_expires_at
+  on public.device_auth_requests (expires_at);
+
+-- Accessed only by the server with the service role key.
+alter table public.device_auth_requests enable row level security;
@@ -0,0 +1,7 @@
+-- Hosted blob storage: ciphertexts uploaded through PUT /blobs before the push that
+-- references them (storage_provider = 'sentra'). Objects are <user_id>/<sha256(root)>/<id>.
+-- Private; accessed only by the server with the service role key.
+
+insert into storage.buckets (id, name, public)
+values ('sentra-blobs', 'sentra-blobs', false)
+on conflict (id) do nothing;
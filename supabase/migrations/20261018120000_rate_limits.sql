@@ -0,0 +1,24 @@
+-- Shared rate limit buckets and signed-request nonces for SENTRA_LIMIT_STORE=postgres,
+-- so limits and replay protection hold across server instances. Rows are written by
+-- sentra_rate_limit_take_v1 and sentra_nonce_mark_v1, which also delete expired rows.
+
+create table if not exists public.sentra_rate_limits (
+  key text primary key,
+  tokens double precision not null,
+  updated_at timestamptz not null default now()
+);
+
+create index if not exists idx_sentra_rate_limits_updated_at
+  on public.sentra_rate_limits (updated_at);
+
+create table if not exists public.sentra_nonces (
+  key text primary key,
+  expires_at timestamptz not null
+);
+
+create index if not exists idx_sentra_nonces_expires_at
+  on public.sentra_nonces (expires_at);
+
+-- Accessed only by the server with the service role key.
+alter table public.sentra_rate_limits enable row level security;
+alter table public.sentra_nonces enable row level security;
@@ -0,0 +1,13 @@
+-- Bytes pushed per user per UTC day, for the daily push quota (SENTRA_LIMITS_FILE).
+-- sentra_usage_add_v1 adds to today's row after each push; sentra_usage_v1 reads it
+-- together with the user's stored file sizes.
+
+create table if not exists public.sentra_usage_daily (
+  user_id uuid not null references auth.users(id) on delete cascade,
+  day date not null,
+  pushed_bytes bigint not null default 0,
+  constraint sentra_usage_daily_pkey primary key (user_id, day)
+);
+
+-- Accessed only by the server with the service role key.
+alter table public.sentra_usage_daily enable row level security;
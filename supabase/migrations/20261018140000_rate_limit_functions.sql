@@ -0,0 +1,88 @@
+-- RPCs behind SENTRA_LIMIT_STORE=postgres (server/internal/ratelimit/supabase.go).
+-- Both run in the caller's transaction and sweep expired rows on about 1% of calls,
+-- which keeps the tables small without a scheduled job.
+
+-- Refills the bucket for the time since its last update, then spends one token if a
+-- whole one is left. Returns whether the request is allowed and the tokens left.
+create or replace function public.sentra_rate_limit_take_v1(
+  p_key text,
+  p_rate double precision,
+  p_burst double precision
+)
+returns table (allowed boolean, tokens double precision)
+language plpgsql
+set search_path = public
+as $$
+declare
+  v_now timestamptz := clock_timestamp();
+  v_tokens double precision;
+  v_updated_at timestamptz;
+begin
+  if random() < 0.01 then
+    delete from public.sentra_rate_limits b
+    where b.updated_at < v_now - interval '30 minutes';
+  end if;
+
+  insert into public.sentra_rate_limits (key, tokens, updated_at)
+  values (p_key, p_burst, v_now)
+  on conflict (key) do nothing;
+
+  select b.tokens, b.updated_at
+  into v_tokens, v_updated_at
+  from public.sentra_rate_limits b
+  where b.key = p_key
+  for update;
+
+  if v_now > v_updated_at then
+    v_tokens := least(p_burst, v_tokens + extract(epoch from v_now - v_updated_at) * p_rate);
+    v_updated_at := v_now;
+  end if;
+
+  allowed := v_tokens >= 1;
+  if allowed then
+    v_tokens := v_tokens - 1;
+  end if;
+  tokens := v_tokens;
+
+  update public.sentra_rate_limits b
+  set tokens = v_tokens, updated_at = v_updated_at
+  where b.key = p_key;
+
+  return next;
+end;
+$$;
+
+-- Marks a signed-request nonce for p_ttl_seconds. Returns true when an unexpired mark
+-- already existed, i.e. the request is a replay.
+create or replace function public.sentra_nonce_mark_v1(
+  p_key text,
+  p_ttl_seconds integer
+)
+returns boolean
+language plpgsql
+set search_path = public
+as $$
+declare
+  v_now timestamptz := clock_timestamp();
+begin
+  if random() < 0.01 then
+    delete from public.sentra_nonces n
+    where n.expires_at <= v_now;
+  end if;
+
+  insert into public.sentra_nonces as n (key, expires_at)
+  values (p_key, v_now + make_interval(secs => p_ttl_seconds))
+  on conflict (key) do update
+    set expires_at = excluded.expires_at
+    where n.expires_at <= v_now;
+
+  -- Nothing was inserted or updated only when a live nonce was already there.
+  return not found;
+end;
+$$;
+
+-- Called only by the server with the service role key.
+revoke execute on function public.sentra_rate_limit_take_v1(text, double precision, double precision) from public, anon, authenticated;
+revoke execute on function public.sentra_nonce_mark_v1(text, integer) from public, anon, authenticated;
+grant execute on function public.sentra_rate_limit_take_v1(text, double precision, double precision) to service_role;
+grant execute on function public.sentra_nonce_mark_v1(text, integer) to service_role;
@@ -0,0 +1,44 @@
+-- RPCs behind the push quotas (server/internal/repo/quota.go). Stored bytes are summed
+-- over the core history tables: projects (id, user_id), commits (id, project_id) and
+-- files (commit_id, size), one row per file of each commit.
+
+-- Returns the user's stored file bytes and the bytes pushed today (UTC).
+create or replace function public.sentra_usage_v1(p_user_id uuid)
+returns table (storage_bytes bigint, pushed_bytes_today bigint)
+language sql
+stable
+set search_path = public
+as $$
+  select
+    coalesce((
+      select sum(f.size)
+      from public.files f
+      join public.commits c on c.id = f.commit_id
+      join public.projects p on p.id = c.project_id
+      where p.user_id = p_user_id
+    ), 0)::bigint,
+    coalesce((
+      select u.pushed_bytes
+      from public.sentra_usage_daily u
+      where u.user_id = p_user_id
+        and u.day = (now() at time zone 'utc')::date
+    ), 0)::bigint;
+$$;
+
+-- Adds p_bytes to the user's row for today (UTC).
+create or replace function public.sentra_usage_add_v1(p_user_id uuid, p_bytes bigint)
+returns void
+language sql
+set search_path = public
+as $$
+  insert into public.sentra_usage_daily as u (user_id, day, pushed_bytes)
+  values (p_user_id, (now() at time zone 'utc')::date, p_bytes)
+  on conflict (user_id, day) do update
+    set pushed_bytes = u.pushed_bytes + excluded.pushed_bytes;
+$$;
+
+-- Called only by the server with the service role key.
+revoke execute on function public.sentra_usage_v1(uuid) from public, anon, authenticated;
+revoke execute on function public.sentra_usage_add_v1(uuid, bigint) from public, anon, authenticated;
+grant execute on function public.sentra_usage_v1(uuid) to service_role;
+grant execute on function public.sentra_usage_add_v1(uuid, bigint) to service_role;
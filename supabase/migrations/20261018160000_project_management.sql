@@ -0,0 +1,194 @@
+-- Project rename, archive and delete (PATCH and DELETE /projects/<id>, see
+-- server/internal/repo/projects.go). Written against the core history tables:
+-- projects (id, user_id, root_path), commits (id, project_id, message, created_at)
+-- and files (commit_id, size, storage_*), one row per file of each commit.
+
+alter table public.projects
+  add column if not exists display_name text,
+  add column if not exists archived_at timestamptz;
+
+-- Lists the user's projects with their newest commit. Replaces sentra_projects_v1,
+-- which does not return display_name and archived_at.
+create or replace function public.sentra_projects_v2(p_user_id uuid)
+returns table (
+  id uuid,
+  root_path text,
+  display_name text,
+  archived_at timestamptz,
+  last_commit_id uuid,
+  last_commit_message text,
+  file_count integer
+)
+language sql
+stable
+set search_path = public
+as $$
+  select
+    p.id,
+    p.root_path,
+    p.display_name,
+    p.archived_at,
+    lc.id,
+    coalesce(lc.message, ''),
+    coalesce((select count(*) from public.files f where f.commit_id = lc.id), 0)::integer
+  from public.projects p
+  left join lateral (
+    select c.id, c.message
+    from public.commits c
+    where c.project_id = p.id
+    order by c.created_at desc
+    limit 1
+  ) lc on true
+  where p.user_id = p_user_id
+  order by p.root_path;
+$$;
+
+-- Applies the non-null arguments: p_root renames, p_display_name sets the label (''
+-- clears it) and p_archived archives or unarchives. Returns the project as listed by
+-- sentra_projects_v2, or no row when the user has no such project. Renaming onto a
+-- root the user already has raises unique_violation, which PostgREST answers with 409.
+create or replace function public.sentra_project_update_v1(
+  p_user_id uuid,
+  p_project_id uuid,
+  p_root text default null,
+  p_display_name text default null,
+  p_archived boolean default null
+)
+returns table (
+  id uuid,
+  root_path text,
+  display_name text,
+  archived_at timestamptz,
+  last_commit_id uuid,
+  last_commit_message text,
+  file_count integer
+)
+language plpgsql
+set search_path = public
+as $$
+#variable_conflict use_column
+begin
+  perform 1
+  from public.projects p
+  where p.id = p_project_id and p.user_id = p_user_id
+  for update;
+  if not found then
+    return;
+  end if;
+
+  if p_root is not null and exists (
+    select 1
+    from public.projects p
+    where p.user_id = p_user_id and p.root_path = p_root and p.id <> p_project_id
+  ) then
+    raise exception 'project root already exists: %', p_root using errcode = 'unique_violation';
+  end if;
+
+  update public.projects p
+  set
+    root_path = coalesce(p_root, p.root_path),
+    display_name = case
+      when p_display_name is null then p.display_name
+      else nullif(p_display_name, '')
+    end,
+    archived_at = case
+      when p_archived is null then p.archived_at
+      when p_archived then coalesce(p.archived_at, now())
+      else null
+    end
+  where p.id = p_project_id and p.user_id = p_user_id;
+
+  return query
+  select *
+  from public.sentra_projects_v2(p_user_id) l
+  where l.id = p_project_id;
+end;
+$$;
+
+-- Deletes the project with its commits and files. Returns how many of each were
+-- deleted and, in storage, the distinct {provider, bucket, key, endpoint, region}
+-- locations that no remaining file of the user references, so the caller can delete
+-- those objects. Returns no row when the user has no such project.
+create or replace function public.sentra_project_delete_v1(
+  p_user_id uuid,
+  p_project_id uuid
+)
+returns table (id uuid, root_path text, commits integer, files integer, storage jsonb)
+language plpgsql
+set search_path = public
+as $$
+#variable_conflict use_column
+declare
+  v_root text;
+  v_commits integer;
+  v_files integer;
+  v_storage jsonb;
+begin
+  select p.root_path
+  into v_root
+  from public.projects p
+  where p.id = p_project_id and p.user_id = p_user_id
+  for update;
+  if not found then
+    return;
+  end if;
+
+  select count(*)
+  into v_commits
+  from public.commits c
+  where c.project_id = p_project_id;
+
+  select count(*)
+  into v_files
+  from public.files f
+  join public.commits c on c.id = f.commit_id
+  where c.project_id = p_project_id;
+
+  select coalesce(jsonb_agg(jsonb_strip_nulls(jsonb_build_object(
+    'provider', s.storage_provider,
+    'bucket', s.storage_bucket,
+    'key', s.storage_key,
+    'endpoint', s.storage_endpoint,
+    'region', s.storage_region
+  ))), '[]'::jsonb)
+  into v_storage
+  from (
+    select distinct f.storage_provider, f.storage_bucket, f.storage_key, f.storage_endpoint, f.storage_region
+    from public.files f
+    join public.commits c on c.id = f.commit_id
+    where c.project_id = p_project_id
+      and f.storage_key is not null
+      and not exists (
+        select 1
+        from public.files o
+        join public.commits oc on oc.id = o.commit_id
+        join public.projects op on op.id = oc.project_id
+        where op.user_id = p_user_id
+          and op.id <> p_project_id
+          and o.storage_key = f.storage_key
+          and o.storage_provider is not distinct from f.storage_provider
+          and o.storage_bucket is not distinct from f.storage_bucket
+      )
+  ) s;
+
+  delete from public.files f
+  using public.commits c
+  where c.id = f.commit_id and c.project_id = p_project_id;
+
+  delete from public.commits c
+  where c.project_id = p_project_id;
+
+  delete from public.projects p
+  where p.id = p_project_id;
+
+  return query select p_project_id, v_root, v_commits, v_files, v_storage;
+end;
+$$;
+
+-- Called only by the server with the service role key.
+revoke execute on function public.sentra_projects_v2(uuid) from public, anon, authenticated;
+revoke execute on function public.sentra_project_update_v1(uuid, uuid, text, text, boolean) from public, anon, authenticated;
+revoke execute on function public.sentra_project_delete_v1(uuid, uuid) from public, anon, authenticated;
+grant execute on function public.sentra_projects_v2(uuid) to service_role;
+grant execute on function public.sentra_project_update_v1(uuid, uuid, text, text, boolean) to service_role;
+grant execute on function public.sentra_project_delete_v1(uuid, uuid) to service_role;
@@ -0,0 +1,80 @@
+-- Paged and filtered commit history (GET /commits, see server/internal/repo/commits.go).
+-- Replaces sentra_commits_v1, which returns every commit at once. Written against the
+-- core history tables: projects (id, user_id, root_path), commits (id, project_id,
+-- machine_id, message, created_at), files (commit_id, file_path) and machines
+-- (user_id, machine_id, machine_name).
+
+create index if not exists idx_commits_project_id_created_at
+  on public.commits (project_id, created_at desc, id desc);
+
+-- Returns the user's commits newest first, ordered by (created_at, commit_id), starting
+-- below (p_before_created_at, p_before_commit_id) when set. Null arguments do not
+-- filter; p_since and p_until are inclusive, p_path matches a file path exactly and
+-- p_message is a case-insensitive substring.
+create or replace function public.sentra_commits_v2(
+  p_user_id uuid,
+  p_root text default null,
+  p_limit integer default null,
+  p_before_created_at timestamptz default null,
+  p_before_commit_id uuid default null,
+  p_since timestamptz default null,
+  p_until timestamptz default null,
+  p_machine_id text default null,
+  p_path text default null,
+  p_message text default null
+)
+returns table (
+  commit_id uuid,
+  created_at timestamptz,
+  message text,
+  machine_id text,
+  machine_name text,
+  files text[],
+  project_id uuid,
+  project_root text,
+  project_name text,
+  file_count integer
+)
+language sql
+stable
+set search_path = public
+as $$
+  select
+    c.id,
+    c.created_at,
+    c.message,
+    c.machine_id::text,
+    coalesce(m.machine_name, ''),
+    coalesce(fl.paths, '{}'::text[]),
+    p.id,
+    p.root_path,
+    coalesce(p.display_name, ''),
+    coalesce(fl.n, 0)
+  from public.commits c
+  join public.projects p on p.id = c.project_id
+  left join public.machines m
+    on m.user_id = p.user_id and m.machine_id::text = c.machine_id::text
+  left join lateral (
+    select array_agg(f.file_path order by f.file_path) as paths, count(*)::integer as n
+    from public.files f
+    where f.commit_id = c.id
+  ) fl on true
+  where p.user_id = p_user_id
+    and (p_root is null or p.root_path = p_root)
+    and (p_since is null or c.created_at >= p_since)
+    and (p_until is null or c.created_at <= p_until)
+    and (p_machine_id is null or c.machine_id::text = p_machine_id)
+    and (p_path is null or exists (
+      select 1 from public.files f where f.commit_id = c.id and f.file_path = p_path
+    ))
+    and (p_message is null or c.message ilike
+      '%' || replace(replace(replace(p_message, '\', '\\'), '%', '\%'), '_', '\_') || '%')
+    and (p_before_created_at is null or p_before_commit_id is null
+      or (c.created_at, c.id) < (p_before_created_at, p_before_commit_id))
+  order by c.created_at desc, c.id desc
+  limit p_limit;
+$$;
+
+-- Called only by the server with the service role key.
+revoke execute on function public.sentra_commits_v2(uuid, text, integer, timestamptz, uuid, timestamptz, timestamptz, text, text, text) from public, anon, authenticated;
+grant execute on function public.sentra_commits_v2(uuid, text, integer, timestamptz, uuid, timestamptz, timestamptz, text, text, text) to service_role;
@@ -0,0 +1,19 @@
+-- Webhooks: URLs notified after every push of a user (sentra webhooks add).
+-- project_root limits one to a single project; null means every project. The signing
+-- secret is stored as is, since the server signs each delivery with it, and is only
+-- returned to the user at creation.
+
+create table if not exists public.webhooks (
+  id uuid primary key default gen_random_uuid(),
+  user_id uuid not null references auth.users(id) on delete cascade,
+  url text not null,
+  project_root text,
+  secret text not null,
+  created_at timestamptz not null default now()
+);
+
+create index if not exists idx_webhooks_user_id
+  on public.webhooks (user_id, created_at desc);
+
+-- Accessed only by the server with the service role key.
+alter table public.webhooks enable row level security;
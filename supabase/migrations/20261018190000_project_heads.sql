@@ -0,0 +1,42 @@
+-- Newest commit of each project (GET /projects/heads, see
+-- server/internal/repo/project_heads.go), which `sentra status --remote` compares with
+-- the commit a checkout last synced. Written against the core history tables:
+-- projects (id, user_id, root_path), commits (id, project_id, machine_id, created_at)
+-- and machines (user_id, machine_id, machine_name).
+
+-- Returns one row per project of the user that has a commit.
+create or replace function public.sentra_project_heads_v1(p_user_id uuid)
+returns table (
+  root_path text,
+  commit_id uuid,
+  created_at timestamptz,
+  machine_id text,
+  machine_name text
+)
+language sql
+stable
+set search_path = public
+as $$
+  select
+    p.root_path,
+    h.id,
+    h.created_at,
+    h.machine_id::text,
+    coalesce(m.machine_name, '')
+  from public.projects p
+  join lateral (
+    select c.id, c.created_at, c.machine_id
+    from public.commits c
+    where c.project_id = p.id
+    order by c.created_at desc, c.id desc
+    limit 1
+  ) h on true
+  left join public.machines m
+    on m.user_id = p.user_id and m.machine_id::text = h.machine_id::text
+  where p.user_id = p_user_id
+  order by p.root_path;
+$$;
+
+-- Called only by the server with the service role key.
+revoke execute on function public.sentra_project_heads_v1(uuid) from public, anon, authenticated;
+grant execute on function public.sentra_project_heads_v1(uuid) to service_role;
@@ -0,0 +1,61 @@
+-- Points stored file rows at a copy of their ciphertext (POST /storage/migrate, see
+-- server/internal/repo/file_locations.go), after `sentra storage migrate` copied it.
+-- Written against the core history tables: projects (id, user_id, root_path),
+-- commits (id, project_id) and files (commit_id, file_path, sha256, blob_b64,
+-- storage_*).
+
+-- p_files is an array of {commit_id, file_path, sha256, blob_b64 | storage_*}. Each
+-- entry sets either blob_b64 or the storage_* columns, clearing the other, on the row
+-- of the user's project p_root with that commit_id and file_path. A row whose sha256
+-- differs is left untouched, so a stale copy never replaces newer content; the CLI
+-- reports those as skipped. Returns how many rows were updated.
+create or replace function public.sentra_relocate_files_v1(
+  p_user_id uuid,
+  p_root text,
+  p_files jsonb
+)
+returns integer
+language plpgsql
+set search_path = public
+as $$
+declare
+  v_updated integer;
+begin
+  update public.files f
+  set
+    blob_b64 = nullif(l.blob_b64, ''),
+    storage_provider = case when nullif(l.blob_b64, '') is null then nullif(l.storage_provider, '') end,
+    storage_bucket = case when nullif(l.blob_b64, '') is null then nullif(l.storage_bucket, '') end,
+    storage_key = case when nullif(l.blob_b64, '') is null then nullif(l.storage_key, '') end,
+    storage_endpoint = case when nullif(l.blob_b64, '') is null then nullif(l.storage_endpoint, '') end,
+    storage_region = case when nullif(l.blob_b64, '') is null then nullif(l.storage_region, '') end
+  from jsonb_to_recordset(p_files) as l(
+    commit_id uuid,
+    file_path text,
+    sha256 text,
+    blob_b64 text,
+    storage_provider text,
+    storage_bucket text,
+    storage_key text,
+    storage_endpoint text,
+    storage_region text
+  ),
+  public.commits c,
+  public.projects p
+  where f.commit_id = l.commit_id
+    and f.file_path = l.file_path
+    and f.sha256 = l.sha256
+    and c.id = f.commit_id
+    and p.id = c.project_id
+    and p.user_id = p_user_id
+    and p.root_path = p_root
+    and (nullif(l.blob_b64, '') is not null or nullif(l.storage_key, '') is not null);
+
+  get diagnostics v_updated = row_count;
+  return v_updated;
+end;
+$$;
+
+-- Called only by the server with the service role key.
+revoke execute on function public.sentra_relocate_files_v1(uuid, text, jsonb) from public, anon, authenticated;
+grant execute on function public.sentra_relocate_files_v1(uuid, text, jsonb) to service_role;
@@ -0,0 +1,24 @@
+-- Storage keys still referenced by a user's files (GET /storage/refs, see
+-- server/internal/repo/storage_refs.go). `sentra storage gc` deletes the objects of
+-- its bucket that are not listed. Written against the core history tables:
+-- projects (id, user_id), commits (id, project_id) and files (commit_id, storage_key).
+
+-- Returns the distinct non-null storage keys of the user's files, across every
+-- project and commit, archived projects included.
+create or replace function public.sentra_storage_refs_v1(p_user_id uuid)
+returns table (storage_key text)
+language sql
+stable
+set search_path = public
+as $$
+  select distinct f.storage_key
+  from public.files f
+  join public.commits c on c.id = f.commit_id
+  join public.projects p on p.id = c.project_id
+  where p.user_id = p_user_id
+    and f.storage_key is not null;
+$$;
+
+-- Called only by the server with the service role key.
+revoke execute on function public.sentra_storage_refs_v1(uuid) from public, anon, authenticated;
+grant execute on function public.sentra_storage_refs_v1(uuid) to service_role;
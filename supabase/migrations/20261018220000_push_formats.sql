@@ -0,0 +1,47 @@
+-- Lets sentra_push_v1 store the file formats added since the baseline push contract,
+-- which allowed ciphers up to sentra-v1, storage provider s3 (with a bucket) and files
+-- up to 1 MiB. The server now accepts sentra-v2 and sentra-v3, the sentra (hosted), fs,
+-- webdav and sftp providers, hosted blobs without a bucket, and files up to 64 MiB (see
+-- server/internal/apispec/schemas/push.v*.schema.json). Written against the core
+-- history table files (cipher, size, storage_provider, storage_bucket).
+--
+-- sentra_push_v1 itself is unchanged: it copies these fields into files as given, and
+-- the server validates them against the push schemas first. Only the table's own
+-- limits are replaced here.
+
+-- Drop check constraints on files that pin cipher, size or storage_provider to the
+-- baseline values, whatever they were named.
+do $$
+declare
+  v_name text;
+begin
+  for v_name in
+    select conname
+    from pg_constraint
+    where conrelid = 'public.files'::regclass
+      and contype = 'c'
+      and pg_get_constraintdef(oid) ~ '\m(cipher|size|storage_provider)\M'
+  loop
+    execute format('alter table public.files drop constraint %I', v_name);
+  end loop;
+end $$;
+
+-- Hosted blobs ("sentra") have no bucket.
+alter table public.files alter column storage_bucket drop not null;
+
+-- Keep the database limits in line with the push schemas. NOT VALID skips existing
+-- rows, which the old constraints already covered.
+alter table public.files
+  add constraint files_cipher_check
+  check (cipher in ('ed25519+aes-256-gcm-v1', 'age-v1', 'sentra-v1', 'sentra-v2', 'sentra-v3'))
+  not valid;
+
+alter table public.files
+  add constraint files_size_check
+  check (size between 1 and 67108864)
+  not valid;
+
+alter table public.files
+  add constraint files_storage_provider_check
+  check (storage_provider is null or storage_provider in ('sentra', 's3', 'fs', 'webdav', 'sftp'))
+  not valid;